@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/database"
+	"github.com/codeZe-us/vestroll-backend/internal/migrate"
+)
+
+const usage = `Usage: migrate [-dir migrations] <command>
+
+Commands:
+  up             apply all pending migrations
+  down [n]       revert the last n applied migrations (default 1)
+  status         list migrations and whether they are applied
+  create <name>  write empty up/down files for the next version
+
+The database is configured with the DB_* environment variables.
+`
+
+func main() {
+	dir := flag.String("dir", "migrations", "directory containing the migration files")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if args[0] == "create" {
+		if len(args) < 2 {
+			log.Fatal("create requires a migration name")
+		}
+		up, down, err := migrate.Create(*dir, args[1])
+		if err != nil {
+			log.Fatalf("create failed: %v", err)
+		}
+		fmt.Printf("Created %s\nCreated %s\n", up, down)
+		return
+	}
+
+	ctx := context.Background()
+	pool, err := database.NewPostgresPool(ctx, config.Load().Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer pool.Close()
+
+	migrator, err := migrate.New(pool, os.DirFS(*dir))
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("Applied %06d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("up failed: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("invalid step count %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %06d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("down failed: %v", err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("status failed: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state += " (file modified since applied)"
+			}
+			fmt.Printf("%06d_%-40s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
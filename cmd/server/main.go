@@ -1,35 +1,60 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/codeZe-us/vestroll-backend/internal/config"
 	"github.com/codeZe-us/vestroll-backend/internal/database"
 	"github.com/codeZe-us/vestroll-backend/internal/handlers"
+	authhandlers "github.com/codeZe-us/vestroll-backend/internal/handlers/auth"
 	"github.com/codeZe-us/vestroll-backend/internal/middleware"
+	"github.com/codeZe-us/vestroll-backend/internal/migrate"
 	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/postgres"
 	"github.com/codeZe-us/vestroll-backend/internal/services"
-	"github.com/codeZe-us/vestroll-backend/internal/services/email_service"
-	"github.com/codeZe-us/vestroll-backend/internal/services/sms_service"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	"github.com/codeZe-us/vestroll-backend/internal/services/notifier"
+	"github.com/codeZe-us/vestroll-backend/migrations"
 	"github.com/gin-gonic/gin"
 	redis "github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.Load()
+	if err := cfg.CheckSecrets(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// ctx is cancelled on SIGINT/SIGTERM to stop the server and background workers
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var workers sync.WaitGroup
 
 	gin.SetMode(gin.DebugMode)
 
 	r := gin.Default()
+	// Only listed proxies may set the client IP that rate limits key on
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	
 	// Add middleware
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 	r.Use(middleware.CORS())
+	r.Use(middleware.ErrorHandler())
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -53,31 +78,143 @@ func main() {
 		}
 	}
 
+	// Durable data (users, profiles, PINs) lives in Postgres when it is reachable;
+	// otherwise it falls back to Redis, which is lost on restart with embedded Redis
+	pgPool, err := database.NewPostgresPool(context.Background(), cfg.Database)
+	if err != nil {
+		log.Printf("Warning: Postgres connection failed, storing accounts and profiles in Redis: %v", err)
+	} else if err := migrateDatabase(pgPool, cfg.Database.AutoMigrate); err != nil {
+		log.Printf("Warning: Postgres migrations failed, storing accounts and profiles in Redis: %v", err)
+		pgPool.Close()
+		pgPool = nil
+	} else {
+		defer pgPool.Close()
+	}
+
+	// SMS and email providers are chosen per channel; the outbox captures messages locally
+	notify, outbox, err := notifier.New(cfg)
+	if err != nil {
+		log.Fatalf("Invalid notifier configuration: %v", err)
+	}
+	// With failover configured, log how each SMS provider is doing
+	if sms, ok := notify[notifier.ChannelSMS].(*notifier.SMSRouter); ok && cfg.Notifier.SMSStatsInterval > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			sms.LogStats(ctx, cfg.Notifier.SMSStatsInterval)
+		}()
+	}
+	if outbox != nil {
+		log.Printf("Warning: notifications are captured in the outbox and not delivered")
+		if cfg.Notifier.OutboxEndpoint {
+			handlers.NewOutboxHandler(outbox).RegisterRoutes(r.Group("/dev"))
+		}
+	}
+
 	// Initialize services only if Redis is available
 	var otpHandler *handlers.OTPHandler
 var businessProfileHandler *handlers.BusinessProfileHandler
 	var profileHandler *handlers.ProfileHandler
 	var pinHandler *handlers.PINHandler
+	var authHandler *authhandlers.AuthHandler
+	var totpHandler *authhandlers.TOTPHandler
+	var deviceHandler *authhandlers.DeviceHandler
+	var passkeyHandler *authhandlers.PasskeyHandler
+	var passwordResetHandler *authhandlers.PasswordResetHandler
+	var notificationHandler *handlers.NotificationHandler
+	var requireAuth gin.HandlerFunc
+	var requireStepUp gin.HandlerFunc
+	var requireElevated gin.HandlerFunc
 	if redisClient != nil {
 		// Initialize repositories
 		otpRepo := repository.NewOTPRepository(redisClient, cfg.OTP.TTL)
-		businessRepo := repository.NewBusinessProfileRepository(redisClient, 0)
-		profileRepo := repository.NewProfileRepository(redisClient, 0)
-		pinRepo := repository.NewPinRepository(redisClient, 0)
+		var businessRepo repository.BusinessProfileStore = repository.NewBusinessProfileRepository(redisClient, 0)
+		var profileRepo repository.ProfileStore = repository.NewProfileRepository(redisClient, 0)
+		var pinRepo repository.PINStore = repository.NewPinRepository(redisClient, 0)
+		var userRepo repository.UserStore = repository.NewUserRepository(redisClient)
+		var totpRepo repository.TOTPStore = repository.NewTOTPRepository(redisClient)
+		var deviceRepo repository.DeviceStore = repository.NewDeviceRepository(redisClient)
+		var passkeyRepo repository.PasskeyStore = repository.NewPasskeyRepository(redisClient)
+		if pgPool != nil {
+			businessRepo = postgres.NewBusinessProfileRepository(pgPool)
+			profileRepo = postgres.NewProfileRepository(pgPool)
+			pinRepo = postgres.NewPinRepository(pgPool)
+			userRepo = postgres.NewUserRepository(pgPool)
+			totpRepo = postgres.NewTOTPRepository(pgPool)
+			deviceRepo = postgres.NewDeviceRepository(pgPool)
+			passkeyRepo = postgres.NewPasskeyRepository(pgPool)
+		}
+		passwordResetRepo := repository.NewPasswordResetRepository(redisClient, cfg.PasswordReset.TTL)
+		refreshRepo := repository.NewRefreshTokenRepository(redisClient)
 
 		// Initialize services
-		smsService := sms_service.NewSMSService(cfg.Twilio)
-		emailService := email_service.NewEmailService(cfg.SMTP)
-		otpService := services.NewOTPService(otpRepo, smsService, emailService, cfg.OTP)
+		templates, err := notifier.NewTemplates(cfg.Notifier.BrandName)
+		if err != nil {
+			log.Fatalf("Failed to load message templates: %v", err)
+		}
+		// With NOTIFY_ASYNC, requests only enqueue messages and workers deliver them with retries
+		var sender notifier.Notifier = notify
+		if cfg.Notifier.Async {
+			queue := notifier.NewQueue(repository.NewNotificationQueueRepository(redisClient, cfg.Notifier.JobRetention), notify, cfg.Notifier)
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				queue.Run(ctx)
+			}()
+			sender = queue
+			notificationHandler = handlers.NewNotificationHandler(queue)
+		}
+		dispatcher, err := services.NewCodeDispatcher(sender, templates, userRepo, profileRepo)
+		if err != nil {
+			log.Fatalf("Failed to create code dispatcher: %v", err)
+		}
+		// SMS pumping protection: country lists, hourly spend caps and burst detection
+		var challenger services.ChallengeVerifier
+		if cfg.SMSGuard.Challenge == "pow" {
+			challenger = services.NewProofOfWork(cfg.SMSGuard.PoWDifficulty)
+		}
+		smsGuard, err := services.NewSMSGuard(repository.NewSMSUsageRepository(redisClient), challenger, cfg.SMSGuard)
+		if err != nil {
+			log.Fatalf("Invalid SMS guard configuration: %v", err)
+		}
+		otpService := services.NewOTPService(otpRepo, dispatcher, smsGuard, cfg.OTP)
+		passwordResetService := services.NewPasswordResetService(passwordResetRepo, dispatcher, otpService, cfg.PasswordReset)
 		businessService := services.NewBusinessProfileService(businessRepo)
 		profileService := services.NewProfileService(profileRepo)
-		pinService := services.NewPINService(pinRepo)
+		pinService := services.NewPINService(pinRepo, repository.NewPINAttemptRepository(redisClient), userRepo, profileRepo, cfg.PIN)
+		tokenService := authservice.NewTokenService(cfg.JWT).WithUsedTokens(repository.NewUsedTokenRepository(redisClient))
+		sessionService := authservice.NewSessionService(tokenService, refreshRepo, cfg.JWT.RefreshTTL)
+		deviceService := authservice.NewDeviceService(deviceRepo, repository.NewDeviceChallengeRepository(redisClient), userRepo, sessionService, dispatcher, cfg.Device)
+		sessionService.WithDevices(deviceService)
+		passkeyService := authservice.NewPasskeyService(passkeyRepo, repository.NewPasskeyChallengeRepository(redisClient), userRepo, sessionService, cfg.WebAuthn)
+		authService := authservice.NewAuthService(userRepo, sessionService, cfg.JWT.RequireVerifiedContact)
+		totpService, err := authservice.NewTOTPService(totpRepo, otpRepo, userRepo, tokenService, cfg.TOTP)
+		if err != nil {
+			log.Fatalf("Invalid TOTP configuration: %v", err)
+		}
 
 		// Initialize handlers
-		otpHandler = handlers.NewOTPHandler(otpService)
+		otpHandler = handlers.NewOTPHandler(otpService, tokenService)
 		businessProfileHandler = handlers.NewBusinessProfileHandler(businessService)
 		profileHandler = handlers.NewProfileHandler(profileService)
-		pinHandler = handlers.NewPINHandler(pinService)
+		pinHandler = handlers.NewPINHandler(pinService, deviceService, sessionService, tokenService)
+		authHandler = authhandlers.NewAuthHandler(authService, sessionService, tokenService)
+		passwordResetHandler = authhandlers.NewPasswordResetHandler(passwordResetService, authService)
+		totpHandler = authhandlers.NewTOTPHandler(totpService)
+		deviceHandler = authhandlers.NewDeviceHandler(deviceService, tokenService)
+		passkeyHandler = authhandlers.NewPasskeyHandler(passkeyService)
+		requireAuth = middleware.Authenticate(sessionService, cfg.JWT.CompatMode)
+		requireStepUp = middleware.RequireStepUp(tokenService, totpService)
+		requireElevated = middleware.RequireElevated(tokenService, pinService)
+
+		// Per-route limits shared by every replica through Redis
+		if cfg.HTTPRateLimit.Enabled {
+			policies, err := middleware.ParseRateLimitPolicies(cfg.HTTPRateLimit.Policies)
+			if err != nil {
+				log.Fatalf("Invalid RATE_LIMIT_POLICIES: %v", err)
+			}
+			r.Use(middleware.NewPolicyRateLimiter(repository.NewRateLimitRepository(redisClient), policies, tokenService).Middleware())
+		}
 	}
 
 	// API routes
@@ -85,8 +222,10 @@ var businessProfileHandler *handlers.BusinessProfileHandler
 	{
 		auth := v1.Group("/auth")
 		{
-			// Apply rate limiting to OTP endpoints
-			auth.Use(middleware.OTPRateLimitMiddleware())
+			// Without Redis, fall back to per-instance rate limiting of auth endpoints
+			if redisClient == nil {
+				auth.Use(middleware.OTPRateLimitMiddleware(cfg.OTP.HTTPRequestsPerMinute))
+			}
 			
 			// OTP endpoints (only if Redis is available)
 			if otpHandler != nil {
@@ -95,16 +234,37 @@ var businessProfileHandler *handlers.BusinessProfileHandler
 
 			// PIN endpoints (only if Redis is available)
 			if pinHandler != nil {
-				pinHandler.RegisterRoutes(auth)
+				pinHandler.RegisterRoutes(auth, requireAuth, requireStepUp)
 			}
 
-			// Existing auth endpoints
-			auth.POST("/login", func(c *gin.Context) {
-				c.JSON(http.StatusOK, gin.H{"message": "Login endpoint - Coming soon"})
-			})
-			auth.POST("/register", func(c *gin.Context) {
-				c.JSON(http.StatusOK, gin.H{"message": "Register endpoint - Coming soon"})
-			})
+			// Account endpoints (only if Redis is available)
+			if authHandler != nil {
+				authHandler.RegisterRoutes(auth, requireAuth, requireStepUp, requireElevated)
+			}
+
+			// Authenticator-app two-factor endpoints (only if Redis is available)
+			if totpHandler != nil {
+				totpHandler.RegisterRoutes(auth.Group("/2fa/totp", requireAuth))
+			}
+
+			// Trusted device endpoints (only if Redis is available)
+			if deviceHandler != nil {
+				deviceHandler.RegisterRoutes(auth.Group("/devices"), requireAuth, requireStepUp, requireElevated)
+			}
+
+			// Passkey endpoints (only if Redis is available)
+			if passkeyHandler != nil {
+				passkeyHandler.RegisterRoutes(auth.Group("/passkeys"), requireAuth, requireStepUp, requireElevated)
+			}
+
+			// Password reset endpoints (only if Redis is available)
+			if passwordResetHandler != nil {
+				authhandlers.RegisterPasswordResetRoutes(auth, passwordResetHandler)
+			}
+		}
+
+		if notificationHandler != nil {
+			notificationHandler.RegisterRoutes(v1.Group("/notifications"))
 		}
 
 		employees := v1.Group("/employees")
@@ -123,6 +283,9 @@ var businessProfileHandler *handlers.BusinessProfileHandler
 
 		profile := v1.Group("/profile")
 		{
+			if requireAuth != nil {
+				profile.Use(requireAuth)
+			}
 			if businessProfileHandler != nil {
 				businessProfileHandler.RegisterRoutes(profile)
 			}
@@ -137,6 +300,9 @@ var businessProfileHandler *handlers.BusinessProfileHandler
 	{
 		profile := api.Group("/profile")
 		{
+			if requireAuth != nil {
+				profile.Use(requireAuth)
+			}
 			if businessProfileHandler != nil {
 				businessProfileHandler.RegisterRoutes(profile)
 			}
@@ -152,6 +318,17 @@ var businessProfileHandler *handlers.BusinessProfileHandler
 	
 	if redisClient != nil {
 		fmt.Println(" Using Redis backend (real or embedded)")
+		fmt.Println(" Account Endpoints:")
+		fmt.Println("   POST /api/v1/auth/register")
+		fmt.Println("   POST /api/v1/auth/login")
+		fmt.Println("   POST /api/v1/auth/refresh")
+		fmt.Println("   POST /api/v1/auth/logout")
+		fmt.Println("   POST /api/v1/auth/logout-all")
+		fmt.Println("   POST /api/v1/auth/change-phone")
+		fmt.Println(" Password Reset Endpoints:")
+		fmt.Println("   POST /api/v1/auth/forgot-password")
+		fmt.Println("   POST /api/v1/auth/verify-reset-code")
+		fmt.Println("   POST /api/v1/auth/reset-password")
 		fmt.Println(" OTP Endpoints:")
 		fmt.Println("   POST /api/v1/auth/send-otp")
 		fmt.Println("   POST /api/v1/auth/verify-otp")
@@ -161,14 +338,60 @@ var businessProfileHandler *handlers.BusinessProfileHandler
 		fmt.Println("   POST /api/v1/profile/account-type")
 		fmt.Println("   POST /api/v1/profile/personal-details")
 		fmt.Println("   POST /api/v1/profile/address")
-		fmt.Println("   GET  /api/v1/profile/status?user_id=")
+		fmt.Println("   GET  /api/v1/profile/status")
 		fmt.Println(" PIN Endpoints:")
 		fmt.Println("   POST /api/v1/auth/setup-pin")
 		fmt.Println("   POST /api/v1/auth/login-pin")
+		fmt.Println("   POST /api/v1/auth/verify-pin")
+		fmt.Println("   POST /api/v1/auth/change-pin")
+		fmt.Println("   POST /api/v1/auth/reset-pin")
+		fmt.Println(" Device Endpoints:")
+		fmt.Println("   POST   /api/v1/auth/devices/challenge")
+		fmt.Println("   GET    /api/v1/auth/devices")
+		fmt.Println("   POST   /api/v1/auth/devices")
+		fmt.Println("   DELETE /api/v1/auth/devices/:device_id")
+		fmt.Println(" Passkey Endpoints:")
+		fmt.Println("   POST   /api/v1/auth/passkeys/register/options")
+		fmt.Println("   POST   /api/v1/auth/passkeys/register")
+		fmt.Println("   POST   /api/v1/auth/passkeys/login/options")
+		fmt.Println("   POST   /api/v1/auth/passkeys/login")
+		fmt.Println("   GET    /api/v1/auth/passkeys")
+		fmt.Println("   DELETE /api/v1/auth/passkeys/:credential_id")
 	} else {
 		fmt.Println(" OTP endpoints disabled (Redis not available)")
 		fmt.Println(" Profile endpoints disabled (Redis not available)")
 	}
 
-	log.Fatal(r.Run(":8080"))
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+	<-ctx.Done()
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+	// Queue workers stop with ctx; a message cut off mid-send is retried after its lease
+	workers.Wait()
+}
+
+// migrateDatabase applies pending migrations when enabled; concurrent replicas
+// serialize on the migrator's advisory lock
+func migrateDatabase(pool *pgxpool.Pool, enabled bool) error {
+	if !enabled {
+		return nil
+	}
+	migrator, err := migrate.New(pool, migrations.FS)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(context.Background())
+	for _, m := range applied {
+		log.Printf("Applied migration %06d_%s", m.Version, m.Name)
+	}
+	return err
 }
@@ -1,24 +1,40 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	OTP      OTPConfig
-	Twilio   TwilioConfig
-	SMTP     SMTPConfig
+	Server        ServerConfig
+	Database      DatabaseConfig
+	Redis         RedisConfig
+	HTTPRateLimit HTTPRateLimitConfig
+	JWT           JWTConfig
+	OTP           OTPConfig
+	TOTP          TOTPConfig
+	PIN           PINConfig
+	Device        DeviceConfig
+	WebAuthn      WebAuthnConfig
+	SMSGuard      SMSGuardConfig
+	PasswordReset PasswordResetConfig
+	Twilio        TwilioConfig
+	SMTP          SMTPConfig
+	Aggregator    AggregatorConfig
+	Notifier      NotifierConfig
 }
 
 type ServerConfig struct {
 	Port string
 	Host string
+	// Env is APP_ENV; only "development" may run on the built-in dev secrets
+	Env string
+	// TrustedProxies are the proxy IPs or CIDRs whose X-Forwarded-For is believed
+	// when working out the client IP; empty trusts none and uses the peer address
+	TrustedProxies []string
 }
 
 type DatabaseConfig struct {
@@ -27,6 +43,10 @@ type DatabaseConfig struct {
 	User     string
 	Password string
 	Name     string
+	SSLMode  string
+	MaxConns int
+	// AutoMigrate applies pending migrations when the server starts
+	AutoMigrate bool
 }
 
 type RedisConfig struct {
@@ -36,15 +56,138 @@ type RedisConfig struct {
 	DB       int
 }
 
+// HTTPRateLimitConfig holds per-route request limits shared across replicas through Redis
+type HTTPRateLimitConfig struct {
+	Enabled bool
+	// Policies maps routes to rules, e.g.
+	// "/api/v1/auth/*=ip:10/1m;/api/v1/auth/send-otp=ip:10/1m,identifier:5/15m"
+	Policies string
+}
+
 type JWTConfig struct {
 	Secret string
 	TTL    time.Duration
+	// RefreshTTL is how long a session survives without being refreshed
+	RefreshTTL time.Duration
+	// VerificationTTL is the lifetime of tokens returned by verify-otp
+	VerificationTTL time.Duration
+	// ElevatedTTL is the lifetime of tokens returned by verify-pin
+	ElevatedTTL time.Duration
+	// CompatMode lets requests without a bearer token fall back to the
+	// user_id in the request body while older mobile builds are phased out
+	CompatMode bool
+	// RequireVerifiedContact makes registration require a verify-otp token
+	// for the email or phone being registered
+	RequireVerifiedContact bool
 }
 
 type OTPConfig struct {
 	Length int
 	TTL    time.Duration
+	// MaxAttempts is how many verification guesses a code allows
+	MaxAttempts int
+	// ResendCooldown is the minimum time between codes sent to one identifier
+	ResendCooldown time.Duration
+	// RateLimit caps sends per identifier
 	RateLimit RateLimitConfig
+	// HTTPRequestsPerMinute is the default per-IP limit on /api/v1/auth endpoints
+	HTTPRequestsPerMinute int
+	// HashKey keys the HMAC used to store codes at rest
+	HashKey string
+}
+
+// TOTPConfig controls authenticator-app two-factor authentication
+type TOTPConfig struct {
+	// Issuer is the account name shown in authenticator apps
+	Issuer string
+	// EncryptionKey encrypts secrets at rest and keys the recovery code hashes
+	EncryptionKey string
+	// MaxAttempts wrong codes within AttemptWindow lock verification until the window ends
+	MaxAttempts   int
+	AttemptWindow time.Duration
+	// RecoveryCodes is how many single-use recovery codes are issued
+	RecoveryCodes int
+	// Skew is how many 30-second steps either side of the current one are accepted
+	Skew int
+}
+
+// PINConfig throttles wrong PINs: FreeAttempts fail without delay, each later
+// failure doubles the wait starting from BaseDelay, and MaxAttempts lock the PIN for Lockout
+type PINConfig struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxAttempts  int
+	Lockout      time.Duration
+	// AttemptWindow is how long failures are remembered after the last one
+	AttemptWindow time.Duration
+	// Pepper is mixed into every PIN hash and kept out of the database
+	Pepper string
+	// Hash is the Argon2id cost; PINs hashed with other settings are rehashed on the next login
+	Hash Argon2Config
+}
+
+// Argon2Config tunes Argon2id; Memory is in KiB
+type Argon2Config struct {
+	Time    int
+	Memory  int
+	Threads int
+}
+
+// DeviceConfig controls the trusted device registry
+type DeviceConfig struct {
+	// ChallengeTTL is how long a device has to sign a login challenge
+	ChallengeTTL time.Duration
+	// MaxPerUser caps how many devices a user can register
+	MaxPerUser int
+}
+
+// WebAuthnConfig identifies this service as a passkey relying party
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are scoped to, e.g. "vestroll.com"
+	RPID   string
+	RPName string
+	// Origins is a comma-separated list of origins ceremonies may come from,
+	// e.g. "https://app.vestroll.com,android:apk-key-hash:..."
+	Origins string
+	// ChallengeTTL is how long a ceremony may take
+	ChallengeTTL time.Duration
+	// RequireUserVerification demands a biometric or device PIN, not just presence
+	RequireUserVerification bool
+}
+
+// SMSGuardConfig limits where and how much send-otp can text, against SMS pumping
+type SMSGuardConfig struct {
+	Enabled bool
+	// AllowedPrefixes, when set, is the only destinations SMS is sent to (e.g. "+234,+233")
+	AllowedPrefixes string
+	// BlockedPrefixes are never texted, even when allowed
+	BlockedPrefixes string
+	// Costs maps prefixes to the price of one SMS, e.g. "+234=0.03;+1=0.008";
+	// other destinations cost DefaultCost
+	Costs       string
+	DefaultCost float64
+	// PrefixHourlyCap and GlobalHourlyCap bound spend per calling code and overall each hour
+	PrefixHourlyCap float64
+	GlobalHourlyCap float64
+	// ChallengePercent is the share of a spend cap after which a challenge is required
+	ChallengePercent int
+	// BurstWindow is how long sends to a block of 100 consecutive numbers are remembered;
+	// BurstChallenge distinct numbers require a challenge and BurstBlock are refused
+	BurstWindow    time.Duration
+	BurstChallenge int
+	BurstBlock     int
+	// Challenge is "pow" for proof-of-work or "none"
+	Challenge string
+	// PoWDifficulty is the number of leading zero bits a proof-of-work hash needs
+	PoWDifficulty int
+}
+
+type PasswordResetConfig struct {
+	CodeLength  int
+	TTL         time.Duration
+	MaxAttempts int
+	// TokenTTL is how long the reset token returned by verify-reset-code stays valid
+	TokenTTL time.Duration
 }
 
 type RateLimitConfig struct {
@@ -59,26 +202,78 @@ type TwilioConfig struct {
 }
 
 type SMTPConfig struct {
-	Host     string
-	Port     int
-	Username string
-	Password string
+	Host      string
+	Port      int
+	Username  string
+	Password  string
 	FromEmail string
-	FromName string
+	FromName  string
+}
+
+// AggregatorConfig points at a local SMS aggregator's HTTP API
+type AggregatorConfig struct {
+	URL      string
+	APIKey   string
+	SenderID string
+}
+
+// NotifierConfig selects the provider used for each delivery channel
+type NotifierConfig struct {
+	// SMSProvider is "twilio", "aggregator" or "outbox", or a comma-separated
+	// list tried in order when a provider fails
+	SMSProvider string
+	// SMSRoutes overrides SMSProvider for destination prefixes,
+	// e.g. "+234=aggregator,twilio;+1=twilio"
+	SMSRoutes string
+	// EmailProvider is "smtp" or "outbox"
+	EmailProvider string
+	// OutboxDir is where the outbox provider writes messages; empty keeps them in memory only
+	OutboxDir string
+	// OutboxEndpoint exposes captured messages on GET /dev/outbox
+	OutboxEndpoint bool
+	// BrandName is the product name shown in message templates
+	BrandName string
+	// Async queues messages in Redis and sends them from background workers
+	Async bool
+	// Workers is the number of delivery goroutines per instance
+	Workers int
+	// MaxAttempts is how many times a message is tried before it is dead-lettered
+	MaxAttempts int
+	// RetryBaseDelay doubles after each failed attempt, up to RetryMaxDelay
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// JobRetention is how long delivery status stays queryable
+	JobRetention time.Duration
+	// SMSStatsInterval is how often the SMS router logs per-provider success rates; 0 disables it
+	SMSStatsInterval time.Duration
+}
+
+// devSecrets are the defaults for each secret, usable only with APP_ENV=development
+var devSecrets = map[string]string{
+	"JWT_SECRET":          "your-secret-key",
+	"OTP_HASH_KEY":        "dev-otp-hash-key",
+	"TOTP_ENCRYPTION_KEY": "dev-totp-encryption-key",
+	"PIN_PEPPER":          "dev-pin-pepper",
 }
 
 func Load() *Config {
+	otpHTTPLimit := getEnvAsInt("OTP_HTTP_RATE_LIMIT_PER_MINUTE", 10)
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "localhost"),
+			Port:           getEnv("SERVER_PORT", "8080"),
+			Host:           getEnv("SERVER_HOST", "localhost"),
+			Env:            getEnv("APP_ENV", "production"),
+			TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", ""),
-			Name:     getEnv("DB_NAME", "vestroll"),
+			Host:        getEnv("DB_HOST", "localhost"),
+			Port:        getEnv("DB_PORT", "5432"),
+			User:        getEnv("DB_USER", "postgres"),
+			Password:    getEnv("DB_PASSWORD", ""),
+			Name:        getEnv("DB_NAME", "vestroll"),
+			SSLMode:     getEnv("DB_SSLMODE", "disable"),
+			MaxConns:    getEnvAsInt("DB_MAX_CONNS", 10),
+			AutoMigrate: getEnvAsBool("DB_AUTO_MIGRATE", true),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -86,17 +281,83 @@ func Load() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
+		HTTPRateLimit: HTTPRateLimitConfig{
+			Enabled:  getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			Policies: getEnv("RATE_LIMIT_POLICIES", fmt.Sprintf("/api/v1/auth/*=ip:%d/1m", otpHTTPLimit)),
+		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-secret-key"),
-			TTL:    time.Duration(getEnvAsInt("JWT_TTL_HOURS", 24)) * time.Hour,
+			Secret:                 getEnv("JWT_SECRET", devSecrets["JWT_SECRET"]),
+			TTL:                    time.Duration(getEnvAsInt("JWT_TTL_HOURS", 24)) * time.Hour,
+			RefreshTTL:             time.Duration(getEnvAsInt("JWT_REFRESH_TTL_HOURS", 720)) * time.Hour,
+			VerificationTTL:        time.Duration(getEnvAsInt("JWT_VERIFICATION_TTL_MINUTES", 15)) * time.Minute,
+			ElevatedTTL:            time.Duration(getEnvAsInt("JWT_ELEVATED_TTL_MINUTES", 5)) * time.Minute,
+			CompatMode:             getEnvAsBool("AUTH_COMPAT_MODE", false),
+			RequireVerifiedContact: getEnvAsBool("AUTH_REQUIRE_VERIFIED_CONTACT", false),
 		},
 		OTP: OTPConfig{
-			Length: 6,
-			TTL:    5 * time.Minute,
+			Length:         getEnvAsInt("OTP_LENGTH", 6),
+			TTL:            time.Duration(getEnvAsInt("OTP_TTL_MINUTES", 5)) * time.Minute,
+			MaxAttempts:    getEnvAsInt("OTP_MAX_ATTEMPTS", 3),
+			ResendCooldown: time.Duration(getEnvAsInt("OTP_RESEND_COOLDOWN_SECONDS", 30)) * time.Second,
 			RateLimit: RateLimitConfig{
 				MaxRequests: getEnvAsInt("OTP_RATE_LIMIT_MAX", 5),
 				WindowSize:  time.Duration(getEnvAsInt("OTP_RATE_LIMIT_WINDOW_MINUTES", 15)) * time.Minute,
 			},
+			HTTPRequestsPerMinute: otpHTTPLimit,
+			HashKey:               getEnv("OTP_HASH_KEY", devSecrets["OTP_HASH_KEY"]),
+		},
+		TOTP: TOTPConfig{
+			Issuer:        getEnv("TOTP_ISSUER", "VestRoll"),
+			EncryptionKey: getEnv("TOTP_ENCRYPTION_KEY", devSecrets["TOTP_ENCRYPTION_KEY"]),
+			MaxAttempts:   getEnvAsInt("TOTP_MAX_ATTEMPTS", 5),
+			AttemptWindow: time.Duration(getEnvAsInt("TOTP_ATTEMPT_WINDOW_MINUTES", 15)) * time.Minute,
+			RecoveryCodes: getEnvAsInt("TOTP_RECOVERY_CODES", 10),
+			Skew:          getEnvAsInt("TOTP_SKEW_STEPS", 1),
+		},
+		PIN: PINConfig{
+			FreeAttempts:  getEnvAsInt("PIN_FREE_ATTEMPTS", 3),
+			BaseDelay:     time.Duration(getEnvAsInt("PIN_DELAY_BASE_SECONDS", 30)) * time.Second,
+			MaxAttempts:   getEnvAsInt("PIN_MAX_ATTEMPTS", 6),
+			Lockout:       time.Duration(getEnvAsInt("PIN_LOCKOUT_MINUTES", 60)) * time.Minute,
+			AttemptWindow: time.Duration(getEnvAsInt("PIN_ATTEMPT_WINDOW_HOURS", 24)) * time.Hour,
+			Pepper:        getEnv("PIN_PEPPER", devSecrets["PIN_PEPPER"]),
+			Hash: Argon2Config{
+				Time:    getEnvAsInt("PIN_ARGON2_TIME", 3),
+				Memory:  getEnvAsInt("PIN_ARGON2_MEMORY_KIB", 64*1024),
+				Threads: getEnvAsInt("PIN_ARGON2_THREADS", 2),
+			},
+		},
+		Device: DeviceConfig{
+			ChallengeTTL: time.Duration(getEnvAsInt("DEVICE_CHALLENGE_TTL_SECONDS", 120)) * time.Second,
+			MaxPerUser:   getEnvAsInt("DEVICE_MAX_PER_USER", 10),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:                    getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPName:                  getEnv("WEBAUTHN_RP_NAME", "VestRoll"),
+			Origins:                 getEnv("WEBAUTHN_ORIGINS", "http://localhost:3000"),
+			ChallengeTTL:            time.Duration(getEnvAsInt("WEBAUTHN_CHALLENGE_TTL_SECONDS", 300)) * time.Second,
+			RequireUserVerification: getEnvAsBool("WEBAUTHN_REQUIRE_USER_VERIFICATION", true),
+		},
+		SMSGuard: SMSGuardConfig{
+			Enabled:          getEnvAsBool("SMS_GUARD_ENABLED", true),
+			AllowedPrefixes:  getEnv("SMS_ALLOWED_PREFIXES", ""),
+			BlockedPrefixes:  getEnv("SMS_BLOCKED_PREFIXES", ""),
+			Costs:            getEnv("SMS_COSTS", ""),
+			DefaultCost:      getEnvAsFloat("SMS_DEFAULT_COST", 0.05),
+			PrefixHourlyCap:  getEnvAsFloat("SMS_PREFIX_HOURLY_CAP", 10),
+			GlobalHourlyCap:  getEnvAsFloat("SMS_GLOBAL_HOURLY_CAP", 50),
+			ChallengePercent: getEnvAsInt("SMS_CHALLENGE_AT_PERCENT", 50),
+			BurstWindow:      time.Duration(getEnvAsInt("SMS_BURST_WINDOW_MINUTES", 10)) * time.Minute,
+			BurstChallenge:   getEnvAsInt("SMS_BURST_CHALLENGE", 3),
+			BurstBlock:       getEnvAsInt("SMS_BURST_BLOCK", 10),
+			Challenge:        getEnv("SMS_CHALLENGE", "pow"),
+			PoWDifficulty:    getEnvAsInt("SMS_POW_DIFFICULTY", 20),
+		},
+		PasswordReset: PasswordResetConfig{
+			CodeLength:  getEnvAsInt("PASSWORD_RESET_CODE_LENGTH", 6),
+			TTL:         time.Duration(getEnvAsInt("PASSWORD_RESET_TTL_MINUTES", 5)) * time.Minute,
+			MaxAttempts: getEnvAsInt("PASSWORD_RESET_MAX_ATTEMPTS", 3),
+			TokenTTL:    time.Duration(getEnvAsInt("PASSWORD_RESET_TOKEN_TTL_MINUTES", 10)) * time.Minute,
 		},
 		Twilio: TwilioConfig{
 			AccountSID: getEnv("TWILIO_ACCOUNT_SID", ""),
@@ -111,9 +372,55 @@ func Load() *Config {
 			FromEmail: getEnv("SMTP_FROM_EMAIL", "noreply@vestroll.com"),
 			FromName:  getEnv("SMTP_FROM_NAME", "VestRoll"),
 		},
+		Aggregator: AggregatorConfig{
+			URL:      getEnv("SMS_AGGREGATOR_URL", ""),
+			APIKey:   getEnv("SMS_AGGREGATOR_API_KEY", ""),
+			SenderID: getEnv("SMS_AGGREGATOR_SENDER_ID", "VestRoll"),
+		},
+		Notifier: NotifierConfig{
+			SMSProvider:      getEnv("NOTIFY_SMS_PROVIDER", "twilio"),
+			SMSRoutes:        getEnv("NOTIFY_SMS_ROUTES", ""),
+			EmailProvider:    getEnv("NOTIFY_EMAIL_PROVIDER", "smtp"),
+			OutboxDir:        getEnv("NOTIFY_OUTBOX_DIR", ""),
+			OutboxEndpoint:   getEnvAsBool("NOTIFY_OUTBOX_ENDPOINT", false),
+			BrandName:        getEnv("NOTIFY_BRAND_NAME", "VestRoll"),
+			Async:            getEnvAsBool("NOTIFY_ASYNC", false),
+			Workers:          getEnvAsInt("NOTIFY_WORKERS", 4),
+			MaxAttempts:      getEnvAsInt("NOTIFY_MAX_ATTEMPTS", 5),
+			RetryBaseDelay:   time.Duration(getEnvAsInt("NOTIFY_RETRY_BASE_SECONDS", 2)) * time.Second,
+			RetryMaxDelay:    time.Duration(getEnvAsInt("NOTIFY_RETRY_MAX_SECONDS", 60)) * time.Second,
+			JobRetention:     time.Duration(getEnvAsInt("NOTIFY_JOB_RETENTION_HOURS", 168)) * time.Hour,
+			SMSStatsInterval: time.Duration(getEnvAsInt("NOTIFY_SMS_STATS_MINUTES", 15)) * time.Minute,
+		},
 	}
 }
 
+// CheckSecrets makes sure that, outside development, every secret is set and
+// none is shared. Each is rotated on its own: a new JWT_SECRET must not
+// invalidate PINs or make stored TOTP secrets undecryptable.
+func (c *Config) CheckSecrets() error {
+	if c.Server.Env == "development" {
+		return nil
+	}
+	secrets := []struct{ name, value string }{
+		{"JWT_SECRET", c.JWT.Secret},
+		{"OTP_HASH_KEY", c.OTP.HashKey},
+		{"TOTP_ENCRYPTION_KEY", c.TOTP.EncryptionKey},
+		{"PIN_PEPPER", c.PIN.Pepper},
+	}
+	seen := map[string]string{}
+	for _, secret := range secrets {
+		if secret.value == devSecrets[secret.name] {
+			return fmt.Errorf("%s must be set when APP_ENV is %q", secret.name, c.Server.Env)
+		}
+		if other, ok := seen[secret.value]; ok {
+			return fmt.Errorf("%s must differ from %s", secret.name, other)
+		}
+		seen[secret.value] = secret.name
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -128,3 +435,30 @@ func getEnvAsInt(name string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsFloat(name string, defaultValue float64) float64 {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(name string, defaultValue bool) bool {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsList splits a comma-separated value, returning nil when it is unset
+func getEnvAsList(name string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(name, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
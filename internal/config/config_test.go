@@ -0,0 +1,32 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSecretsRequiresDistinctSecretsOutsideDevelopment(t *testing.T) {
+	cfg := &Config{}
+	cfg.Server.Env = "development"
+	cfg.JWT.Secret = devSecrets["JWT_SECRET"]
+	cfg.OTP.HashKey = devSecrets["OTP_HASH_KEY"]
+	cfg.TOTP.EncryptionKey = devSecrets["TOTP_ENCRYPTION_KEY"]
+	cfg.PIN.Pepper = devSecrets["PIN_PEPPER"]
+	if err := cfg.CheckSecrets(); err != nil {
+		t.Fatalf("development with dev secrets = %v, want nil", err)
+	}
+
+	cfg.Server.Env = "production"
+	if err := cfg.CheckSecrets(); err == nil || !strings.Contains(err.Error(), "JWT_SECRET") {
+		t.Fatalf("production with dev secrets = %v, want JWT_SECRET error", err)
+	}
+	cfg.JWT.Secret, cfg.OTP.HashKey, cfg.TOTP.EncryptionKey = "jwt", "otp", "totp"
+	cfg.PIN.Pepper = "jwt"
+	if err := cfg.CheckSecrets(); err == nil || !strings.Contains(err.Error(), "PIN_PEPPER must differ from JWT_SECRET") {
+		t.Fatalf("shared secret = %v, want PIN_PEPPER error", err)
+	}
+	cfg.PIN.Pepper = "pin"
+	if err := cfg.CheckSecrets(); err != nil {
+		t.Fatalf("distinct secrets = %v, want nil", err)
+	}
+}
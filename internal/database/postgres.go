@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresURL builds a connection URL from the DB_* settings
+func PostgresURL(cfg config.DatabaseConfig) string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(cfg.User, cfg.Password),
+		Host:   net.JoinHostPort(cfg.Host, cfg.Port),
+		Path:   "/" + cfg.Name,
+	}
+	q := url.Values{}
+	if cfg.SSLMode != "" {
+		q.Set("sslmode", cfg.SSLMode)
+	}
+	if cfg.MaxConns > 0 {
+		q.Set("pool_max_conns", fmt.Sprint(cfg.MaxConns))
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func NewPostgresPool(ctx context.Context, cfg config.DatabaseConfig) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.New(ctx, PostgresURL(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Postgres: %w", err)
+	}
+
+	// Test connection
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/codeZe-us/vestroll-backend/internal/middleware"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandler manages registration, login and session endpoints
+type AuthHandler struct {
+	service  *authservice.AuthService
+	sessions *authservice.SessionService
+	tokens   *authservice.TokenService
+}
+
+func NewAuthHandler(service *authservice.AuthService, sessions *authservice.SessionService, tokens *authservice.TokenService) *AuthHandler {
+	return &AuthHandler{service: service, sessions: sessions, tokens: tokens}
+}
+
+// RegisterRoutes registers account endpoints under /auth
+// requireAuth guards endpoints that act on the signed-in user;
+// requireStepUp additionally demands a recent second-factor check and
+// requireElevated a recent PIN check
+func (h *AuthHandler) RegisterRoutes(router *gin.RouterGroup, requireAuth, requireStepUp, requireElevated gin.HandlerFunc) {
+	router.POST("/register", h.Register)
+	router.POST("/login", h.Login)
+	router.POST("/refresh", h.Refresh)
+	router.POST("/logout", h.Logout)
+	router.POST("/logout-all", requireAuth, h.LogoutAll)
+	router.POST("/change-phone", requireAuth, requireStepUp, requireElevated, middleware.RequireVerification(h.tokens, authservice.PurposePhoneVerified), h.ChangePhone)
+}
+
+// Register handles POST /api/v1/auth/register
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req models.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	resp, err := h.service.Register(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Login handles POST /api/v1/auth/login
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	resp, err := h.service.Login(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Refresh handles POST /api/v1/auth/refresh
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	pair, err := h.sessions.Refresh(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.TokenResponse{Success: true, Message: "Token refreshed", TokenPair: pair})
+}
+
+// Logout handles POST /api/v1/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	if err := h.sessions.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.LogoutResponse{Success: true, Message: "Logged out"})
+}
+
+// LogoutAll handles POST /api/v1/auth/logout-all
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.Error(apperrors.Unauthorized("Missing bearer token"))
+		return
+	}
+	if err := h.sessions.LogoutAll(c.Request.Context(), userID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.LogoutResponse{Success: true, Message: "Logged out of all sessions"})
+}
+
+// ChangePhone handles POST /api/v1/auth/change-phone
+func (h *AuthHandler) ChangePhone(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.Error(apperrors.Unauthorized("Missing bearer token"))
+		return
+	}
+	var req models.ChangePhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	if verified, _ := middleware.VerifiedIdentifier(c); verified != req.Phone {
+		c.Error(authservice.ErrVerificationRequired)
+		return
+	}
+	user, err := h.service.ChangePhone(c.Request.Context(), userID, req.Phone)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.UserResponse{Success: true, Message: "Phone number updated", User: user})
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/codeZe-us/vestroll-backend/internal/middleware"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceHandler manages trusted device endpoints
+type DeviceHandler struct {
+	service *authservice.DeviceService
+	tokens  *authservice.TokenService
+}
+
+func NewDeviceHandler(service *authservice.DeviceService, tokens *authservice.TokenService) *DeviceHandler {
+	return &DeviceHandler{service: service, tokens: tokens}
+}
+
+// RegisterRoutes registers device endpoints under /auth/devices
+// The challenge comes before PIN login so it is public; requireAuth guards the
+// rest, requireStepUp guards adding a device when the user has a second factor
+// and requireElevated guards removing one when the user has a PIN
+func (h *DeviceHandler) RegisterRoutes(router *gin.RouterGroup, requireAuth, requireStepUp, requireElevated gin.HandlerFunc) {
+	router.POST("/challenge", h.Challenge)
+	router.GET("", requireAuth, h.List)
+	router.POST("", requireAuth, requireStepUp, h.Register)
+	router.DELETE("/:device_id", requireAuth, requireElevated, h.Revoke)
+}
+
+// Register handles POST /api/v1/auth/devices. The new_device verification
+// token is redeemed only once the body is valid, so a bad request can be retried.
+func (h *DeviceHandler) Register(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req models.RegisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	if err := h.service.ValidateRegistration(req); err != nil {
+		c.Error(err)
+		return
+	}
+	verified, ok := middleware.RedeemVerification(c, h.tokens, authservice.PurposeNewDevice)
+	if !ok {
+		return
+	}
+	device, err := h.service.Register(c.Request.Context(), userID, verified, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.DeviceResponse{Success: true, Message: "Device registered", Device: *device})
+}
+
+// List handles GET /api/v1/auth/devices
+func (h *DeviceHandler) List(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	devices, err := h.service.List(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.DeviceListResponse{Devices: devices})
+}
+
+// Revoke handles DELETE /api/v1/auth/devices/:device_id
+func (h *DeviceHandler) Revoke(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Revoke(c.Request.Context(), userID, c.Param("device_id")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.LogoutResponse{Success: true, Message: "Device removed and its sessions ended"})
+}
+
+// Challenge handles POST /api/v1/auth/devices/challenge
+func (h *DeviceHandler) Challenge(c *gin.Context) {
+	var req models.DeviceChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	resp, err := h.service.Challenge(c.Request.Context(), req.UserID, req.DeviceID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// PasskeyHandler manages WebAuthn passkey endpoints
+type PasskeyHandler struct {
+	service *authservice.PasskeyService
+}
+
+func NewPasskeyHandler(service *authservice.PasskeyService) *PasskeyHandler {
+	return &PasskeyHandler{service: service}
+}
+
+// RegisterRoutes registers passkey endpoints under /auth/passkeys
+// Login is public; requireAuth guards managing passkeys, requireStepUp
+// guards adding one when the user has a second factor and requireElevated
+// guards removing one when the user has a PIN
+func (h *PasskeyHandler) RegisterRoutes(router *gin.RouterGroup, requireAuth, requireStepUp, requireElevated gin.HandlerFunc) {
+	router.POST("/login/options", h.LoginOptions)
+	router.POST("/login", h.Login)
+	router.POST("/register/options", requireAuth, h.RegistrationOptions)
+	router.POST("/register", requireAuth, requireStepUp, h.Register)
+	router.GET("", requireAuth, h.List)
+	router.DELETE("/:credential_id", requireAuth, requireElevated, h.Delete)
+}
+
+// RegistrationOptions handles POST /api/v1/auth/passkeys/register/options
+func (h *PasskeyHandler) RegistrationOptions(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	options, err := h.service.RegistrationOptions(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// Register handles POST /api/v1/auth/passkeys/register
+func (h *PasskeyHandler) Register(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req models.PasskeyRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	passkey, err := h.service.Register(c.Request.Context(), userID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.PasskeyResponse{Success: true, Message: "Passkey registered", Passkey: *passkey})
+}
+
+// LoginOptions handles POST /api/v1/auth/passkeys/login/options
+// The body is optional; without an identifier any discoverable passkey may answer
+func (h *PasskeyHandler) LoginOptions(c *gin.Context) {
+	var req models.PasskeyLoginOptionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	options, err := h.service.LoginOptions(c.Request.Context(), req.Identifier)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, options)
+}
+
+// Login handles POST /api/v1/auth/passkeys/login
+func (h *PasskeyHandler) Login(c *gin.Context) {
+	var req models.PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	resp, err := h.service.Login(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// List handles GET /api/v1/auth/passkeys
+func (h *PasskeyHandler) List(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	passkeys, err := h.service.List(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.PasskeyListResponse{Passkeys: passkeys})
+}
+
+// Delete handles DELETE /api/v1/auth/passkeys/:credential_id
+func (h *PasskeyHandler) Delete(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(c.Request.Context(), userID, c.Param("credential_id")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.LogoutResponse{Success: true, Message: "Passkey removed"})
+}
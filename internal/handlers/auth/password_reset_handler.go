@@ -1,108 +1,126 @@
 package auth
 
 import (
+	"errors"
+	"log"
 	"net/http"
-	"time"
-	"github.com/go-redis/redis/v8"
-	"github.com/gin-gonic/gin"
-	"github.com/codeZe-us/vestroll-backend/internal/services/email_service"
-	"github.com/codeZe-us/vestroll-backend/internal/services/sms_service"
-	"github.com/codeZe-us/vestroll-backend/internal/services/password_reset_service"
-	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"strings"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/services"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
 	"github.com/codeZe-us/vestroll-backend/internal/utils"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
 )
 
-// Handler struct for password reset
-// Add dependencies as needed
-// EmailService, SMSService, RedisClient
-
+// PasswordResetHandler manages the forgot/verify/reset password endpoints
+// Accounts resolves identifiers to users and updates their password
 type PasswordResetHandler struct {
-	EmailService *email_service.EmailService
-	SMSService   *sms_service.SMSService
-	RedisClient  *redis.Client
+	service  *services.PasswordResetService
+	accounts *authservice.AuthService
+}
+
+func NewPasswordResetHandler(service *services.PasswordResetService, accounts *authservice.AuthService) *PasswordResetHandler {
+	return &PasswordResetHandler{service: service, accounts: accounts}
 }
 
 // POST /api/auth/forgot-password
+// Responds the same way whether or not the account exists, and whether or not
+// delivery succeeded, to avoid account enumeration
 func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
-	var req struct {
-		Identifier string `json:"identifier"`
-		Channel    string `json:"channel"` // "email" or "sms"
+	var req models.PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil || req.Identifier == "" || (req.Channel != "email" && req.Channel != "sms") {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid request"})
+	// Checked before the lookup so the answer does not depend on the account existing
+	release, err := h.service.AdmitSend(c.Request.Context(), req.Identifier, req.Channel, req.Challenge)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	resetService := getPasswordResetService(h)
-	_, err := resetService.GenerateAndSendResetCode(c.Request.Context(), req.Identifier, req.Channel)
+	user, err := h.accounts.FindUser(c.Request.Context(), req.Identifier)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		release()
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Reset code sent"})
+	if user != nil {
+		locale := req.Locale
+		if locale == "" {
+			locale = c.GetHeader("Accept-Language")
+		}
+		if err := h.service.SendResetCode(c.Request.Context(), resetContact(*user, req.Identifier), req.Channel, locale); err != nil {
+			log.Printf("failed to send password reset code to user %s: %v", user.ID, err)
+			release()
+		}
+	}
+	c.JSON(http.StatusOK, models.PasswordResetResponse{Success: true, Message: "If an account exists, a reset code has been sent"})
 }
 
 // POST /api/auth/verify-reset-code
 func (h *PasswordResetHandler) VerifyResetCode(c *gin.Context) {
-	var req struct {
-		Identifier string `json:"identifier"`
-		Code       string `json:"code"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil || req.Identifier == "" || req.Code == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid request"})
+	var req models.VerifyResetCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
 		return
 	}
-	resetService := getPasswordResetService(h)
-	ok, err := resetService.VerifyResetCode(c.Request.Context(), req.Identifier, req.Code)
+	user, err := h.accounts.FindUser(c.Request.Context(), req.Identifier)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(services.ErrInvalidResetCode)
 		return
 	}
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid or expired code"})
+	token, err := h.service.VerifyResetCode(c.Request.Context(), resetContact(*user, req.Identifier), req.Code)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Code verified"})
+	c.JSON(http.StatusOK, models.VerifyResetCodeResponse{
+		Success:    true,
+		Message:    "Code verified",
+		ResetToken: token,
+		ExpiresIn:  int64(h.service.TokenTTL().Seconds()),
+	})
+}
+
+// resetContact is the stored phone or email matching the identifier's kind, so
+// codes are keyed the same way however the user typed the identifier
+func resetContact(user models.User, identifier string) string {
+	if strings.HasPrefix(strings.TrimSpace(identifier), "+") {
+		return user.Phone
+	}
+	return user.Email
 }
 
 // POST /api/auth/reset-password
 func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
-	var req struct {
-		Identifier  string `json:"identifier"`
-		Code        string `json:"code"`
-		NewPassword string `json:"new_password"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil || req.Identifier == "" || req.Code == "" || req.NewPassword == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid request"})
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
 		return
 	}
-	resetService := getPasswordResetService(h)
-	ok, err := resetService.VerifyResetCode(c.Request.Context(), req.Identifier, req.Code)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+	// Check strength before redeeming so a weak password doesn't burn the token
+	if err := utils.ValidatePasswordStrength(req.NewPassword); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
 		return
 	}
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid or expired code"})
+	identifier, err := h.service.ConsumeResetToken(c.Request.Context(), req.ResetToken)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	if err := utils.ValidatePasswordStrength(req.NewPassword); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+	if err := h.accounts.ResetPassword(c.Request.Context(), identifier, req.NewPassword); err != nil {
+		// The account behind the token is gone; report it like any other dead token
+		if errors.Is(err, authservice.ErrInvalidCredentials) {
+			err = services.ErrInvalidResetToken
+		}
+		c.Error(err)
 		return
 	}
-	// TODO: Update password in user database here
-	_ = resetService.DeleteResetCode(c.Request.Context(), req.Identifier)
-	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Password reset successful"})
-}
-
-// Utility: Generate and cache reset code/token
-
-// Utility: Validate password strength
-// Helper to get PasswordResetService
-func getPasswordResetService(h *PasswordResetHandler) *password_reset_service.PasswordResetService {
-	return password_reset_service.NewPasswordResetService(
-		repository.NewPasswordResetRepository(h.RedisClient, 5*time.Minute),
-		h.EmailService,
-		h.SMSService,
-		5*time.Minute,
-	)
+	c.JSON(http.StatusOK, models.PasswordResetResponse{Success: true, Message: "Password reset successful"})
 }
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/codeZe-us/vestroll-backend/internal/middleware"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// TOTPHandler manages authenticator-app two-factor endpoints
+type TOTPHandler struct {
+	service *authservice.TOTPService
+}
+
+func NewTOTPHandler(service *authservice.TOTPService) *TOTPHandler {
+	return &TOTPHandler{service: service}
+}
+
+// RegisterRoutes registers TOTP endpoints under /auth/2fa/totp; every route
+// acts on the signed-in user
+func (h *TOTPHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("", h.Status)
+	router.POST("/enroll", h.Enroll)
+	router.POST("/confirm", h.Confirm)
+	router.POST("/verify", h.Verify)
+	router.POST("/disable", h.Disable)
+	router.POST("/recovery-codes", h.RegenerateRecoveryCodes)
+}
+
+// Status handles GET /api/v1/auth/2fa/totp
+func (h *TOTPHandler) Status(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	resp, err := h.service.Status(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Enroll handles POST /api/v1/auth/2fa/totp/enroll
+func (h *TOTPHandler) Enroll(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	resp, err := h.service.Enroll(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Confirm handles POST /api/v1/auth/2fa/totp/confirm
+func (h *TOTPHandler) Confirm(c *gin.Context) {
+	userID, req, ok := bindTOTPCode(c)
+	if !ok {
+		return
+	}
+	resp, err := h.service.Confirm(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Verify handles POST /api/v1/auth/2fa/totp/verify
+func (h *TOTPHandler) Verify(c *gin.Context) {
+	userID, req, ok := bindTOTPCode(c)
+	if !ok {
+		return
+	}
+	resp, err := h.service.Verify(c.Request.Context(), userID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Disable handles POST /api/v1/auth/2fa/totp/disable
+func (h *TOTPHandler) Disable(c *gin.Context) {
+	userID, req, ok := bindTOTPCode(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Disable(c.Request.Context(), userID, req); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.TOTPStatusResponse{})
+}
+
+// RegenerateRecoveryCodes handles POST /api/v1/auth/2fa/totp/recovery-codes
+func (h *TOTPHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, req, ok := bindTOTPCode(c)
+	if !ok {
+		return
+	}
+	resp, err := h.service.RegenerateRecoveryCodes(c.Request.Context(), userID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func currentUser(c *gin.Context) (string, bool) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.Error(apperrors.Unauthorized("Missing bearer token"))
+	}
+	return userID, ok
+}
+
+func bindTOTPCode(c *gin.Context) (string, models.TOTPCodeRequest, bool) {
+	var req models.TOTPCodeRequest
+	userID, ok := currentUser(c)
+	if !ok {
+		return "", req, false
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return "", req, false
+	}
+	return userID, req, true
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/codeZe-us/vestroll-backend/internal/models"
 	"github.com/codeZe-us/vestroll-backend/internal/services"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,38 +28,31 @@ func (h *BusinessProfileHandler) RegisterRoutes(router *gin.RouterGroup) {
 func (h *BusinessProfileHandler) PostBusinessDetails(c *gin.Context) {
 	var req models.BusinessDetailsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+		c.Error(apperrors.Validation(err.Error()))
 		return
 	}
 
+	userID, ok := resolveUserID(c, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
 	// Only contractor supported for now
 	if req.AccountType != models.AccountTypeContractor {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: "unsupported account_type",
-		})
+		c.Error(apperrors.Validation("unsupported account_type"))
 		return
 	}
 
 	if err := h.service.ValidateContractor(req); err != nil {
-		status := http.StatusBadRequest
-		c.JSON(status, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
 	profile := h.service.BuildProfile(req)
 
 	if err := h.service.Save(c.Request.Context(), profile); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "internal_error",
-			Message: err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
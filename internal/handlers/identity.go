@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"github.com/codeZe-us/vestroll-backend/internal/middleware"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// resolveUserID determines which user the request acts on.
+// Authenticated requests always act as the token subject and a user_id supplied by
+// the client must match it. Unauthenticated requests only reach handlers in
+// compatibility mode, where the client-supplied user_id is still honoured.
+// It records the error for the error handler and returns false when no identity can be used.
+func resolveUserID(c *gin.Context, clientUserID string) (string, bool) {
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		if clientUserID != "" && clientUserID != userID {
+			c.Error(apperrors.Forbidden("user_id does not match the authenticated user"))
+			return "", false
+		}
+		return userID, true
+	}
+	if clientUserID == "" {
+		c.Error(apperrors.Unauthorized("Missing bearer token"))
+		return "", false
+	}
+	return clientUserID, true
+}
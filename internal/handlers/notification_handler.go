@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/services/notifier"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationHandler reports the delivery status of queued messages
+type NotificationHandler struct {
+	queue *notifier.Queue
+}
+
+func NewNotificationHandler(queue *notifier.Queue) *NotificationHandler {
+	return &NotificationHandler{queue: queue}
+}
+
+// RegisterRoutes registers the notification endpoints under /notifications
+func (h *NotificationHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/:id", h.GetStatus)
+}
+
+// GetStatus handles GET /api/v1/notifications/:id
+// Only the status is returned; recipients and content are never exposed
+func (h *NotificationHandler) GetStatus(c *gin.Context) {
+	job, err := h.queue.Status(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if job == nil {
+		c.Error(apperrors.NotFound("notification not found"))
+		return
+	}
+	c.JSON(http.StatusOK, models.NotificationStatusResponse{
+		ID:        job.ID,
+		Channel:   job.Channel,
+		Status:    job.Status,
+		Attempts:  job.Attempts,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	})
+}
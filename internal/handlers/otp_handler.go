@@ -5,103 +5,74 @@ import (
 
 	"github.com/codeZe-us/vestroll-backend/internal/models"
 	"github.com/codeZe-us/vestroll-backend/internal/services"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
 	"github.com/gin-gonic/gin"
 )
 
 type OTPHandler struct {
 	otpService *services.OTPService
+	tokens     *authservice.TokenService
 }
 
-func NewOTPHandler(otpService *services.OTPService) *OTPHandler {
+func NewOTPHandler(otpService *services.OTPService, tokens *authservice.TokenService) *OTPHandler {
 	return &OTPHandler{
 		otpService: otpService,
+		tokens:     tokens,
 	}
 }
 
 // SendOTP handles POST /api/auth/send-otp
 func (h *OTPHandler) SendOTP(c *gin.Context) {
 	var req models.OTPRequest
-	
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+		c.Error(apperrors.Validation(err.Error()))
 		return
 	}
 
-	if err := h.otpService.SendOTP(c.Request.Context(), req); err != nil {
-		// Determine status code based on error type
-		statusCode := http.StatusInternalServerError
-		errorCode := "internal_error"
-		
-		// Handle specific error cases
-		if err.Error() == "rate limit exceeded" || 
-		   err.Error()[:17] == "rate limit exceeded" {
-			statusCode = http.StatusTooManyRequests
-			errorCode = "rate_limit_exceeded"
-		} else if err.Error()[:7] == "invalid" {
-			statusCode = http.StatusBadRequest
-			errorCode = "validation_error"
-		} else if err.Error()[:13] == "SMS service is" || 
-				  err.Error()[:15] == "email service is" {
-			statusCode = http.StatusServiceUnavailable
-			errorCode = "service_unavailable"
-		}
+	if req.Locale == "" {
+		req.Locale = c.GetHeader("Accept-Language")
+	}
 
-		c.JSON(statusCode, models.ErrorResponse{
-			Error:   errorCode,
-			Message: err.Error(),
-		})
+	messageID, quota, err := h.otpService.SendOTP(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, models.OTPResponse{
-		Success: true,
-		Message: "OTP sent successfully",
+		Success:   true,
+		Message:   "OTP sent successfully",
+		MessageID: messageID,
+		OTPQuota:  quota,
 	})
 }
 
 // VerifyOTP handles POST /api/auth/verify-otp
 func (h *OTPHandler) VerifyOTP(c *gin.Context) {
 	var req models.OTPVerificationRequest
-	
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+		c.Error(apperrors.Validation(err.Error()))
 		return
 	}
 
 	if err := h.otpService.VerifyOTP(c.Request.Context(), req); err != nil {
-		// Determine status code based on error type
-		statusCode := http.StatusBadRequest
-		errorCode := "verification_failed"
-		
-		// Handle specific error cases
-		if err.Error() == "OTP not found or expired" ||
-		   err.Error() == "OTP has expired" {
-			errorCode = "otp_expired"
-		} else if err.Error() == "invalid OTP code" {
-			errorCode = "invalid_otp"
-		} else if err.Error() == "maximum verification attempts exceeded" {
-			statusCode = http.StatusTooManyRequests
-			errorCode = "max_attempts_exceeded"
-		} else if err.Error()[:7] == "invalid" {
-			errorCode = "validation_error"
-		}
+		c.Error(err)
+		return
+	}
 
-		c.JSON(statusCode, models.ErrorResponse{
-			Error:   errorCode,
-			Message: err.Error(),
-		})
+	// Issue a proof of verification that registration and sensitive actions can require
+	token, err := h.tokens.IssueVerificationToken(authservice.VerificationPurpose(req.Purpose, req.Type), req.Identifier)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, models.OTPVerificationResponse{
-		Success: true,
-		Message: "OTP verified successfully",
+		Success:   true,
+		Message:   "OTP verified successfully",
+		Token:     token,
+		ExpiresIn: int64(h.tokens.VerificationTTL().Seconds()),
 	})
 }
 
@@ -109,4 +80,4 @@ func (h *OTPHandler) VerifyOTP(c *gin.Context) {
 func (h *OTPHandler) RegisterRoutes(router *gin.RouterGroup) {
 	router.POST("/send-otp", h.SendOTP)
 	router.POST("/verify-otp", h.VerifyOTP)
-}
\ No newline at end of file
+}
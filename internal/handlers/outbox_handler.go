@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/codeZe-us/vestroll-backend/internal/services/notifier"
+	"github.com/gin-gonic/gin"
+)
+
+// OutboxHandler exposes messages captured by the development outbox provider
+// so QA and integration tests can read codes without real SMS/email
+type OutboxHandler struct {
+	outbox *notifier.Outbox
+}
+
+func NewOutboxHandler(outbox *notifier.Outbox) *OutboxHandler {
+	return &OutboxHandler{outbox: outbox}
+}
+
+// RegisterRoutes registers the outbox endpoints under /dev
+func (h *OutboxHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/outbox", h.List)
+	router.DELETE("/outbox", h.Clear)
+}
+
+// List handles GET /dev/outbox?to=<recipient>, newest message first
+func (h *OutboxHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"messages": h.outbox.Messages(c.Query("to"))})
+}
+
+// Clear handles DELETE /dev/outbox
+func (h *OutboxHandler) Clear(c *gin.Context) {
+	h.outbox.Clear()
+	c.Status(http.StatusNoContent)
+}
@@ -2,71 +2,146 @@ package handlers
 
 import (
 	"net/http"
-	"strings"
 
+	"github.com/codeZe-us/vestroll-backend/internal/middleware"
 	"github.com/codeZe-us/vestroll-backend/internal/models"
 	"github.com/codeZe-us/vestroll-backend/internal/services"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
 	"github.com/gin-gonic/gin"
 )
 
 // PINHandler manages PIN setup and authentication endpoints
 type PINHandler struct {
-	service *services.PINService
+	service  *services.PINService
+	devices  *authservice.DeviceService
+	sessions *authservice.SessionService
+	tokens   *authservice.TokenService
 }
 
-func NewPINHandler(service *services.PINService) *PINHandler {
-	return &PINHandler{service: service}
+func NewPINHandler(service *services.PINService, devices *authservice.DeviceService, sessions *authservice.SessionService, tokens *authservice.TokenService) *PINHandler {
+	return &PINHandler{service: service, devices: devices, sessions: sessions, tokens: tokens}
 }
 
 // RegisterRoutes registers PIN endpoints under /auth
-func (h *PINHandler) RegisterRoutes(router *gin.RouterGroup) {
-	router.POST("/setup-pin", h.SetupPIN)
+// login-pin starts a session so it is public; requireAuth guards the rest and
+// requireStepUp guards setting the PIN when the user has a second factor
+func (h *PINHandler) RegisterRoutes(router *gin.RouterGroup, requireAuth, requireStepUp gin.HandlerFunc) {
+	router.POST("/setup-pin", requireAuth, requireStepUp, h.SetupPIN)
 	router.POST("/login-pin", h.LoginPIN)
+	router.POST("/verify-pin", requireAuth, h.VerifyPIN)
+	router.POST("/change-pin", requireAuth, requireStepUp, h.ChangePIN)
+	router.POST("/reset-pin", requireAuth, requireStepUp, middleware.RequireVerification(h.tokens, authservice.PurposePINReset), h.ResetPIN)
 }
 
 // SetupPIN handles POST /api/auth/setup-pin
 func (h *PINHandler) SetupPIN(c *gin.Context) {
 	var req models.SetupPINRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		c.Error(apperrors.Validation(err.Error()))
 		return
 	}
+	userID, ok := resolveUserID(c, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
 	if err := h.service.SetupPIN(c.Request.Context(), req); err != nil {
-		status := http.StatusBadRequest
-		code := "validation_error"
-		// Adjust status codes for known error types
-		if strings.Contains(err.Error(), "internal") {
-			status = http.StatusInternalServerError
-			code = "internal_error"
-		}
-		c.JSON(status, models.ErrorResponse{Error: code, Message: err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, models.SetupPINResponse{Success: true, Message: "PIN setup successful"})
 }
 
-// LoginPIN handles POST /api/auth/login-pin
+// LoginPIN handles POST /api/v1/auth/login-pin
+// The registered device must sign its challenge before the PIN is checked;
+// a correct PIN then starts a session bound to that device
 func (h *PINHandler) LoginPIN(c *gin.Context) {
 	var req models.LoginPINRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "validation_error", Message: err.Error()})
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	if req.UserID == "" {
+		c.Error(apperrors.Validation("user_id is required"))
+		return
+	}
+	if err := h.devices.VerifyChallenge(c.Request.Context(), req.UserID, req.DeviceID, req.Challenge, req.Signature); err != nil {
+		c.Error(err)
 		return
 	}
 	if err := h.service.LoginPIN(c.Request.Context(), req); err != nil {
-		status := http.StatusUnauthorized
-		code := "invalid_pin"
-		if err.Error() == "pin not set" {
-			status = http.StatusNotFound
-			code = "not_found"
-		} else if strings.HasPrefix(err.Error(), "user_") || strings.HasPrefix(err.Error(), "pin") || strings.HasPrefix(err.Error(), "invalid pin") {
-			status = http.StatusBadRequest
-			code = "validation_error"
-		} else if strings.Contains(err.Error(), "internal") {
-			status = http.StatusInternalServerError
-			code = "internal_error"
-		}
-		c.JSON(status, models.ErrorResponse{Error: code, Message: err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, models.LoginPINResponse{Success: true, Message: "PIN authentication successful"})
-}
\ No newline at end of file
+		c.Error(err)
+		return
+	}
+	pair, err := h.sessions.IssueForDevice(c.Request.Context(), req.UserID, req.DeviceID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.LoginPINResponse{Success: true, Message: "PIN authentication successful", TokenPair: pair})
+}
+
+// VerifyPIN handles POST /api/v1/auth/verify-pin
+// A correct PIN returns a short-lived elevated token for RequireElevated routes
+func (h *PINHandler) VerifyPIN(c *gin.Context) {
+	var req models.VerifyPINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.Error(apperrors.Unauthorized("Missing bearer token"))
+		return
+	}
+	if err := h.service.VerifyPIN(c.Request.Context(), userID, req.PIN); err != nil {
+		c.Error(err)
+		return
+	}
+	token, err := h.tokens.IssueElevatedToken(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.VerifyPINResponse{ElevatedToken: token, ExpiresIn: int(h.tokens.ElevatedTTL().Seconds())})
+}
+
+// ChangePIN handles POST /api/v1/auth/change-pin
+func (h *PINHandler) ChangePIN(c *gin.Context) {
+	var req models.ChangePINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.Error(apperrors.Unauthorized("Missing bearer token"))
+		return
+	}
+	if err := h.service.ChangePIN(c.Request.Context(), userID, req); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.SetupPINResponse{Success: true, Message: "PIN changed"})
+}
+
+// ResetPIN handles POST /api/v1/auth/reset-pin
+func (h *PINHandler) ResetPIN(c *gin.Context) {
+	var req models.ResetPINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.Error(apperrors.Unauthorized("Missing bearer token"))
+		return
+	}
+	verified, _ := middleware.VerifiedIdentifier(c)
+	if err := h.service.ResetPIN(c.Request.Context(), userID, verified, req.NewPIN); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.SetupPINResponse{Success: true, Message: "PIN reset"})
+}
@@ -5,6 +5,7 @@ import (
 
     "github.com/codeZe-us/vestroll-backend/internal/models"
     "github.com/codeZe-us/vestroll-backend/internal/services"
+    apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
     "github.com/gin-gonic/gin"
 )
 
@@ -20,6 +21,7 @@ func (h *ProfileHandler) RegisterRoutes(router *gin.RouterGroup) {
     router.POST("/account-type", h.PostAccountType)
     router.POST("/personal-details", h.PostPersonalDetails)
     router.POST("/address", h.PostAddress)
+    router.POST("/locale", h.PostLocale)
     router.GET("/status", h.GetStatus)
 }
 
@@ -27,12 +29,15 @@ func (h *ProfileHandler) RegisterRoutes(router *gin.RouterGroup) {
 func (h *ProfileHandler) PostAccountType(c *gin.Context) {
     var req models.AccountTypeRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "validation_error", Message: err.Error()})
+        c.Error(apperrors.Validation(err.Error()))
         return
     }
+    userID, ok := resolveUserID(c, req.UserID)
+    if !ok { return }
+    req.UserID = userID
     prof, err := h.service.UpdateAccountType(c.Request.Context(), req)
     if err != nil {
-        c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "validation_error", Message: err.Error()})
+        c.Error(err)
         return
     }
     c.JSON(http.StatusOK, models.ProfileResponse{Success: true, Message: "Account type saved", Profile: prof})
@@ -42,12 +47,15 @@ func (h *ProfileHandler) PostAccountType(c *gin.Context) {
 func (h *ProfileHandler) PostPersonalDetails(c *gin.Context) {
     var req models.PersonalDetailsRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "validation_error", Message: err.Error()})
+        c.Error(apperrors.Validation(err.Error()))
         return
     }
+    userID, ok := resolveUserID(c, req.UserID)
+    if !ok { return }
+    req.UserID = userID
     prof, err := h.service.UpdatePersonalDetails(c.Request.Context(), req)
     if err != nil {
-        c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "validation_error", Message: err.Error()})
+        c.Error(err)
         return
     }
     c.JSON(http.StatusOK, models.ProfileResponse{Success: true, Message: "Personal details saved", Profile: prof})
@@ -57,28 +65,46 @@ func (h *ProfileHandler) PostPersonalDetails(c *gin.Context) {
 func (h *ProfileHandler) PostAddress(c *gin.Context) {
     var req models.AddressRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "validation_error", Message: err.Error()})
+        c.Error(apperrors.Validation(err.Error()))
         return
     }
+    userID, ok := resolveUserID(c, req.UserID)
+    if !ok { return }
+    req.UserID = userID
     prof, err := h.service.UpdateAddress(c.Request.Context(), req)
     if err != nil {
-        c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "validation_error", Message: err.Error()})
+        c.Error(err)
         return
     }
     c.JSON(http.StatusOK, models.ProfileResponse{Success: true, Message: "Address saved", Profile: prof})
 }
 
-// GET /api/profile/status?user_id=...
+// GET /api/profile/status (user_id query parameter only honoured in compatibility mode)
 func (h *ProfileHandler) GetStatus(c *gin.Context) {
-    userID := c.Query("user_id")
-    if userID == "" {
-        c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "validation_error", Message: "user_id is required"})
-        return
-    }
+    userID, ok := resolveUserID(c, c.Query("user_id"))
+    if !ok { return }
     prof, err := h.service.GetProfile(c.Request.Context(), userID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "internal_error", Message: err.Error()})
+        c.Error(err)
         return
     }
     c.JSON(http.StatusOK, models.ProfileResponse{Success: true, Message: "Profile status", Profile: prof})
 }
+
+// POST /api/profile/locale
+func (h *ProfileHandler) PostLocale(c *gin.Context) {
+    var req models.LocaleRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.Error(apperrors.Validation(err.Error()))
+        return
+    }
+    userID, ok := resolveUserID(c, req.UserID)
+    if !ok { return }
+    req.UserID = userID
+    prof, err := h.service.UpdateLocale(c.Request.Context(), req)
+    if err != nil {
+        c.Error(err)
+        return
+    }
+    c.JSON(http.StatusOK, models.ProfileResponse{Success: true, Message: "Locale saved", Profile: prof})
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// UserIDKey is the gin context key holding the authenticated user ID
+const UserIDKey = "auth_user_id"
+
+// TokenValidator validates access tokens; *auth.SessionService also rejects
+// tokens whose session has been revoked
+type TokenValidator interface {
+	ValidateAccessToken(ctx context.Context, token string) (*authservice.Claims, error)
+}
+
+type userIDContextKey struct{}
+
+// Authenticate validates "Authorization: Bearer <token>" access tokens and stores
+// the token subject in both the gin context and the request context.
+// With compatMode enabled, requests without an Authorization header are let through
+// unauthenticated so legacy clients sending user_id in the body keep working;
+// a header that is present but invalid is always rejected.
+func Authenticate(tokens TokenValidator, compatMode bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			if compatMode {
+				c.Next()
+				return
+			}
+			abortWithError(c, apperrors.Unauthorized("Missing bearer token"))
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			abortWithError(c, apperrors.Unauthorized("Authorization header must use the Bearer scheme"))
+			return
+		}
+
+		claims, err := tokens.ValidateAccessToken(c.Request.Context(), strings.TrimSpace(token))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set(UserIDKey, claims.Subject)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), userIDContextKey{}, claims.Subject))
+		c.Next()
+	}
+}
+
+// CurrentUserID returns the authenticated user ID set by Authenticate
+func CurrentUserID(c *gin.Context) (string, bool) {
+	id := c.GetString(UserIDKey)
+	return id, id != ""
+}
+
+// UserIDFromContext returns the authenticated user ID from a request context
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(userIDContextKey{}).(string)
+	return id, ok && id != ""
+}
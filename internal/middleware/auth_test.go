@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func newAuthRouter(compatMode bool) (*gin.Engine, *authservice.TokenService) {
+	gin.SetMode(gin.TestMode)
+	tokens := authservice.NewTokenService(config.JWTConfig{Secret: "test-secret", TTL: time.Hour})
+	r := gin.New()
+	r.GET("/me", Authenticate(tokens, compatMode), func(c *gin.Context) {
+		id, _ := CurrentUserID(c)
+		ctxID, _ := UserIDFromContext(c.Request.Context())
+		c.String(http.StatusOK, id+"|"+ctxID)
+	})
+	return r, tokens
+}
+
+func doGet(r http.Handler, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthenticateSetsSubject(t *testing.T) {
+	r, tokens := newAuthRouter(false)
+	token, err := tokens.IssueAccessToken("u1", "s1")
+	if err != nil {
+		t.Fatalf("IssueAccessToken error: %v", err)
+	}
+
+	w := doGet(r, "Bearer "+token)
+	if w.Code != http.StatusOK || w.Body.String() != "u1|u1" {
+		t.Fatalf("expected 200 u1|u1, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthenticateRejectsMissingAndInvalidTokens(t *testing.T) {
+	strict, _ := newAuthRouter(false)
+	if w := doGet(strict, ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", w.Code)
+	}
+
+	compat, _ := newAuthRouter(true)
+	if w := doGet(compat, ""); w.Code != http.StatusOK || w.Body.String() != "|" {
+		t.Fatalf("expected anonymous pass-through in compat mode, got %d %q", w.Code, w.Body.String())
+	}
+	if w := doGet(compat, "Bearer not-a-jwt"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for invalid token even in compat mode, got %d", w.Code)
+	}
+	if w := doGet(compat, "Basic abc"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for non-bearer scheme, got %d", w.Code)
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler renders the last error a handler attached with c.Error, mapping
+// pkg/errors kinds to status codes and models.ErrorResponse bodies
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		RenderError(c, c.Errors.Last().Err)
+	}
+}
+
+// RenderError writes the error response for err. Errors without a kind are
+// treated as internal and their details are logged instead of returned.
+func RenderError(c *gin.Context, err error) {
+	status := apperrors.HTTPStatus(err)
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("internal error on %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		message = "An internal error occurred"
+	}
+	c.JSON(status, models.ErrorResponse{Error: apperrors.ResponseCode(err), Message: message})
+}
+
+// abortWithError stops the chain and renders err; used by middleware that runs before handlers
+func abortWithError(c *gin.Context, err error) {
+	c.Abort()
+	RenderError(c, err)
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+func renderVia(err error) (*httptest.ResponseRecorder, models.ErrorResponse) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.GET("/", func(c *gin.Context) { c.Error(err) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var body models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func TestErrorHandlerRendersTypedErrors(t *testing.T) {
+	// A short message used to panic the old prefix slicing in the OTP handler
+	w, body := renderVia(apperrors.RateLimited("rate limit exceeded"))
+	if w.Code != http.StatusTooManyRequests || body.Error != "rate_limit_exceeded" || body.Message != "rate limit exceeded" {
+		t.Fatalf("unexpected response %d %+v", w.Code, body)
+	}
+
+	w, body = renderVia(fmt.Errorf("invalid input: %w", apperrors.Validation("phone is required")))
+	if w.Code != http.StatusBadRequest || body.Error != "validation_error" || body.Message != "invalid input: phone is required" {
+		t.Fatalf("unexpected response %d %+v", w.Code, body)
+	}
+}
+
+func TestErrorHandlerHidesInternalErrors(t *testing.T) {
+	w, body := renderVia(errors.New("redis: connection refused"))
+	if w.Code != http.StatusInternalServerError || body.Error != "internal_error" {
+		t.Fatalf("unexpected response %d %+v", w.Code, body)
+	}
+	if body.Message == "redis: connection refused" {
+		t.Fatalf("expected internal details not to be returned to the client")
+	}
+}
+
+func TestErrorHandlerLeavesWrittenResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.GET("/", func(c *gin.Context) {
+		c.Error(apperrors.NotFound("ignored"))
+		c.String(http.StatusOK, "ok")
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Fatalf("expected handler response to be kept, got %d %q", w.Code, w.Body.String())
+	}
+}
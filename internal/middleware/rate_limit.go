@@ -1,27 +1,34 @@
 package middleware
 
 import (
-	"net/http"
 	"sync"
 	"time"
 
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
 
-// RateLimiter implements token bucket rate limiting
+// RateLimiter implements token bucket rate limiting in process memory. Each
+// replica keeps its own buckets; PolicyRateLimiter shares limits through Redis.
 type RateLimiter struct {
-	limiters map[string]*rate.Limiter
+	limiters map[string]*bucket
 	mu       sync.RWMutex
 	rate     rate.Limit
 	burst    int
 	cleanup  time.Duration
 }
 
+// bucket is a key's limiter and when it was last used
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
 // NewRateLimiter creates a new rate limiter
 func NewRateLimiter(rps float64, burst int) *RateLimiter {
 	rl := &RateLimiter{
-		limiters: make(map[string]*rate.Limiter),
+		limiters: make(map[string]*bucket),
 		rate:     rate.Limit(rps),
 		burst:    burst,
 		cleanup:  time.Hour, // Clean up old limiters every hour
@@ -33,23 +40,18 @@ func NewRateLimiter(rps float64, burst int) *RateLimiter {
 	return rl
 }
 
-// getLimiter gets or creates a limiter for the given key
+// getLimiter gets or creates a limiter for the given key and marks it used
 func (rl *RateLimiter) getLimiter(key string) *rate.Limiter {
-	rl.mu.RLock()
-	limiter, exists := rl.limiters[key]
-	rl.mu.RUnlock()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 
+	b, exists := rl.limiters[key]
 	if !exists {
-		rl.mu.Lock()
-		// Double check in case another goroutine created it
-		if limiter, exists = rl.limiters[key]; !exists {
-			limiter = rate.NewLimiter(rl.rate, rl.burst)
-			rl.limiters[key] = limiter
-		}
-		rl.mu.Unlock()
+		b = &bucket{limiter: rate.NewLimiter(rl.rate, rl.burst)}
+		rl.limiters[key] = b
 	}
-
-	return limiter
+	b.lastSeen = time.Now()
+	return b.limiter
 }
 
 // Allow checks if the request is allowed
@@ -64,9 +66,9 @@ func (rl *RateLimiter) cleanupRoutine() {
 
 	for range ticker.C {
 		rl.mu.Lock()
-		for key, limiter := range rl.limiters {
-			// Remove limiters that haven't been used recently
-			if limiter.Tokens() == float64(rl.burst) {
+		for key, b := range rl.limiters {
+			// Remove limiters that haven't been used since the last sweep
+			if time.Since(b.lastSeen) > rl.cleanup {
 				delete(rl.limiters, key)
 			}
 		}
@@ -83,11 +85,7 @@ func RateLimitMiddleware(rps float64, burst int) gin.HandlerFunc {
 		key := c.ClientIP()
 		
 		if !limiter.Allow(key) {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":   "rate_limit_exceeded",
-				"message": "Too many requests. Please try again later.",
-			})
-			c.Abort()
+			abortWithError(c, apperrors.RateLimited("Too many requests. Please try again later."))
 			return
 		}
 
@@ -96,7 +94,7 @@ func RateLimitMiddleware(rps float64, burst int) gin.HandlerFunc {
 }
 
 // OTPRateLimitMiddleware creates a rate limiting middleware specifically for OTP endpoints
-func OTPRateLimitMiddleware() gin.HandlerFunc {
-	// Allow 10 requests per minute for OTP endpoints
-	return RateLimitMiddleware(10.0/60.0, 10)
+func OTPRateLimitMiddleware(perMinute int) gin.HandlerFunc {
+	// Allow perMinute requests per minute, all of which may arrive at once
+	return RateLimitMiddleware(float64(perMinute)/60.0, perMinute)
 }
\ No newline at end of file
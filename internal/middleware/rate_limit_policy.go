@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// What a rate limit rule counts requests by
+const (
+	LimitByIP         = "ip"
+	LimitByUser       = "user"
+	LimitByIdentifier = "identifier"
+	LimitByAPIKey     = "api_key"
+)
+
+// APIKeyHeader carries the key that api_key rules count by
+const APIKeyHeader = "X-API-Key"
+
+// maxIdentifierBody is how much of a JSON body is read to find the identifier
+const maxIdentifierBody = 64 << 10
+
+// RateLimitRule allows Limit requests per Period for each value of By
+type RateLimitRule struct {
+	By     string
+	Limit  int
+	Period time.Duration
+}
+
+// RateLimitPolicy applies its rules to Route: an exact route path such as
+// "/api/v1/auth/send-otp", a prefix ending in "/*", or "*" for every route
+type RateLimitPolicy struct {
+	Route string
+	Rules []RateLimitRule
+}
+
+// ParseRateLimitPolicies parses "route=by:limit/period[,by:limit/period];route=...",
+// e.g. "/api/v1/auth/*=ip:10/1m;/api/v1/auth/send-otp=ip:10/1m,identifier:5/15m"
+func ParseRateLimitPolicies(spec string) ([]RateLimitPolicy, error) {
+	var policies []RateLimitPolicy
+	for _, part := range strings.Split(spec, ";") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		route, rules, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("rate limit policy %q must look like /route=ip:10/1m", part)
+		}
+		policy := RateLimitPolicy{Route: strings.TrimSpace(route)}
+		for _, rule := range strings.Split(rules, ",") {
+			parsed, err := parseRateLimitRule(strings.TrimSpace(rule))
+			if err != nil {
+				return nil, fmt.Errorf("rate limit policy %q: %w", policy.Route, err)
+			}
+			policy.Rules = append(policy.Rules, parsed)
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func parseRateLimitRule(rule string) (RateLimitRule, error) {
+	by, quota, ok := strings.Cut(rule, ":")
+	count, period, ok2 := strings.Cut(quota, "/")
+	if !ok || !ok2 {
+		return RateLimitRule{}, fmt.Errorf("rule %q must look like ip:10/1m", rule)
+	}
+	switch by {
+	case LimitByIP, LimitByUser, LimitByIdentifier, LimitByAPIKey:
+	default:
+		return RateLimitRule{}, fmt.Errorf("rule %q: unknown key %q", rule, by)
+	}
+	limit, err := strconv.Atoi(count)
+	if err != nil || limit <= 0 {
+		return RateLimitRule{}, fmt.Errorf("rule %q: limit must be a positive number", rule)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimitRule{}, fmt.Errorf("rule %q: period must be a duration such as 1m", rule)
+	}
+	return RateLimitRule{By: by, Limit: limit, Period: d}, nil
+}
+
+// PolicyRateLimiter enforces per-route policies against a shared store, so limits
+// hold across replicas and restarts. It sets RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy on every limited response, and Retry-After
+// when a request is refused. If the store fails, requests are let through.
+type PolicyRateLimiter struct {
+	store    repository.RateLimitStore
+	policies []RateLimitPolicy
+	// tokens identifies callers for user rules when the limiter runs before Authenticate; may be nil
+	tokens TokenValidator
+	now    func() time.Time
+}
+
+func NewPolicyRateLimiter(store repository.RateLimitStore, policies []RateLimitPolicy, tokens TokenValidator) *PolicyRateLimiter {
+	return &PolicyRateLimiter{store: store, policies: policies, tokens: tokens, now: time.Now}
+}
+
+// policyFor picks the exact route, else the longest matching prefix, else "*"
+func (l *PolicyRateLimiter) policyFor(route string) *RateLimitPolicy {
+	var best *RateLimitPolicy
+	bestLen := -1
+	for i := range l.policies {
+		p := &l.policies[i]
+		switch {
+		case p.Route == route && route != "":
+			return p
+		case p.Route == "*" && bestLen < 0:
+			best, bestLen = p, 0
+		case strings.HasSuffix(p.Route, "/*") && strings.HasPrefix(route, strings.TrimSuffix(p.Route, "*")) && len(p.Route) > bestLen:
+			best, bestLen = p, len(p.Route)
+		}
+	}
+	return best
+}
+
+// Middleware limits requests by the policy matching their route
+func (l *PolicyRateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := l.policyFor(c.FullPath())
+		if policy == nil {
+			c.Next()
+			return
+		}
+
+		now := l.now()
+		var tightest *models.RateLimitResult
+		var tightestRule RateLimitRule
+		for _, rule := range policy.Rules {
+			key := fmt.Sprintf("%s:%s:%s", policy.Route, rule.By, l.keyFor(c, rule.By))
+			res, err := l.store.Take(c.Request.Context(), key, rule.Limit, rule.Period, now)
+			if err != nil {
+				log.Printf("rate limiter unavailable, allowing request: %v", err)
+				continue
+			}
+			if !res.Allowed {
+				setRateLimitHeaders(c, res, rule)
+				c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				abortWithError(c, apperrors.RateLimited("Too many requests. Please try again later."))
+				return
+			}
+			if tightest == nil || res.Remaining < tightest.Remaining {
+				tightest, tightestRule = &res, rule
+			}
+		}
+		if tightest != nil {
+			setRateLimitHeaders(c, *tightest, tightestRule)
+		}
+		c.Next()
+	}
+}
+
+// keyFor returns the value a rule counts by, falling back to the client IP when
+// the request carries no user, identifier or API key
+func (l *PolicyRateLimiter) keyFor(c *gin.Context, by string) string {
+	switch by {
+	case LimitByUser:
+		if id, ok := CurrentUserID(c); ok {
+			return id
+		}
+		if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && l.tokens != nil {
+			if claims, err := l.tokens.ValidateAccessToken(c.Request.Context(), strings.TrimSpace(token)); err == nil {
+				return claims.Subject
+			}
+		}
+	case LimitByIdentifier:
+		if identifier := bodyIdentifier(c); identifier != "" {
+			return hashKey(strings.ToLower(identifier))
+		}
+	case LimitByAPIKey:
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			return hashKey(key)
+		}
+	}
+	return "ip-" + c.ClientIP()
+}
+
+// bodyIdentifier reads "identifier" from a JSON body and restores the body for the handler
+func bodyIdentifier(c *gin.Context) string {
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return ""
+	}
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIdentifierBody))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+	if err != nil {
+		return ""
+	}
+	var body struct {
+		Identifier string `json:"identifier"`
+	}
+	if json.Unmarshal(head, &body) != nil {
+		return ""
+	}
+	return strings.TrimSpace(body.Identifier)
+}
+
+// hashKey keeps phone numbers, emails and API keys out of Redis key names
+func hashKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:16])
+}
+
+func setRateLimitHeaders(c *gin.Context, res models.RateLimitResult, rule RateLimitRule) {
+	c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Limit, ceilSeconds(rule.Period)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
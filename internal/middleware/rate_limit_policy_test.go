@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func newLimitedRouter(t *testing.T, spec string) (*gin.Engine, *PolicyRateLimiter, *authservice.TokenService) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	policies, err := ParseRateLimitPolicies(spec)
+	if err != nil {
+		t.Fatalf("ParseRateLimitPolicies error: %v", err)
+	}
+	tokens := authservice.NewTokenService(config.JWTConfig{Secret: "test-secret", TTL: time.Hour})
+	limiter := NewPolicyRateLimiter(memory.NewRateLimits(), policies, tokens)
+	clock := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return clock }
+
+	r := gin.New()
+	r.Use(limiter.Middleware())
+	ok := func(c *gin.Context) {
+		var body struct {
+			Identifier string `json:"identifier"`
+		}
+		c.ShouldBindJSON(&body)
+		c.String(http.StatusOK, body.Identifier)
+	}
+	r.POST("/api/v1/auth/send-otp", ok)
+	r.POST("/api/v1/auth/login", ok)
+	r.GET("/api/v1/me", ok)
+	r.GET("/health", ok)
+	return r, limiter, tokens
+}
+
+func doRequest(r http.Handler, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.RemoteAddr = "203.0.113.7:1234"
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestParseRateLimitPolicies(t *testing.T) {
+	policies, err := ParseRateLimitPolicies("/api/v1/auth/*=ip:10/1m; /api/v1/auth/send-otp=ip:10/1m,identifier:5/15m")
+	if err != nil {
+		t.Fatalf("ParseRateLimitPolicies error: %v", err)
+	}
+	if len(policies) != 2 || len(policies[1].Rules) != 2 || policies[1].Rules[1] != (RateLimitRule{By: LimitByIdentifier, Limit: 5, Period: 15 * time.Minute}) {
+		t.Fatalf("policies = %+v", policies)
+	}
+	for _, bad := range []string{"/x", "/x=ip", "/x=cookie:1/1m", "/x=ip:0/1m", "/x=ip:1/soon"} {
+		if _, err := ParseRateLimitPolicies(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestPolicyRateLimiterByIP(t *testing.T) {
+	r, limiter, _ := newLimitedRouter(t, "/api/v1/auth/*=ip:2/1m")
+
+	first := doRequest(r, http.MethodPost, "/api/v1/auth/login", `{}`, nil)
+	if first.Code != http.StatusOK || first.Header().Get("RateLimit-Limit") != "2" || first.Header().Get("RateLimit-Remaining") != "1" ||
+		first.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Fatalf("first response = %d %v", first.Code, first.Header())
+	}
+	// Routes under the same prefix share the bucket
+	doRequest(r, http.MethodPost, "/api/v1/auth/send-otp", `{}`, nil)
+	denied := doRequest(r, http.MethodPost, "/api/v1/auth/login", `{}`, nil)
+	if denied.Code != http.StatusTooManyRequests || denied.Header().Get("Retry-After") != "30" || denied.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("denied response = %d %v", denied.Code, denied.Header())
+	}
+	// Routes without a policy are not limited
+	if w := doRequest(r, http.MethodGet, "/health", "", nil); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("unlimited route = %d %v", w.Code, w.Header())
+	}
+
+	later := limiter.now().Add(30 * time.Second)
+	limiter.now = func() time.Time { return later }
+	if w := doRequest(r, http.MethodPost, "/api/v1/auth/login", `{}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected a request to be allowed after Retry-After, got %d", w.Code)
+	}
+}
+
+func TestPolicyRateLimiterIgnoresSpoofedForwardedFor(t *testing.T) {
+	r, _, _ := newLimitedRouter(t, "/api/v1/auth/*=ip:1/1m")
+	r.SetTrustedProxies(nil)
+
+	doRequest(r, http.MethodPost, "/api/v1/auth/login", `{}`, map[string]string{"X-Forwarded-For": "198.51.100.1"})
+	w := doRequest(r, http.MethodPost, "/api/v1/auth/login", `{}`, map[string]string{"X-Forwarded-For": "198.51.100.2"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("a new X-Forwarded-For from an untrusted peer must not reset the limit, got %d", w.Code)
+	}
+
+	// Behind a trusted proxy each forwarded client gets its own bucket
+	r, _, _ = newLimitedRouter(t, "/api/v1/auth/*=ip:1/1m")
+	r.SetTrustedProxies([]string{"203.0.113.7"})
+	doRequest(r, http.MethodPost, "/api/v1/auth/login", `{}`, map[string]string{"X-Forwarded-For": "198.51.100.1"})
+	if w := doRequest(r, http.MethodPost, "/api/v1/auth/login", `{}`, map[string]string{"X-Forwarded-For": "198.51.100.2"}); w.Code != http.StatusOK {
+		t.Fatalf("expected a separate bucket per forwarded client, got %d", w.Code)
+	}
+}
+
+func TestPolicyRateLimiterByIdentifier(t *testing.T) {
+	r, _, _ := newLimitedRouter(t, "/api/v1/auth/*=ip:100/1m;/api/v1/auth/send-otp=identifier:1/15m")
+
+	w := doRequest(r, http.MethodPost, "/api/v1/auth/send-otp", `{"identifier":"+2348012345678"}`, nil)
+	// The handler still sees the body after the limiter read it
+	if w.Code != http.StatusOK || w.Body.String() != "+2348012345678" {
+		t.Fatalf("first send = %d %q", w.Code, w.Body.String())
+	}
+	if w := doRequest(r, http.MethodPost, "/api/v1/auth/send-otp", `{"identifier":"+2348012345678"}`, nil); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the same identifier to be limited, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodPost, "/api/v1/auth/send-otp", `{"identifier":"+2348012345679"}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected another identifier to be allowed, got %d", w.Code)
+	}
+	// The exact route policy replaces the prefix one
+	if w := doRequest(r, http.MethodPost, "/api/v1/auth/login", `{}`, nil); w.Header().Get("RateLimit-Limit") != "100" {
+		t.Fatalf("login should use the prefix policy, got %v", w.Header())
+	}
+}
+
+func TestPolicyRateLimiterByUserAndAPIKey(t *testing.T) {
+	r, _, tokens := newLimitedRouter(t, "*=user:1/1m")
+	alice, _ := tokens.IssueAccessToken("alice", "s1")
+	bob, _ := tokens.IssueAccessToken("bob", "s2")
+
+	if w := doRequest(r, http.MethodGet, "/api/v1/me", "", map[string]string{"Authorization": "Bearer " + alice}); w.Code != http.StatusOK {
+		t.Fatalf("alice first = %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodGet, "/api/v1/me", "", map[string]string{"Authorization": "Bearer " + alice}); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("alice second = %d; want 429", w.Code)
+	}
+	// Same IP, different user
+	if w := doRequest(r, http.MethodGet, "/api/v1/me", "", map[string]string{"Authorization": "Bearer " + bob}); w.Code != http.StatusOK {
+		t.Fatalf("bob = %d", w.Code)
+	}
+
+	r, _, _ = newLimitedRouter(t, "*=api_key:1/1m")
+	if w := doRequest(r, http.MethodGet, "/health", "", map[string]string{APIKeyHeader: "k1"}); w.Code != http.StatusOK {
+		t.Fatalf("k1 first = %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodGet, "/health", "", map[string]string{APIKeyHeader: "k1"}); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("k1 second = %d; want 429", w.Code)
+	}
+	if w := doRequest(r, http.MethodGet, "/health", "", map[string]string{APIKeyHeader: "k2"}); w.Code != http.StatusOK {
+		t.Fatalf("k2 = %d", w.Code)
+	}
+}
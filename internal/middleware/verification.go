@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// VerificationHeader carries the token returned by verify-otp
+const VerificationHeader = "X-Verification-Token"
+
+// VerifiedIdentifierKey is the gin context key holding the identifier proven by the token
+const VerifiedIdentifierKey = "verified_identifier"
+
+// RequireVerification demands a verify-otp token for the given purpose
+// (e.g. phone_verified) before sensitive actions. Each token is redeemed on
+// first use. Handlers read the proven phone/email with VerifiedIdentifier and
+// must check it matches what they act on.
+func RequireVerification(tokens *authservice.TokenService, purpose string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := RedeemVerification(c, tokens, purpose); !ok {
+			return
+		}
+		c.Next()
+	}
+}
+
+// RedeemVerification redeems the verify-otp token like RequireVerification, for
+// handlers that validate their body first so a bad request does not use it up.
+// It returns the proven identifier, or aborts with an error and returns false.
+func RedeemVerification(c *gin.Context, tokens *authservice.TokenService, purpose string) (string, bool) {
+	token := c.GetHeader(VerificationHeader)
+	if token == "" {
+		abortWithError(c, apperrors.Forbidden("Missing "+VerificationHeader+" header").WithCode("verification_required"))
+		return "", false
+	}
+	claims, err := tokens.ConsumeVerificationToken(c.Request.Context(), token, purpose)
+	if err != nil {
+		if !errors.Is(err, authservice.ErrInvalidToken) {
+			abortWithError(c, err)
+			return "", false
+		}
+		abortWithError(c, apperrors.Forbidden("Verification token is invalid, expired, already used or for a different purpose").WithCode("verification_required"))
+		return "", false
+	}
+	c.Set(VerifiedIdentifierKey, claims.Subject)
+	return claims.Subject, true
+}
+
+// VerifiedIdentifier returns the identifier proven by RequireVerification
+func VerifiedIdentifier(c *gin.Context) (string, bool) {
+	id := c.GetString(VerifiedIdentifierKey)
+	return id, id != ""
+}
+
+// StepUpHeader carries the token returned by POST /api/v1/auth/2fa/totp/verify
+const StepUpHeader = "X-Step-Up-Token"
+
+// SecondFactors reports whether a user has a second factor enrolled
+type SecondFactors interface {
+	Enabled(ctx context.Context, userID string) (bool, error)
+}
+
+// RequireStepUp demands a recent second-factor verification for the signed-in
+// user before sensitive actions. Users without a second factor pass through,
+// as do anonymous compat-mode requests. Must run after Authenticate.
+func RequireStepUp(tokens *authservice.TokenService, factors SecondFactors) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := CurrentUserID(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		enabled, err := factors.Enabled(c.Request.Context(), userID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if !enabled {
+			c.Next()
+			return
+		}
+		token := c.GetHeader(StepUpHeader)
+		if token == "" {
+			abortWithError(c, apperrors.Forbidden("Missing "+StepUpHeader+" header; verify your authenticator code first").WithCode("step_up_required"))
+			return
+		}
+		claims, err := tokens.ParseVerificationToken(token, authservice.PurposeStepUp)
+		if err != nil || claims.Subject != userID {
+			abortWithError(c, apperrors.Forbidden("Step-up token is invalid, expired or for another user").WithCode("step_up_required"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ElevatedHeader carries the token returned by POST /api/v1/auth/verify-pin
+const ElevatedHeader = "X-Elevated-Token"
+
+// RequireElevated demands that the signed-in user re-entered their PIN moments
+// ago, for actions such as changing bank details or approving payroll. With pins
+// set, users who have no PIN pass through as they have nothing to re-enter;
+// with nil it applies to every user. Must run after Authenticate.
+func RequireElevated(tokens *authservice.TokenService, pins SecondFactors) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := CurrentUserID(c)
+		if !ok {
+			abortWithError(c, apperrors.Unauthorized("Missing bearer token"))
+			return
+		}
+		if pins != nil {
+			hasPIN, err := pins.Enabled(c.Request.Context(), userID)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+			if !hasPIN {
+				c.Next()
+				return
+			}
+		}
+		token := c.GetHeader(ElevatedHeader)
+		if token == "" {
+			abortWithError(c, apperrors.Forbidden("Missing "+ElevatedHeader+" header; verify your PIN first").WithCode("pin_required"))
+			return
+		}
+		claims, err := tokens.ParseVerificationToken(token, authservice.PurposeElevated)
+		if err != nil || claims.Subject != userID {
+			abortWithError(c, apperrors.Forbidden("Elevated token is invalid, expired or for another user").WithCode("pin_required"))
+			return
+		}
+		c.Next()
+	}
+}
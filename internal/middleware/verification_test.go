@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
+	authservice "github.com/codeZe-us/vestroll-backend/internal/services/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// enrolledUsers reports a second factor for the listed users
+type enrolledUsers map[string]bool
+
+func (e enrolledUsers) Enabled(ctx context.Context, userID string) (bool, error) {
+	return e[userID], nil
+}
+
+func TestRequireStepUp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := authservice.NewTokenService(config.JWTConfig{Secret: "test-secret", TTL: time.Hour, VerificationTTL: time.Minute})
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.POST("/sensitive", Authenticate(tokens, false), RequireStepUp(tokens, enrolledUsers{"u1": true}), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	do := func(userID, stepUp string) int {
+		access, _ := tokens.IssueAccessToken(userID, "s1")
+		req := httptest.NewRequest(http.MethodPost, "/sensitive", nil)
+		req.Header.Set("Authorization", "Bearer "+access)
+		if stepUp != "" {
+			req.Header.Set(StepUpHeader, stepUp)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do("u2", ""); code != http.StatusNoContent {
+		t.Fatalf("user without a second factor got %d, want 204", code)
+	}
+	if code := do("u1", ""); code != http.StatusForbidden {
+		t.Fatalf("missing step-up token got %d, want 403", code)
+	}
+	otherUser, _ := tokens.IssueVerificationToken(authservice.PurposeStepUp, "u2")
+	if code := do("u1", otherUser); code != http.StatusForbidden {
+		t.Fatalf("another user's step-up token got %d, want 403", code)
+	}
+	wrongPurpose, _ := tokens.IssueVerificationToken(authservice.PurposePhoneVerified, "u1")
+	if code := do("u1", wrongPurpose); code != http.StatusForbidden {
+		t.Fatalf("phone verification token got %d, want 403", code)
+	}
+	valid, _ := tokens.IssueVerificationToken(authservice.PurposeStepUp, "u1")
+	if code := do("u1", valid); code != http.StatusNoContent {
+		t.Fatalf("valid step-up token got %d, want 204", code)
+	}
+}
+
+func TestRequireElevated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := authservice.NewTokenService(config.JWTConfig{Secret: "test-secret", TTL: time.Hour, VerificationTTL: time.Minute, ElevatedTTL: time.Minute})
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.POST("/payroll/approve", Authenticate(tokens, false), RequireElevated(tokens, nil), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	r.DELETE("/devices/d1", Authenticate(tokens, false), RequireElevated(tokens, enrolledUsers{"u1": true}), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	send := func(method, path, userID, elevated string) int {
+		access, _ := tokens.IssueAccessToken(userID, "s1")
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+access)
+		if elevated != "" {
+			req.Header.Set(ElevatedHeader, elevated)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	do := func(elevated string) int { return send(http.MethodPost, "/payroll/approve", "u1", elevated) }
+
+	if code := do(""); code != http.StatusForbidden {
+		t.Fatalf("missing elevated token got %d, want 403", code)
+	}
+	otherUser, _ := tokens.IssueElevatedToken("u2")
+	if code := do(otherUser); code != http.StatusForbidden {
+		t.Fatalf("another user's elevated token got %d, want 403", code)
+	}
+	stepUp, _ := tokens.IssueVerificationToken(authservice.PurposeStepUp, "u1")
+	if code := do(stepUp); code != http.StatusForbidden {
+		t.Fatalf("step-up token got %d, want 403", code)
+	}
+	valid, _ := tokens.IssueElevatedToken("u1")
+	if code := do(valid); code != http.StatusNoContent {
+		t.Fatalf("valid elevated token got %d, want 204", code)
+	}
+
+	// With a PIN check, only users who have a PIN must re-enter it
+	if code := send(http.MethodDelete, "/devices/d1", "u1", ""); code != http.StatusForbidden {
+		t.Fatalf("user with a PIN and no elevated token got %d, want 403", code)
+	}
+	if code := send(http.MethodDelete, "/devices/d1", "u1", valid); code != http.StatusNoContent {
+		t.Fatalf("user with a PIN and an elevated token got %d, want 204", code)
+	}
+	if code := send(http.MethodDelete, "/devices/d1", "u2", ""); code != http.StatusNoContent {
+		t.Fatalf("user without a PIN got %d, want 204", code)
+	}
+}
+
+func TestRequireVerificationRedeemsTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := authservice.NewTokenService(config.JWTConfig{Secret: "test-secret", TTL: time.Hour, VerificationTTL: time.Minute}).
+		WithUsedTokens(memory.NewUsedTokens(nil))
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.POST("/reset-pin", RequireVerification(tokens, authservice.PurposePINReset), func(c *gin.Context) {
+		id, _ := VerifiedIdentifier(c)
+		c.String(http.StatusOK, id)
+	})
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/reset-pin", nil)
+		if token != "" {
+			req.Header.Set(VerificationHeader, token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(""); w.Code != http.StatusForbidden {
+		t.Fatalf("missing verification token got %d, want 403", w.Code)
+	}
+	wrongPurpose, _ := tokens.IssueVerificationToken(authservice.PurposeNewDevice, "+2348012345678")
+	if w := do(wrongPurpose); w.Code != http.StatusForbidden {
+		t.Fatalf("new_device token got %d, want 403", w.Code)
+	}
+	valid, _ := tokens.IssueVerificationToken(authservice.PurposePINReset, "+2348012345678")
+	if w := do(valid); w.Code != http.StatusOK || w.Body.String() != "+2348012345678" {
+		t.Fatalf("valid token got %d %q, want 200 with the identifier", w.Code, w.Body.String())
+	}
+	if w := do(valid); w.Code != http.StatusForbidden {
+		t.Fatalf("replayed token got %d, want 403", w.Code)
+	}
+}
+
+func TestRedeemVerificationAfterBindingKeepsTokenForBadRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := authservice.NewTokenService(config.JWTConfig{Secret: "test-secret", TTL: time.Hour, VerificationTTL: time.Minute}).
+		WithUsedTokens(memory.NewUsedTokens(nil))
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.POST("/devices", func(c *gin.Context) {
+		var body struct {
+			DeviceID string `json:"device_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		id, ok := RedeemVerification(c, tokens, authservice.PurposeNewDevice)
+		if !ok {
+			return
+		}
+		c.String(http.StatusOK, id)
+	})
+	do := func(body, token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/devices", strings.NewReader(body))
+		req.Header.Set(VerificationHeader, token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	valid, _ := tokens.IssueVerificationToken(authservice.PurposeNewDevice, "ada@example.com")
+	if code := do(`{}`, valid); code != http.StatusBadRequest {
+		t.Fatalf("malformed body got %d, want 400", code)
+	}
+	if code := do(`{"device_id":"d1"}`, valid); code != http.StatusOK {
+		t.Fatalf("retry after a malformed body got %d, want 200", code)
+	}
+	if code := do(`{"device_id":"d1"}`, valid); code != http.StatusForbidden {
+		t.Fatalf("replayed token got %d, want 403", code)
+	}
+}
@@ -0,0 +1,183 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/codeZe-us/vestroll-backend/internal/testdb"
+	"github.com/codeZe-us/vestroll-backend/migrations"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestLoadSortsAndPairsFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_second.up.sql":   {Data: []byte("CREATE TABLE b (id int);")},
+		"000002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"000001_first.up.sql":    {Data: []byte("CREATE TABLE a (id int);")},
+		"README.md":              {Data: []byte("ignored")},
+	}
+	got, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(got) != 2 || got[0].Version != 1 || got[1].Version != 2 {
+		t.Fatalf("unexpected migrations %+v", got)
+	}
+	if got[0].Down != "" || got[1].Down != "DROP TABLE b;" || got[0].Checksum == got[1].Checksum {
+		t.Fatalf("unexpected migration contents %+v", got)
+	}
+}
+
+func TestLoadRejectsBadSets(t *testing.T) {
+	if _, err := Load(fstest.MapFS{"000001_only.down.sql": {Data: []byte("x")}}); err == nil {
+		t.Fatalf("expected error for missing up file")
+	}
+	if _, err := Load(fstest.MapFS{
+		"000001_a.up.sql": {Data: []byte("x")},
+		"000001_b.up.sql": {Data: []byte("y")},
+	}); err == nil {
+		t.Fatalf("expected error for duplicate version")
+	}
+}
+
+func TestEmbeddedMigrationsAreReversible(t *testing.T) {
+	got, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(got) == 0 {
+		t.Fatalf("expected embedded migrations")
+	}
+	for i, m := range got {
+		if m.Version != int64(i+1) {
+			t.Errorf("expected contiguous versions, got %d at position %d", m.Version, i)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+	}
+}
+
+func TestCreateUsesNextVersion(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(dir+"/000007_existing.up.sql", []byte("SELECT 1;"), 0o644)
+
+	up, down, err := Create(dir, "Add Wallets!")
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	if up != dir+"/000008_add_wallets.up.sql" || down != dir+"/000008_add_wallets.down.sql" {
+		t.Fatalf("unexpected paths %s %s", up, down)
+	}
+	if _, err := Load(os.DirFS(dir)); err != nil {
+		t.Fatalf("created files should load: %v", err)
+	}
+}
+
+func TestMain(m *testing.M) {
+	testdb.Main(m)
+}
+
+// newTestPool returns a pool on the harness database (see internal/testdb) with a private schema
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	return testdb.NewPool(t, "migrate_test")
+}
+
+func TestUpDownStatus(t *testing.T) {
+	pool := newTestPool(t)
+	ctx := context.Background()
+	m, err := New(pool, migrations.FS)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil || len(applied) != len(m.migrations) {
+		t.Fatalf("Up applied %d, err %v", len(applied), err)
+	}
+	if again, err := m.Up(ctx); err != nil || len(again) != 0 {
+		t.Fatalf("second Up should be a no-op, got %d, %v", len(again), err)
+	}
+
+	reverted, err := m.Down(ctx, 2)
+	if err != nil || len(reverted) != 2 || reverted[0].Version != m.migrations[len(m.migrations)-1].Version {
+		t.Fatalf("Down reverted %+v, err %v", reverted, err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status error: %v", err)
+	}
+	pending := 0
+	for _, s := range statuses {
+		if !s.Applied {
+			pending++
+		}
+	}
+	if pending != 2 {
+		t.Fatalf("expected 2 pending migrations, got %d", pending)
+	}
+
+	// Every down file must undo its up file so the full set can be replayed
+	reverted, err = m.Down(ctx, len(m.migrations))
+	if err != nil || len(reverted) != len(m.migrations)-2 {
+		t.Fatalf("Down all reverted %d, err %v", len(reverted), err)
+	}
+	var tables int
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'`).Scan(&tables); err != nil || tables != 0 {
+		t.Fatalf("expected no tables after Down all, got %d, %v", tables, err)
+	}
+	if applied, err := m.Up(ctx); err != nil || len(applied) != len(m.migrations) {
+		t.Fatalf("Up after Down all applied %d, err %v", len(applied), err)
+	}
+}
+
+func TestUpDetectsEditedMigration(t *testing.T) {
+	pool := newTestPool(t)
+	ctx := context.Background()
+	original := fstest.MapFS{"000001_t.up.sql": {Data: []byte("CREATE TABLE t (id int);")}}
+	m, _ := New(pool, original)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up error: %v", err)
+	}
+
+	edited := fstest.MapFS{"000001_t.up.sql": {Data: []byte("CREATE TABLE t (id bigint);")}}
+	m, _ = New(pool, edited)
+	if _, err := m.Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestConcurrentUpAppliesOnce(t *testing.T) {
+	pool := newTestPool(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	total := 0
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, _ := New(pool, migrations.FS)
+			applied, err := m.Up(ctx)
+			if err != nil {
+				t.Errorf("Up error: %v", err)
+			}
+			mu.Lock()
+			total += len(applied)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	m, _ := New(pool, migrations.FS)
+	if total != len(m.migrations) {
+		t.Fatalf("expected each migration applied once, got %d applications", total)
+	}
+}
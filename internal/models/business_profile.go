@@ -24,8 +24,9 @@ type BusinessContact struct {
 
 // BusinessDetailsRequest is the payload for creating/updating business details
 // For now, only contractor account type is supported
+// UserID is ignored for authenticated requests (see AccountTypeRequest)
 type BusinessDetailsRequest struct {
-	UserID             string           `json:"user_id" binding:"required"`
+	UserID             string           `json:"user_id"`
 	AccountType        string           `json:"account_type" binding:"required,oneof=contractor"`
 	BusinessName       string           `json:"business_name" binding:"required"`
 	RegistrationNumber string           `json:"registration_number" binding:"required"`
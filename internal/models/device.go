@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Device platforms accepted at registration
+const (
+	DevicePlatformIOS     = "ios"
+	DevicePlatformAndroid = "android"
+	DevicePlatformWeb     = "web"
+)
+
+// Device is a trusted device registered to a user. ID is chosen by the client
+// and unique per user; PublicKey is the base64 DER (PKIX) ECDSA P-256 or
+// Ed25519 key the device signs login challenges with.
+type Device struct {
+	ID         string    `json:"device_id"`
+	UserID     string    `json:"user_id"`
+	Platform   string    `json:"platform"`
+	Name       string    `json:"name"`
+	PublicKey  string    `json:"public_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// RegisterDeviceRequest adds a device after a new_device OTP was verified;
+// the verify-otp token goes in the X-Verification-Token header
+type RegisterDeviceRequest struct {
+	DeviceID  string `json:"device_id" binding:"required,max=128"`
+	Platform  string `json:"platform" binding:"required,oneof=ios android web"`
+	Name      string `json:"name" binding:"required,max=100"`
+	PublicKey string `json:"public_key" binding:"required"`
+}
+
+// DeviceChallengeRequest asks for a nonce for the device to sign before PIN login
+type DeviceChallengeRequest struct {
+	UserID   string `json:"user_id" binding:"required"`
+	DeviceID string `json:"device_id" binding:"required"`
+}
+
+// DeviceChallengeResponse carries a single-use nonce; the device signs its exact bytes
+type DeviceChallengeResponse struct {
+	Challenge string `json:"challenge"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// DeviceResponse is returned when a device is registered
+type DeviceResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Device  Device `json:"device"`
+}
+
+// DeviceListResponse lists the user's devices, oldest first
+type DeviceListResponse struct {
+	Devices []Device `json:"devices"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// NotificationStatus tracks a queued notification through delivery
+type NotificationStatus string
+
+const (
+	NotificationQueued    NotificationStatus = "queued"
+	NotificationRetrying  NotificationStatus = "retrying"
+	NotificationDelivered NotificationStatus = "delivered"
+	NotificationDead      NotificationStatus = "dead"
+)
+
+// NotificationJob is an outbound message waiting for (or finished with) delivery.
+// Payload is the provider message and is cleared once the job is delivered or dead,
+// so codes do not linger in storage. Jobs with ExpiresAt (codes) are dropped undelivered
+// once it passes, and stores keep their payload no longer than that.
+type NotificationJob struct {
+	ID            string             `json:"id"`
+	Channel       string             `json:"channel"`
+	Payload       json.RawMessage    `json:"payload,omitempty"`
+	Status        NotificationStatus `json:"status"`
+	Attempts      int                `json:"attempts"`
+	LastError     string             `json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `json:"next_attempt_at,omitempty"`
+	ExpiresAt     time.Time          `json:"expires_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+// NotificationStatusResponse is the public view of a job returned by the API
+type NotificationStatusResponse struct {
+	ID        string             `json:"id"`
+	Channel   string             `json:"channel"`
+	Status    NotificationStatus `json:"status"`
+	Attempts  int                `json:"attempts"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
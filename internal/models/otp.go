@@ -11,33 +11,103 @@ const (
 	OTPTypeEmail OTPType = "email"
 )
 
+// OTPPurpose binds a code to the flow it was issued for so that, for example,
+// a password reset code can never satisfy a transaction confirmation
+type OTPPurpose string
+
+const (
+	// OTPPurposeVerification proves control of a phone/email (default for older clients)
+	OTPPurposeVerification  OTPPurpose = "verification"
+	OTPPurposeSignup        OTPPurpose = "signup"
+	OTPPurposeLogin         OTPPurpose = "login"
+	OTPPurposePasswordReset OTPPurpose = "password_reset"
+	OTPPurposeTransaction   OTPPurpose = "transaction"
+	OTPPurposePINReset      OTPPurpose = "pin_reset"
+	OTPPurposeNewDevice     OTPPurpose = "new_device"
+)
+
+// PurposeOrDefault returns the purpose, falling back to verification when unset
+func (p OTPPurpose) PurposeOrDefault() OTPPurpose {
+	if p == "" {
+		return OTPPurposeVerification
+	}
+	return p
+}
+
+// Label is the human-readable name of the purpose used in messages
+func (p OTPPurpose) Label() string {
+	switch p.PurposeOrDefault() {
+	case OTPPurposeSignup:
+		return "sign-up"
+	case OTPPurposeLogin:
+		return "login"
+	case OTPPurposePasswordReset:
+		return "password reset"
+	case OTPPurposeTransaction:
+		return "transaction confirmation"
+	case OTPPurposePINReset:
+		return "PIN reset"
+	case OTPPurposeNewDevice:
+		return "new device"
+	default:
+		return "verification"
+	}
+}
+
 type OTPRequest struct {
-	Identifier string  `json:"identifier" binding:"required"`
-	Type       OTPType `json:"type" binding:"required,oneof=sms email"`
+	Identifier string     `json:"identifier" binding:"required"`
+	Type       OTPType    `json:"type" binding:"required,oneof=sms email"`
+	Purpose    OTPPurpose `json:"purpose" binding:"omitempty,oneof=verification signup login password_reset transaction pin_reset new_device"`
+	// Locale picks the message language (e.g. "fr"); the Accept-Language header is used when empty
+	Locale string `json:"locale,omitempty"`
+	// Challenge answers the challenge send-otp asks for when SMS traffic looks abusive
+	Challenge string `json:"challenge,omitempty"`
 }
 
+// OTPVerificationRequest's code length follows OTP_LENGTH and is checked by the service
 type OTPVerificationRequest struct {
-	Identifier string  `json:"identifier" binding:"required"`
-	Code       string  `json:"code" binding:"required,len=6"`
-	Type       OTPType `json:"type" binding:"required,oneof=sms email"`
+	Identifier string     `json:"identifier" binding:"required"`
+	Code       string     `json:"code" binding:"required,numeric"`
+	Type       OTPType    `json:"type" binding:"required,oneof=sms email"`
+	Purpose    OTPPurpose `json:"purpose" binding:"omitempty,oneof=verification signup login password_reset transaction pin_reset new_device"`
 }
 
+// OTPData is the stored state of an issued code; only a keyed hash of the code is kept
 type OTPData struct {
-	Code      string    `json:"code"`
-	Type      OTPType   `json:"type"`
-	ExpiresAt time.Time `json:"expires_at"`
-	Attempts  int       `json:"attempts"`
+	CodeHash  string     `json:"code_hash"`
+	Type      OTPType    `json:"type"`
+	Purpose   OTPPurpose `json:"purpose"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	Attempts  int        `json:"attempts"`
 }
 
 type OTPResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
+	// MessageID identifies the queued SMS/email for GET /notifications/{id}
+	MessageID string `json:"message_id,omitempty"`
+	OTPQuota
 }
 
+// OTPQuota tells clients when the code expires and how many sends and
+// guesses are left, so they can render countdowns
+type OTPQuota struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	// RemainingAttempts is how many verification guesses the code allows
+	RemainingAttempts int `json:"remaining_attempts"`
+	// RemainingSends is how many more codes can be requested in the current window
+	RemainingSends int `json:"remaining_sends"`
+	// ResendAvailableAt is the earliest time another code can be requested
+	ResendAvailableAt time.Time `json:"resend_available_at"`
+}
+
+// OTPVerificationResponse carries a short-lived verification token scoped to
+// the verified identifier and channel (phone_verified or email_verified)
 type OTPVerificationResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Token   string `json:"token,omitempty"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	Token     string `json:"token,omitempty"`
+	ExpiresIn int64  `json:"expires_in,omitempty"`
 }
 
 type ErrorResponse struct {
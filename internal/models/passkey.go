@@ -0,0 +1,155 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PasskeyCredential is a WebAuthn credential registered to a user.
+// ID is the base64url credential ID; PublicKey is the base64 COSE key from the
+// authenticator. SignCount is the last counter the authenticator reported and
+// must grow on every use unless the authenticator always reports zero.
+type PasskeyCredential struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Name       string    `json:"name"`
+	PublicKey  string    `json:"public_key"`
+	Algorithm  int       `json:"algorithm"`
+	SignCount  uint32    `json:"sign_count"`
+	Transports []string  `json:"transports,omitempty"`
+	AAGUID     string    `json:"aaguid"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// Passkey ceremony kinds recorded in PasskeyChallenge.Ceremony
+const (
+	PasskeyCeremonyRegistration   = "registration"
+	PasskeyCeremonyAuthentication = "authentication"
+)
+
+// PasskeyChallenge is the server-side state of a ceremony in progress.
+// UserID is empty for usernameless logins, where the authenticator picks the account.
+type PasskeyChallenge struct {
+	Challenge string    `json:"challenge"`
+	Ceremony  string    `json:"ceremony"`
+	UserID    string    `json:"user_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// The options below follow the WebAuthn JSON encoding (camelCase, base64url
+// binary fields) so browsers can pass them to PublicKeyCredential.parseCreationOptionsFromJSON
+// and parseRequestOptionsFromJSON unchanged.
+
+// PasskeyRelyingParty identifies this service to the authenticator
+type PasskeyRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// PasskeyUserEntity describes the account a credential is created for
+type PasskeyUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// PasskeyCredentialParameter is an accepted COSE algorithm
+type PasskeyCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// PasskeyCredentialDescriptor points the authenticator at an existing credential
+type PasskeyCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// PasskeyAuthenticatorSelection states the authenticator features required
+type PasskeyAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// PasskeyCreationOptions is PublicKeyCredentialCreationOptionsJSON
+type PasskeyCreationOptions struct {
+	RP                     PasskeyRelyingParty           `json:"rp"`
+	User                   PasskeyUserEntity             `json:"user"`
+	Challenge              string                        `json:"challenge"`
+	PubKeyCredParams       []PasskeyCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                         `json:"timeout"`
+	ExcludeCredentials     []PasskeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection PasskeyAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                        `json:"attestation"`
+}
+
+// PasskeyRequestOptions is PublicKeyCredentialRequestOptionsJSON
+type PasskeyRequestOptions struct {
+	RPID             string                        `json:"rpId"`
+	Challenge        string                        `json:"challenge"`
+	Timeout          int64                         `json:"timeout"`
+	AllowCredentials []PasskeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                        `json:"userVerification"`
+}
+
+// PasskeyCreationOptionsResponse is returned by register/options
+type PasskeyCreationOptionsResponse struct {
+	PublicKey PasskeyCreationOptions `json:"publicKey"`
+}
+
+// PasskeyRequestOptionsResponse is returned by login/options
+type PasskeyRequestOptionsResponse struct {
+	PublicKey PasskeyRequestOptions `json:"publicKey"`
+}
+
+// PasskeyAuthenticatorResponse holds the base64url fields of an
+// AuthenticatorAttestationResponse or AuthenticatorAssertionResponse
+type PasskeyAuthenticatorResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON"`
+	AttestationObject string   `json:"attestationObject,omitempty"`
+	AuthenticatorData string   `json:"authenticatorData,omitempty"`
+	Signature         string   `json:"signature,omitempty"`
+	UserHandle        string   `json:"userHandle,omitempty"`
+	Transports        []string `json:"transports,omitempty"`
+}
+
+// PasskeyCredentialJSON is the PublicKeyCredential.toJSON() output
+type PasskeyCredentialJSON struct {
+	ID       string                       `json:"id" binding:"required"`
+	RawID    string                       `json:"rawId" binding:"required"`
+	Type     string                       `json:"type" binding:"required,eq=public-key"`
+	Response PasskeyAuthenticatorResponse `json:"response"`
+	// ClientExtensionResults are accepted but not used
+	ClientExtensionResults json.RawMessage `json:"clientExtensionResults,omitempty"`
+}
+
+// PasskeyRegisterRequest completes registration; Name labels the passkey in lists
+type PasskeyRegisterRequest struct {
+	Name       string                `json:"name" binding:"max=100"`
+	Credential PasskeyCredentialJSON `json:"credential" binding:"required"`
+}
+
+// PasskeyLoginOptionsRequest starts a login; without an identifier the
+// authenticator offers any passkey it holds for this site
+type PasskeyLoginOptionsRequest struct {
+	Identifier string `json:"identifier"`
+}
+
+// PasskeyLoginRequest completes a login with an assertion
+type PasskeyLoginRequest struct {
+	Credential PasskeyCredentialJSON `json:"credential" binding:"required"`
+}
+
+// PasskeyResponse is returned when a passkey is registered
+type PasskeyResponse struct {
+	Success bool              `json:"success"`
+	Message string            `json:"message"`
+	Passkey PasskeyCredential `json:"passkey"`
+}
+
+// PasskeyListResponse lists the user's passkeys, oldest first
+type PasskeyListResponse struct {
+	Passkeys []PasskeyCredential `json:"passkeys"`
+}
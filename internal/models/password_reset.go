@@ -1,18 +1,26 @@
 package models
 
 type PasswordResetRequest struct {
-	Identifier string `json:"identifier" binding:"required"` // email or phone
+	Identifier string  `json:"identifier" binding:"required"` // email or phone
+	Channel    OTPType `json:"channel" binding:"required,oneof=email sms"`
+	// Locale picks the message language; the Accept-Language header is used when empty
+	Locale string `json:"locale,omitempty"`
+	// Challenge answers the challenge forgot-password asks for when SMS traffic looks abusive
+	Challenge string `json:"challenge,omitempty"`
 }
 
+// VerifyResetCodeRequest carries the emailed/texted code; its length follows
+// PASSWORD_RESET_CODE_LENGTH and is checked by the service
 type VerifyResetCodeRequest struct {
 	Identifier string `json:"identifier" binding:"required"`
-	Code       string `json:"code" binding:"required,len=6"`
+	Code       string `json:"code" binding:"required,numeric"`
 }
 
+// ResetPasswordRequest completes the reset using the single-use token
+// returned by verify-reset-code
 type ResetPasswordRequest struct {
-	Identifier      string `json:"identifier" binding:"required"`
-	Code            string `json:"code" binding:"required,len=6"`
-	NewPassword     string `json:"new_password" binding:"required"`
+	ResetToken  string `json:"reset_token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 // Response types
@@ -21,3 +29,11 @@ type PasswordResetResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
 }
+
+// VerifyResetCodeResponse carries the token that authorizes reset-password
+type VerifyResetCodeResponse struct {
+	Success    bool   `json:"success"`
+	Message    string `json:"message"`
+	ResetToken string `json:"reset_token"`
+	ExpiresIn  int64  `json:"expires_in"`
+}
@@ -3,19 +3,58 @@ package models
 import "time"
 
 // SetupPINRequest is the payload to set up a user's PIN
+// UserID is ignored for authenticated requests (see AccountTypeRequest)
 type SetupPINRequest struct {
-	UserID string `json:"user_id" binding:"required"`
+	UserID string `json:"user_id"`
 	PIN    string `json:"pin" binding:"required"`
 }
 
 // LoginPINRequest is the payload to authenticate a user using PIN
+// DeviceID names the registered device the new session is bound to; Signature
+// is the device key's base64 signature over Challenge from devices/challenge
 type LoginPINRequest struct {
-	UserID string `json:"user_id" binding:"required"`
-	PIN    string `json:"pin" binding:"required"`
+	UserID    string `json:"user_id"`
+	DeviceID  string `json:"device_id" binding:"required"`
+	Challenge string `json:"challenge" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+	PIN       string `json:"pin" binding:"required"`
+}
+
+// VerifyPINRequest re-checks the signed-in user's PIN before a sensitive action
+type VerifyPINRequest struct {
+	PIN string `json:"pin" binding:"required"`
+}
+
+// ChangePINRequest replaces the PIN after proving the current one
+type ChangePINRequest struct {
+	CurrentPIN string `json:"current_pin" binding:"required"`
+	NewPIN     string `json:"new_pin" binding:"required"`
+}
+
+// ResetPINRequest sets a new PIN after a pin_reset OTP was verified;
+// the verify-otp token goes in the X-Verification-Token header
+type ResetPINRequest struct {
+	NewPIN string `json:"new_pin" binding:"required"`
+}
+
+// PINAttempts tracks consecutive wrong PINs for a user
+type PINAttempts struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
 }
 
-// PinData represents the stored PIN data (salt + hashed PIN)
+// PIN hash formats recorded in PinData.Version
+const (
+	// PINHashLegacySHA256 is sha256("{pin}:{salt}") in hex; rehashed on the next successful check
+	PINHashLegacySHA256 = 0
+	// PINHashArgon2id is Argon2id over HMAC-SHA256(pepper, pin), PHC-encoded in Hash with its own salt
+	PINHashArgon2id = 1
+)
+
+// PinData represents the stored PIN data. Version selects how Hash was derived;
+// Salt is only used by the legacy format.
 type PinData struct {
+	Version   int       `json:"version,omitempty"`
 	Salt      string    `json:"salt"`
 	Hash      string    `json:"hash"`
 	CreatedAt time.Time `json:"created_at"`
@@ -27,8 +66,15 @@ type SetupPINResponse struct {
 	Message string `json:"message"`
 }
 
-// LoginPINResponse is returned by the login PIN endpoint
+// LoginPINResponse is returned by the login PIN endpoint with the device-bound session
 type LoginPINResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
+	TokenPair
+}
+
+// VerifyPINResponse carries the token that unlocks routes requiring a fresh PIN
+type VerifyPINResponse struct {
+	ElevatedToken string `json:"elevated_token"`
+	ExpiresIn     int    `json:"expires_in"`
 }
\ No newline at end of file
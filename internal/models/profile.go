@@ -9,12 +9,13 @@ const (
 )
 
 // AccountTypeRequest sets the user's selected account type
-// Note: user_id is required since we are not using auth yet
+// Note: user_id is taken from the bearer token; the body value is only honoured
+// for unauthenticated requests while AUTH_COMPAT_MODE is enabled
 // binding: oneof constraint ensures value is freelancer or contractor
 // We keep a separate constant name AccountTypeContractorProfile to avoid
 // conflicting with the BusinessProfile model's constant.
 type AccountTypeRequest struct {
-    UserID      string `json:"user_id" binding:"required"`
+    UserID      string `json:"user_id"`
     AccountType string `json:"account_type" binding:"required,oneof=freelancer contractor"`
 }
 
@@ -33,7 +34,7 @@ type PersonalDetails struct {
 }
 
 type PersonalDetailsRequest struct {
-    UserID string          `json:"user_id" binding:"required"`
+    UserID string          `json:"user_id"`
     Data   PersonalDetails `json:"data" binding:"required"`
 }
 
@@ -48,10 +49,16 @@ type Address struct {
 }
 
 type AddressRequest struct {
-    UserID string  `json:"user_id" binding:"required"`
+    UserID string  `json:"user_id"`
     Data   Address `json:"data" binding:"required"`
 }
 
+// LocaleRequest sets the language used for the user's notifications
+type LocaleRequest struct {
+    UserID string `json:"user_id"`
+    Locale string `json:"locale" binding:"required"`
+}
+
 // UserProfile is the aggregate profile assembled across the steps
 // CompletionPercent is computed based on filled sections; Completed when 100%.
 type UserProfile struct {
@@ -59,6 +66,8 @@ type UserProfile struct {
     AccountType       string           `json:"account_type"`
     Personal          *PersonalDetails `json:"personal,omitempty"`
     Address           *Address         `json:"address,omitempty"`
+    // Locale is the preferred language for notifications (e.g. "en", "fr")
+    Locale            string           `json:"locale,omitempty"`
     Completed         bool             `json:"completed"`
     CompletionPercent int              `json:"completion_percent"`
     UpdatedAt         time.Time        `json:"updated_at"`
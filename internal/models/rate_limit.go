@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// RateLimitResult is the outcome of taking one request from a rate limit
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a denied request would be allowed
+	RetryAfter time.Duration
+	// ResetAfter is how long until the limit is fully replenished
+	ResetAfter time.Duration
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// TokenPair is an access token plus the refresh token that renews it
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+}
+
+// RefreshTokenRecord is the server-side state of an issued refresh token
+// FamilyID groups every token rotated from the same login; revoking the family
+// ends the session. DeviceID is set for sessions bound to a device, such as
+// PIN logins, and the token only refreshes from that device.
+type RefreshTokenRecord struct {
+	UserID    string    `json:"user_id"`
+	FamilyID  string    `json:"family_id"`
+	DeviceID  string    `json:"device_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RefreshRequest is the payload to rotate a refresh token
+// DeviceID, Challenge and Signature are required for device-bound sessions:
+// the device signs a challenge from POST /auth/devices/challenge
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	DeviceID     string `json:"device_id"`
+	Challenge    string `json:"challenge"`
+	Signature    string `json:"signature"`
+}
+
+// LogoutRequest is the payload to end the session a refresh token belongs to
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse is returned by the refresh endpoint
+type TokenResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	TokenPair
+}
+
+// LogoutResponse is returned by the logout endpoints
+type LogoutResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
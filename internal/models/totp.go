@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// TOTPEnrollment is a user's authenticator-app secret and recovery codes.
+// Secret is encrypted at rest; RecoveryCodes holds hashes of the codes not yet used.
+type TOTPEnrollment struct {
+	UserID    string `json:"user_id"`
+	Secret    string `json:"secret"`
+	Confirmed bool   `json:"confirmed"`
+	// LastUsedStep is the last 30-second time step accepted, so a code cannot be replayed
+	LastUsedStep  int64      `json:"last_used_step"`
+	RecoveryCodes []string   `json:"recovery_codes"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ConfirmedAt   *time.Time `json:"confirmed_at,omitempty"`
+}
+
+// TOTPEnrollResponse is returned when enrollment starts. The secret is shown
+// once so it can be typed in when the QR code cannot be scanned.
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	// QRCodePNG is the otpauth URI as a base64-encoded PNG
+	QRCodePNG string `json:"qr_code_png"`
+}
+
+// TOTPCodeRequest carries an authenticator code or, where accepted, a recovery code
+type TOTPCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// TOTPRecoveryCodesResponse lists freshly generated recovery codes; they are not shown again
+type TOTPRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TOTPStatusResponse reports whether TOTP is on and how many recovery codes are left
+type TOTPStatusResponse struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// TOTPStepUpResponse carries the token that unlocks sensitive routes
+type TOTPStepUpResponse struct {
+	StepUpToken string `json:"step_up_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// User is a registered account holder
+// PasswordHash is an encoded Argon2id hash and must never be returned to clients;
+// handlers respond with UserInfo instead.
+type User struct {
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	Phone        string `json:"phone,omitempty"`
+	PasswordHash string `json:"password_hash"`
+	// EmailVerified and PhoneVerified record that a verify-otp token was presented
+	EmailVerified bool      `json:"email_verified"`
+	PhoneVerified bool      `json:"phone_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// UserInfo is the public view of a user returned by the API
+type UserInfo struct {
+	ID            string    `json:"id"`
+	Email         string    `json:"email"`
+	Phone         string    `json:"phone,omitempty"`
+	EmailVerified bool      `json:"email_verified"`
+	PhoneVerified bool      `json:"phone_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Info returns the public view of the user
+func (u User) Info() UserInfo {
+	return UserInfo{
+		ID:            u.ID,
+		Email:         u.Email,
+		Phone:         u.Phone,
+		EmailVerified: u.EmailVerified,
+		PhoneVerified: u.PhoneVerified,
+		CreatedAt:     u.CreatedAt,
+	}
+}
+
+// RegisterRequest is the payload to create a new account
+// Phone is optional but must be in E.164 format when provided
+// VerificationToken is the token returned by verify-otp for the email or phone;
+// it is required when AUTH_REQUIRE_VERIFIED_CONTACT is enabled
+type RegisterRequest struct {
+	Email             string `json:"email" binding:"required,email"`
+	Phone             string `json:"phone"`
+	Password          string `json:"password" binding:"required"`
+	VerificationToken string `json:"verification_token"`
+}
+
+// ChangePhoneRequest replaces the account's phone number
+// The new number must be proven with a phone_verified token in X-Verification-Token
+type ChangePhoneRequest struct {
+	Phone string `json:"phone" binding:"required"`
+}
+
+// UserResponse is returned by endpoints that update the account
+type UserResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message"`
+	User    UserInfo `json:"user"`
+}
+
+// LoginRequest is the payload to authenticate with password credentials
+// Identifier is the account's email address or phone number
+type LoginRequest struct {
+	Identifier string `json:"identifier" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+}
+
+// AuthResponse is returned by the register and login endpoints
+type AuthResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	TokenPair
+	User UserInfo `json:"user"`
+}
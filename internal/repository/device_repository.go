@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrDeviceExists is returned when the user already registered a device with the same ID
+var ErrDeviceExists = errors.New("device already exists")
+
+// DeviceRepository stores trusted devices in Redis
+// Key patterns:
+//   user_device:{user_id}:{device_id} -> Device JSON
+//   user_devices:{user_id}            -> set of device IDs
+type DeviceRepository struct {
+	client *redis.Client
+}
+
+func NewDeviceRepository(client *redis.Client) *DeviceRepository {
+	return &DeviceRepository{client: client}
+}
+
+func (r *DeviceRepository) key(userID, deviceID string) string {
+	return fmt.Sprintf("user_device:%s:%s", userID, deviceID)
+}
+
+func (r *DeviceRepository) listKey(userID string) string {
+	return fmt.Sprintf("user_devices:%s", userID)
+}
+
+// Create stores a new device; SETNX keeps device IDs unique per user
+func (r *DeviceRepository) Create(ctx context.Context, device models.Device) error {
+	data, err := json.Marshal(device)
+	if err != nil {
+		return err
+	}
+	ok, err := r.client.SetNX(ctx, r.key(device.UserID, device.ID), data, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeviceExists
+	}
+	return r.client.SAdd(ctx, r.listKey(device.UserID), device.ID).Err()
+}
+
+// Get returns the device, or nil when the user has no device with that ID
+func (r *DeviceRepository) Get(ctx context.Context, userID, deviceID string) (*models.Device, error) {
+	val, err := r.client.Get(ctx, r.key(userID, deviceID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var device models.Device
+	if err := json.Unmarshal(val, &device); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// List returns the user's devices, oldest first
+func (r *DeviceRepository) List(ctx context.Context, userID string) ([]models.Device, error) {
+	ids, err := r.client.SMembers(ctx, r.listKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]models.Device, 0, len(ids))
+	for _, id := range ids {
+		device, err := r.Get(ctx, userID, id)
+		if err != nil {
+			return nil, err
+		}
+		if device != nil {
+			devices = append(devices, *device)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].CreatedAt.Before(devices[j].CreatedAt) })
+	return devices, nil
+}
+
+// Touch sets the device's last use time if it still exists
+func (r *DeviceRepository) Touch(ctx context.Context, userID, deviceID string, at time.Time) error {
+	device, err := r.Get(ctx, userID, deviceID)
+	if err != nil || device == nil {
+		return err
+	}
+	device.LastUsedAt = at
+	data, err := json.Marshal(device)
+	if err != nil {
+		return err
+	}
+	// XX so a device revoked meanwhile is not brought back
+	return r.client.SetXX(ctx, r.key(userID, deviceID), data, 0).Err()
+}
+
+// Delete removes the device
+func (r *DeviceRepository) Delete(ctx context.Context, userID, deviceID string) (bool, error) {
+	pipe := r.client.TxPipeline()
+	del := pipe.Del(ctx, r.key(userID, deviceID))
+	pipe.SRem(ctx, r.listKey(userID), deviceID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return del.Val() == 1, nil
+}
+
+// DeviceChallengeRepository stores login challenges in Redis
+// Key pattern: device_challenge:{user_id}:{device_id}:{challenge} -> marker with the challenge TTL
+type DeviceChallengeRepository struct {
+	client *redis.Client
+}
+
+func NewDeviceChallengeRepository(client *redis.Client) *DeviceChallengeRepository {
+	return &DeviceChallengeRepository{client: client}
+}
+
+func (r *DeviceChallengeRepository) key(userID, deviceID, challenge string) string {
+	return fmt.Sprintf("device_challenge:%s:%s:%s", userID, deviceID, challenge)
+}
+
+// StoreChallenge saves the challenge until ttl passes
+func (r *DeviceChallengeRepository) StoreChallenge(ctx context.Context, userID, deviceID, challenge string, ttl time.Duration) error {
+	return r.client.Set(ctx, r.key(userID, deviceID, challenge), 1, ttl).Err()
+}
+
+// ConsumeChallenge deletes the challenge; only the caller whose DEL removed it succeeds
+func (r *DeviceChallengeRepository) ConsumeChallenge(ctx context.Context, userID, deviceID, challenge string) (bool, error) {
+	n, err := r.client.Del(ctx, r.key(userID, deviceID, challenge)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
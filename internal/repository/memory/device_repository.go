@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+)
+
+// DeviceRepository keeps trusted devices in memory
+type DeviceRepository struct {
+	mu      sync.Mutex
+	devices map[string]models.Device // keyed by user_id/device_id
+}
+
+func NewDeviceRepository() *DeviceRepository {
+	return &DeviceRepository{devices: map[string]models.Device{}}
+}
+
+func deviceKey(userID, deviceID string) string {
+	return userID + "/" + deviceID
+}
+
+// Create stores a new device unless the user already has one with that ID
+func (r *DeviceRepository) Create(ctx context.Context, device models.Device) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := deviceKey(device.UserID, device.ID)
+	if _, ok := r.devices[key]; ok {
+		return repository.ErrDeviceExists
+	}
+	r.devices[key] = device
+	return nil
+}
+
+// Get returns the device, or nil when the user has no device with that ID
+func (r *DeviceRepository) Get(ctx context.Context, userID, deviceID string) (*models.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device, ok := r.devices[deviceKey(userID, deviceID)]
+	if !ok {
+		return nil, nil
+	}
+	return &device, nil
+}
+
+// List returns the user's devices, oldest first
+func (r *DeviceRepository) List(ctx context.Context, userID string) ([]models.Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	devices := []models.Device{}
+	for _, device := range r.devices {
+		if device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].CreatedAt.Before(devices[j].CreatedAt) })
+	return devices, nil
+}
+
+// Touch sets the device's last use time if it exists
+func (r *DeviceRepository) Touch(ctx context.Context, userID, deviceID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := deviceKey(userID, deviceID)
+	if device, ok := r.devices[key]; ok {
+		device.LastUsedAt = at
+		r.devices[key] = device
+	}
+	return nil
+}
+
+// Delete removes the device
+func (r *DeviceRepository) Delete(ctx context.Context, userID, deviceID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := deviceKey(userID, deviceID)
+	_, ok := r.devices[key]
+	delete(r.devices, key)
+	return ok, nil
+}
+
+// DeviceChallenges keeps login challenges in memory until they expire
+type DeviceChallenges struct {
+	mu         sync.Mutex
+	now        func() time.Time
+	challenges map[string]time.Time // keyed by user_id/device_id/challenge, valued by expiry
+}
+
+// NewDeviceChallenges creates an in-memory challenge store; now defaults to time.Now
+func NewDeviceChallenges(now func() time.Time) *DeviceChallenges {
+	return &DeviceChallenges{now: clock(now), challenges: map[string]time.Time{}}
+}
+
+// StoreChallenge saves the challenge until ttl passes
+func (r *DeviceChallenges) StoreChallenge(ctx context.Context, userID, deviceID, challenge string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.challenges[deviceKey(userID, deviceID)+"/"+challenge] = r.now().Add(ttl)
+	return nil
+}
+
+// ConsumeChallenge removes the challenge, accepting it only if it has not expired
+func (r *DeviceChallenges) ConsumeChallenge(ctx context.Context, userID, deviceID, challenge string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := deviceKey(userID, deviceID) + "/" + challenge
+	expiry, ok := r.challenges[key]
+	delete(r.challenges, key)
+	return ok && r.now().Before(expiry), nil
+}
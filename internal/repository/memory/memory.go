@@ -0,0 +1,35 @@
+// Package memory implements the repository interfaces with in-process maps.
+// It is meant for tests and single-instance development; nothing survives a restart.
+package memory
+
+import (
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+)
+
+// clock returns now, or time.Now when now is nil
+func clock(now func() time.Time) func() time.Time {
+	if now == nil {
+		return time.Now
+	}
+	return now
+}
+
+var (
+	_ repository.UsedTokenStore         = (*UsedTokens)(nil)
+	_ repository.PasskeyStore           = (*PasskeyRepository)(nil)
+	_ repository.PasskeyChallengeStore  = (*PasskeyChallenges)(nil)
+	_ repository.DeviceStore            = (*DeviceRepository)(nil)
+	_ repository.DeviceChallengeStore   = (*DeviceChallenges)(nil)
+	_ repository.TOTPStore              = (*TOTPRepository)(nil)
+	_ repository.PINAttemptStore        = (*PINAttempts)(nil)
+	_ repository.RateLimitStore         = (*RateLimits)(nil)
+	_ repository.SMSUsageStore          = (*SMSUsage)(nil)
+	_ repository.NotificationQueueStore = (*NotificationQueue)(nil)
+	_ repository.OTPStore               = (*OTPRepository)(nil)
+	_ repository.UserStore              = (*UserRepository)(nil)
+	_ repository.ProfileStore           = (*ProfileRepository)(nil)
+	_ repository.BusinessProfileStore   = (*BusinessProfileRepository)(nil)
+	_ repository.PINStore               = (*PinRepository)(nil)
+)
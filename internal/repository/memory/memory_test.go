@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/repotest"
+)
+
+func TestUserStore(t *testing.T) {
+	repotest.RunUserStore(t, func(t *testing.T) repository.UserStore { return NewUserRepository() })
+}
+
+func TestProfileStore(t *testing.T) {
+	repotest.RunProfileStore(t, func(t *testing.T) repository.ProfileStore { return NewProfileRepository() })
+}
+
+func TestBusinessProfileStore(t *testing.T) {
+	repotest.RunBusinessProfileStore(t, func(t *testing.T) repository.BusinessProfileStore { return NewBusinessProfileRepository() })
+}
+
+func TestPINStore(t *testing.T) {
+	repotest.RunPINStore(t, func(t *testing.T) repository.PINStore { return NewPinRepository() })
+}
+
+func TestOTPStore(t *testing.T) {
+	repotest.RunOTPStore(t, func(t *testing.T) repotest.OTPHarness {
+		var mu sync.Mutex
+		current := time.Now()
+		now := func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return current
+		}
+		const ttl = 5 * time.Minute
+		return repotest.OTPHarness{
+			Store: NewOTPRepository(ttl, now),
+			TTL:   ttl,
+			Advance: func(d time.Duration) {
+				mu.Lock()
+				current = current.Add(d)
+				mu.Unlock()
+			},
+		}
+	})
+}
+
+func TestProfileIsCopied(t *testing.T) {
+	// Mutating a returned profile must not change what is stored
+	ctx := context.Background()
+	repo := NewProfileRepository()
+	repo.Save(ctx, models.UserProfile{UserID: "u1", Address: &models.Address{City: "Lagos"}})
+	got, _ := repo.Get(ctx, "u1")
+	got.Address.City = "Abuja"
+	if again, _ := repo.Get(ctx, "u1"); again.Address.City != "Lagos" {
+		t.Fatalf("stored profile was mutated through a returned pointer")
+	}
+}
+
+func TestNotificationQueueStore(t *testing.T) {
+	repotest.RunNotificationQueueStore(t, func(t *testing.T) repository.NotificationQueueStore { return NewNotificationQueue() })
+}
+
+func TestSMSUsageStore(t *testing.T) {
+	repotest.RunSMSUsageStore(t, func(t *testing.T) repository.SMSUsageStore { return NewSMSUsage() })
+}
+
+func TestRateLimitStore(t *testing.T) {
+	repotest.RunRateLimitStore(t, func(t *testing.T) repository.RateLimitStore { return NewRateLimits() })
+}
+
+func TestTOTPStore(t *testing.T) {
+	repotest.RunTOTPStore(t, func(t *testing.T) repository.TOTPStore { return NewTOTPRepository() })
+}
+
+func TestPINAttemptStore(t *testing.T) {
+	repotest.RunPINAttemptStore(t, func(t *testing.T) repository.PINAttemptStore { return NewPINAttempts() })
+}
+
+func TestDeviceStore(t *testing.T) {
+	repotest.RunDeviceStore(t, func(t *testing.T) repository.DeviceStore { return NewDeviceRepository() })
+}
+
+func TestDeviceChallengeStore(t *testing.T) {
+	repotest.RunDeviceChallengeStore(t, func(t *testing.T) repotest.DeviceChallengeHarness {
+		var mu sync.Mutex
+		current := time.Now()
+		now := func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return current
+		}
+		return repotest.DeviceChallengeHarness{
+			Store: NewDeviceChallenges(now),
+			Advance: func(d time.Duration) {
+				mu.Lock()
+				current = current.Add(d)
+				mu.Unlock()
+			},
+		}
+	})
+}
+
+func TestPasskeyStore(t *testing.T) {
+	repotest.RunPasskeyStore(t, func(t *testing.T) repository.PasskeyStore { return NewPasskeyRepository() })
+}
+
+func TestPasskeyChallengeStore(t *testing.T) {
+	repotest.RunPasskeyChallengeStore(t, func(t *testing.T) repotest.PasskeyChallengeHarness {
+		var mu sync.Mutex
+		current := time.Now()
+		now := func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return current
+		}
+		return repotest.PasskeyChallengeHarness{
+			Store: NewPasskeyChallenges(now),
+			Advance: func(d time.Duration) {
+				mu.Lock()
+				current = current.Add(d)
+				mu.Unlock()
+			},
+		}
+	})
+}
+
+func TestUsedTokenStore(t *testing.T) {
+	repotest.RunUsedTokenStore(t, func(t *testing.T) repotest.UsedTokenHarness {
+		var mu sync.Mutex
+		current := time.Now()
+		now := func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return current
+		}
+		return repotest.UsedTokenHarness{
+			Store: NewUsedTokens(now),
+			Advance: func(d time.Duration) {
+				mu.Lock()
+				current = current.Add(d)
+				mu.Unlock()
+			},
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+)
+
+// deadLetterLimit caps the dead-letter list like the Redis store
+const deadLetterLimit = 1000
+
+// NotificationQueue is an in-process delivery queue with the same lease and
+// retry semantics as the Redis store
+type NotificationQueue struct {
+	mu         sync.Mutex
+	jobs       map[string]models.NotificationJob
+	ready      []string
+	delayed    map[string]time.Time
+	processing map[string]time.Time
+	dead       []string
+}
+
+func NewNotificationQueue() *NotificationQueue {
+	return &NotificationQueue{
+		jobs:       map[string]models.NotificationJob{},
+		delayed:    map[string]time.Time{},
+		processing: map[string]time.Time{},
+	}
+}
+
+func (q *NotificationQueue) Enqueue(ctx context.Context, job models.NotificationJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs[job.ID] = job
+	q.ready = append(q.ready, job.ID)
+	return nil
+}
+
+// Dequeue leases the next job until now+lease, or returns nil when idle
+func (q *NotificationQueue) Dequeue(ctx context.Context, now time.Time, lease time.Duration) (*models.NotificationJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, pending := range []map[string]time.Time{q.delayed, q.processing} {
+		for id, at := range pending {
+			if !at.After(now) {
+				delete(pending, id)
+				q.ready = append(q.ready, id)
+			}
+		}
+	}
+	if len(q.ready) == 0 {
+		return nil, nil
+	}
+	id := q.ready[0]
+	q.ready = q.ready[1:]
+	q.processing[id] = now.Add(lease)
+	job := q.jobs[id]
+	return &job, nil
+}
+
+func (q *NotificationQueue) Complete(ctx context.Context, job models.NotificationJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs[job.ID] = job
+	delete(q.processing, job.ID)
+	return nil
+}
+
+func (q *NotificationQueue) Retry(ctx context.Context, job models.NotificationJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs[job.ID] = job
+	delete(q.processing, job.ID)
+	q.delayed[job.ID] = job.NextAttemptAt
+	return nil
+}
+
+func (q *NotificationQueue) Bury(ctx context.Context, job models.NotificationJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs[job.ID] = job
+	delete(q.processing, job.ID)
+	q.dead = append([]string{job.ID}, q.dead...)
+	if len(q.dead) > deadLetterLimit {
+		q.dead = q.dead[:deadLetterLimit]
+	}
+	return nil
+}
+
+func (q *NotificationQueue) Get(ctx context.Context, id string) (*models.NotificationJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// DeadLetters returns up to limit dead jobs, newest first
+func (q *NotificationQueue) DeadLetters(ctx context.Context, limit int) ([]models.NotificationJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := []models.NotificationJob{}
+	for _, id := range q.dead {
+		if len(jobs) == limit {
+			break
+		}
+		jobs = append(jobs, q.jobs[id])
+	}
+	return jobs, nil
+}
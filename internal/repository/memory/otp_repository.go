@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+)
+
+type otpEntry struct {
+	data   models.OTPData
+	expiry time.Time
+}
+
+type counter struct {
+	count  int
+	expiry time.Time
+}
+
+// OTPRepository mirrors the Redis OTP store: codes expire after ttl, attempts
+// are counted in place, and send counters expire windowSize after the last send
+type OTPRepository struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	now       func() time.Time
+	codes     map[string]otpEntry
+	counters  map[string]counter
+	cooldowns map[string]time.Time
+}
+
+// NewOTPRepository creates an in-memory OTP store; now defaults to time.Now
+func NewOTPRepository(ttl time.Duration, now func() time.Time) *OTPRepository {
+	return &OTPRepository{
+		ttl:       ttl,
+		now:       clock(now),
+		codes:     map[string]otpEntry{},
+		counters:  map[string]counter{},
+		cooldowns: map[string]time.Time{},
+	}
+}
+
+func (r *OTPRepository) key(identifier string, purpose models.OTPPurpose, otpType models.OTPType) string {
+	return string(purpose.PurposeOrDefault()) + ":" + string(otpType) + ":" + identifier
+}
+
+// lookup returns the live entry for key, dropping it if its TTL has passed
+func (r *OTPRepository) lookup(key string) (otpEntry, bool) {
+	entry, ok := r.codes[key]
+	if ok && !r.now().Before(entry.expiry) {
+		delete(r.codes, key)
+		return otpEntry{}, false
+	}
+	return entry, ok
+}
+
+// StoreOTP replaces any pending code for the same identifier, purpose and channel
+func (r *OTPRepository) StoreOTP(ctx context.Context, identifier string, otpData models.OTPData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	otpData.Purpose = otpData.Purpose.PurposeOrDefault()
+	r.codes[r.key(identifier, otpData.Purpose, otpData.Type)] = otpEntry{data: otpData, expiry: r.now().Add(r.ttl)}
+	return nil
+}
+
+func (r *OTPRepository) GetOTP(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType) (*models.OTPData, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.lookup(r.key(identifier, purpose, otpType))
+	if !ok {
+		return nil, nil
+	}
+	return &entry.data, nil
+}
+
+func (r *OTPRepository) DeleteOTP(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.codes, r.key(identifier, purpose, otpType))
+	return nil
+}
+
+// RegisterAttempt counts a verification attempt and returns the stored code,
+// deleting it once Attempts exceeds maxAttempts. It returns nil when no code is pending.
+func (r *OTPRepository) RegisterAttempt(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType, maxAttempts int) (*models.OTPData, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := r.key(identifier, purpose, otpType)
+	entry, ok := r.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	entry.data.Attempts++
+	if entry.data.Attempts > maxAttempts {
+		delete(r.codes, key)
+	} else {
+		r.codes[key] = entry
+	}
+	return &entry.data, nil
+}
+
+// ConsumeOTP deletes the code if it still matches codeHash and reports whether it did
+func (r *OTPRepository) ConsumeOTP(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := r.key(identifier, purpose, otpType)
+	entry, ok := r.lookup(key)
+	if !ok || entry.data.CodeHash != codeHash {
+		return false, nil
+	}
+	delete(r.codes, key)
+	return true, nil
+}
+
+// CheckRateLimit counts a send and reports whether it is within maxRequests per window
+func (r *OTPRepository) CheckRateLimit(ctx context.Context, identifier string, maxRequests int, windowSize time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.counter(identifier)
+	if c.count >= maxRequests {
+		return false, nil
+	}
+	r.counters[identifier] = counter{count: c.count + 1, expiry: r.now().Add(windowSize)}
+	return true, nil
+}
+
+func (r *OTPRepository) ClearRateLimit(ctx context.Context, identifier string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.counters, identifier)
+	return nil
+}
+
+func (r *OTPRepository) GetRemainingAttempts(ctx context.Context, identifier string, maxRequests int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	remaining := maxRequests - r.counter(identifier).count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, nil
+}
+
+func (r *OTPRepository) counter(identifier string) counter {
+	c, ok := r.counters[identifier]
+	if ok && !r.now().Before(c.expiry) {
+		delete(r.counters, identifier)
+		return counter{}
+	}
+	return c
+}
+
+func (r *OTPRepository) RateLimitResetIn(ctx context.Context, identifier string) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.counter(identifier)
+	if c.count == 0 {
+		return 0, nil
+	}
+	return c.expiry.Sub(r.now()), nil
+}
+
+func (r *OTPRepository) StartCooldown(ctx context.Context, identifier string, cooldown time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cooldown <= 0 {
+		return true, nil
+	}
+	if r.cooldownLeft(identifier) > 0 {
+		return false, nil
+	}
+	r.cooldowns[identifier] = r.now().Add(cooldown)
+	return true, nil
+}
+
+func (r *OTPRepository) ClearCooldown(ctx context.Context, identifier string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cooldowns, identifier)
+	return nil
+}
+
+func (r *OTPRepository) CooldownRemaining(ctx context.Context, identifier string) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cooldownLeft(identifier), nil
+}
+
+func (r *OTPRepository) cooldownLeft(identifier string) time.Duration {
+	left := r.cooldowns[identifier].Sub(r.now())
+	if left <= 0 {
+		delete(r.cooldowns, identifier)
+		return 0
+	}
+	return left
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+)
+
+// PasskeyRepository keeps WebAuthn credentials in memory
+type PasskeyRepository struct {
+	mu          sync.Mutex
+	credentials map[string]models.PasskeyCredential // keyed by credential ID
+}
+
+func NewPasskeyRepository() *PasskeyRepository {
+	return &PasskeyRepository{credentials: map[string]models.PasskeyCredential{}}
+}
+
+// Create stores a new credential unless the ID is already registered
+func (r *PasskeyRepository) Create(ctx context.Context, credential models.PasskeyCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.credentials[credential.ID]; ok {
+		return repository.ErrPasskeyExists
+	}
+	credential.Transports = append([]string(nil), credential.Transports...)
+	r.credentials[credential.ID] = credential
+	return nil
+}
+
+// Get returns the credential, or nil when the ID is unknown
+func (r *PasskeyRepository) Get(ctx context.Context, credentialID string) (*models.PasskeyCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.credentials[credentialID]
+	if !ok {
+		return nil, nil
+	}
+	return &credential, nil
+}
+
+// ListByUser returns the user's credentials, oldest first
+func (r *PasskeyRepository) ListByUser(ctx context.Context, userID string) ([]models.PasskeyCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credentials := []models.PasskeyCredential{}
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, credential)
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool { return credentials[i].CreatedAt.Before(credentials[j].CreatedAt) })
+	return credentials, nil
+}
+
+// UpdateSignCount advances the counter if it grows, or stays at zero for
+// authenticators that do not count
+func (r *PasskeyRepository) UpdateSignCount(ctx context.Context, credentialID string, signCount uint32, usedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.credentials[credentialID]
+	if !ok || (signCount <= credential.SignCount && !(signCount == 0 && credential.SignCount == 0)) {
+		return false, nil
+	}
+	credential.SignCount = signCount
+	credential.LastUsedAt = usedAt
+	r.credentials[credentialID] = credential
+	return true, nil
+}
+
+// Delete removes the user's credential
+func (r *PasskeyRepository) Delete(ctx context.Context, userID, credentialID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.credentials[credentialID]
+	if !ok || credential.UserID != userID {
+		return false, nil
+	}
+	delete(r.credentials, credentialID)
+	return true, nil
+}
+
+// PasskeyChallenges keeps ceremony challenges in memory until they expire
+type PasskeyChallenges struct {
+	mu         sync.Mutex
+	now        func() time.Time
+	challenges map[string]models.PasskeyChallenge
+}
+
+// NewPasskeyChallenges creates an in-memory challenge store; now defaults to time.Now
+func NewPasskeyChallenges(now func() time.Time) *PasskeyChallenges {
+	return &PasskeyChallenges{now: clock(now), challenges: map[string]models.PasskeyChallenge{}}
+}
+
+// StoreChallenge saves the challenge until its ExpiresAt
+func (r *PasskeyChallenges) StoreChallenge(ctx context.Context, challenge models.PasskeyChallenge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.challenges[challenge.Challenge] = challenge
+	return nil
+}
+
+// ConsumeChallenge removes the challenge, returning it only if it has not expired
+func (r *PasskeyChallenges) ConsumeChallenge(ctx context.Context, challenge string) (*models.PasskeyChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.challenges[challenge]
+	delete(r.challenges, challenge)
+	if !ok || !r.now().Before(stored.ExpiresAt) {
+		return nil, nil
+	}
+	return &stored, nil
+}
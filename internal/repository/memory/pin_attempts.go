@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+)
+
+// PINAttempts implements repository.PINAttemptStore
+type PINAttempts struct {
+	mu       sync.Mutex
+	attempts map[string]pinAttempts
+}
+
+type pinAttempts struct {
+	models.PINAttempts
+	expiresAt time.Time
+}
+
+func NewPINAttempts() *PINAttempts {
+	return &PINAttempts{attempts: map[string]pinAttempts{}}
+}
+
+func (r *PINAttempts) ReserveAttempt(ctx context.Context, userID string, now time.Time, window time.Duration, waits []time.Duration) (models.PINAttempts, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a := r.attempts[userID]
+	if !now.Before(a.expiresAt) {
+		a = pinAttempts{}
+	}
+	if a.Failures > 0 && len(waits) > 0 {
+		wait := waits[min(a.Failures, len(waits)-1)]
+		if a.LastFailure.Add(wait).After(now) {
+			return a.PINAttempts, false, nil
+		}
+	}
+	a.Failures++
+	a.LastFailure = now
+	a.expiresAt = now.Add(window)
+	r.attempts[userID] = a
+	return a.PINAttempts, true, nil
+}
+
+func (r *PINAttempts) GetAttempts(ctx context.Context, userID string, now time.Time) (models.PINAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.attempts[userID]
+	if !ok || !now.Before(a.expiresAt) {
+		return models.PINAttempts{}, nil
+	}
+	return a.PINAttempts, nil
+}
+
+func (r *PINAttempts) ClearAttempts(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, userID)
+	return nil
+}
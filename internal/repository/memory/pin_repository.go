@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+)
+
+// PinRepository keeps hashed PINs in memory
+type PinRepository struct {
+	mu   sync.RWMutex
+	pins map[string]models.PinData
+}
+
+func NewPinRepository() *PinRepository {
+	return &PinRepository{pins: map[string]models.PinData{}}
+}
+
+// Save stores the hashed PIN and salt for a user
+func (r *PinRepository) Save(ctx context.Context, userID string, data models.PinData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pins[userID] = data
+	return nil
+}
+
+// Get retrieves the stored PIN data for a user, or nil when no PIN is set
+func (r *PinRepository) Get(ctx context.Context, userID string) (*models.PinData, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	data, ok := r.pins[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &data, nil
+}
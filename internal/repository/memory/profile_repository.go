@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+)
+
+// ProfileRepository keeps onboarding profiles in memory
+type ProfileRepository struct {
+	mu       sync.RWMutex
+	profiles map[string]models.UserProfile
+}
+
+func NewProfileRepository() *ProfileRepository {
+	return &ProfileRepository{profiles: map[string]models.UserProfile{}}
+}
+
+func (r *ProfileRepository) Save(ctx context.Context, profile models.UserProfile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.profiles[profile.UserID] = copyProfile(profile)
+	return nil
+}
+
+func (r *ProfileRepository) Get(ctx context.Context, userID string) (*models.UserProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	profile, ok := r.profiles[userID]
+	if !ok {
+		return nil, nil
+	}
+	profile = copyProfile(profile)
+	return &profile, nil
+}
+
+// copyProfile detaches the nested sections so callers can't mutate stored data
+func copyProfile(p models.UserProfile) models.UserProfile {
+	if p.Personal != nil {
+		personal := *p.Personal
+		p.Personal = &personal
+	}
+	if p.Address != nil {
+		address := *p.Address
+		p.Address = &address
+	}
+	return p
+}
+
+// BusinessProfileRepository keeps business profiles in memory
+type BusinessProfileRepository struct {
+	mu       sync.RWMutex
+	profiles map[string]models.BusinessProfile
+}
+
+func NewBusinessProfileRepository() *BusinessProfileRepository {
+	return &BusinessProfileRepository{profiles: map[string]models.BusinessProfile{}}
+}
+
+func (r *BusinessProfileRepository) Save(ctx context.Context, profile models.BusinessProfile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.profiles[profile.UserID] = profile
+	return nil
+}
+
+func (r *BusinessProfileRepository) Get(ctx context.Context, userID string) (*models.BusinessProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	profile, ok := r.profiles[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &profile, nil
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+)
+
+// RateLimits implements repository.RateLimitStore
+type RateLimits struct {
+	mu  sync.Mutex
+	tat map[string]time.Time
+}
+
+func NewRateLimits() *RateLimits {
+	return &RateLimits{tat: map[string]time.Time{}}
+}
+
+func (r *RateLimits) Take(ctx context.Context, key string, limit int, period time.Duration, now time.Time) (models.RateLimitResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	interval := period / time.Duration(limit)
+	tat := r.tat[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(interval)
+	if allowAt := newTat.Add(-period); allowAt.After(now) {
+		return models.RateLimitResult{Limit: limit, RetryAfter: allowAt.Sub(now), ResetAfter: tat.Sub(now)}, nil
+	}
+	r.tat[key] = newTat
+	return models.RateLimitResult{
+		Allowed:    true,
+		Limit:      limit,
+		Remaining:  int((now.Add(period).Sub(newTat)) / interval),
+		ResetAfter: newTat.Sub(now),
+	}, nil
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SMSUsage implements repository.SMSUsageStore
+type SMSUsage struct {
+	mu     sync.Mutex
+	spend  map[string]int64 // hour + prefix -> spend
+	ranges map[string]map[string]time.Time
+}
+
+func NewSMSUsage() *SMSUsage {
+	return &SMSUsage{spend: map[string]int64{}, ranges: map[string]map[string]time.Time{}}
+}
+
+func spendKey(prefix string, now time.Time) string {
+	return now.Truncate(time.Hour).Format(time.RFC3339) + "|" + prefix
+}
+
+func (s *SMSUsage) HourlySpend(ctx context.Context, prefix string, now time.Time) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spend[spendKey(prefix, now)], s.spend[spendKey("all", now)], nil
+}
+
+func (s *SMSUsage) AddSpend(ctx context.Context, prefix string, cost, prefixCap, globalCap int64, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pk, gk := spendKey(prefix, now), spendKey("all", now)
+	if s.spend[pk]+cost > prefixCap || s.spend[gk]+cost > globalCap {
+		return false, nil
+	}
+	s.spend[pk] += cost
+	s.spend[gk] += cost
+	return true, nil
+}
+
+func (s *SMSUsage) TrackDestination(ctx context.Context, numberRange, number string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := s.ranges[numberRange]
+	if seen == nil {
+		seen = map[string]time.Time{}
+		s.ranges[numberRange] = seen
+	}
+	for n, at := range seen {
+		if at.Before(now.Add(-window)) {
+			delete(seen, n)
+		}
+	}
+	seen[number] = now
+	return len(seen), nil
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+)
+
+// TOTPRepository keeps authenticator-app enrollments in memory
+type TOTPRepository struct {
+	mu          sync.Mutex
+	enrollments map[string]models.TOTPEnrollment
+}
+
+func NewTOTPRepository() *TOTPRepository {
+	return &TOTPRepository{enrollments: map[string]models.TOTPEnrollment{}}
+}
+
+// Save replaces the user's enrollment
+func (r *TOTPRepository) Save(ctx context.Context, enrollment models.TOTPEnrollment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enrollment.RecoveryCodes = append([]string(nil), enrollment.RecoveryCodes...)
+	r.enrollments[enrollment.UserID] = enrollment
+	return nil
+}
+
+// Get returns the user's enrollment, or nil when there is none
+func (r *TOTPRepository) Get(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enrollment, ok := r.enrollments[userID]
+	if !ok {
+		return nil, nil
+	}
+	enrollment.RecoveryCodes = append([]string(nil), enrollment.RecoveryCodes...)
+	return &enrollment, nil
+}
+
+// Delete removes the user's enrollment
+func (r *TOTPRepository) Delete(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.enrollments, userID)
+	return nil
+}
+
+// UseStep accepts step if it is after the last accepted one
+func (r *TOTPRepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enrollment, ok := r.enrollments[userID]
+	if !ok || step <= enrollment.LastUsedStep {
+		return false, nil
+	}
+	enrollment.LastUsedStep = step
+	r.enrollments[userID] = enrollment
+	return true, nil
+}
+
+// UseRecoveryCode removes codeHash from the unused codes
+func (r *TOTPRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enrollment, ok := r.enrollments[userID]
+	if !ok {
+		return false, nil
+	}
+	for i, code := range enrollment.RecoveryCodes {
+		if code == codeHash {
+			enrollment.RecoveryCodes = append(enrollment.RecoveryCodes[:i:i], enrollment.RecoveryCodes[i+1:]...)
+			r.enrollments[userID] = enrollment
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// UsedTokens remembers redeemed single-use token IDs in memory until they expire
+type UsedTokens struct {
+	mu  sync.Mutex
+	now func() time.Time
+	ids map[string]time.Time // valued by expiry
+}
+
+// NewUsedTokens creates an in-memory used-token store; now defaults to time.Now
+func NewUsedTokens(now func() time.Time) *UsedTokens {
+	return &UsedTokens{now: clock(now), ids: map[string]time.Time{}}
+}
+
+// MarkUsed records the ID unless an unexpired record exists
+func (r *UsedTokens) MarkUsed(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if expiry, ok := r.ids[id]; ok && now.Before(expiry) {
+		return false, nil
+	}
+	r.ids[id] = now.Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+)
+
+// UserRepository keeps user accounts in memory with email and phone indexes
+type UserRepository struct {
+	mu      sync.RWMutex
+	users   map[string]models.User
+	byEmail map[string]string
+	byPhone map[string]string
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{
+		users:   map[string]models.User{},
+		byEmail: map[string]string{},
+		byPhone: map[string]string{},
+	}
+}
+
+// Create stores a new user, reserving its email and phone so they stay unique
+func (r *UserRepository) Create(ctx context.Context, user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, taken := r.byEmail[user.Email]; taken {
+		return repository.ErrUserExists
+	}
+	if _, taken := r.byPhone[user.Phone]; taken && user.Phone != "" {
+		return repository.ErrUserExists
+	}
+	r.byEmail[user.Email] = user.ID
+	if user.Phone != "" {
+		r.byPhone[user.Phone] = user.ID
+	}
+	r.users[user.ID] = user
+	return nil
+}
+
+// Save overwrites the stored user record
+func (r *UserRepository) Save(ctx context.Context, user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = user
+	return nil
+}
+
+// UpdatePhone saves the user after moving the phone index from oldPhone to user.Phone
+func (r *UserRepository) UpdatePhone(ctx context.Context, user models.User, oldPhone string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if owner, taken := r.byPhone[user.Phone]; taken && owner != user.ID {
+		return repository.ErrUserExists
+	}
+	if oldPhone != "" && oldPhone != user.Phone {
+		delete(r.byPhone, oldPhone)
+	}
+	if user.Phone != "" {
+		r.byPhone[user.Phone] = user.ID
+	}
+	r.users[user.ID] = user
+	return nil
+}
+
+// GetByID returns the user or nil when it does not exist
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.get(id), nil
+}
+
+// GetByEmail looks up a user by email address
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, ok := r.byEmail[email]
+	if !ok {
+		return nil, nil
+	}
+	return r.get(id), nil
+}
+
+// GetByPhone looks up a user by phone number
+func (r *UserRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, ok := r.byPhone[phone]
+	if !ok {
+		return nil, nil
+	}
+	return r.get(id), nil
+}
+
+func (r *UserRepository) get(id string) *models.User {
+	user, ok := r.users[id]
+	if !ok {
+		return nil
+	}
+	return &user
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// deadLetterLimit caps the dead-letter list; older entries are trimmed
+const deadLetterLimit = 1000
+
+// NotificationQueueRepository is a Redis-backed delivery queue
+// Key patterns:
+//   notify:job:{id}     -> NotificationJob JSON, kept for the retention period (only until
+//                          ExpiresAt while it still holds a payload)
+//   notify:ready        -> list of job IDs ready to send
+//   notify:delayed      -> zset of job IDs scored by next attempt (unix ms)
+//   notify:processing   -> zset of in-flight job IDs scored by lease expiry (unix ms)
+//   notify:dead         -> list of dead-lettered job IDs, newest first
+type NotificationQueueRepository struct {
+	client    *redis.Client
+	retention time.Duration
+}
+
+func NewNotificationQueueRepository(client *redis.Client, retention time.Duration) *NotificationQueueRepository {
+	return &NotificationQueueRepository{client: client, retention: retention}
+}
+
+const (
+	readyKey      = "notify:ready"
+	delayedKey    = "notify:delayed"
+	processingKey = "notify:processing"
+	deadKey       = "notify:dead"
+)
+
+func (r *NotificationQueueRepository) jobKey(id string) string {
+	return fmt.Sprintf("notify:job:%s", id)
+}
+
+func (r *NotificationQueueRepository) save(ctx context.Context, pipe redis.Pipeliner, job models.NotificationJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	ttl := r.retention
+	if len(job.Payload) > 0 && !job.ExpiresAt.IsZero() {
+		// Don't keep a code in Redis past its own expiry
+		if until := time.Until(job.ExpiresAt); until < ttl {
+			ttl = until
+		}
+		if ttl <= 0 {
+			pipe.Del(ctx, r.jobKey(job.ID))
+			return nil
+		}
+	}
+	pipe.Set(ctx, r.jobKey(job.ID), data, ttl)
+	return nil
+}
+
+// Enqueue stores the job and makes it ready to send
+func (r *NotificationQueueRepository) Enqueue(ctx context.Context, job models.NotificationJob) error {
+	pipe := r.client.TxPipeline()
+	if err := r.save(ctx, pipe, job); err != nil {
+		return err
+	}
+	pipe.LPush(ctx, readyKey, job.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// dequeueScript promotes due retries and expired leases, then leases the oldest ready job
+var dequeueScript = redis.NewScript(`
+for _, key in ipairs({KEYS[2], KEYS[3]}) do
+	local due = redis.call('ZRANGEBYSCORE', key, '-inf', ARGV[1], 'LIMIT', 0, 100)
+	for _, id in ipairs(due) do
+		redis.call('ZREM', key, id)
+		redis.call('LPUSH', KEYS[1], id)
+	end
+end
+local id = redis.call('RPOP', KEYS[1])
+if not id then
+	return false
+end
+redis.call('ZADD', KEYS[3], ARGV[2], id)
+return id
+`)
+
+// Dequeue leases the next job until now+lease; a job whose lease runs out before
+// it is completed, retried or buried is handed out again. It returns nil when idle.
+func (r *NotificationQueueRepository) Dequeue(ctx context.Context, now time.Time, lease time.Duration) (*models.NotificationJob, error) {
+	for {
+		id, err := dequeueScript.Run(ctx, r.client, []string{readyKey, delayedKey, processingKey},
+			now.UnixMilli(), now.Add(lease).UnixMilli()).Text()
+		if err == redis.Nil {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		job, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			return job, nil
+		}
+		// The record outlived its retention; drop the orphaned ID and keep looking
+		r.client.ZRem(ctx, processingKey, id)
+	}
+}
+
+// Complete records a delivered job and releases its lease
+func (r *NotificationQueueRepository) Complete(ctx context.Context, job models.NotificationJob) error {
+	pipe := r.client.TxPipeline()
+	if err := r.save(ctx, pipe, job); err != nil {
+		return err
+	}
+	pipe.ZRem(ctx, processingKey, job.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Retry records a failed attempt and schedules the job for job.NextAttemptAt
+func (r *NotificationQueueRepository) Retry(ctx context.Context, job models.NotificationJob) error {
+	pipe := r.client.TxPipeline()
+	if err := r.save(ctx, pipe, job); err != nil {
+		return err
+	}
+	pipe.ZRem(ctx, processingKey, job.ID)
+	pipe.ZAdd(ctx, delayedKey, &redis.Z{Score: float64(job.NextAttemptAt.UnixMilli()), Member: job.ID})
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Bury moves a job that will not be retried to the dead-letter list
+func (r *NotificationQueueRepository) Bury(ctx context.Context, job models.NotificationJob) error {
+	pipe := r.client.TxPipeline()
+	if err := r.save(ctx, pipe, job); err != nil {
+		return err
+	}
+	pipe.ZRem(ctx, processingKey, job.ID)
+	pipe.LPush(ctx, deadKey, job.ID)
+	pipe.LTrim(ctx, deadKey, 0, deadLetterLimit-1)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Get returns the job or nil when it is unknown or past retention
+func (r *NotificationQueueRepository) Get(ctx context.Context, id string) (*models.NotificationJob, error) {
+	data, err := r.client.Get(ctx, r.jobKey(id)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var job models.NotificationJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// DeadLetters returns up to limit dead jobs, newest first
+func (r *NotificationQueueRepository) DeadLetters(ctx context.Context, limit int) ([]models.NotificationJob, error) {
+	ids, err := r.client.LRange(ctx, deadKey, 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := []models.NotificationJob{}
+	for _, id := range ids {
+		job, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs, nil
+}
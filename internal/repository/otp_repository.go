@@ -2,8 +2,8 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/codeZe-us/vestroll-backend/internal/models"
@@ -22,71 +22,152 @@ func NewOTPRepository(client *redis.Client, ttl time.Duration) *OTPRepository {
 	}
 }
 
+// key namespaces codes by purpose and channel: otp:{purpose}:{type}:{identifier}
+func (r *OTPRepository) key(identifier string, purpose models.OTPPurpose, otpType models.OTPType) string {
+	return fmt.Sprintf("otp:%s:%s:%s", string(purpose.PurposeOrDefault()), string(otpType), identifier)
+}
+
+// StoreOTP replaces any pending code for the same identifier, purpose and channel
+// Codes are kept as a hash so attempts can be counted in place without resetting the TTL
 func (r *OTPRepository) StoreOTP(ctx context.Context, identifier string, otpData models.OTPData) error {
-	key := fmt.Sprintf("otp:%s:%s", string(otpData.Type), identifier)
-	
-	data, err := json.Marshal(otpData)
+	key := r.key(identifier, otpData.Purpose, otpData.Type)
+
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"code_hash":  otpData.CodeHash,
+		"type":       string(otpData.Type),
+		"purpose":    string(otpData.Purpose.PurposeOrDefault()),
+		"expires_at": otpData.ExpiresAt.UTC().Format(time.RFC3339Nano),
+		"attempts":   otpData.Attempts,
+	})
+	pipe.Expire(ctx, key, r.ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *OTPRepository) GetOTP(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType) (*models.OTPData, error) {
+	fields, err := r.client.HGetAll(ctx, r.key(identifier, purpose, otpType)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil // OTP not found or expired
+	}
+
+	attempts, err := strconv.Atoi(fields["attempts"])
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := time.Parse(time.RFC3339Nano, fields["expires_at"])
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return r.client.Set(ctx, key, data, r.ttl).Err()
+	return &models.OTPData{
+		CodeHash:  fields["code_hash"],
+		Type:      models.OTPType(fields["type"]),
+		Purpose:   models.OTPPurpose(fields["purpose"]),
+		ExpiresAt: expiresAt,
+		Attempts:  attempts,
+	}, nil
 }
 
-func (r *OTPRepository) GetOTP(ctx context.Context, identifier string, otpType models.OTPType) (*models.OTPData, error) {
-	key := fmt.Sprintf("otp:%s:%s", string(otpType), identifier)
-	
-	data, err := r.client.Get(ctx, key).Result()
+func (r *OTPRepository) DeleteOTP(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType) error {
+	return r.client.Del(ctx, r.key(identifier, purpose, otpType)).Err()
+}
+
+// registerAttemptScript counts an attempt and returns the stored hash in one step,
+// deleting the code once the attempt limit is passed
+var registerAttemptScript = redis.NewScript(`
+local hash = redis.call('HGET', KEYS[1], 'code_hash')
+if not hash then
+	return false
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+local expiresAt = redis.call('HGET', KEYS[1], 'expires_at')
+if attempts > tonumber(ARGV[1]) then
+	redis.call('DEL', KEYS[1])
+end
+return {hash, expiresAt, attempts}
+`)
+
+// consumeScript deletes the code only if it still holds the given hash
+var consumeScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'code_hash') == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RegisterAttempt atomically counts a verification attempt and returns the stored code,
+// with Attempts including this one. Once Attempts exceeds maxAttempts the code is gone.
+// It returns nil when no code is pending.
+func (r *OTPRepository) RegisterAttempt(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType, maxAttempts int) (*models.OTPData, error) {
+	res, err := registerAttemptScript.Run(ctx, r.client, []string{r.key(identifier, purpose, otpType)}, maxAttempts).Slice()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, nil // OTP not found or expired
+			return nil, nil
 		}
 		return nil, err
 	}
+	if len(res) != 3 {
+		return nil, fmt.Errorf("unexpected attempt script result: %v", res)
+	}
 
-	var otpData models.OTPData
-	if err := json.Unmarshal([]byte(data), &otpData); err != nil {
+	hash, _ := res[0].(string)
+	expires, _ := res[1].(string)
+	attempts, _ := res[2].(int64)
+	expiresAt, err := time.Parse(time.RFC3339Nano, expires)
+	if err != nil {
 		return nil, err
 	}
 
-	return &otpData, nil
-}
-
-func (r *OTPRepository) DeleteOTP(ctx context.Context, identifier string, otpType models.OTPType) error {
-	key := fmt.Sprintf("otp:%s:%s", string(otpType), identifier)
-	return r.client.Del(ctx, key).Err()
+	return &models.OTPData{
+		CodeHash:  hash,
+		Type:      otpType,
+		Purpose:   purpose.PurposeOrDefault(),
+		ExpiresAt: expiresAt,
+		Attempts:  int(attempts),
+	}, nil
 }
 
-func (r *OTPRepository) IncrementAttempts(ctx context.Context, identifier string, otpType models.OTPType) error {
-	otpData, err := r.GetOTP(ctx, identifier, otpType)
-	if err != nil || otpData == nil {
-		return err
+// ConsumeOTP deletes the code if it still matches codeHash and reports whether
+// this caller removed it, so a code can only be redeemed once
+func (r *OTPRepository) ConsumeOTP(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType, codeHash string) (bool, error) {
+	n, err := consumeScript.Run(ctx, r.client, []string{r.key(identifier, purpose, otpType)}, codeHash).Int()
+	if err != nil {
+		return false, err
 	}
-
-	otpData.Attempts++
-	return r.StoreOTP(ctx, identifier, *otpData)
+	return n == 1, nil
 }
 
 // Rate limiting methods
+// rateLimitScript counts one request unless the limit is reached, restarting the window
+var rateLimitScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// CheckRateLimit counts a request against rate_limit:otp:{identifier} and reports
+// whether it was within maxRequests; check and increment are one atomic step
 func (r *OTPRepository) CheckRateLimit(ctx context.Context, identifier string, maxRequests int, windowSize time.Duration) (bool, error) {
 	key := fmt.Sprintf("rate_limit:otp:%s", identifier)
-	
-	current, err := r.client.Get(ctx, key).Int()
-	if err != nil && err != redis.Nil {
+	allowed, err := rateLimitScript.Run(ctx, r.client, []string{key}, maxRequests, windowSize.Milliseconds()).Int()
+	if err != nil {
 		return false, err
 	}
+	return allowed == 1, nil
+}
 
-	if current >= maxRequests {
-		return false, nil // Rate limit exceeded
-	}
-
-	// Increment counter
-	pipe := r.client.Pipeline()
-	pipe.Incr(ctx, key)
-	pipe.Expire(ctx, key, windowSize)
-	_, err = pipe.Exec(ctx)
-	
-	return err == nil, err
+// ClearRateLimit deletes rate_limit:otp:{identifier}
+func (r *OTPRepository) ClearRateLimit(ctx context.Context, identifier string) error {
+	return r.client.Del(ctx, fmt.Sprintf("rate_limit:otp:%s", identifier)).Err()
 }
 
 func (r *OTPRepository) GetRemainingAttempts(ctx context.Context, identifier string, maxRequests int) (int, error) {
@@ -103,4 +184,38 @@ func (r *OTPRepository) GetRemainingAttempts(ctx context.Context, identifier str
 	}
 
 	return remaining, nil
-}
\ No newline at end of file
+}
+
+// RateLimitResetIn returns the time left on the identifier's send counter
+func (r *OTPRepository) RateLimitResetIn(ctx context.Context, identifier string) (time.Duration, error) {
+	return r.ttlOf(ctx, fmt.Sprintf("rate_limit:otp:%s", identifier))
+}
+
+// StartCooldown sets otp_cooldown:{identifier} unless it already exists
+func (r *OTPRepository) StartCooldown(ctx context.Context, identifier string, cooldown time.Duration) (bool, error) {
+	if cooldown <= 0 {
+		return true, nil
+	}
+	return r.client.SetNX(ctx, fmt.Sprintf("otp_cooldown:%s", identifier), 1, cooldown).Result()
+}
+
+// ClearCooldown deletes otp_cooldown:{identifier}
+func (r *OTPRepository) ClearCooldown(ctx context.Context, identifier string) error {
+	return r.client.Del(ctx, fmt.Sprintf("otp_cooldown:%s", identifier)).Err()
+}
+
+func (r *OTPRepository) CooldownRemaining(ctx context.Context, identifier string) (time.Duration, error) {
+	return r.ttlOf(ctx, fmt.Sprintf("otp_cooldown:%s", identifier))
+}
+
+// ttlOf returns a key's remaining lifetime, or 0 when it is missing or has no expiry
+func (r *OTPRepository) ttlOf(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
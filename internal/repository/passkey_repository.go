@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrPasskeyExists is returned when the credential ID is already registered
+var ErrPasskeyExists = errors.New("passkey already exists")
+
+// PasskeyRepository stores WebAuthn credentials in Redis
+// Key patterns:
+//   passkey:{credential_id}         -> PasskeyCredential JSON without SignCount and LastUsedAt
+//   passkey_counter:{credential_id} -> hash of sign_count and last_used_at (RFC 3339)
+//   user_passkeys:{user_id}         -> set of credential IDs
+type PasskeyRepository struct {
+	client *redis.Client
+}
+
+func NewPasskeyRepository(client *redis.Client) *PasskeyRepository {
+	return &PasskeyRepository{client: client}
+}
+
+func (r *PasskeyRepository) key(credentialID string) string {
+	return fmt.Sprintf("passkey:%s", credentialID)
+}
+
+func (r *PasskeyRepository) counterKey(credentialID string) string {
+	return fmt.Sprintf("passkey_counter:%s", credentialID)
+}
+
+func (r *PasskeyRepository) userKey(userID string) string {
+	return fmt.Sprintf("user_passkeys:%s", userID)
+}
+
+// Create stores a new credential; SETNX keeps credential IDs unique
+func (r *PasskeyRepository) Create(ctx context.Context, credential models.PasskeyCredential) error {
+	stored := credential
+	stored.SignCount = 0
+	stored.LastUsedAt = time.Time{}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	ok, err := r.client.SetNX(ctx, r.key(credential.ID), data, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPasskeyExists
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, r.counterKey(credential.ID), "sign_count", credential.SignCount, "last_used_at", credential.LastUsedAt.Format(time.RFC3339Nano))
+		pipe.SAdd(ctx, r.userKey(credential.UserID), credential.ID)
+		return nil
+	})
+	return err
+}
+
+// Get returns the credential, or nil when the ID is unknown
+func (r *PasskeyRepository) Get(ctx context.Context, credentialID string) (*models.PasskeyCredential, error) {
+	pipe := r.client.Pipeline()
+	get := pipe.Get(ctx, r.key(credentialID))
+	counter := pipe.HGetAll(ctx, r.counterKey(credentialID))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	val, err := get.Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var credential models.PasskeyCredential
+	if err := json.Unmarshal(val, &credential); err != nil {
+		return nil, err
+	}
+	fields := counter.Val()
+	var count uint64
+	if _, err := fmt.Sscan(fields["sign_count"], &count); err == nil {
+		credential.SignCount = uint32(count)
+	}
+	if usedAt, err := time.Parse(time.RFC3339Nano, fields["last_used_at"]); err == nil {
+		credential.LastUsedAt = usedAt
+	}
+	return &credential, nil
+}
+
+// ListByUser returns the user's credentials, oldest first
+func (r *PasskeyRepository) ListByUser(ctx context.Context, userID string) ([]models.PasskeyCredential, error) {
+	ids, err := r.client.SMembers(ctx, r.userKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	credentials := make([]models.PasskeyCredential, 0, len(ids))
+	for _, id := range ids {
+		credential, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if credential != nil {
+			credentials = append(credentials, *credential)
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool { return credentials[i].CreatedAt.Before(credentials[j].CreatedAt) })
+	return credentials, nil
+}
+
+// updateSignCountScript advances the counter only when the credential exists and the
+// counter grows, or stays at zero for authenticators that do not count
+var updateSignCountScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local stored = tonumber(redis.call('HGET', KEYS[2], 'sign_count') or '0')
+local count = tonumber(ARGV[1])
+if count <= stored and not (count == 0 and stored == 0) then
+	return 0
+end
+redis.call('HSET', KEYS[2], 'sign_count', ARGV[1], 'last_used_at', ARGV[2])
+return 1
+`)
+
+// UpdateSignCount atomically advances the credential's counter
+func (r *PasskeyRepository) UpdateSignCount(ctx context.Context, credentialID string, signCount uint32, usedAt time.Time) (bool, error) {
+	keys := []string{r.key(credentialID), r.counterKey(credentialID)}
+	res, err := updateSignCountScript.Run(ctx, r.client, keys, signCount, usedAt.Format(time.RFC3339Nano)).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// Delete removes the user's credential
+func (r *PasskeyRepository) Delete(ctx context.Context, userID, credentialID string) (bool, error) {
+	credential, err := r.Get(ctx, credentialID)
+	if err != nil || credential == nil || credential.UserID != userID {
+		return false, err
+	}
+	pipe := r.client.TxPipeline()
+	del := pipe.Del(ctx, r.key(credentialID))
+	pipe.Del(ctx, r.counterKey(credentialID))
+	pipe.SRem(ctx, r.userKey(userID), credentialID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return del.Val() == 1, nil
+}
+
+// PasskeyChallengeRepository stores ceremony challenges in Redis
+// Key pattern: passkey_challenge:{challenge} -> PasskeyChallenge JSON, expiring at ExpiresAt
+type PasskeyChallengeRepository struct {
+	client *redis.Client
+}
+
+func NewPasskeyChallengeRepository(client *redis.Client) *PasskeyChallengeRepository {
+	return &PasskeyChallengeRepository{client: client}
+}
+
+func (r *PasskeyChallengeRepository) key(challenge string) string {
+	return fmt.Sprintf("passkey_challenge:%s", challenge)
+}
+
+// StoreChallenge saves the challenge until it expires; an already expired one is dropped
+func (r *PasskeyChallengeRepository) StoreChallenge(ctx context.Context, challenge models.PasskeyChallenge) error {
+	ttl := time.Until(challenge.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, r.key(challenge.Challenge), data, ttl).Err()
+}
+
+// ConsumeChallenge removes the challenge with GETDEL so only one caller receives it
+func (r *PasskeyChallengeRepository) ConsumeChallenge(ctx context.Context, challenge string) (*models.PasskeyChallenge, error) {
+	val, err := r.client.GetDel(ctx, r.key(challenge)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var stored models.PasskeyChallenge
+	if err := json.Unmarshal(val, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
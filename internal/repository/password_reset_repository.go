@@ -9,31 +9,51 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+// PasswordResetRepository stores reset codes, verification attempts and reset tokens
+// Key patterns:
+//   password_reset:{identifier}          -> code JSON
+//   password_reset_attempts:{identifier} -> verification attempt counter, kept across resends
+//   password_reset_token:{token_hash}    -> identifier the token may reset
 type PasswordResetRepository struct {
-	client *redis.Client
+	client   *redis.Client
 	resetTTL time.Duration
 }
 
 func NewPasswordResetRepository(client *redis.Client, resetTTL time.Duration) *PasswordResetRepository {
 	return &PasswordResetRepository{
-		client: client,
+		client:   client,
 		resetTTL: resetTTL,
 	}
 }
 
+func (r *PasswordResetRepository) codeKey(identifier string) string {
+	return fmt.Sprintf("password_reset:%s", identifier)
+}
+
+func (r *PasswordResetRepository) attemptsKey(identifier string) string {
+	return fmt.Sprintf("password_reset_attempts:%s", identifier)
+}
+
+func (r *PasswordResetRepository) tokenKey(hash string) string {
+	return fmt.Sprintf("password_reset_token:%s", hash)
+}
+
+// StoreResetCode saves a new code; attempts made against earlier codes still count
 func (r *PasswordResetRepository) StoreResetCode(ctx context.Context, identifier, code string) error {
-	key := fmt.Sprintf("password_reset:%s", identifier)
 	data, _ := json.Marshal(map[string]interface{}{
-		"code": code,
+		"code":       code,
 		"created_at": time.Now().Unix(),
 	})
-	return r.client.Set(ctx, key, data, r.resetTTL).Err()
+	return r.client.Set(ctx, r.codeKey(identifier), data, r.resetTTL).Err()
 }
 
+// GetResetCode returns the pending code, or "" when none exists or it expired
 func (r *PasswordResetRepository) GetResetCode(ctx context.Context, identifier string) (string, error) {
-	key := fmt.Sprintf("password_reset:%s", identifier)
-	data, err := r.client.Get(ctx, key).Result()
+	data, err := r.client.Get(ctx, r.codeKey(identifier)).Result()
 	if err != nil {
+		if err == redis.Nil {
+			return "", nil
+		}
 		return "", err
 	}
 	var result map[string]interface{}
@@ -47,7 +67,50 @@ func (r *PasswordResetRepository) GetResetCode(ctx context.Context, identifier s
 	return code, nil
 }
 
+// IncrementAttempts atomically counts a verification attempt and returns the new total.
+// The counter expires one reset TTL after the last attempt.
+func (r *PasswordResetRepository) IncrementAttempts(ctx context.Context, identifier string) (int, error) {
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, r.attemptsKey(identifier))
+	pipe.Expire(ctx, r.attemptsKey(identifier), r.resetTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(incr.Val()), nil
+}
+
+// Attempts returns the verification attempts counted for the identifier
+func (r *PasswordResetRepository) Attempts(ctx context.Context, identifier string) (int, error) {
+	n, err := r.client.Get(ctx, r.attemptsKey(identifier)).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// ClearAttempts forgets the identifier's verification attempts
+func (r *PasswordResetRepository) ClearAttempts(ctx context.Context, identifier string) error {
+	return r.client.Del(ctx, r.attemptsKey(identifier)).Err()
+}
+
+// DeleteResetCode removes the pending code; its attempts stay counted
 func (r *PasswordResetRepository) DeleteResetCode(ctx context.Context, identifier string) error {
-	key := fmt.Sprintf("password_reset:%s", identifier)
-	return r.client.Del(ctx, key).Err()
+	return r.client.Del(ctx, r.codeKey(identifier)).Err()
+}
+
+// StoreResetToken saves a reset token hash that authorizes resetting the identifier's password
+func (r *PasswordResetRepository) StoreResetToken(ctx context.Context, hash, identifier string, ttl time.Duration) error {
+	return r.client.Set(ctx, r.tokenKey(hash), identifier, ttl).Err()
+}
+
+// ConsumeResetToken atomically reads and deletes a reset token, returning "" if it is unknown
+func (r *PasswordResetRepository) ConsumeResetToken(ctx context.Context, hash string) (string, error) {
+	identifier, err := r.client.GetDel(ctx, r.tokenKey(hash)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", nil
+		}
+		return "", err
+	}
+	return identifier, nil
 }
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// PINAttemptRepository counts wrong PINs in Redis
+// Key patterns:
+//   pin_attempts:{user_id} -> hash of failures and last_failure (unix ms), expiring window after the last attempt
+type PINAttemptRepository struct {
+	client *redis.Client
+}
+
+func NewPINAttemptRepository(client *redis.Client) *PINAttemptRepository {
+	return &PINAttemptRepository{client: client}
+}
+
+func (r *PINAttemptRepository) key(userID string) string {
+	return fmt.Sprintf("pin_attempts:%s", userID)
+}
+
+// reserveAttemptScript refuses the attempt while the wait after the last one
+// runs, and otherwise counts it and moves the expiry in one step.
+// ARGV: now (unix ms), window (ms), then the wait (ms) for 0, 1, 2... failures
+var reserveAttemptScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local failures = tonumber(redis.call('HGET', KEYS[1], 'failures') or '0')
+if failures > 0 and #ARGV > 2 then
+	local wait = tonumber(ARGV[math.min(failures, #ARGV - 3) + 3])
+	local last = tonumber(redis.call('HGET', KEYS[1], 'last_failure') or '0')
+	if last + wait > now then
+		return {0, failures, last}
+	end
+end
+failures = redis.call('HINCRBY', KEYS[1], 'failures', 1)
+redis.call('HSET', KEYS[1], 'last_failure', now)
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return {1, failures, now}
+`)
+
+// ReserveAttempt atomically counts an attempt at now unless the user must wait
+func (r *PINAttemptRepository) ReserveAttempt(ctx context.Context, userID string, now time.Time, window time.Duration, waits []time.Duration) (models.PINAttempts, bool, error) {
+	args := []interface{}{now.UnixMilli(), window.Milliseconds()}
+	for _, wait := range waits {
+		args = append(args, wait.Milliseconds())
+	}
+	res, err := reserveAttemptScript.Run(ctx, r.client, []string{r.key(userID)}, args...).Int64Slice()
+	if err != nil {
+		return models.PINAttempts{}, false, err
+	}
+	return models.PINAttempts{Failures: int(res[1]), LastFailure: time.UnixMilli(res[2])}, res[0] == 1, nil
+}
+
+// GetAttempts returns the user's wrong PIN count, zero when none are remembered
+func (r *PINAttemptRepository) GetAttempts(ctx context.Context, userID string, now time.Time) (models.PINAttempts, error) {
+	vals, err := r.client.HMGet(ctx, r.key(userID), "failures", "last_failure").Result()
+	if err != nil {
+		return models.PINAttempts{}, err
+	}
+	var attempts models.PINAttempts
+	if s, ok := vals[0].(string); ok {
+		if attempts.Failures, err = strconv.Atoi(s); err != nil {
+			return models.PINAttempts{}, err
+		}
+	}
+	if s, ok := vals[1].(string); ok {
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return models.PINAttempts{}, err
+		}
+		attempts.LastFailure = time.UnixMilli(ms)
+	}
+	return attempts, nil
+}
+
+// ClearAttempts forgets the user's wrong PINs
+func (r *PINAttemptRepository) ClearAttempts(ctx context.Context, userID string) error {
+	return r.client.Del(ctx, r.key(userID)).Err()
+}
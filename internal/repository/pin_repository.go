@@ -33,15 +33,18 @@ func (r *PinRepository) Save(ctx context.Context, userID string, data models.Pin
 	return r.client.Set(ctx, r.key(userID), string(b), r.ttl).Err()
 }
 
-// Get retrieves the stored PIN data for a user
-func (r *PinRepository) Get(ctx context.Context, userID string) (models.PinData, error) {
+// Get retrieves the stored PIN data for a user, or nil when no PIN is set
+func (r *PinRepository) Get(ctx context.Context, userID string) (*models.PinData, error) {
 	val, err := r.client.Get(ctx, r.key(userID)).Result()
 	if err != nil {
-		return models.PinData{}, err
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
 	}
 	var data models.PinData
 	if err := json.Unmarshal([]byte(val), &data); err != nil {
-		return models.PinData{}, err
+		return nil, err
 	}
-	return data, nil
+	return &data, nil
 }
\ No newline at end of file
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BusinessProfileRepository stores business profiles in the business_profiles table
+type BusinessProfileRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewBusinessProfileRepository(pool *pgxpool.Pool) *BusinessProfileRepository {
+	return &BusinessProfileRepository{pool: pool}
+}
+
+// Save upserts the business profile for profile.UserID
+func (r *BusinessProfileRepository) Save(ctx context.Context, profile models.BusinessProfile) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO business_profiles (user_id, account_type, business_name, registration_number, tax_id,
+		                               address, contact, completed, completion_percent, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id) DO UPDATE
+		SET account_type = EXCLUDED.account_type, business_name = EXCLUDED.business_name,
+		    registration_number = EXCLUDED.registration_number, tax_id = EXCLUDED.tax_id,
+		    address = EXCLUDED.address, contact = EXCLUDED.contact, completed = EXCLUDED.completed,
+		    completion_percent = EXCLUDED.completion_percent, updated_at = EXCLUDED.updated_at`,
+		profile.UserID, profile.AccountType, profile.BusinessName, profile.RegistrationNumber, profile.TaxID,
+		profile.Address, profile.Contact, profile.Completed, profile.CompletionPercent, profile.UpdatedAt)
+	return err
+}
+
+// Get returns the business profile or nil when the user has none
+func (r *BusinessProfileRepository) Get(ctx context.Context, userID string) (*models.BusinessProfile, error) {
+	var p models.BusinessProfile
+	err := r.pool.QueryRow(ctx, `
+		SELECT user_id, account_type, business_name, registration_number, tax_id,
+		       address, contact, completed, completion_percent, updated_at
+		FROM business_profiles WHERE user_id = $1`, userID).Scan(
+		&p.UserID, &p.AccountType, &p.BusinessName, &p.RegistrationNumber, &p.TaxID,
+		&p.Address, &p.Contact, &p.Completed, &p.CompletionPercent, &p.UpdatedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DeviceRepository stores trusted devices in the user_devices table
+type DeviceRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewDeviceRepository(pool *pgxpool.Pool) *DeviceRepository {
+	return &DeviceRepository{pool: pool}
+}
+
+const deviceColumns = `device_id, user_id, platform, name, public_key, created_at, last_used_at`
+
+// Create inserts a new device; the primary key keeps device IDs unique per user
+func (r *DeviceRepository) Create(ctx context.Context, d models.Device) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO user_devices (`+deviceColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		d.ID, d.UserID, d.Platform, d.Name, d.PublicKey, d.CreatedAt, d.LastUsedAt)
+	if isUniqueViolation(err) {
+		return repository.ErrDeviceExists
+	}
+	return err
+}
+
+// Get returns the device, or nil when the user has no device with that ID
+func (r *DeviceRepository) Get(ctx context.Context, userID, deviceID string) (*models.Device, error) {
+	var d models.Device
+	err := r.pool.QueryRow(ctx, `SELECT `+deviceColumns+` FROM user_devices WHERE user_id = $1 AND device_id = $2`, userID, deviceID).
+		Scan(&d.ID, &d.UserID, &d.Platform, &d.Name, &d.PublicKey, &d.CreatedAt, &d.LastUsedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// List returns the user's devices, oldest first
+func (r *DeviceRepository) List(ctx context.Context, userID string) ([]models.Device, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+deviceColumns+` FROM user_devices WHERE user_id = $1 ORDER BY created_at, device_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	devices := []models.Device{}
+	for rows.Next() {
+		var d models.Device
+		if err := rows.Scan(&d.ID, &d.UserID, &d.Platform, &d.Name, &d.PublicKey, &d.CreatedAt, &d.LastUsedAt); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+// Touch sets the device's last use time
+func (r *DeviceRepository) Touch(ctx context.Context, userID, deviceID string, at time.Time) error {
+	_, err := r.pool.Exec(ctx, `UPDATE user_devices SET last_used_at = $3 WHERE user_id = $1 AND device_id = $2`, userID, deviceID, at)
+	return err
+}
+
+// Delete removes the device
+func (r *DeviceRepository) Delete(ctx context.Context, userID, deviceID string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM user_devices WHERE user_id = $1 AND device_id = $2`, userID, deviceID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
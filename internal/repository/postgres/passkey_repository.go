@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PasskeyRepository stores WebAuthn credentials in the user_passkeys table
+type PasskeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPasskeyRepository(pool *pgxpool.Pool) *PasskeyRepository {
+	return &PasskeyRepository{pool: pool}
+}
+
+const passkeyColumns = `credential_id, user_id, name, public_key, algorithm, sign_count, transports, aaguid, created_at, last_used_at`
+
+func scanPasskey(row pgx.Row) (models.PasskeyCredential, error) {
+	var p models.PasskeyCredential
+	var signCount int64
+	err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.PublicKey, &p.Algorithm, &signCount, &p.Transports, &p.AAGUID, &p.CreatedAt, &p.LastUsedAt)
+	p.SignCount = uint32(signCount)
+	if len(p.Transports) == 0 {
+		p.Transports = nil
+	}
+	return p, err
+}
+
+// Create inserts a new credential; the primary key keeps credential IDs unique
+func (r *PasskeyRepository) Create(ctx context.Context, p models.PasskeyCredential) error {
+	transports := p.Transports
+	if transports == nil {
+		transports = []string{}
+	}
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO user_passkeys (`+passkeyColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		p.ID, p.UserID, p.Name, p.PublicKey, p.Algorithm, int64(p.SignCount), transports, p.AAGUID, p.CreatedAt, p.LastUsedAt)
+	if isUniqueViolation(err) {
+		return repository.ErrPasskeyExists
+	}
+	return err
+}
+
+// Get returns the credential, or nil when the ID is unknown
+func (r *PasskeyRepository) Get(ctx context.Context, credentialID string) (*models.PasskeyCredential, error) {
+	p, err := scanPasskey(r.pool.QueryRow(ctx, `SELECT `+passkeyColumns+` FROM user_passkeys WHERE credential_id = $1`, credentialID))
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListByUser returns the user's credentials, oldest first
+func (r *PasskeyRepository) ListByUser(ctx context.Context, userID string) ([]models.PasskeyCredential, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+passkeyColumns+` FROM user_passkeys WHERE user_id = $1 ORDER BY created_at, credential_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	credentials := []models.PasskeyCredential{}
+	for rows.Next() {
+		p, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, p)
+	}
+	return credentials, rows.Err()
+}
+
+// UpdateSignCount advances sign_count in a single conditional update
+func (r *PasskeyRepository) UpdateSignCount(ctx context.Context, credentialID string, signCount uint32, usedAt time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE user_passkeys SET sign_count = $2, last_used_at = $3
+		WHERE credential_id = $1 AND ($2 > sign_count OR ($2 = 0 AND sign_count = 0))`,
+		credentialID, int64(signCount), usedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Delete removes the user's credential
+func (r *PasskeyRepository) Delete(ctx context.Context, userID, credentialID string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM user_passkeys WHERE user_id = $1 AND credential_id = $2`, userID, credentialID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
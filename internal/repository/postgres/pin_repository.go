@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PinRepository stores hashed PINs in the user_pins table
+type PinRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPinRepository(pool *pgxpool.Pool) *PinRepository {
+	return &PinRepository{pool: pool}
+}
+
+// Save stores the hashed PIN for a user, replacing any previous PIN
+func (r *PinRepository) Save(ctx context.Context, userID string, data models.PinData) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO user_pins (user_id, version, salt, hash, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET version = EXCLUDED.version, salt = EXCLUDED.salt, hash = EXCLUDED.hash, created_at = EXCLUDED.created_at`,
+		userID, data.Version, data.Salt, data.Hash, data.CreatedAt)
+	return err
+}
+
+// Get retrieves the stored PIN data for a user, or nil when no PIN is set
+func (r *PinRepository) Get(ctx context.Context, userID string) (*models.PinData, error) {
+	var data models.PinData
+	err := r.pool.QueryRow(ctx, `SELECT version, salt, hash, created_at FROM user_pins WHERE user_id = $1`, userID).
+		Scan(&data.Version, &data.Salt, &data.Hash, &data.CreatedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &data, nil
+}
@@ -0,0 +1,34 @@
+// Package postgres implements the durable repository interfaces on PostgreSQL.
+// Tables are created by the SQL files in migrations/ (see internal/migrate).
+// Ephemeral data (OTPs, rate limits, refresh tokens) stays in Redis.
+package postgres
+
+import (
+	"errors"
+
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueViolation is the SQLSTATE for unique constraint violations
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
+
+var (
+	_ repository.UserStore            = (*UserRepository)(nil)
+	_ repository.ProfileStore         = (*ProfileRepository)(nil)
+	_ repository.BusinessProfileStore = (*BusinessProfileRepository)(nil)
+	_ repository.PINStore             = (*PinRepository)(nil)
+	_ repository.TOTPStore            = (*TOTPRepository)(nil)
+	_ repository.DeviceStore          = (*DeviceRepository)(nil)
+	_ repository.PasskeyStore         = (*PasskeyRepository)(nil)
+)
@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codeZe-us/vestroll-backend/internal/migrate"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/repotest"
+	"github.com/codeZe-us/vestroll-backend/internal/testdb"
+	"github.com/codeZe-us/vestroll-backend/migrations"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestMain(m *testing.M) {
+	testdb.Main(m)
+}
+
+// newTestPool returns a pool on the harness database (see internal/testdb)
+// with every migration applied in a private schema
+func newTestPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	pool := testdb.NewPool(t, "test")
+	migrator, err := migrate.New(pool, migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate up error: %v", err)
+	}
+	return pool
+}
+
+func TestUserStore(t *testing.T) {
+	repotest.RunUserStore(t, func(t *testing.T) repository.UserStore { return NewUserRepository(newTestPool(t)) })
+}
+
+func TestProfileStore(t *testing.T) {
+	repotest.RunProfileStore(t, func(t *testing.T) repository.ProfileStore { return NewProfileRepository(newTestPool(t)) })
+}
+
+func TestBusinessProfileStore(t *testing.T) {
+	repotest.RunBusinessProfileStore(t, func(t *testing.T) repository.BusinessProfileStore {
+		return NewBusinessProfileRepository(newTestPool(t))
+	})
+}
+
+func TestPINStore(t *testing.T) {
+	repotest.RunPINStore(t, func(t *testing.T) repository.PINStore { return NewPinRepository(newTestPool(t)) })
+}
+
+func TestTOTPStore(t *testing.T) {
+	repotest.RunTOTPStore(t, func(t *testing.T) repository.TOTPStore { return NewTOTPRepository(newTestPool(t)) })
+}
+
+func TestDeviceStore(t *testing.T) {
+	repotest.RunDeviceStore(t, func(t *testing.T) repository.DeviceStore { return NewDeviceRepository(newTestPool(t)) })
+}
+
+func TestPasskeyStore(t *testing.T) {
+	repotest.RunPasskeyStore(t, func(t *testing.T) repository.PasskeyStore { return NewPasskeyRepository(newTestPool(t)) })
+}
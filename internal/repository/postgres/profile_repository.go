@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ProfileRepository stores onboarding profiles in the user_profiles table
+// Personal details and address are kept as JSONB documents
+type ProfileRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewProfileRepository(pool *pgxpool.Pool) *ProfileRepository {
+	return &ProfileRepository{pool: pool}
+}
+
+// Save upserts the profile for profile.UserID
+func (r *ProfileRepository) Save(ctx context.Context, profile models.UserProfile) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO user_profiles (user_id, account_type, personal, address, locale, completed, completion_percent, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id) DO UPDATE
+		SET account_type = EXCLUDED.account_type, personal = EXCLUDED.personal,
+		    address = EXCLUDED.address, locale = EXCLUDED.locale, completed = EXCLUDED.completed,
+		    completion_percent = EXCLUDED.completion_percent, updated_at = EXCLUDED.updated_at`,
+		profile.UserID, profile.AccountType, profile.Personal, profile.Address, profile.Locale,
+		profile.Completed, profile.CompletionPercent, profile.UpdatedAt)
+	return err
+}
+
+// Get returns the profile or nil when the user has none
+func (r *ProfileRepository) Get(ctx context.Context, userID string) (*models.UserProfile, error) {
+	var p models.UserProfile
+	err := r.pool.QueryRow(ctx, `
+		SELECT user_id, account_type, personal, address, locale, completed, completion_percent, updated_at
+		FROM user_profiles WHERE user_id = $1`, userID).Scan(
+		&p.UserID, &p.AccountType, &p.Personal, &p.Address, &p.Locale,
+		&p.Completed, &p.CompletionPercent, &p.UpdatedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TOTPRepository stores authenticator-app enrollments in the user_totp table
+type TOTPRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTOTPRepository(pool *pgxpool.Pool) *TOTPRepository {
+	return &TOTPRepository{pool: pool}
+}
+
+// Save replaces the user's enrollment
+func (r *TOTPRepository) Save(ctx context.Context, e models.TOTPEnrollment) error {
+	codes := e.RecoveryCodes
+	if codes == nil {
+		codes = []string{}
+	}
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO user_totp (user_id, secret, confirmed, last_used_step, recovery_codes, created_at, confirmed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, confirmed = EXCLUDED.confirmed, last_used_step = EXCLUDED.last_used_step,
+		    recovery_codes = EXCLUDED.recovery_codes, created_at = EXCLUDED.created_at, confirmed_at = EXCLUDED.confirmed_at`,
+		e.UserID, e.Secret, e.Confirmed, e.LastUsedStep, codes, e.CreatedAt, e.ConfirmedAt)
+	return err
+}
+
+// Get returns the user's enrollment, or nil when there is none
+func (r *TOTPRepository) Get(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	e := models.TOTPEnrollment{UserID: userID}
+	err := r.pool.QueryRow(ctx, `
+		SELECT secret, confirmed, last_used_step, recovery_codes, created_at, confirmed_at
+		FROM user_totp WHERE user_id = $1`, userID).
+		Scan(&e.Secret, &e.Confirmed, &e.LastUsedStep, &e.RecoveryCodes, &e.CreatedAt, &e.ConfirmedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Delete removes the user's enrollment
+func (r *TOTPRepository) Delete(ctx context.Context, userID string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+	return err
+}
+
+// UseStep advances last_used_step in a single conditional update
+func (r *TOTPRepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode removes codeHash in a single conditional update
+func (r *TOTPRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE user_totp SET recovery_codes = array_remove(recovery_codes, $2)
+		WHERE user_id = $1 AND $2 = ANY(recovery_codes)`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserRepository stores user accounts in the users table
+type UserRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewUserRepository(pool *pgxpool.Pool) *UserRepository {
+	return &UserRepository{pool: pool}
+}
+
+const userColumns = `id, email, phone, password_hash, email_verified, phone_verified, created_at, updated_at`
+
+// Create inserts a new user; unique email/phone constraints report ErrUserExists
+func (r *UserRepository) Create(ctx context.Context, user models.User) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO users (`+userColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		user.ID, user.Email, nullable(user.Phone), user.PasswordHash,
+		user.EmailVerified, user.PhoneVerified, user.CreatedAt, user.UpdatedAt)
+	if isUniqueViolation(err) {
+		return repository.ErrUserExists
+	}
+	return err
+}
+
+// Save overwrites the stored user record
+func (r *UserRepository) Save(ctx context.Context, user models.User) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE users
+		SET email = $2, phone = $3, password_hash = $4, email_verified = $5,
+		    phone_verified = $6, updated_at = $7
+		WHERE id = $1`,
+		user.ID, user.Email, nullable(user.Phone), user.PasswordHash,
+		user.EmailVerified, user.PhoneVerified, user.UpdatedAt)
+	if isUniqueViolation(err) {
+		return repository.ErrUserExists
+	}
+	return err
+}
+
+// UpdatePhone saves the user; the unique phone column moves the index with it
+func (r *UserRepository) UpdatePhone(ctx context.Context, user models.User, oldPhone string) error {
+	return r.Save(ctx, user)
+}
+
+// GetByID returns the user or nil when it does not exist
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	return r.getBy(ctx, "id", id)
+}
+
+// GetByEmail looks up a user by email address
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.getBy(ctx, "email", email)
+}
+
+// GetByPhone looks up a user by phone number
+func (r *UserRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	return r.getBy(ctx, "phone", phone)
+}
+
+// getBy selects on one of the fixed column names above; never pass user input as column
+func (r *UserRepository) getBy(ctx context.Context, column, value string) (*models.User, error) {
+	var user models.User
+	var phone *string
+	err := r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE `+column+` = $1`, value).Scan(
+		&user.ID, &user.Email, &phone, &user.PasswordHash,
+		&user.EmailVerified, &user.PhoneVerified, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if isNoRows(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if phone != nil {
+		user.Phone = *phone
+	}
+	return &user, nil
+}
+
+// nullable stores empty strings as NULL so optional unique columns don't collide
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
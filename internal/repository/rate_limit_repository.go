@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// RateLimitRepository keeps rate limits in Redis so every replica shares them
+// Key patterns:
+//   ratelimit:{key} -> theoretical arrival time of the next request (unix ms)
+type RateLimitRepository struct {
+	client *redis.Client
+}
+
+func NewRateLimitRepository(client *redis.Client) *RateLimitRepository {
+	return &RateLimitRepository{client: client}
+}
+
+// gcraScript returns {allowed, remaining, retry_after_ms, reset_after_ms}
+var gcraScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local tat = tonumber(redis.call('GET', KEYS[1]) or ARGV[1])
+if tat < now then
+	tat = now
+end
+local newTat = tat + interval
+if newTat - period > now then
+	return {0, 0, math.ceil(newTat - period - now), math.ceil(tat - now)}
+end
+redis.call('SET', KEYS[1], tostring(newTat), 'PX', math.ceil(newTat - now))
+return {1, math.floor((now + period - newTat) / interval), 0, math.ceil(newTat - now)}
+`)
+
+// Take counts one request against key
+func (r *RateLimitRepository) Take(ctx context.Context, key string, limit int, period time.Duration, now time.Time) (models.RateLimitResult, error) {
+	periodMs := float64(period.Milliseconds())
+	args := []interface{}{now.UnixMilli(), periodMs / float64(limit), periodMs}
+	res, err := gcraScript.Run(ctx, r.client, []string{fmt.Sprintf("ratelimit:%s", key)}, args...).Int64Slice()
+	if err != nil {
+		return models.RateLimitResult{}, err
+	}
+	return models.RateLimitResult{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		ResetAfter: time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// RefreshTokenRepository stores refresh tokens and session families in Redis
+// Key patterns:
+//   refresh_token:{token_hash}      -> RefreshTokenRecord JSON
+//   refresh_token_used:{token_hash} -> marker set when the token is rotated
+//   refresh_family:{family_id}      -> user ID while the session is active
+//   user_sessions:{user_id}         -> set of active family IDs
+//   device_sessions:{user_id}:{device_id} -> set of family IDs bound to the device
+//   session_devices:{user_id}       -> set of device IDs with a device_sessions set
+type RefreshTokenRepository struct {
+	client *redis.Client
+}
+
+func NewRefreshTokenRepository(client *redis.Client) *RefreshTokenRepository {
+	return &RefreshTokenRepository{client: client}
+}
+
+func (r *RefreshTokenRepository) tokenKey(hash string) string {
+	return fmt.Sprintf("refresh_token:%s", hash)
+}
+
+func (r *RefreshTokenRepository) usedKey(hash string) string {
+	return fmt.Sprintf("refresh_token_used:%s", hash)
+}
+
+func (r *RefreshTokenRepository) familyKey(familyID string) string {
+	return fmt.Sprintf("refresh_family:%s", familyID)
+}
+
+func (r *RefreshTokenRepository) sessionsKey(userID string) string {
+	return fmt.Sprintf("user_sessions:%s", userID)
+}
+
+func (r *RefreshTokenRepository) deviceKey(userID, deviceID string) string {
+	return fmt.Sprintf("device_sessions:%s:%s", userID, deviceID)
+}
+
+func (r *RefreshTokenRepository) devicesKey(userID string) string {
+	return fmt.Sprintf("session_devices:%s", userID)
+}
+
+// CreateFamily starts a new session family for the user, bound to deviceID unless it is empty
+func (r *RefreshTokenRepository) CreateFamily(ctx context.Context, userID, familyID, deviceID string, ttl time.Duration) error {
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, r.familyKey(familyID), userID, ttl)
+	pipe.SAdd(ctx, r.sessionsKey(userID), familyID)
+	pipe.Expire(ctx, r.sessionsKey(userID), ttl)
+	if deviceID != "" {
+		pipe.SAdd(ctx, r.deviceKey(userID, deviceID), familyID)
+		pipe.Expire(ctx, r.deviceKey(userID, deviceID), ttl)
+		pipe.SAdd(ctx, r.devicesKey(userID), deviceID)
+		pipe.Expire(ctx, r.devicesKey(userID), ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// TouchFamily extends an active family's lifetime after a rotation, along with
+// the user and device sets that let it be revoked
+func (r *RefreshTokenRepository) TouchFamily(ctx context.Context, userID, familyID, deviceID string, ttl time.Duration) error {
+	pipe := r.client.TxPipeline()
+	pipe.Expire(ctx, r.familyKey(familyID), ttl)
+	pipe.Expire(ctx, r.sessionsKey(userID), ttl)
+	if deviceID != "" {
+		pipe.Expire(ctx, r.deviceKey(userID, deviceID), ttl)
+		pipe.Expire(ctx, r.devicesKey(userID), ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// FamilyActive reports whether the session family has not been revoked or expired
+func (r *RefreshTokenRepository) FamilyActive(ctx context.Context, familyID string) (bool, error) {
+	n, err := r.client.Exists(ctx, r.familyKey(familyID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// RevokeFamily ends a single session
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, userID, familyID string) error {
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, r.familyKey(familyID))
+	pipe.SRem(ctx, r.sessionsKey(userID), familyID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RevokeAll ends every session belonging to the user, along with its device sets
+func (r *RefreshTokenRepository) RevokeAll(ctx context.Context, userID string) error {
+	families, err := r.client.SMembers(ctx, r.sessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	devices, err := r.client.SMembers(ctx, r.devicesKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{r.sessionsKey(userID), r.devicesKey(userID)}
+	for _, familyID := range families {
+		keys = append(keys, r.familyKey(familyID))
+	}
+	for _, deviceID := range devices {
+		keys = append(keys, r.deviceKey(userID, deviceID))
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// RevokeDevice ends every session bound to the user's device
+func (r *RefreshTokenRepository) RevokeDevice(ctx context.Context, userID, deviceID string) error {
+	families, err := r.client.SMembers(ctx, r.deviceKey(userID, deviceID)).Result()
+	if err != nil {
+		return err
+	}
+	pipe := r.client.TxPipeline()
+	for _, familyID := range families {
+		pipe.Del(ctx, r.familyKey(familyID))
+		pipe.SRem(ctx, r.sessionsKey(userID), familyID)
+	}
+	pipe.Del(ctx, r.deviceKey(userID, deviceID))
+	pipe.SRem(ctx, r.devicesKey(userID), deviceID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// StoreToken saves a refresh token record under its hash until it expires
+func (r *RefreshTokenRepository) StoreToken(ctx context.Context, hash string, record models.RefreshTokenRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, r.tokenKey(hash), data, time.Until(record.ExpiresAt)).Err()
+}
+
+// GetToken loads a refresh token without using it up. It returns a nil record
+// when the token is unknown or expired, and used=true when it was already rotated.
+func (r *RefreshTokenRepository) GetToken(ctx context.Context, hash string) (*models.RefreshTokenRecord, bool, error) {
+	record, err := r.loadToken(ctx, hash)
+	if err != nil || record == nil {
+		return nil, false, err
+	}
+	n, err := r.client.Exists(ctx, r.usedKey(hash)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	return record, n == 1, nil
+}
+
+// ConsumeToken loads a refresh token and atomically marks it as used.
+// It returns a nil record when the token is unknown or expired, and
+// firstUse=false when the token had already been rotated before.
+func (r *RefreshTokenRepository) ConsumeToken(ctx context.Context, hash string) (*models.RefreshTokenRecord, bool, error) {
+	record, err := r.loadToken(ctx, hash)
+	if err != nil || record == nil {
+		return nil, false, err
+	}
+	firstUse, err := r.client.SetNX(ctx, r.usedKey(hash), time.Now().Unix(), time.Until(record.ExpiresAt)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	return record, firstUse, nil
+}
+
+// loadToken returns the token's record, or nil when it is unknown or expired
+func (r *RefreshTokenRepository) loadToken(ctx context.Context, hash string) (*models.RefreshTokenRecord, error) {
+	val, err := r.client.Get(ctx, r.tokenKey(hash)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var record models.RefreshTokenRecord
+	if err := json.Unmarshal(val, &record); err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(record.ExpiresAt) {
+		return nil, nil
+	}
+	return &record, nil
+}
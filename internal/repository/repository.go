@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+)
+
+// Services read and write storage through these interfaces. Durable account data
+// can live in Postgres (repository/postgres) or Redis, and every store also has an
+// in-memory implementation (repository/memory). All backends must pass the shared
+// conformance suite in repository/repotest. Getters return nil, nil when the record
+// does not exist.
+
+// UserStore persists user accounts; Create and UpdatePhone return ErrUserExists
+// when the email or phone belongs to another account
+type UserStore interface {
+	Create(ctx context.Context, user models.User) error
+	Save(ctx context.Context, user models.User) error
+	UpdatePhone(ctx context.Context, user models.User, oldPhone string) error
+	GetByID(ctx context.Context, id string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByPhone(ctx context.Context, phone string) (*models.User, error)
+}
+
+// ProfileStore persists onboarding profiles keyed by user ID
+type ProfileStore interface {
+	Save(ctx context.Context, profile models.UserProfile) error
+	Get(ctx context.Context, userID string) (*models.UserProfile, error)
+}
+
+// BusinessProfileStore persists business profiles keyed by user ID
+type BusinessProfileStore interface {
+	Save(ctx context.Context, profile models.BusinessProfile) error
+	Get(ctx context.Context, userID string) (*models.BusinessProfile, error)
+}
+
+// PINStore persists hashed PINs keyed by user ID
+type PINStore interface {
+	Save(ctx context.Context, userID string, data models.PinData) error
+	Get(ctx context.Context, userID string) (*models.PinData, error)
+}
+
+// PINAttemptStore counts PIN attempts per user. Every attempt is counted up front
+// and the count is cleared after a correct PIN, so it holds consecutive wrong PINs.
+// Counts are forgotten once window passes without an attempt.
+// ReserveAttempt refuses the attempt while the user must wait, which is
+// waits[failures] after the last one (the last entry covers higher counts).
+// The check and the count are one atomic step so parallel guesses cannot skip the wait.
+type PINAttemptStore interface {
+	ReserveAttempt(ctx context.Context, userID string, now time.Time, window time.Duration, waits []time.Duration) (models.PINAttempts, bool, error)
+	GetAttempts(ctx context.Context, userID string, now time.Time) (models.PINAttempts, error)
+	ClearAttempts(ctx context.Context, userID string) error
+}
+
+// OTPStore keeps pending one-time codes and per-identifier send counters.
+// RegisterAttempt and ConsumeOTP must be atomic so concurrent guesses cannot
+// exceed the attempt limit or redeem a code twice.
+type OTPStore interface {
+	StoreOTP(ctx context.Context, identifier string, otpData models.OTPData) error
+	GetOTP(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType) (*models.OTPData, error)
+	DeleteOTP(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType) error
+	RegisterAttempt(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType, maxAttempts int) (*models.OTPData, error)
+	ConsumeOTP(ctx context.Context, identifier string, purpose models.OTPPurpose, otpType models.OTPType, codeHash string) (bool, error)
+	// CheckRateLimit counts a request and reports whether it was within maxRequests, atomically
+	CheckRateLimit(ctx context.Context, identifier string, maxRequests int, windowSize time.Duration) (bool, error)
+	// ClearRateLimit forgets the identifier's counter
+	ClearRateLimit(ctx context.Context, identifier string) error
+	GetRemainingAttempts(ctx context.Context, identifier string, maxRequests int) (int, error)
+	// RateLimitResetIn is how long until the identifier's send counter expires (0 if it has none)
+	RateLimitResetIn(ctx context.Context, identifier string) (time.Duration, error)
+	// StartCooldown blocks resends to identifier for cooldown unless a cooldown is already running
+	StartCooldown(ctx context.Context, identifier string, cooldown time.Duration) (bool, error)
+	// ClearCooldown lifts a cooldown early, e.g. when the code it guarded was never sent
+	ClearCooldown(ctx context.Context, identifier string) error
+	// CooldownRemaining is how long until identifier may be sent another code
+	CooldownRemaining(ctx context.Context, identifier string) (time.Duration, error)
+}
+
+// UsedTokenStore remembers the IDs of single-use tokens until they expire.
+// MarkUsed must be atomic so each token is accepted at most once.
+type UsedTokenStore interface {
+	// MarkUsed records id for ttl and returns false if it was already recorded
+	MarkUsed(ctx context.Context, id string, ttl time.Duration) (bool, error)
+}
+
+// TOTPStore persists authenticator-app enrollments keyed by user ID.
+// UseStep and UseRecoveryCode must be atomic so each code is accepted at most once.
+type TOTPStore interface {
+	Save(ctx context.Context, enrollment models.TOTPEnrollment) error
+	Get(ctx context.Context, userID string) (*models.TOTPEnrollment, error)
+	Delete(ctx context.Context, userID string) error
+	// UseStep records step as used and returns false if it is not after the last used step
+	// or the user has no enrollment
+	UseStep(ctx context.Context, userID string, step int64) (bool, error)
+	// UseRecoveryCode removes codeHash from the unused codes and returns false if it was not there
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+}
+
+// DeviceStore persists trusted devices keyed by user and device ID.
+// Create returns ErrDeviceExists when the user already has a device with that ID.
+type DeviceStore interface {
+	Create(ctx context.Context, device models.Device) error
+	Get(ctx context.Context, userID, deviceID string) (*models.Device, error)
+	// List returns the user's devices, oldest first
+	List(ctx context.Context, userID string) ([]models.Device, error)
+	// Touch records that the device was just used to sign in
+	Touch(ctx context.Context, userID, deviceID string, at time.Time) error
+	// Delete removes the device and returns false if it did not exist
+	Delete(ctx context.Context, userID, deviceID string) (bool, error)
+}
+
+// DeviceChallengeStore keeps login challenges until they are signed or expire.
+// ConsumeChallenge must be atomic so each challenge is accepted at most once.
+type DeviceChallengeStore interface {
+	StoreChallenge(ctx context.Context, userID, deviceID, challenge string, ttl time.Duration) error
+	// ConsumeChallenge removes the challenge and returns false if it was unknown or expired
+	ConsumeChallenge(ctx context.Context, userID, deviceID, challenge string) (bool, error)
+}
+
+// PasskeyStore persists WebAuthn credentials. Credential IDs are globally unique;
+// Create returns ErrPasskeyExists when the ID is already registered.
+type PasskeyStore interface {
+	Create(ctx context.Context, credential models.PasskeyCredential) error
+	Get(ctx context.Context, credentialID string) (*models.PasskeyCredential, error)
+	// ListByUser returns the user's credentials, oldest first
+	ListByUser(ctx context.Context, userID string) ([]models.PasskeyCredential, error)
+	// UpdateSignCount stores signCount and the use time, returning false unless
+	// signCount is above the stored count or both are zero. It must be atomic
+	// so a cloned authenticator cannot replay the same counter.
+	UpdateSignCount(ctx context.Context, credentialID string, signCount uint32, usedAt time.Time) (bool, error)
+	// Delete removes the user's credential and returns false if it did not exist
+	Delete(ctx context.Context, userID, credentialID string) (bool, error)
+}
+
+// PasskeyChallengeStore keeps ceremony challenges until they are answered or expire.
+// ConsumeChallenge must be atomic so each challenge is answered at most once.
+type PasskeyChallengeStore interface {
+	StoreChallenge(ctx context.Context, challenge models.PasskeyChallenge) error
+	// ConsumeChallenge removes and returns the challenge, or nil if it is unknown or expired
+	ConsumeChallenge(ctx context.Context, challenge string) (*models.PasskeyChallenge, error)
+}
+
+// NotificationQueueStore holds outbound notifications until workers deliver them.
+// Dequeue leases a job; the worker then calls Complete, Retry or Bury with the
+// updated job. Jobs whose lease expires are handed out again.
+type NotificationQueueStore interface {
+	Enqueue(ctx context.Context, job models.NotificationJob) error
+	Dequeue(ctx context.Context, now time.Time, lease time.Duration) (*models.NotificationJob, error)
+	Complete(ctx context.Context, job models.NotificationJob) error
+	Retry(ctx context.Context, job models.NotificationJob) error
+	Bury(ctx context.Context, job models.NotificationJob) error
+	Get(ctx context.Context, id string) (*models.NotificationJob, error)
+	DeadLetters(ctx context.Context, limit int) ([]models.NotificationJob, error)
+}
+
+// SMSUsageStore tracks SMS spend and destinations for toll-fraud limits.
+// Spend is in integer micro-units of the billing currency and bucketed by the hour containing now.
+type SMSUsageStore interface {
+	// HourlySpend returns this hour's spend for a calling code and across all codes
+	HourlySpend(ctx context.Context, prefix string, now time.Time) (prefixSpend, globalSpend int64, err error)
+	// AddSpend records cost against the calling code and the global total unless
+	// either would go over its cap, in which case nothing is recorded and it returns false
+	AddSpend(ctx context.Context, prefix string, cost, prefixCap, globalCap int64, now time.Time) (bool, error)
+	// TrackDestination records number under its number range and returns how many
+	// distinct numbers in that range were seen within window
+	TrackDestination(ctx context.Context, numberRange, number string, now time.Time, window time.Duration) (int, error)
+}
+
+// RateLimitStore applies a generic cell rate limit (GCRA): limit requests per period,
+// all of which may arrive at once, replenished evenly over the period
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit int, period time.Duration, now time.Time) (models.RateLimitResult, error)
+}
+
+var (
+	_ UsedTokenStore         = (*UsedTokenRepository)(nil)
+	_ PasskeyStore           = (*PasskeyRepository)(nil)
+	_ PasskeyChallengeStore  = (*PasskeyChallengeRepository)(nil)
+	_ DeviceStore            = (*DeviceRepository)(nil)
+	_ DeviceChallengeStore   = (*DeviceChallengeRepository)(nil)
+	_ TOTPStore              = (*TOTPRepository)(nil)
+	_ PINAttemptStore        = (*PINAttemptRepository)(nil)
+	_ RateLimitStore         = (*RateLimitRepository)(nil)
+	_ SMSUsageStore          = (*SMSUsageRepository)(nil)
+	_ NotificationQueueStore = (*NotificationQueueRepository)(nil)
+	_ OTPStore               = (*OTPRepository)(nil)
+	_ UserStore              = (*UserRepository)(nil)
+	_ ProfileStore           = (*ProfileRepository)(nil)
+	_ BusinessProfileStore   = (*BusinessProfileRepository)(nil)
+	_ PINStore               = (*PinRepository)(nil)
+)
@@ -0,0 +1,190 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/repotest"
+	"github.com/go-redis/redis/v8"
+)
+
+func newRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	mini, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mini.Close)
+	return redis.NewClient(&redis.Options{Addr: mini.Addr()}), mini
+}
+
+func TestUserStore(t *testing.T) {
+	repotest.RunUserStore(t, func(t *testing.T) repository.UserStore {
+		client, _ := newRedis(t)
+		return repository.NewUserRepository(client)
+	})
+}
+
+func TestProfileStore(t *testing.T) {
+	repotest.RunProfileStore(t, func(t *testing.T) repository.ProfileStore {
+		client, _ := newRedis(t)
+		return repository.NewProfileRepository(client, 24*time.Hour)
+	})
+}
+
+func TestBusinessProfileStore(t *testing.T) {
+	repotest.RunBusinessProfileStore(t, func(t *testing.T) repository.BusinessProfileStore {
+		client, _ := newRedis(t)
+		return repository.NewBusinessProfileRepository(client, 24*time.Hour)
+	})
+}
+
+func TestPINStore(t *testing.T) {
+	repotest.RunPINStore(t, func(t *testing.T) repository.PINStore {
+		client, _ := newRedis(t)
+		return repository.NewPinRepository(client, 24*time.Hour)
+	})
+}
+
+func TestOTPStore(t *testing.T) {
+	repotest.RunOTPStore(t, func(t *testing.T) repotest.OTPHarness {
+		client, mini := newRedis(t)
+		const ttl = 5 * time.Minute
+		return repotest.OTPHarness{
+			Store:   repository.NewOTPRepository(client, ttl),
+			TTL:     ttl,
+			Advance: mini.FastForward,
+		}
+	})
+}
+
+func TestNotificationQueueStore(t *testing.T) {
+	repotest.RunNotificationQueueStore(t, func(t *testing.T) repository.NotificationQueueStore {
+		client, _ := newRedis(t)
+		return repository.NewNotificationQueueRepository(client, 24*time.Hour)
+	})
+}
+
+func TestNotificationQueueKeepsPayloadUntilExpiry(t *testing.T) {
+	client, mini := newRedis(t)
+	store := repository.NewNotificationQueueRepository(client, 24*time.Hour)
+	ctx := context.Background()
+
+	job := models.NotificationJob{ID: "j1", Payload: []byte(`{"text":"code 123456"}`), ExpiresAt: time.Now().Add(5 * time.Minute)}
+	if err := store.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	if ttl := mini.TTL("notify:job:j1"); ttl <= 0 || ttl > 5*time.Minute {
+		t.Fatalf("expected the code to expire with it, got TTL %v", ttl)
+	}
+	// Once delivered the payload is gone and the status keeps the full retention
+	job.Payload, job.Status = nil, models.NotificationDelivered
+	store.Complete(ctx, job)
+	if ttl := mini.TTL("notify:job:j1"); ttl != 24*time.Hour {
+		t.Fatalf("expected full retention after delivery, got %v", ttl)
+	}
+}
+
+func TestRefreshFamilyStaysRevocableAfterTouch(t *testing.T) {
+	client, mini := newRedis(t)
+	repo := repository.NewRefreshTokenRepository(client)
+	ctx := context.Background()
+
+	if err := repo.CreateFamily(ctx, "u1", "f1", "phone-1", time.Hour); err != nil {
+		t.Fatalf("CreateFamily error: %v", err)
+	}
+	mini.FastForward(50 * time.Minute)
+	if err := repo.TouchFamily(ctx, "u1", "f1", "phone-1", time.Hour); err != nil {
+		t.Fatalf("TouchFamily error: %v", err)
+	}
+	// Past the original TTL the family is still alive and its device still finds it
+	mini.FastForward(50 * time.Minute)
+	if err := repo.RevokeDevice(ctx, "u1", "phone-1"); err != nil {
+		t.Fatalf("RevokeDevice error: %v", err)
+	}
+	if active, err := repo.FamilyActive(ctx, "f1"); err != nil || active {
+		t.Fatalf("expected RevokeDevice to end the touched family, got active=%v, %v", active, err)
+	}
+}
+
+func TestRevokeAllRemovesDeviceSets(t *testing.T) {
+	client, mini := newRedis(t)
+	repo := repository.NewRefreshTokenRepository(client)
+	ctx := context.Background()
+
+	repo.CreateFamily(ctx, "u1", "f1", "phone-1", time.Hour)
+	repo.CreateFamily(ctx, "u1", "f2", "tablet-1", time.Hour)
+	repo.CreateFamily(ctx, "u1", "f3", "", time.Hour)
+	if err := repo.RevokeAll(ctx, "u1"); err != nil {
+		t.Fatalf("RevokeAll error: %v", err)
+	}
+	if keys := mini.Keys(); len(keys) != 0 {
+		t.Fatalf("expected no session keys left, got %v", keys)
+	}
+}
+
+func TestSMSUsageStore(t *testing.T) {
+	repotest.RunSMSUsageStore(t, func(t *testing.T) repository.SMSUsageStore {
+		client, _ := newRedis(t)
+		return repository.NewSMSUsageRepository(client)
+	})
+}
+
+func TestRateLimitStore(t *testing.T) {
+	repotest.RunRateLimitStore(t, func(t *testing.T) repository.RateLimitStore {
+		client, _ := newRedis(t)
+		return repository.NewRateLimitRepository(client)
+	})
+}
+
+func TestTOTPStore(t *testing.T) {
+	repotest.RunTOTPStore(t, func(t *testing.T) repository.TOTPStore {
+		client, _ := newRedis(t)
+		return repository.NewTOTPRepository(client)
+	})
+}
+
+func TestPINAttemptStore(t *testing.T) {
+	repotest.RunPINAttemptStore(t, func(t *testing.T) repository.PINAttemptStore {
+		client, _ := newRedis(t)
+		return repository.NewPINAttemptRepository(client)
+	})
+}
+
+func TestDeviceStore(t *testing.T) {
+	repotest.RunDeviceStore(t, func(t *testing.T) repository.DeviceStore {
+		client, _ := newRedis(t)
+		return repository.NewDeviceRepository(client)
+	})
+}
+
+func TestDeviceChallengeStore(t *testing.T) {
+	repotest.RunDeviceChallengeStore(t, func(t *testing.T) repotest.DeviceChallengeHarness {
+		client, mini := newRedis(t)
+		return repotest.DeviceChallengeHarness{Store: repository.NewDeviceChallengeRepository(client), Advance: mini.FastForward}
+	})
+}
+
+func TestPasskeyStore(t *testing.T) {
+	repotest.RunPasskeyStore(t, func(t *testing.T) repository.PasskeyStore {
+		client, _ := newRedis(t)
+		return repository.NewPasskeyRepository(client)
+	})
+}
+
+func TestPasskeyChallengeStore(t *testing.T) {
+	repotest.RunPasskeyChallengeStore(t, func(t *testing.T) repotest.PasskeyChallengeHarness {
+		client, mini := newRedis(t)
+		return repotest.PasskeyChallengeHarness{Store: repository.NewPasskeyChallengeRepository(client), Advance: mini.FastForward}
+	})
+}
+
+func TestUsedTokenStore(t *testing.T) {
+	repotest.RunUsedTokenStore(t, func(t *testing.T) repotest.UsedTokenHarness {
+		client, mini := newRedis(t)
+		return repotest.UsedTokenHarness{Store: repository.NewUsedTokenRepository(client), Advance: mini.FastForward}
+	})
+}
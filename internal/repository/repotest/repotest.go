@@ -0,0 +1,1115 @@
+// Package repotest is the conformance suite every repository backend must pass.
+// Backend packages call the Run* functions from their own tests with a factory
+// that returns a fresh, empty store.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+)
+
+// now is truncated so backends storing microsecond timestamps round-trip exactly
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// RunUserStore checks lookups, uniqueness of email and phone, and phone moves
+func RunUserStore(t *testing.T, newStore func(t *testing.T) repository.UserStore) {
+	ctx := context.Background()
+	ts := now()
+	user := func(id, email, phone string) models.User {
+		return models.User{ID: id, Email: email, Phone: phone, PasswordHash: "h", CreatedAt: ts, UpdatedAt: ts}
+	}
+
+	t.Run("MissingReturnsNil", func(t *testing.T) {
+		store := newStore(t)
+		if got, err := store.GetByID(ctx, "nobody"); got != nil || err != nil {
+			t.Fatalf("GetByID = %+v, %v; want nil, nil", got, err)
+		}
+		if got, err := store.GetByEmail(ctx, "nobody@example.com"); got != nil || err != nil {
+			t.Fatalf("GetByEmail = %+v, %v; want nil, nil", got, err)
+		}
+		if got, err := store.GetByPhone(ctx, "+2348000000000"); got != nil || err != nil {
+			t.Fatalf("GetByPhone = %+v, %v; want nil, nil", got, err)
+		}
+	})
+
+	t.Run("CreateAndLookup", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Create(ctx, user("u1", "a@example.com", "+2348000000001")); err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+		for name, get := range map[string]func() (*models.User, error){
+			"GetByID":    func() (*models.User, error) { return store.GetByID(ctx, "u1") },
+			"GetByEmail": func() (*models.User, error) { return store.GetByEmail(ctx, "a@example.com") },
+			"GetByPhone": func() (*models.User, error) { return store.GetByPhone(ctx, "+2348000000001") },
+		} {
+			got, err := get()
+			if err != nil || got == nil || got.ID != "u1" || !got.CreatedAt.Equal(ts) {
+				t.Fatalf("%s = %+v, %v", name, got, err)
+			}
+		}
+	})
+
+	t.Run("DuplicatesRejected", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Create(ctx, user("u1", "a@example.com", "+2348000000001")); err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+		if err := store.Create(ctx, user("u2", "a@example.com", "")); !errors.Is(err, repository.ErrUserExists) {
+			t.Fatalf("duplicate email: got %v, want ErrUserExists", err)
+		}
+		if err := store.Create(ctx, user("u3", "c@example.com", "+2348000000001")); !errors.Is(err, repository.ErrUserExists) {
+			t.Fatalf("duplicate phone: got %v, want ErrUserExists", err)
+		}
+		// A rejected create must not leave its email reserved
+		if err := store.Create(ctx, user("u4", "c@example.com", "")); err != nil {
+			t.Fatalf("Create after rejected phone error: %v", err)
+		}
+	})
+
+	t.Run("UsersWithoutPhoneDoNotCollide", func(t *testing.T) {
+		store := newStore(t)
+		for _, id := range []string{"u1", "u2"} {
+			if err := store.Create(ctx, user(id, id+"@example.com", "")); err != nil {
+				t.Fatalf("Create(%s) error: %v", id, err)
+			}
+		}
+	})
+
+	t.Run("UpdatePhone", func(t *testing.T) {
+		store := newStore(t)
+		a := user("u1", "a@example.com", "+2348000000001")
+		b := user("u2", "b@example.com", "")
+		for _, u := range []models.User{a, b} {
+			if err := store.Create(ctx, u); err != nil {
+				t.Fatalf("Create error: %v", err)
+			}
+		}
+
+		b.Phone = a.Phone
+		if err := store.UpdatePhone(ctx, b, ""); !errors.Is(err, repository.ErrUserExists) {
+			t.Fatalf("taken phone: got %v, want ErrUserExists", err)
+		}
+		a.Phone = "+2348000000009"
+		if err := store.UpdatePhone(ctx, a, "+2348000000001"); err != nil {
+			t.Fatalf("UpdatePhone error: %v", err)
+		}
+		if got, _ := store.GetByPhone(ctx, "+2348000000001"); got != nil {
+			t.Fatalf("old phone still resolves to %s", got.ID)
+		}
+		if got, err := store.GetByPhone(ctx, "+2348000000009"); err != nil || got == nil || got.ID != "u1" {
+			t.Fatalf("GetByPhone(new) = %+v, %v", got, err)
+		}
+		// Re-saving the same phone is not a conflict with yourself
+		if err := store.UpdatePhone(ctx, a, a.Phone); err != nil {
+			t.Fatalf("UpdatePhone(same) error: %v", err)
+		}
+	})
+
+	t.Run("Save", func(t *testing.T) {
+		store := newStore(t)
+		u := user("u1", "a@example.com", "")
+		if err := store.Create(ctx, u); err != nil {
+			t.Fatalf("Create error: %v", err)
+		}
+		u.EmailVerified = true
+		if err := store.Save(ctx, u); err != nil {
+			t.Fatalf("Save error: %v", err)
+		}
+		if got, err := store.GetByEmail(ctx, "a@example.com"); err != nil || got == nil || !got.EmailVerified {
+			t.Fatalf("GetByEmail after Save = %+v, %v", got, err)
+		}
+	})
+}
+
+// RunProfileStore checks upserts and that optional sections round-trip
+func RunProfileStore(t *testing.T, newStore func(t *testing.T) repository.ProfileStore) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	if got, err := store.Get(ctx, "u1"); got != nil || err != nil {
+		t.Fatalf("Get(missing) = %+v, %v; want nil, nil", got, err)
+	}
+	p := models.UserProfile{UserID: "u1", AccountType: "freelancer", UpdatedAt: now(), CompletionPercent: 33}
+	if err := store.Save(ctx, p); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	p.Address = &models.Address{Country: "NG", Street: "1 Marina", City: "Lagos"}
+	p.CompletionPercent = 66
+	p.Locale = "fr"
+	if err := store.Save(ctx, p); err != nil {
+		t.Fatalf("Save (update) error: %v", err)
+	}
+	got, err := store.Get(ctx, "u1")
+	if err != nil || got == nil || got.Personal != nil || got.Address == nil || got.Address.City != "Lagos" || got.CompletionPercent != 66 || got.Locale != "fr" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	if !got.UpdatedAt.Equal(p.UpdatedAt) {
+		t.Fatalf("UpdatedAt = %v, want %v", got.UpdatedAt, p.UpdatedAt)
+	}
+}
+
+// RunBusinessProfileStore checks upserts of business profiles
+func RunBusinessProfileStore(t *testing.T, newStore func(t *testing.T) repository.BusinessProfileStore) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	if got, err := store.Get(ctx, "u1"); got != nil || err != nil {
+		t.Fatalf("Get(missing) = %+v, %v; want nil, nil", got, err)
+	}
+	bp := models.BusinessProfile{
+		UserID: "u1", AccountType: "contractor", BusinessName: "Acme", RegistrationNumber: "RC1", TaxID: "T1",
+		Address:   models.BusinessAddress{Street: "1 Marina", City: "Lagos", Country: "NG"},
+		Contact:   models.BusinessContact{Email: "ops@acme.test", Phone: "8012345678"},
+		UpdatedAt: now(),
+	}
+	if err := store.Save(ctx, bp); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	bp.BusinessName = "Acme Ltd"
+	if err := store.Save(ctx, bp); err != nil {
+		t.Fatalf("Save (update) error: %v", err)
+	}
+	got, err := store.Get(ctx, "u1")
+	if err != nil || got == nil || got.BusinessName != "Acme Ltd" || got.Contact.Email != "ops@acme.test" || got.Address.City != "Lagos" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+}
+
+// RunPINStore checks that saving a PIN replaces the previous one, including its hash version
+func RunPINStore(t *testing.T, newStore func(t *testing.T) repository.PINStore) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	if got, err := store.Get(ctx, "u1"); got != nil || err != nil {
+		t.Fatalf("Get(missing) = %+v, %v; want nil, nil", got, err)
+	}
+	ts := now()
+	if err := store.Save(ctx, "u1", models.PinData{Salt: "s", Hash: "h", CreatedAt: ts}); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	if err := store.Save(ctx, "u1", models.PinData{Version: models.PINHashArgon2id, Hash: "h2", CreatedAt: ts}); err != nil {
+		t.Fatalf("Save (replace) error: %v", err)
+	}
+	got, err := store.Get(ctx, "u1")
+	if err != nil || got == nil || got.Version != models.PINHashArgon2id || got.Hash != "h2" || got.Salt != "" || !got.CreatedAt.Equal(ts) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+}
+
+// OTPHarness is an OTP store whose clock the suite can move forward.
+// TTL is the lifetime the store was created with.
+type OTPHarness struct {
+	Store   repository.OTPStore
+	TTL     time.Duration
+	Advance func(d time.Duration)
+}
+
+// RunOTPStore checks expiry, purpose isolation, atomic attempt counting,
+// single redemption and send rate limiting
+func RunOTPStore(t *testing.T, newHarness func(t *testing.T) OTPHarness) {
+	ctx := context.Background()
+	const id = "+2348000000001"
+	pending := func() models.OTPData {
+		return models.OTPData{CodeHash: "hash", Type: models.OTPTypeSMS, Purpose: models.OTPPurposeVerification, ExpiresAt: now().Add(time.Minute)}
+	}
+
+	t.Run("StoreGetDelete", func(t *testing.T) {
+		h := newHarness(t)
+		if got, err := h.Store.GetOTP(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS); got != nil || err != nil {
+			t.Fatalf("GetOTP(missing) = %+v, %v; want nil, nil", got, err)
+		}
+		data := pending()
+		if err := h.Store.StoreOTP(ctx, id, data); err != nil {
+			t.Fatalf("StoreOTP error: %v", err)
+		}
+		got, err := h.Store.GetOTP(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS)
+		if err != nil || got == nil || got.CodeHash != "hash" || got.Attempts != 0 || !got.ExpiresAt.Equal(data.ExpiresAt) {
+			t.Fatalf("GetOTP = %+v, %v", got, err)
+		}
+		if got, _ := h.Store.GetOTP(ctx, id, models.OTPPurposePasswordReset, models.OTPTypeSMS); got != nil {
+			t.Fatalf("code leaked across purposes")
+		}
+		if got, _ := h.Store.GetOTP(ctx, id, models.OTPPurposeVerification, models.OTPTypeEmail); got != nil {
+			t.Fatalf("code leaked across channels")
+		}
+		if err := h.Store.DeleteOTP(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS); err != nil {
+			t.Fatalf("DeleteOTP error: %v", err)
+		}
+		if got, _ := h.Store.GetOTP(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS); got != nil {
+			t.Fatalf("code survived DeleteOTP")
+		}
+	})
+
+	t.Run("StoreReplacesPendingCode", func(t *testing.T) {
+		h := newHarness(t)
+		h.Store.StoreOTP(ctx, id, pending())
+		h.Store.RegisterAttempt(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS, 3)
+		next := pending()
+		next.CodeHash = "hash2"
+		h.Store.StoreOTP(ctx, id, next)
+		got, err := h.Store.GetOTP(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS)
+		if err != nil || got == nil || got.CodeHash != "hash2" || got.Attempts != 0 {
+			t.Fatalf("GetOTP after replace = %+v, %v", got, err)
+		}
+	})
+
+	t.Run("ExpiresAfterTTLDespiteAttempts", func(t *testing.T) {
+		h := newHarness(t)
+		h.Store.StoreOTP(ctx, id, pending())
+		h.Advance(h.TTL / 2)
+		if got, err := h.Store.RegisterAttempt(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS, 3); err != nil || got == nil || got.Attempts != 1 {
+			t.Fatalf("RegisterAttempt = %+v, %v", got, err)
+		}
+		h.Advance(h.TTL/2 + time.Second)
+		if got, err := h.Store.RegisterAttempt(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS, 3); got != nil || err != nil {
+			t.Fatalf("RegisterAttempt after TTL = %+v, %v; want nil, nil", got, err)
+		}
+	})
+
+	t.Run("AttemptLimitDeletesCode", func(t *testing.T) {
+		h := newHarness(t)
+		h.Store.StoreOTP(ctx, id, pending())
+		for i := 1; i <= 4; i++ {
+			got, err := h.Store.RegisterAttempt(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS, 3)
+			if err != nil || got == nil || got.Attempts != i || got.CodeHash != "hash" {
+				t.Fatalf("attempt %d = %+v, %v", i, got, err)
+			}
+		}
+		if got, err := h.Store.RegisterAttempt(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS, 3); got != nil || err != nil {
+			t.Fatalf("RegisterAttempt after limit = %+v, %v; want nil, nil", got, err)
+		}
+	})
+
+	t.Run("ConcurrentAttemptsAreCounted", func(t *testing.T) {
+		h := newHarness(t)
+		h.Store.StoreOTP(ctx, id, pending())
+		const workers, limit = 20, 3
+		var within int32
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := h.Store.RegisterAttempt(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS, limit)
+				if err != nil {
+					t.Errorf("RegisterAttempt error: %v", err)
+				}
+				if got != nil && got.Attempts <= limit {
+					atomic.AddInt32(&within, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		if within != limit {
+			t.Fatalf("%d attempts were within the limit, want %d", within, limit)
+		}
+	})
+
+	t.Run("ConsumeOnce", func(t *testing.T) {
+		h := newHarness(t)
+		h.Store.StoreOTP(ctx, id, pending())
+		if ok, err := h.Store.ConsumeOTP(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS, "other"); ok || err != nil {
+			t.Fatalf("ConsumeOTP(wrong hash) = %v, %v; want false, nil", ok, err)
+		}
+		var consumed int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := h.Store.ConsumeOTP(ctx, id, models.OTPPurposeVerification, models.OTPTypeSMS, "hash")
+				if err != nil {
+					t.Errorf("ConsumeOTP error: %v", err)
+				}
+				if ok {
+					atomic.AddInt32(&consumed, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		if consumed != 1 {
+			t.Fatalf("code consumed %d times, want 1", consumed)
+		}
+	})
+
+	t.Run("RateLimit", func(t *testing.T) {
+		h := newHarness(t)
+		if n, err := h.Store.GetRemainingAttempts(ctx, id, 2); n != 2 || err != nil {
+			t.Fatalf("GetRemainingAttempts = %d, %v; want 2", n, err)
+		}
+		for i := 0; i < 2; i++ {
+			if ok, err := h.Store.CheckRateLimit(ctx, id, 2, time.Hour); !ok || err != nil {
+				t.Fatalf("send %d: CheckRateLimit = %v, %v; want true", i+1, ok, err)
+			}
+		}
+		if ok, err := h.Store.CheckRateLimit(ctx, id, 2, time.Hour); ok || err != nil {
+			t.Fatalf("CheckRateLimit over limit = %v, %v; want false", ok, err)
+		}
+		if n, _ := h.Store.GetRemainingAttempts(ctx, id, 2); n != 0 {
+			t.Fatalf("GetRemainingAttempts = %d, want 0", n)
+		}
+		if n, _ := h.Store.GetRemainingAttempts(ctx, "other", 2); n != 2 {
+			t.Fatalf("counters leaked across identifiers")
+		}
+		if reset, err := h.Store.RateLimitResetIn(ctx, id); err != nil || reset <= 59*time.Minute || reset > time.Hour {
+			t.Fatalf("RateLimitResetIn = %v, %v; want about an hour", reset, err)
+		}
+		if reset, _ := h.Store.RateLimitResetIn(ctx, "other"); reset != 0 {
+			t.Fatalf("RateLimitResetIn(no sends) = %v; want 0", reset)
+		}
+		h.Advance(time.Hour + time.Second)
+		if ok, err := h.Store.CheckRateLimit(ctx, id, 2, time.Hour); !ok || err != nil {
+			t.Fatalf("CheckRateLimit after window = %v, %v; want true", ok, err)
+		}
+		if err := h.Store.ClearRateLimit(ctx, id); err != nil {
+			t.Fatalf("ClearRateLimit error: %v", err)
+		}
+		if n, _ := h.Store.GetRemainingAttempts(ctx, id, 2); n != 2 {
+			t.Fatalf("GetRemainingAttempts after ClearRateLimit = %d, want 2", n)
+		}
+	})
+
+	t.Run("ConcurrentRateLimit", func(t *testing.T) {
+		h := newHarness(t)
+		const workers, limit = 20, 3
+		var allowed int32
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := h.Store.CheckRateLimit(ctx, id, limit, time.Hour)
+				if err != nil {
+					t.Errorf("CheckRateLimit error: %v", err)
+				}
+				if ok {
+					atomic.AddInt32(&allowed, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		if allowed != limit {
+			t.Fatalf("%d requests were allowed, want %d", allowed, limit)
+		}
+	})
+
+	t.Run("Cooldown", func(t *testing.T) {
+		h := newHarness(t)
+		if left, err := h.Store.CooldownRemaining(ctx, id); left != 0 || err != nil {
+			t.Fatalf("CooldownRemaining = %v, %v; want 0", left, err)
+		}
+		if ok, err := h.Store.StartCooldown(ctx, id, 30*time.Second); !ok || err != nil {
+			t.Fatalf("StartCooldown = %v, %v; want true", ok, err)
+		}
+		if ok, _ := h.Store.StartCooldown(ctx, id, 30*time.Second); ok {
+			t.Fatalf("a second cooldown started while one was running")
+		}
+		if left, _ := h.Store.CooldownRemaining(ctx, id); left <= 29*time.Second || left > 30*time.Second {
+			t.Fatalf("CooldownRemaining = %v; want about 30s", left)
+		}
+		if left, _ := h.Store.CooldownRemaining(ctx, "other"); left != 0 {
+			t.Fatalf("cooldown leaked across identifiers")
+		}
+		h.Advance(31 * time.Second)
+		if left, _ := h.Store.CooldownRemaining(ctx, id); left != 0 {
+			t.Fatalf("CooldownRemaining after cooldown = %v; want 0", left)
+		}
+		if ok, _ := h.Store.StartCooldown(ctx, id, 30*time.Second); !ok {
+			t.Fatalf("StartCooldown after cooldown = false; want true")
+		}
+		if err := h.Store.ClearCooldown(ctx, id); err != nil {
+			t.Fatalf("ClearCooldown error: %v", err)
+		}
+		if ok, _ := h.Store.StartCooldown(ctx, id, 30*time.Second); !ok {
+			t.Fatalf("StartCooldown after ClearCooldown = false; want true")
+		}
+	})
+}
+
+// RunNotificationQueueStore checks FIFO delivery, leases, delayed retries and dead-lettering
+func RunNotificationQueueStore(t *testing.T, newStore func(t *testing.T) repository.NotificationQueueStore) {
+	ctx := context.Background()
+	start := now()
+	job := func(id string) models.NotificationJob {
+		return models.NotificationJob{ID: id, Channel: "sms", Payload: []byte(`{"to":"+2348000000001"}`), Status: models.NotificationQueued, CreatedAt: start, UpdatedAt: start}
+	}
+
+	t.Run("FIFOAndStatus", func(t *testing.T) {
+		store := newStore(t)
+		if got, err := store.Dequeue(ctx, start, time.Minute); got != nil || err != nil {
+			t.Fatalf("Dequeue(empty) = %+v, %v; want nil, nil", got, err)
+		}
+		if got, err := store.Get(ctx, "missing"); got != nil || err != nil {
+			t.Fatalf("Get(missing) = %+v, %v; want nil, nil", got, err)
+		}
+		for _, id := range []string{"a", "b"} {
+			if err := store.Enqueue(ctx, job(id)); err != nil {
+				t.Fatalf("Enqueue error: %v", err)
+			}
+		}
+		first, err := store.Dequeue(ctx, start, time.Minute)
+		if err != nil || first == nil || first.ID != "a" || string(first.Payload) != `{"to":"+2348000000001"}` {
+			t.Fatalf("Dequeue = %+v, %v; want a", first, err)
+		}
+		first.Status = models.NotificationDelivered
+		first.Attempts = 1
+		first.Payload = nil
+		if err := store.Complete(ctx, *first); err != nil {
+			t.Fatalf("Complete error: %v", err)
+		}
+		got, err := store.Get(ctx, "a")
+		if err != nil || got == nil || got.Status != models.NotificationDelivered || got.Attempts != 1 || len(got.Payload) != 0 {
+			t.Fatalf("Get after Complete = %+v, %v", got, err)
+		}
+		// Completed jobs are not handed out again, even after the lease would have expired
+		if next, _ := store.Dequeue(ctx, start.Add(time.Hour), time.Minute); next == nil || next.ID != "b" {
+			t.Fatalf("expected b next, got %+v", next)
+		}
+		if next, _ := store.Dequeue(ctx, start.Add(time.Hour), time.Minute); next != nil {
+			t.Fatalf("expected empty queue, got %+v", next)
+		}
+	})
+
+	t.Run("ExpiredLeaseIsRedelivered", func(t *testing.T) {
+		store := newStore(t)
+		store.Enqueue(ctx, job("a"))
+		if got, _ := store.Dequeue(ctx, start, time.Minute); got == nil {
+			t.Fatalf("expected a job")
+		}
+		if got, _ := store.Dequeue(ctx, start.Add(30*time.Second), time.Minute); got != nil {
+			t.Fatalf("leased job handed out twice: %+v", got)
+		}
+		if got, _ := store.Dequeue(ctx, start.Add(2*time.Minute), time.Minute); got == nil || got.ID != "a" {
+			t.Fatalf("expected a after lease expiry, got %+v", got)
+		}
+	})
+
+	t.Run("RetryWaitsUntilNextAttempt", func(t *testing.T) {
+		store := newStore(t)
+		store.Enqueue(ctx, job("a"))
+		got, _ := store.Dequeue(ctx, start, time.Minute)
+		got.Status = models.NotificationRetrying
+		got.Attempts = 1
+		got.LastError = "timeout"
+		got.NextAttemptAt = start.Add(10 * time.Second)
+		if err := store.Retry(ctx, *got); err != nil {
+			t.Fatalf("Retry error: %v", err)
+		}
+		if early, _ := store.Dequeue(ctx, start.Add(5*time.Second), time.Minute); early != nil {
+			t.Fatalf("retry handed out early: %+v", early)
+		}
+		again, err := store.Dequeue(ctx, start.Add(11*time.Second), time.Minute)
+		if err != nil || again == nil || again.ID != "a" || again.Attempts != 1 || again.LastError != "timeout" {
+			t.Fatalf("Dequeue after delay = %+v, %v", again, err)
+		}
+	})
+
+	t.Run("DeadLetters", func(t *testing.T) {
+		store := newStore(t)
+		for _, id := range []string{"a", "b"} {
+			store.Enqueue(ctx, job(id))
+			got, _ := store.Dequeue(ctx, start, time.Minute)
+			got.Status = models.NotificationDead
+			got.Payload = nil
+			if err := store.Bury(ctx, *got); err != nil {
+				t.Fatalf("Bury error: %v", err)
+			}
+		}
+		dead, err := store.DeadLetters(ctx, 10)
+		if err != nil || len(dead) != 2 || dead[0].ID != "b" || dead[1].Status != models.NotificationDead {
+			t.Fatalf("DeadLetters = %+v, %v", dead, err)
+		}
+		if dead, _ := store.DeadLetters(ctx, 1); len(dead) != 1 {
+			t.Fatalf("expected limit to apply, got %d", len(dead))
+		}
+		if got, _ := store.Dequeue(ctx, start.Add(time.Hour), time.Minute); got != nil {
+			t.Fatalf("dead job handed out: %+v", got)
+		}
+	})
+}
+
+// RunSMSUsageStore checks hourly spend buckets, cap enforcement and number-range tracking
+func RunSMSUsageStore(t *testing.T, newStore func(t *testing.T) repository.SMSUsageStore) {
+	ctx := context.Background()
+	hour := now().Truncate(time.Hour)
+
+	t.Run("Spend", func(t *testing.T) {
+		store := newStore(t)
+		if p, g, err := store.HourlySpend(ctx, "234", hour); p != 0 || g != 0 || err != nil {
+			t.Fatalf("HourlySpend(empty) = %d, %d, %v", p, g, err)
+		}
+		if ok, err := store.AddSpend(ctx, "234", 30, 100, 150, hour); !ok || err != nil {
+			t.Fatalf("AddSpend = %v, %v; want true", ok, err)
+		}
+		if ok, _ := store.AddSpend(ctx, "44", 50, 100, 150, hour.Add(10*time.Minute)); !ok {
+			t.Fatalf("expected spend for another prefix to be recorded")
+		}
+		if p, g, _ := store.HourlySpend(ctx, "234", hour.Add(30*time.Minute)); p != 30 || g != 80 {
+			t.Fatalf("HourlySpend = %d, %d; want 30, 80", p, g)
+		}
+		// Over the prefix cap: nothing is recorded
+		if ok, _ := store.AddSpend(ctx, "234", 80, 100, 150, hour); ok {
+			t.Fatalf("expected the prefix cap to reject the spend")
+		}
+		// Over the global cap
+		if ok, _ := store.AddSpend(ctx, "1", 80, 100, 150, hour); ok {
+			t.Fatalf("expected the global cap to reject the spend")
+		}
+		if p, g, _ := store.HourlySpend(ctx, "234", hour); p != 30 || g != 80 {
+			t.Fatalf("rejected spend was recorded: %d, %d", p, g)
+		}
+		// A new hour starts from zero
+		if p, g, _ := store.HourlySpend(ctx, "234", hour.Add(time.Hour)); p != 0 || g != 0 {
+			t.Fatalf("next hour = %d, %d; want 0, 0", p, g)
+		}
+	})
+
+	t.Run("Destinations", func(t *testing.T) {
+		store := newStore(t)
+		start := now()
+		for i, number := range []string{"+2348012345601", "+2348012345602", "+2348012345601"} {
+			n, err := store.TrackDestination(ctx, "+23480123456", number, start.Add(time.Duration(i)*time.Second), 10*time.Minute)
+			if err != nil {
+				t.Fatalf("TrackDestination error: %v", err)
+			}
+			if want := []int{1, 2, 2}[i]; n != want {
+				t.Fatalf("TrackDestination #%d = %d; want %d", i, n, want)
+			}
+		}
+		if n, _ := store.TrackDestination(ctx, "+23480999999", "+2348099999901", start, 10*time.Minute); n != 1 {
+			t.Fatalf("other range = %d; want 1", n)
+		}
+		// Older sightings fall out of the window
+		if n, _ := store.TrackDestination(ctx, "+23480123456", "+2348012345603", start.Add(15*time.Minute), 10*time.Minute); n != 1 {
+			t.Fatalf("after window = %d; want 1", n)
+		}
+	})
+}
+
+// RunRateLimitStore checks bursts up to the limit, even replenishment and key isolation
+func RunRateLimitStore(t *testing.T, newStore func(t *testing.T) repository.RateLimitStore) {
+	ctx := context.Background()
+	store := newStore(t)
+	start := now()
+
+	for i := 0; i < 3; i++ {
+		res, err := store.Take(ctx, "ip:1.2.3.4", 3, time.Minute, start)
+		if err != nil {
+			t.Fatalf("Take error: %v", err)
+		}
+		if !res.Allowed || res.Limit != 3 || res.Remaining != 2-i {
+			t.Fatalf("Take #%d = %+v; want allowed with %d remaining", i, res, 2-i)
+		}
+	}
+	res, err := store.Take(ctx, "ip:1.2.3.4", 3, time.Minute, start)
+	if err != nil || res.Allowed || res.Remaining != 0 {
+		t.Fatalf("Take over limit = %+v, %v; want denied", res, err)
+	}
+	if res.RetryAfter != 20*time.Second || res.ResetAfter != time.Minute {
+		t.Fatalf("RetryAfter = %v, ResetAfter = %v; want 20s, 1m", res.RetryAfter, res.ResetAfter)
+	}
+
+	if res, _ := store.Take(ctx, "ip:5.6.7.8", 3, time.Minute, start); !res.Allowed {
+		t.Fatalf("another key was limited")
+	}
+
+	// One request's worth replenishes every period/limit
+	if res, _ := store.Take(ctx, "ip:1.2.3.4", 3, time.Minute, start.Add(20*time.Second)); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("Take after 20s = %+v; want allowed with 0 remaining", res)
+	}
+	if res, _ := store.Take(ctx, "ip:1.2.3.4", 3, time.Minute, start.Add(2*time.Minute)); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("Take after the period = %+v; want a full limit", res)
+	}
+}
+
+// RunTOTPStore checks round-trips, replay protection, single-use recovery codes and deletion
+func RunTOTPStore(t *testing.T, newStore func(t *testing.T) repository.TOTPStore) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	if got, err := store.Get(ctx, "u1"); got != nil || err != nil {
+		t.Fatalf("Get(missing) = %+v, %v; want nil, nil", got, err)
+	}
+	if ok, err := store.UseStep(ctx, "u1", 10); ok || err != nil {
+		t.Fatalf("UseStep(missing) = %v, %v; want false", ok, err)
+	}
+
+	ts := now()
+	enrollment := models.TOTPEnrollment{UserID: "u1", Secret: "sealed", CreatedAt: ts}
+	if err := store.Save(ctx, enrollment); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	enrollment.Confirmed = true
+	enrollment.ConfirmedAt = &ts
+	enrollment.RecoveryCodes = []string{"h1", "h2"}
+	if err := store.Save(ctx, enrollment); err != nil {
+		t.Fatalf("Save (confirm) error: %v", err)
+	}
+	got, err := store.Get(ctx, "u1")
+	if err != nil || got == nil || got.Secret != "sealed" || !got.Confirmed || got.ConfirmedAt == nil ||
+		!got.ConfirmedAt.Equal(ts) || !got.CreatedAt.Equal(ts) || len(got.RecoveryCodes) != 2 {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	t.Run("StepsAreSingleUse", func(t *testing.T) {
+		if ok, err := store.UseStep(ctx, "u1", 100); !ok || err != nil {
+			t.Fatalf("UseStep(100) = %v, %v; want true", ok, err)
+		}
+		for _, step := range []int64{100, 99} {
+			if ok, _ := store.UseStep(ctx, "u1", step); ok {
+				t.Fatalf("UseStep(%d) accepted a step at or before the last used one", step)
+			}
+		}
+		if got, _ := store.Get(ctx, "u1"); got.LastUsedStep != 100 {
+			t.Fatalf("LastUsedStep = %d; want 100", got.LastUsedStep)
+		}
+		// Concurrent uses of the next step: exactly one wins
+		var wins int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ok, _ := store.UseStep(ctx, "u1", 101); ok {
+					atomic.AddInt32(&wins, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		if wins != 1 {
+			t.Fatalf("step 101 accepted %d times; want 1", wins)
+		}
+	})
+
+	t.Run("RecoveryCodesAreSingleUse", func(t *testing.T) {
+		if ok, err := store.UseRecoveryCode(ctx, "u1", "h1"); !ok || err != nil {
+			t.Fatalf("UseRecoveryCode(h1) = %v, %v; want true", ok, err)
+		}
+		for _, hash := range []string{"h1", "unknown"} {
+			if ok, _ := store.UseRecoveryCode(ctx, "u1", hash); ok {
+				t.Fatalf("UseRecoveryCode(%s) accepted a used or unknown code", hash)
+			}
+		}
+		if got, _ := store.Get(ctx, "u1"); len(got.RecoveryCodes) != 1 || got.RecoveryCodes[0] != "h2" {
+			t.Fatalf("RecoveryCodes = %v; want [h2]", got.RecoveryCodes)
+		}
+	})
+
+	if err := store.Delete(ctx, "u1"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if got, err := store.Get(ctx, "u1"); got != nil || err != nil {
+		t.Fatalf("Get after Delete = %+v, %v; want nil, nil", got, err)
+	}
+	if ok, _ := store.UseRecoveryCode(ctx, "u1", "h2"); ok {
+		t.Fatalf("recovery code survived Delete")
+	}
+}
+
+// RunPINAttemptStore checks counting, waits, clearing, per-user isolation and atomic reservations
+func RunPINAttemptStore(t *testing.T, newStore func(t *testing.T) repository.PINAttemptStore) {
+	ctx := context.Background()
+	store := newStore(t)
+	start := now().Truncate(time.Millisecond)
+
+	if got, err := store.GetAttempts(ctx, "u1", start); err != nil || got.Failures != 0 {
+		t.Fatalf("GetAttempts(missing) = %+v, %v; want zero", got, err)
+	}
+	for i := 1; i <= 3; i++ {
+		got, ok, err := store.ReserveAttempt(ctx, "u1", start.Add(time.Duration(i)*time.Second), time.Hour, nil)
+		if err != nil || !ok || got.Failures != i {
+			t.Fatalf("ReserveAttempt #%d = %+v, %v, %v", i, got, ok, err)
+		}
+	}
+	got, err := store.GetAttempts(ctx, "u1", start.Add(5*time.Second))
+	if err != nil || got.Failures != 3 || !got.LastFailure.Equal(start.Add(3*time.Second)) {
+		t.Fatalf("GetAttempts = %+v, %v; want 3 failures, last at +3s", got, err)
+	}
+	if other, _ := store.GetAttempts(ctx, "u2", start); other.Failures != 0 {
+		t.Fatalf("failures leaked to another user: %+v", other)
+	}
+
+	// With 3 failures the last wait applies: refused until 10s after the last attempt
+	waits := []time.Duration{0, 0, 5 * time.Second, 10 * time.Second}
+	if got, ok, err := store.ReserveAttempt(ctx, "u1", start.Add(12*time.Second), time.Hour, waits); ok || err != nil || got.Failures != 3 || !got.LastFailure.Equal(start.Add(3*time.Second)) {
+		t.Fatalf("ReserveAttempt during the wait = %+v, %v, %v; want refused with 3 failures", got, ok, err)
+	}
+	if got, ok, _ := store.ReserveAttempt(ctx, "u1", start.Add(13*time.Second), time.Hour, waits); !ok || got.Failures != 4 {
+		t.Fatalf("ReserveAttempt after the wait = %+v, %v; want the 4th attempt", got, ok)
+	}
+
+	// Parallel guesses are all counted, and a wait lets only one through
+	var wg sync.WaitGroup
+	var reserved int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, _ := store.ReserveAttempt(ctx, "u2", start, time.Hour, []time.Duration{0, time.Minute}); ok {
+				atomic.AddInt32(&reserved, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, _ := store.GetAttempts(ctx, "u2", start); reserved != 1 || got.Failures != 1 {
+		t.Fatalf("%d parallel attempts reserved, %d counted; want 1", reserved, got.Failures)
+	}
+
+	if err := store.ClearAttempts(ctx, "u1"); err != nil {
+		t.Fatalf("ClearAttempts error: %v", err)
+	}
+	if got, _ := store.GetAttempts(ctx, "u1", start); got.Failures != 0 {
+		t.Fatalf("GetAttempts after clear = %+v; want zero", got)
+	}
+	if _, ok, _ := store.ReserveAttempt(ctx, "u1", start.Add(14*time.Second), time.Hour, waits); !ok {
+		t.Fatalf("ReserveAttempt after clear was refused")
+	}
+}
+
+// RunDeviceStore checks round-trips, per-user uniqueness, ordering, touch and deletion
+func RunDeviceStore(t *testing.T, newStore func(t *testing.T) repository.DeviceStore) {
+	ctx := context.Background()
+	store := newStore(t)
+	ts := now()
+	device := func(userID, id string, created time.Time) models.Device {
+		return models.Device{ID: id, UserID: userID, Platform: models.DevicePlatformIOS, Name: "Phone " + id, PublicKey: "key-" + id, CreatedAt: created, LastUsedAt: created}
+	}
+
+	if got, err := store.Get(ctx, "u1", "d1"); got != nil || err != nil {
+		t.Fatalf("Get(missing) = %+v, %v; want nil, nil", got, err)
+	}
+	if got, err := store.List(ctx, "u1"); len(got) != 0 || err != nil {
+		t.Fatalf("List(empty) = %+v, %v; want none", got, err)
+	}
+	for _, d := range []models.Device{device("u1", "d2", ts.Add(time.Second)), device("u1", "d1", ts), device("u2", "d1", ts)} {
+		if err := store.Create(ctx, d); err != nil {
+			t.Fatalf("Create(%s/%s) error: %v", d.UserID, d.ID, err)
+		}
+	}
+	if err := store.Create(ctx, device("u1", "d1", ts)); !errors.Is(err, repository.ErrDeviceExists) {
+		t.Fatalf("Create(duplicate) = %v; want ErrDeviceExists", err)
+	}
+
+	got, err := store.Get(ctx, "u1", "d1")
+	if err != nil || got == nil || got.Name != "Phone d1" || got.PublicKey != "key-d1" || got.Platform != models.DevicePlatformIOS || !got.CreatedAt.Equal(ts) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	list, err := store.List(ctx, "u1")
+	if err != nil || len(list) != 2 || list[0].ID != "d1" || list[1].ID != "d2" {
+		t.Fatalf("List = %+v, %v; want d1 then d2", list, err)
+	}
+
+	used := ts.Add(time.Hour)
+	if err := store.Touch(ctx, "u1", "d1", used); err != nil {
+		t.Fatalf("Touch error: %v", err)
+	}
+	if got, _ := store.Get(ctx, "u1", "d1"); !got.LastUsedAt.Equal(used) {
+		t.Fatalf("LastUsedAt = %v; want %v", got.LastUsedAt, used)
+	}
+
+	if ok, err := store.Delete(ctx, "u1", "d1"); !ok || err != nil {
+		t.Fatalf("Delete = %v, %v; want true", ok, err)
+	}
+	if ok, _ := store.Delete(ctx, "u1", "d1"); ok {
+		t.Fatalf("Delete of a removed device returned true")
+	}
+	if got, _ := store.Get(ctx, "u1", "d1"); got != nil {
+		t.Fatalf("device survived Delete")
+	}
+	if err := store.Touch(ctx, "u1", "d1", used); err != nil {
+		t.Fatalf("Touch(removed) error: %v", err)
+	}
+	if got, _ := store.Get(ctx, "u1", "d1"); got != nil {
+		t.Fatalf("Touch brought a removed device back")
+	}
+	if got, _ := store.Get(ctx, "u2", "d1"); got == nil {
+		t.Fatalf("Delete removed another user's device with the same ID")
+	}
+}
+
+// DeviceChallengeHarness is a challenge store whose clock the suite can move forward
+type DeviceChallengeHarness struct {
+	Store   repository.DeviceChallengeStore
+	Advance func(d time.Duration)
+}
+
+// RunDeviceChallengeStore checks single use, device isolation, expiry and atomic consumption
+func RunDeviceChallengeStore(t *testing.T, newHarness func(t *testing.T) DeviceChallengeHarness) {
+	ctx := context.Background()
+
+	t.Run("SingleUse", func(t *testing.T) {
+		h := newHarness(t)
+		if err := h.Store.StoreChallenge(ctx, "u1", "d1", "c1", time.Minute); err != nil {
+			t.Fatalf("StoreChallenge error: %v", err)
+		}
+		if ok, _ := h.Store.ConsumeChallenge(ctx, "u1", "d2", "c1"); ok {
+			t.Fatalf("challenge accepted for another device")
+		}
+		if ok, err := h.Store.ConsumeChallenge(ctx, "u1", "d1", "c1"); !ok || err != nil {
+			t.Fatalf("ConsumeChallenge = %v, %v; want true", ok, err)
+		}
+		if ok, _ := h.Store.ConsumeChallenge(ctx, "u1", "d1", "c1"); ok {
+			t.Fatalf("challenge accepted twice")
+		}
+	})
+
+	t.Run("Expires", func(t *testing.T) {
+		h := newHarness(t)
+		h.Store.StoreChallenge(ctx, "u1", "d1", "c1", time.Minute)
+		h.Advance(2 * time.Minute)
+		if ok, _ := h.Store.ConsumeChallenge(ctx, "u1", "d1", "c1"); ok {
+			t.Fatalf("expired challenge accepted")
+		}
+	})
+
+	t.Run("ConcurrentConsume", func(t *testing.T) {
+		h := newHarness(t)
+		h.Store.StoreChallenge(ctx, "u1", "d1", "c1", time.Minute)
+		var wins int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ok, _ := h.Store.ConsumeChallenge(ctx, "u1", "d1", "c1"); ok {
+					atomic.AddInt32(&wins, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		if wins != 1 {
+			t.Fatalf("challenge accepted %d times; want 1", wins)
+		}
+	})
+}
+
+// RunPasskeyStore checks round-trips, global ID uniqueness, ordering, sign counter rules and deletion
+func RunPasskeyStore(t *testing.T, newStore func(t *testing.T) repository.PasskeyStore) {
+	ctx := context.Background()
+	store := newStore(t)
+	ts := now()
+	passkey := func(userID, id string, created time.Time) models.PasskeyCredential {
+		return models.PasskeyCredential{
+			ID: id, UserID: userID, Name: "Key " + id, PublicKey: "cose-" + id, Algorithm: -7,
+			SignCount: 5, Transports: []string{"internal", "hybrid"}, AAGUID: "aaguid-" + id,
+			CreatedAt: created, LastUsedAt: created,
+		}
+	}
+
+	if got, err := store.Get(ctx, "c1"); got != nil || err != nil {
+		t.Fatalf("Get(missing) = %+v, %v; want nil, nil", got, err)
+	}
+	if got, err := store.ListByUser(ctx, "u1"); len(got) != 0 || err != nil {
+		t.Fatalf("ListByUser(empty) = %+v, %v; want none", got, err)
+	}
+	if ok, err := store.UpdateSignCount(ctx, "c1", 1, ts); ok || err != nil {
+		t.Fatalf("UpdateSignCount(missing) = %v, %v; want false", ok, err)
+	}
+	for _, p := range []models.PasskeyCredential{passkey("u1", "c2", ts.Add(time.Second)), passkey("u1", "c1", ts), passkey("u2", "c3", ts)} {
+		if err := store.Create(ctx, p); err != nil {
+			t.Fatalf("Create(%s) error: %v", p.ID, err)
+		}
+	}
+	// Credential IDs are unique across users
+	if err := store.Create(ctx, passkey("u2", "c1", ts)); !errors.Is(err, repository.ErrPasskeyExists) {
+		t.Fatalf("Create(duplicate) = %v; want ErrPasskeyExists", err)
+	}
+
+	got, err := store.Get(ctx, "c1")
+	if err != nil || got == nil || got.UserID != "u1" || got.Name != "Key c1" || got.PublicKey != "cose-c1" || got.Algorithm != -7 ||
+		got.SignCount != 5 || len(got.Transports) != 2 || got.AAGUID != "aaguid-c1" || !got.CreatedAt.Equal(ts) || !got.LastUsedAt.Equal(ts) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	list, err := store.ListByUser(ctx, "u1")
+	if err != nil || len(list) != 2 || list[0].ID != "c1" || list[1].ID != "c2" {
+		t.Fatalf("ListByUser = %+v, %v; want c1 then c2", list, err)
+	}
+
+	t.Run("SignCountOnlyGrows", func(t *testing.T) {
+		used := ts.Add(time.Hour)
+		for _, count := range []uint32{5, 4, 0} {
+			if ok, _ := store.UpdateSignCount(ctx, "c1", count, used); ok {
+				t.Fatalf("UpdateSignCount(%d) accepted a counter at or below 5", count)
+			}
+		}
+		if ok, err := store.UpdateSignCount(ctx, "c1", 6, used); !ok || err != nil {
+			t.Fatalf("UpdateSignCount(6) = %v, %v; want true", ok, err)
+		}
+		if got, _ := store.Get(ctx, "c1"); got.SignCount != 6 || !got.LastUsedAt.Equal(used) {
+			t.Fatalf("after update = %+v; want count 6 used at %v", got, used)
+		}
+		// Concurrent assertions with the next counter: exactly one wins
+		var wins int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ok, _ := store.UpdateSignCount(ctx, "c1", 7, used); ok {
+					atomic.AddInt32(&wins, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		if wins != 1 {
+			t.Fatalf("counter 7 accepted %d times; want 1", wins)
+		}
+	})
+
+	t.Run("ZeroCounterStaysUsable", func(t *testing.T) {
+		p := passkey("u3", "c4", ts)
+		p.SignCount = 0
+		store.Create(ctx, p)
+		for i := 0; i < 2; i++ {
+			if ok, err := store.UpdateSignCount(ctx, "c4", 0, ts.Add(time.Minute)); !ok || err != nil {
+				t.Fatalf("UpdateSignCount(0) #%d = %v, %v; want true", i+1, ok, err)
+			}
+		}
+		if ok, _ := store.UpdateSignCount(ctx, "c4", 3, ts); !ok {
+			t.Fatalf("counter could not start counting")
+		}
+		if ok, _ := store.UpdateSignCount(ctx, "c4", 0, ts); ok {
+			t.Fatalf("counter went back to zero")
+		}
+	})
+
+	if ok, _ := store.Delete(ctx, "u2", "c1"); ok {
+		t.Fatalf("Delete removed another user's passkey")
+	}
+	if ok, err := store.Delete(ctx, "u1", "c1"); !ok || err != nil {
+		t.Fatalf("Delete = %v, %v; want true", ok, err)
+	}
+	if ok, _ := store.Delete(ctx, "u1", "c1"); ok {
+		t.Fatalf("Delete of a removed passkey returned true")
+	}
+	if got, _ := store.Get(ctx, "c1"); got != nil {
+		t.Fatalf("passkey survived Delete")
+	}
+	if list, _ := store.ListByUser(ctx, "u1"); len(list) != 1 || list[0].ID != "c2" {
+		t.Fatalf("ListByUser after Delete = %+v; want c2", list)
+	}
+	if ok, _ := store.UpdateSignCount(ctx, "c1", 100, ts); ok {
+		t.Fatalf("UpdateSignCount brought a removed passkey back")
+	}
+}
+
+// PasskeyChallengeHarness is a challenge store whose clock the suite can move forward
+type PasskeyChallengeHarness struct {
+	Store   repository.PasskeyChallengeStore
+	Advance func(d time.Duration)
+}
+
+// RunPasskeyChallengeStore checks round-trips, single use, expiry and atomic consumption
+func RunPasskeyChallengeStore(t *testing.T, newHarness func(t *testing.T) PasskeyChallengeHarness) {
+	ctx := context.Background()
+	challenge := func(value string) models.PasskeyChallenge {
+		return models.PasskeyChallenge{Challenge: value, Ceremony: models.PasskeyCeremonyRegistration, UserID: "u1", ExpiresAt: time.Now().Add(time.Minute)}
+	}
+
+	t.Run("SingleUse", func(t *testing.T) {
+		h := newHarness(t)
+		if got, err := h.Store.ConsumeChallenge(ctx, "c1"); got != nil || err != nil {
+			t.Fatalf("ConsumeChallenge(missing) = %+v, %v; want nil, nil", got, err)
+		}
+		if err := h.Store.StoreChallenge(ctx, challenge("c1")); err != nil {
+			t.Fatalf("StoreChallenge error: %v", err)
+		}
+		got, err := h.Store.ConsumeChallenge(ctx, "c1")
+		if err != nil || got == nil || got.Ceremony != models.PasskeyCeremonyRegistration || got.UserID != "u1" {
+			t.Fatalf("ConsumeChallenge = %+v, %v", got, err)
+		}
+		if got, _ := h.Store.ConsumeChallenge(ctx, "c1"); got != nil {
+			t.Fatalf("challenge accepted twice")
+		}
+	})
+
+	t.Run("Expires", func(t *testing.T) {
+		h := newHarness(t)
+		h.Store.StoreChallenge(ctx, challenge("c1"))
+		h.Advance(2 * time.Minute)
+		if got, _ := h.Store.ConsumeChallenge(ctx, "c1"); got != nil {
+			t.Fatalf("expired challenge accepted")
+		}
+	})
+
+	t.Run("ConcurrentConsume", func(t *testing.T) {
+		h := newHarness(t)
+		h.Store.StoreChallenge(ctx, challenge("c1"))
+		var wins int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if got, _ := h.Store.ConsumeChallenge(ctx, "c1"); got != nil {
+					atomic.AddInt32(&wins, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		if wins != 1 {
+			t.Fatalf("challenge accepted %d times; want 1", wins)
+		}
+	})
+}
+
+// UsedTokenHarness is a used-token store whose clock the suite can move forward
+type UsedTokenHarness struct {
+	Store   repository.UsedTokenStore
+	Advance func(d time.Duration)
+}
+
+// RunUsedTokenStore checks single use, expiry and atomic marking
+func RunUsedTokenStore(t *testing.T, newHarness func(t *testing.T) UsedTokenHarness) {
+	ctx := context.Background()
+
+	t.Run("SingleUse", func(t *testing.T) {
+		h := newHarness(t)
+		if ok, err := h.Store.MarkUsed(ctx, "j1", time.Minute); !ok || err != nil {
+			t.Fatalf("MarkUsed = %v, %v; want true", ok, err)
+		}
+		if ok, _ := h.Store.MarkUsed(ctx, "j1", time.Minute); ok {
+			t.Fatalf("token accepted twice")
+		}
+		if ok, _ := h.Store.MarkUsed(ctx, "j2", time.Minute); !ok {
+			t.Fatalf("another token was refused")
+		}
+	})
+
+	t.Run("Expires", func(t *testing.T) {
+		h := newHarness(t)
+		h.Store.MarkUsed(ctx, "j1", time.Minute)
+		h.Advance(2 * time.Minute)
+		if ok, _ := h.Store.MarkUsed(ctx, "j1", time.Minute); !ok {
+			t.Fatalf("record outlived its ttl")
+		}
+	})
+
+	t.Run("ConcurrentMark", func(t *testing.T) {
+		h := newHarness(t)
+		var wins int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ok, _ := h.Store.MarkUsed(ctx, "j1", time.Minute); ok {
+					atomic.AddInt32(&wins, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		if wins != 1 {
+			t.Fatalf("token accepted %d times; want 1", wins)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// SMSUsageRepository keeps SMS toll-fraud counters in Redis
+// Key patterns:
+//   smsguard:spend:{hour}:{prefix}  -> spend for a calling code in the hour starting at {hour} (unix seconds)
+//   smsguard:spend:{hour}:all       -> spend across all calling codes in that hour
+//   smsguard:range:{range}          -> zset of numbers in a number range scored by last send (unix ms)
+type SMSUsageRepository struct {
+	client *redis.Client
+}
+
+func NewSMSUsageRepository(client *redis.Client) *SMSUsageRepository {
+	return &SMSUsageRepository{client: client}
+}
+
+// spendTTL keeps an hour's counters a little past the end of the hour
+const spendTTL = 2 * time.Hour
+
+func (r *SMSUsageRepository) spendKey(prefix string, now time.Time) string {
+	return fmt.Sprintf("smsguard:spend:%d:%s", now.Truncate(time.Hour).Unix(), prefix)
+}
+
+func (r *SMSUsageRepository) rangeKey(numberRange string) string {
+	return fmt.Sprintf("smsguard:range:%s", numberRange)
+}
+
+// HourlySpend returns this hour's spend for prefix and overall
+func (r *SMSUsageRepository) HourlySpend(ctx context.Context, prefix string, now time.Time) (int64, int64, error) {
+	vals, err := r.client.MGet(ctx, r.spendKey(prefix, now), r.spendKey("all", now)).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	var out [2]int64
+	for i, v := range vals {
+		if s, ok := v.(string); ok {
+			if out[i], err = strconv.ParseInt(s, 10, 64); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	return out[0], out[1], nil
+}
+
+// addSpendScript increments both counters only when neither would exceed its cap
+var addSpendScript = redis.NewScript(`
+local cost = tonumber(ARGV[1])
+local prefixSpend = tonumber(redis.call('GET', KEYS[1]) or '0')
+local globalSpend = tonumber(redis.call('GET', KEYS[2]) or '0')
+if prefixSpend + cost > tonumber(ARGV[2]) or globalSpend + cost > tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('INCRBY', KEYS[1], cost)
+redis.call('INCRBY', KEYS[2], cost)
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+redis.call('EXPIRE', KEYS[2], ARGV[4])
+return 1
+`)
+
+// AddSpend atomically records cost when it fits under both caps
+func (r *SMSUsageRepository) AddSpend(ctx context.Context, prefix string, cost, prefixCap, globalCap int64, now time.Time) (bool, error) {
+	keys := []string{r.spendKey(prefix, now), r.spendKey("all", now)}
+	res, err := addSpendScript.Run(ctx, r.client, keys, cost, prefixCap, globalCap, int(spendTTL.Seconds())).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// TrackDestination records number and counts the distinct numbers in its range within window
+func (r *SMSUsageRepository) TrackDestination(ctx context.Context, numberRange, number string, now time.Time, window time.Duration) (int, error) {
+	key := r.rangeKey(numberRange)
+	pipe := r.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("(%d", now.Add(-window).UnixMilli()))
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now.UnixMilli()), Member: number})
+	count := pipe.ZCard(ctx, key)
+	pipe.Expire(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(count.Val()), nil
+}
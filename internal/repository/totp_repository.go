@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// TOTPRepository stores authenticator-app enrollments in Redis
+// Key patterns:
+//   user_totp:{user_id}          -> TOTPEnrollment JSON without LastUsedStep and RecoveryCodes
+//   user_totp_step:{user_id}     -> last accepted time step
+//   user_totp_recovery:{user_id} -> set of unused recovery code hashes
+type TOTPRepository struct {
+	client *redis.Client
+}
+
+func NewTOTPRepository(client *redis.Client) *TOTPRepository {
+	return &TOTPRepository{client: client}
+}
+
+func (r *TOTPRepository) key(userID string) string {
+	return fmt.Sprintf("user_totp:%s", userID)
+}
+
+func (r *TOTPRepository) stepKey(userID string) string {
+	return fmt.Sprintf("user_totp_step:%s", userID)
+}
+
+func (r *TOTPRepository) recoveryKey(userID string) string {
+	return fmt.Sprintf("user_totp_recovery:%s", userID)
+}
+
+// Save replaces the user's enrollment, including its used step and recovery codes
+func (r *TOTPRepository) Save(ctx context.Context, enrollment models.TOTPEnrollment) error {
+	stored := enrollment
+	stored.LastUsedStep = 0
+	stored.RecoveryCodes = nil
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	id := enrollment.UserID
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(id), string(b), 0)
+		pipe.Set(ctx, r.stepKey(id), enrollment.LastUsedStep, 0)
+		pipe.Del(ctx, r.recoveryKey(id))
+		if len(enrollment.RecoveryCodes) > 0 {
+			members := make([]interface{}, len(enrollment.RecoveryCodes))
+			for i, code := range enrollment.RecoveryCodes {
+				members[i] = code
+			}
+			pipe.SAdd(ctx, r.recoveryKey(id), members...)
+		}
+		return nil
+	})
+	return err
+}
+
+// Get returns the user's enrollment, or nil when there is none
+func (r *TOTPRepository) Get(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	pipe := r.client.Pipeline()
+	get := pipe.Get(ctx, r.key(userID))
+	step := pipe.Get(ctx, r.stepKey(userID))
+	codes := pipe.SMembers(ctx, r.recoveryKey(userID))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	val, err := get.Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var enrollment models.TOTPEnrollment
+	if err := json.Unmarshal([]byte(val), &enrollment); err != nil {
+		return nil, err
+	}
+	if enrollment.LastUsedStep, err = step.Int64(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	enrollment.RecoveryCodes = codes.Val()
+	return &enrollment, nil
+}
+
+// Delete removes the user's enrollment
+func (r *TOTPRepository) Delete(ctx context.Context, userID string) error {
+	return r.client.Del(ctx, r.key(userID), r.stepKey(userID), r.recoveryKey(userID)).Err()
+}
+
+// useStepScript advances the last used step only when the enrollment exists and step is newer
+var useStepScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local last = tonumber(redis.call('GET', KEYS[2]) or '0')
+if tonumber(ARGV[1]) <= last then
+	return 0
+end
+redis.call('SET', KEYS[2], ARGV[1])
+return 1
+`)
+
+// UseStep atomically accepts step if it is after the last accepted one
+func (r *TOTPRepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	res, err := useStepScript.Run(ctx, r.client, []string{r.key(userID), r.stepKey(userID)}, step).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// UseRecoveryCode removes codeHash from the unused set; SREM makes it single-use
+func (r *TOTPRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	n, err := r.client.SRem(ctx, r.recoveryKey(userID), codeHash).Result()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// UsedTokenRepository remembers redeemed single-use token IDs in Redis
+// Key pattern: used_token:{jti} -> "1", expiring with the token
+type UsedTokenRepository struct {
+	client *redis.Client
+}
+
+func NewUsedTokenRepository(client *redis.Client) *UsedTokenRepository {
+	return &UsedTokenRepository{client: client}
+}
+
+// MarkUsed records the ID with SETNX so only the first caller succeeds
+func (r *UsedTokenRepository) MarkUsed(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, fmt.Sprintf("used_token:%s", id), "1", ttl).Result()
+}
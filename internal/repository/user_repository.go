@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrUserExists is returned when the email or phone is already registered
+var ErrUserExists = errors.New("user already exists")
+
+// UserRepository stores user accounts in Redis
+// Key patterns: user:{id}, user_email:{email} -> id, user_phone:{phone} -> id
+type UserRepository struct {
+	client *redis.Client
+}
+
+func NewUserRepository(client *redis.Client) *UserRepository {
+	return &UserRepository{client: client}
+}
+
+func (r *UserRepository) key(id string) string {
+	return fmt.Sprintf("user:%s", id)
+}
+
+func (r *UserRepository) emailKey(email string) string {
+	return fmt.Sprintf("user_email:%s", email)
+}
+
+func (r *UserRepository) phoneKey(phone string) string {
+	return fmt.Sprintf("user_phone:%s", phone)
+}
+
+// Create stores a new user, reserving its email and phone so they stay unique
+func (r *UserRepository) Create(ctx context.Context, user models.User) error {
+	ok, err := r.client.SetNX(ctx, r.emailKey(user.Email), user.ID, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUserExists
+	}
+	if user.Phone != "" {
+		ok, err := r.client.SetNX(ctx, r.phoneKey(user.Phone), user.ID, 0).Result()
+		if err != nil || !ok {
+			r.client.Del(ctx, r.emailKey(user.Email))
+			if err != nil {
+				return err
+			}
+			return ErrUserExists
+		}
+	}
+	return r.Save(ctx, user)
+}
+
+// Save overwrites the stored user record
+func (r *UserRepository) Save(ctx context.Context, user models.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, r.key(user.ID), data, 0).Err()
+}
+
+// UpdatePhone saves the user after moving the phone index from oldPhone to user.Phone
+func (r *UserRepository) UpdatePhone(ctx context.Context, user models.User, oldPhone string) error {
+	ok, err := r.client.SetNX(ctx, r.phoneKey(user.Phone), user.ID, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		owner, err := r.client.Get(ctx, r.phoneKey(user.Phone)).Result()
+		if err != nil {
+			return err
+		}
+		if owner != user.ID {
+			return ErrUserExists
+		}
+	}
+	if err := r.Save(ctx, user); err != nil {
+		if ok {
+			r.client.Del(ctx, r.phoneKey(user.Phone))
+		}
+		return err
+	}
+	if oldPhone != "" && oldPhone != user.Phone {
+		return r.client.Del(ctx, r.phoneKey(oldPhone)).Err()
+	}
+	return nil
+}
+
+// GetByID returns the user or nil when it does not exist
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+	val, err := r.client.Get(ctx, r.key(id)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var user models.User
+	if err := json.Unmarshal(val, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByEmail looks up a user by email address
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.getByIndex(ctx, r.emailKey(email))
+}
+
+// GetByPhone looks up a user by phone number
+func (r *UserRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	return r.getByIndex(ctx, r.phoneKey(phone))
+}
+
+func (r *UserRepository) getByIndex(ctx context.Context, indexKey string) (*models.User, error) {
+	id, err := r.client.Get(ctx, indexKey).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r.GetByID(ctx, id)
+}
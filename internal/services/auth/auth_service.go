@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/codeZe-us/vestroll-backend/internal/utils"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidInput wraps request validation failures
+	ErrInvalidInput = apperrors.Validation("invalid input")
+	// ErrInvalidCredentials is returned for an unknown identifier or wrong password
+	ErrInvalidCredentials = apperrors.Unauthorized("invalid credentials").WithCode("invalid_credentials")
+	// ErrAccountExists is returned when registering an email or phone that is taken
+	ErrAccountExists = apperrors.Conflict("an account with this email or phone already exists").WithCode("account_exists")
+	// ErrVerificationRequired is returned when a verify-otp token is missing or does not match
+	ErrVerificationRequired = apperrors.Forbidden("a valid verification token for this email or phone is required").WithCode("verification_required")
+	// ErrUserNotFound is returned when the authenticated user no longer exists
+	ErrUserNotFound = apperrors.NotFound("user not found")
+)
+
+var phoneRegex = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
+
+// dummyHash is verified against when the identifier is unknown so that
+// login takes the same time whether or not the account exists
+var dummyHash, _ = utils.HashPassword("dummy-password-for-timing")
+
+// AuthService registers users and authenticates password credentials
+type AuthService struct {
+	users                  repository.UserStore
+	sessions               *SessionService
+	requireVerifiedContact bool
+}
+
+func NewAuthService(users repository.UserStore, sessions *SessionService, requireVerifiedContact bool) *AuthService {
+	return &AuthService{users: users, sessions: sessions, requireVerifiedContact: requireVerifiedContact}
+}
+
+// Register creates a new account and returns an access token for it
+func (s *AuthService) Register(ctx context.Context, req models.RegisterRequest) (models.AuthResponse, error) {
+	email := normalizeEmail(req.Email)
+	phone := strings.TrimSpace(req.Phone)
+	if phone != "" && !phoneRegex.MatchString(phone) {
+		return models.AuthResponse{}, fmt.Errorf("%w: phone must be in international format (e.g., +2348012345678)", ErrInvalidInput)
+	}
+	if err := utils.ValidatePasswordStrength(req.Password); err != nil {
+		return models.AuthResponse{}, fmt.Errorf("%w: %s", ErrInvalidInput, err.Error())
+	}
+	emailVerified, phoneVerified, err := s.checkVerification(req.VerificationToken, email, phone)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	hash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return models.AuthResponse{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+	now := time.Now()
+	user := models.User{
+		ID:            uuid.NewString(),
+		Email:         email,
+		Phone:         phone,
+		PasswordHash:  hash,
+		EmailVerified: emailVerified,
+		PhoneVerified: phoneVerified,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.users.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrUserExists) {
+			return models.AuthResponse{}, ErrAccountExists
+		}
+		return models.AuthResponse{}, fmt.Errorf("failed to create user: %w", err)
+	}
+	return s.issue(ctx, user, "Registration successful")
+}
+
+// Login verifies the password for the email or phone identifier and returns an access token
+func (s *AuthService) Login(ctx context.Context, req models.LoginRequest) (models.AuthResponse, error) {
+	user, err := s.lookup(ctx, req.Identifier)
+	if err != nil {
+		return models.AuthResponse{}, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user == nil {
+		utils.VerifyPassword(req.Password, dummyHash)
+		return models.AuthResponse{}, ErrInvalidCredentials
+	}
+	ok, err := utils.VerifyPassword(req.Password, user.PasswordHash)
+	if err != nil {
+		return models.AuthResponse{}, fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !ok {
+		return models.AuthResponse{}, ErrInvalidCredentials
+	}
+	return s.issue(ctx, *user, "Login successful")
+}
+
+// checkVerification validates an optional verify-otp token against the email and phone
+// being registered and enforces it when verified contacts are required
+func (s *AuthService) checkVerification(token, email, phone string) (emailVerified, phoneVerified bool, err error) {
+	if token != "" {
+		claims, err := s.sessions.tokens.ParseVerificationToken(token, "")
+		if err != nil {
+			return false, false, ErrVerificationRequired
+		}
+		switch claims.Purpose {
+		case PurposeEmailVerified:
+			emailVerified = normalizeEmail(claims.Subject) == email
+		case PurposePhoneVerified:
+			phoneVerified = phone != "" && claims.Subject == phone
+		}
+		if !emailVerified && !phoneVerified {
+			return false, false, ErrVerificationRequired
+		}
+	}
+	if s.requireVerifiedContact && !emailVerified && !phoneVerified {
+		return false, false, ErrVerificationRequired
+	}
+	return emailVerified, phoneVerified, nil
+}
+
+// ChangePhone replaces the user's phone number with one that has been verified
+func (s *AuthService) ChangePhone(ctx context.Context, userID, phone string) (models.UserInfo, error) {
+	phone = strings.TrimSpace(phone)
+	if !phoneRegex.MatchString(phone) {
+		return models.UserInfo{}, fmt.Errorf("%w: phone must be in international format (e.g., +2348012345678)", ErrInvalidInput)
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return models.UserInfo{}, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user == nil {
+		return models.UserInfo{}, ErrUserNotFound
+	}
+	oldPhone := user.Phone
+	user.Phone = phone
+	user.PhoneVerified = true
+	user.UpdatedAt = time.Now()
+	if err := s.users.UpdatePhone(ctx, *user, oldPhone); err != nil {
+		if errors.Is(err, repository.ErrUserExists) {
+			return models.UserInfo{}, ErrAccountExists
+		}
+		return models.UserInfo{}, fmt.Errorf("failed to update phone: %w", err)
+	}
+	return user.Info(), nil
+}
+
+// FindUser looks up an account by email or phone, returning nil when none exists
+func (s *AuthService) FindUser(ctx context.Context, identifier string) (*models.User, error) {
+	return s.lookup(ctx, identifier)
+}
+
+// ResetPassword sets a new password for the account and revokes all of its sessions
+func (s *AuthService) ResetPassword(ctx context.Context, identifier, newPassword string) error {
+	if err := utils.ValidatePasswordStrength(newPassword); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidInput, err.Error())
+	}
+	user, err := s.lookup(ctx, identifier)
+	if err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+	if user == nil {
+		return ErrInvalidCredentials
+	}
+	hash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.PasswordHash = hash
+	user.UpdatedAt = time.Now()
+	if err := s.users.Save(ctx, *user); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if err := s.sessions.LogoutAll(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+func (s *AuthService) lookup(ctx context.Context, identifier string) (*models.User, error) {
+	identifier = strings.TrimSpace(identifier)
+	if strings.HasPrefix(identifier, "+") {
+		return s.users.GetByPhone(ctx, identifier)
+	}
+	return s.users.GetByEmail(ctx, normalizeEmail(identifier))
+}
+
+func (s *AuthService) issue(ctx context.Context, user models.User, message string) (models.AuthResponse, error) {
+	pair, err := s.sessions.Issue(ctx, user.ID)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+	return models.AuthResponse{
+		Success:   true,
+		Message:   message,
+		TokenPair: pair,
+		User:      user.Info(),
+	}, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
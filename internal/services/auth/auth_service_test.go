@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	redis "github.com/go-redis/redis/v8"
+)
+
+func setupAuthService(t *testing.T) (*AuthService, *TokenService) {
+	return setupAuthServiceWith(t, false)
+}
+
+func setupAuthServiceWith(t *testing.T, requireVerifiedContact bool) (*AuthService, *TokenService) {
+	mini, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mini.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: mini.Addr()})
+	tokens := NewTokenService(config.JWTConfig{Secret: "test-secret", TTL: time.Hour, VerificationTTL: 15 * time.Minute})
+	sessions := NewSessionService(tokens, repository.NewRefreshTokenRepository(rdb), 24*time.Hour)
+	return NewAuthService(repository.NewUserRepository(rdb), sessions, requireVerifiedContact), tokens
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	svc, tokens := setupAuthService(t)
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, models.RegisterRequest{Email: "Ada@Example.com", Phone: "+2348012345678", Password: "Str0ng!Pass"})
+	if err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if reg.User.Email != "ada@example.com" {
+		t.Fatalf("expected normalized email, got %s", reg.User.Email)
+	}
+	if reg.ExpiresIn != 3600 {
+		t.Fatalf("expected expires_in 3600, got %d", reg.ExpiresIn)
+	}
+
+	claims, err := tokens.ParseAccessToken(reg.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken error: %v", err)
+	}
+	if claims.Subject != reg.User.ID {
+		t.Fatalf("expected subject %s, got %s", reg.User.ID, claims.Subject)
+	}
+
+	for _, identifier := range []string{"ada@example.com", "+2348012345678"} {
+		resp, err := svc.Login(ctx, models.LoginRequest{Identifier: identifier, Password: "Str0ng!Pass"})
+		if err != nil {
+			t.Fatalf("Login(%s) error: %v", identifier, err)
+		}
+		if resp.User.ID != reg.User.ID {
+			t.Fatalf("Login(%s) returned wrong user", identifier)
+		}
+	}
+
+	if _, err := svc.Login(ctx, models.LoginRequest{Identifier: "ada@example.com", Password: "Wr0ng!Pass"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials for wrong password, got %v", err)
+	}
+	if _, err := svc.Login(ctx, models.LoginRequest{Identifier: "nobody@example.com", Password: "Str0ng!Pass"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials for unknown user, got %v", err)
+	}
+}
+
+func TestRegisterRejectsDuplicatesAndWeakPasswords(t *testing.T) {
+	svc, _ := setupAuthService(t)
+	ctx := context.Background()
+
+	if _, err := svc.Register(ctx, models.RegisterRequest{Email: "a@example.com", Password: "weak"}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected ErrInvalidInput for weak password, got %v", err)
+	}
+	if _, err := svc.Register(ctx, models.RegisterRequest{Email: "a@example.com", Password: "Str0ng!Pass"}); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if _, err := svc.Register(ctx, models.RegisterRequest{Email: "A@example.com", Password: "Str0ng!Pass"}); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("expected ErrAccountExists, got %v", err)
+	}
+}
+
+func TestParseAccessTokenRejectsTampering(t *testing.T) {
+	tokens := NewTokenService(config.JWTConfig{Secret: "test-secret", TTL: time.Hour})
+	other := NewTokenService(config.JWTConfig{Secret: "other-secret", TTL: time.Hour})
+	token, err := other.IssueAccessToken("u1", "s1")
+	if err != nil {
+		t.Fatalf("IssueAccessToken error: %v", err)
+	}
+	if _, err := tokens.ParseAccessToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestResetPasswordRevokesSessions(t *testing.T) {
+	svc, _ := setupAuthService(t)
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, models.RegisterRequest{Email: "a@example.com", Password: "Str0ng!Pass"})
+	if err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if err := svc.ResetPassword(ctx, "a@example.com", "N3w!Password"); err != nil {
+		t.Fatalf("ResetPassword error: %v", err)
+	}
+	if _, err := svc.sessions.ValidateAccessToken(ctx, reg.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected existing session to be revoked, got %v", err)
+	}
+	if _, err := svc.Login(ctx, models.LoginRequest{Identifier: "a@example.com", Password: "Str0ng!Pass"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected old password to be rejected, got %v", err)
+	}
+	if _, err := svc.Login(ctx, models.LoginRequest{Identifier: "a@example.com", Password: "N3w!Password"}); err != nil {
+		t.Fatalf("expected new password to work, got %v", err)
+	}
+}
+
+func TestRegisterRequiresMatchingVerificationToken(t *testing.T) {
+	svc, tokens := setupAuthServiceWith(t, true)
+	ctx := context.Background()
+	req := models.RegisterRequest{Email: "a@example.com", Phone: "+2348012345678", Password: "Str0ng!Pass"}
+
+	if _, err := svc.Register(ctx, req); !errors.Is(err, ErrVerificationRequired) {
+		t.Fatalf("expected ErrVerificationRequired without token, got %v", err)
+	}
+
+	other, _ := tokens.IssueVerificationToken(PurposeEmailVerified, "b@example.com")
+	req.VerificationToken = other
+	if _, err := svc.Register(ctx, req); !errors.Is(err, ErrVerificationRequired) {
+		t.Fatalf("expected ErrVerificationRequired for another identifier, got %v", err)
+	}
+
+	phoneToken, _ := tokens.IssueVerificationToken(PurposePhoneVerified, "+2348012345678")
+	req.VerificationToken = phoneToken
+	resp, err := svc.Register(ctx, req)
+	if err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if !resp.User.PhoneVerified || resp.User.EmailVerified {
+		t.Fatalf("expected only phone to be verified, got %+v", resp.User)
+	}
+}
+
+func TestVerificationTokenPurposeIsEnforced(t *testing.T) {
+	_, tokens := setupAuthService(t)
+	token, _ := tokens.IssueVerificationToken(PurposeEmailVerified, "a@example.com")
+	if _, err := tokens.ParseVerificationToken(token, PurposePhoneVerified); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected purpose mismatch to be rejected, got %v", err)
+	}
+	if _, err := tokens.ParseAccessToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected verification token to be rejected as access token, got %v", err)
+	}
+}
+
+func TestChangePhoneMovesIndex(t *testing.T) {
+	svc, _ := setupAuthService(t)
+	ctx := context.Background()
+
+	a, _ := svc.Register(ctx, models.RegisterRequest{Email: "a@example.com", Phone: "+2348000000001", Password: "Str0ng!Pass"})
+	if _, err := svc.Register(ctx, models.RegisterRequest{Email: "b@example.com", Phone: "+2348000000002", Password: "Str0ng!Pass"}); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+
+	if _, err := svc.ChangePhone(ctx, a.User.ID, "+2348000000002"); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("expected ErrAccountExists for a taken phone, got %v", err)
+	}
+	info, err := svc.ChangePhone(ctx, a.User.ID, "+2348000000003")
+	if err != nil {
+		t.Fatalf("ChangePhone error: %v", err)
+	}
+	if info.Phone != "+2348000000003" || !info.PhoneVerified {
+		t.Fatalf("expected verified new phone, got %+v", info)
+	}
+	if u, _ := svc.FindUser(ctx, "+2348000000001"); u != nil {
+		t.Fatalf("expected old phone to be released")
+	}
+	if u, _ := svc.FindUser(ctx, "+2348000000003"); u == nil || u.ID != a.User.ID {
+		t.Fatalf("expected new phone to resolve to the user")
+	}
+}
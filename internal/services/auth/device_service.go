@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+var (
+	// ErrDeviceNotVerified is returned when the verified phone or email is not the user's
+	ErrDeviceNotVerified = apperrors.Forbidden("a new_device verification for your phone or email is required").WithCode("verification_required")
+	// ErrDeviceExists is returned when the user already registered the device ID
+	ErrDeviceExists = apperrors.Conflict("device already registered").WithCode("device_exists")
+	// ErrTooManyDevices is returned when the user reached DeviceConfig.MaxPerUser
+	ErrTooManyDevices = apperrors.Conflict("too many devices; remove one first").WithCode("device_limit_reached")
+	// ErrDeviceNotFound is returned when revoking a device the user does not have
+	ErrDeviceNotFound = apperrors.NotFound("device not found")
+	// ErrUnknownDevice is returned when a device-bound session cannot be checked
+	// against its device
+	ErrUnknownDevice = apperrors.Unauthorized("device is not registered").WithCode("device_not_registered")
+	// ErrInvalidDeviceSignature is returned for a signature the device key did not make
+	// or a device that is not registered; the two look the same so the public login
+	// endpoints do not reveal which devices a user has
+	ErrInvalidDeviceSignature = apperrors.Unauthorized("invalid device signature").WithCode("invalid_device_signature")
+	// ErrDeviceChallengeExpired is returned for a missing, unknown, expired or reused
+	// challenge; the client can ask for a new one and try again
+	ErrDeviceChallengeExpired = apperrors.Unauthorized("device challenge is invalid or expired; request a new one").WithCode("challenge_expired")
+)
+
+// DeviceSessions ends the sessions bound to a device; *SessionService implements it
+type DeviceSessions interface {
+	LogoutDevice(ctx context.Context, userID, deviceID string) error
+}
+
+// DeviceNotifier tells the user a device was added to their account;
+// *services.CodeDispatcher implements it
+type DeviceNotifier interface {
+	NotifyNewDevice(ctx context.Context, user models.User, device models.Device) error
+}
+
+// DeviceService manages the trusted devices a user can sign in from with a PIN.
+// Each device holds a private key; PIN login needs a signature over a fresh
+// challenge, so a stolen PIN alone cannot start a session.
+type DeviceService struct {
+	devices    repository.DeviceStore
+	challenges repository.DeviceChallengeStore
+	users      repository.UserStore
+	sessions   DeviceSessions
+	notices    DeviceNotifier
+	config     config.DeviceConfig
+	now        func() time.Time
+}
+
+// NewDeviceService creates the service; notices may be nil to skip new-device messages
+func NewDeviceService(devices repository.DeviceStore, challenges repository.DeviceChallengeStore, users repository.UserStore, sessions DeviceSessions, notices DeviceNotifier, cfg config.DeviceConfig) *DeviceService {
+	return &DeviceService{devices: devices, challenges: challenges, users: users, sessions: sessions, notices: notices, config: cfg, now: time.Now}
+}
+
+// ValidateRegistration checks the request's public key is one Register accepts
+func (s *DeviceService) ValidateRegistration(req models.RegisterDeviceRequest) error {
+	if _, err := parseDeviceKey(req.PublicKey); err != nil {
+		return apperrors.Validation(err.Error())
+	}
+	return nil
+}
+
+// Register adds a device for the user. verifiedIdentifier is the phone or
+// email proven by a new_device OTP and must belong to the user. The user is
+// told about the new device on every contact they have.
+func (s *DeviceService) Register(ctx context.Context, userID, verifiedIdentifier string, req models.RegisterDeviceRequest) (*models.Device, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user == nil || verifiedIdentifier == "" ||
+		(verifiedIdentifier != user.Phone && !strings.EqualFold(verifiedIdentifier, user.Email)) {
+		return nil, ErrDeviceNotVerified
+	}
+	if err := s.ValidateRegistration(req); err != nil {
+		return nil, err
+	}
+	existing, err := s.devices.List(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	if s.config.MaxPerUser > 0 && len(existing) >= s.config.MaxPerUser {
+		return nil, ErrTooManyDevices
+	}
+
+	now := s.now()
+	device := models.Device{
+		ID:         req.DeviceID,
+		UserID:     userID,
+		Platform:   req.Platform,
+		Name:       req.Name,
+		PublicKey:  req.PublicKey,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	if err := s.devices.Create(ctx, device); err != nil {
+		if errors.Is(err, repository.ErrDeviceExists) {
+			return nil, ErrDeviceExists
+		}
+		return nil, fmt.Errorf("failed to save device: %w", err)
+	}
+	if s.notices != nil {
+		if err := s.notices.NotifyNewDevice(ctx, *user, device); err != nil {
+			log.Printf("failed to send new device notice to user %s: %v", userID, err)
+		}
+	}
+	return &device, nil
+}
+
+// List returns the user's devices, oldest first
+func (s *DeviceService) List(ctx context.Context, userID string) ([]models.Device, error) {
+	devices, err := s.devices.List(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	return devices, nil
+}
+
+// Revoke removes the device and ends every session started on it
+func (s *DeviceService) Revoke(ctx context.Context, userID, deviceID string) error {
+	removed, err := s.devices.Delete(ctx, userID, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to remove device: %w", err)
+	}
+	if !removed {
+		return ErrDeviceNotFound
+	}
+	if err := s.sessions.LogoutDevice(ctx, userID, deviceID); err != nil {
+		return fmt.Errorf("failed to end device sessions: %w", err)
+	}
+	return nil
+}
+
+// Challenge issues a single-use nonce for the device to sign. Any user and
+// device ID get one, so the answer does not reveal whether the device is
+// registered; VerifyChallenge refuses unknown devices.
+func (s *DeviceService) Challenge(ctx context.Context, userID, deviceID string) (models.DeviceChallengeResponse, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return models.DeviceChallengeResponse{}, err
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(nonce)
+	if err := s.challenges.StoreChallenge(ctx, userID, deviceID, challenge, s.config.ChallengeTTL); err != nil {
+		return models.DeviceChallengeResponse{}, fmt.Errorf("failed to store challenge: %w", err)
+	}
+	return models.DeviceChallengeResponse{Challenge: challenge, ExpiresIn: int(s.config.ChallengeTTL.Seconds())}, nil
+}
+
+// VerifyChallenge checks that the registered device signed the challenge,
+// which is used up whether or not the signature is valid
+func (s *DeviceService) VerifyChallenge(ctx context.Context, userID, deviceID, challenge, signature string) error {
+	ok, err := s.challenges.ConsumeChallenge(ctx, userID, deviceID, challenge)
+	if err != nil {
+		return fmt.Errorf("failed to check challenge: %w", err)
+	}
+	if !ok {
+		return ErrDeviceChallengeExpired
+	}
+	device, err := s.devices.Get(ctx, userID, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to load device: %w", err)
+	}
+	if device == nil {
+		return ErrInvalidDeviceSignature
+	}
+	key, err := parseDeviceKey(device.PublicKey)
+	if err != nil {
+		return fmt.Errorf("stored device key is invalid: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !verifyDeviceSignature(key, []byte(challenge), sig) {
+		return ErrInvalidDeviceSignature
+	}
+	if err := s.devices.Touch(ctx, userID, deviceID, s.now()); err != nil {
+		log.Printf("failed to record use of device %s for user %s: %v", deviceID, userID, err)
+	}
+	return nil
+}
+
+// parseDeviceKey decodes a base64 DER (PKIX) public key, accepting the key
+// types mobile keystores generate: ECDSA P-256 and Ed25519
+func parseDeviceKey(encoded string) (crypto.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("public_key must be base64-encoded DER")
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, errors.New("public_key is not a valid PKIX public key")
+	}
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("public_key must use the P-256 curve")
+		}
+	case ed25519.PublicKey:
+	default:
+		return nil, errors.New("public_key must be an ECDSA P-256 or Ed25519 key")
+	}
+	return key, nil
+}
+
+// verifyDeviceSignature checks an ASN.1 ECDSA-SHA256 or Ed25519 signature over message
+func verifyDeviceSignature(key crypto.PublicKey, message, sig []byte) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, message, sig)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+// recordingDevices captures new-device notices and ended device sessions
+type recordingDevices struct {
+	notices []models.Device
+	ended   []string
+}
+
+func (r *recordingDevices) NotifyNewDevice(ctx context.Context, user models.User, device models.Device) error {
+	r.notices = append(r.notices, device)
+	return nil
+}
+
+func (r *recordingDevices) LogoutDevice(ctx context.Context, userID, deviceID string) error {
+	r.ended = append(r.ended, userID+"/"+deviceID)
+	return nil
+}
+
+func newTestDevices(t *testing.T) (*DeviceService, *recordingDevices) {
+	t.Helper()
+	users := memory.NewUserRepository()
+	if err := users.Create(context.Background(), models.User{ID: "u1", Email: "ada@example.com", Phone: "+2348012345678"}); err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+	rec := &recordingDevices{}
+	cfg := config.DeviceConfig{ChallengeTTL: time.Minute, MaxPerUser: 2}
+	return NewDeviceService(memory.NewDeviceRepository(), memory.NewDeviceChallenges(nil), users, rec, rec, cfg), rec
+}
+
+// newP256Device returns a registration request and a signer for a software device key
+func newP256Device(t *testing.T, id string) (models.RegisterDeviceRequest, func(challenge string) string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	req := models.RegisterDeviceRequest{DeviceID: id, Platform: models.DevicePlatformIOS, Name: "iPhone", PublicKey: base64.StdEncoding.EncodeToString(der)}
+	return req, func(challenge string) string {
+		digest := sha256.Sum256([]byte(challenge))
+		sig, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+}
+
+func TestRegisterDeviceChecksVerificationKeyAndLimit(t *testing.T) {
+	svc, rec := newTestDevices(t)
+	ctx := context.Background()
+	req, _ := newP256Device(t, "d1")
+
+	if _, err := svc.Register(ctx, "u1", "+2340000000000", req); !errors.Is(err, ErrDeviceNotVerified) {
+		t.Fatalf("Register with another phone = %v, want ErrDeviceNotVerified", err)
+	}
+	bad := req
+	bad.PublicKey = base64.StdEncoding.EncodeToString([]byte("not a key"))
+	if _, err := svc.Register(ctx, "u1", "ada@example.com", bad); apperrors.KindOf(err) != apperrors.KindValidation {
+		t.Fatalf("Register with a bad key = %v, want validation error", err)
+	}
+	if _, err := svc.Register(ctx, "u1", "ADA@example.com", req); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if len(rec.notices) != 1 || rec.notices[0].ID != "d1" {
+		t.Fatalf("expected a new device notice for d1, got %+v", rec.notices)
+	}
+	if _, err := svc.Register(ctx, "u1", "+2348012345678", req); !errors.Is(err, ErrDeviceExists) {
+		t.Fatalf("Register twice = %v, want ErrDeviceExists", err)
+	}
+
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	second := models.RegisterDeviceRequest{DeviceID: "d2", Platform: models.DevicePlatformAndroid, Name: "Pixel", PublicKey: base64.StdEncoding.EncodeToString(der)}
+	if _, err := svc.Register(ctx, "u1", "+2348012345678", second); err != nil {
+		t.Fatalf("Register Ed25519 device error: %v", err)
+	}
+	third, _ := newP256Device(t, "d3")
+	if _, err := svc.Register(ctx, "u1", "+2348012345678", third); !errors.Is(err, ErrTooManyDevices) {
+		t.Fatalf("Register past the limit = %v, want ErrTooManyDevices", err)
+	}
+}
+
+func TestDeviceChallengeIsSignedOnceByTheDeviceKey(t *testing.T) {
+	svc, _ := newTestDevices(t)
+	ctx := context.Background()
+	req, sign := newP256Device(t, "d1")
+	svc.Register(ctx, "u1", "ada@example.com", req)
+	_, otherSign := newP256Device(t, "other")
+
+	// Unknown devices get a challenge too and fail like a bad signature
+	unknown, err := svc.Challenge(ctx, "u1", "unknown")
+	if err != nil || unknown.Challenge == "" {
+		t.Fatalf("Challenge for an unknown device = %+v, %v", unknown, err)
+	}
+	if err := svc.VerifyChallenge(ctx, "u1", "unknown", unknown.Challenge, sign(unknown.Challenge)); !errors.Is(err, ErrInvalidDeviceSignature) {
+		t.Fatalf("VerifyChallenge for an unknown device = %v, want ErrInvalidDeviceSignature", err)
+	}
+	ch, err := svc.Challenge(ctx, "u1", "d1")
+	if err != nil || ch.Challenge == "" || ch.ExpiresIn != 60 {
+		t.Fatalf("Challenge = %+v, %v", ch, err)
+	}
+	// A signature from another key uses up the challenge
+	if err := svc.VerifyChallenge(ctx, "u1", "d1", ch.Challenge, otherSign(ch.Challenge)); !errors.Is(err, ErrInvalidDeviceSignature) {
+		t.Fatalf("VerifyChallenge with another key = %v, want ErrInvalidDeviceSignature", err)
+	}
+	if err := svc.VerifyChallenge(ctx, "u1", "d1", ch.Challenge, sign(ch.Challenge)); !errors.Is(err, ErrDeviceChallengeExpired) {
+		t.Fatalf("VerifyChallenge reused a challenge: %v", err)
+	}
+
+	ch, _ = svc.Challenge(ctx, "u1", "d1")
+	if err := svc.VerifyChallenge(ctx, "u1", "d1", ch.Challenge, sign(ch.Challenge)); err != nil {
+		t.Fatalf("VerifyChallenge error: %v", err)
+	}
+	if err := svc.VerifyChallenge(ctx, "u1", "d1", ch.Challenge, sign(ch.Challenge)); !errors.Is(err, ErrDeviceChallengeExpired) {
+		t.Fatalf("VerifyChallenge accepted a challenge twice: %v", err)
+	}
+}
+
+func TestRevokeDeviceEndsItsSessions(t *testing.T) {
+	svc, rec := newTestDevices(t)
+	ctx := context.Background()
+	req, sign := newP256Device(t, "d1")
+	svc.Register(ctx, "u1", "ada@example.com", req)
+
+	if err := svc.Revoke(ctx, "u1", "d1"); err != nil {
+		t.Fatalf("Revoke error: %v", err)
+	}
+	if len(rec.ended) != 1 || rec.ended[0] != "u1/d1" {
+		t.Fatalf("expected d1 sessions to end, got %v", rec.ended)
+	}
+	if err := svc.Revoke(ctx, "u1", "d1"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("Revoke twice = %v, want ErrDeviceNotFound", err)
+	}
+	ch, _ := svc.Challenge(ctx, "u1", "d1")
+	if err := svc.VerifyChallenge(ctx, "u1", "d1", ch.Challenge, sign(ch.Challenge)); !errors.Is(err, ErrInvalidDeviceSignature) {
+		t.Fatalf("VerifyChallenge for a revoked device = %v, want ErrInvalidDeviceSignature", err)
+	}
+}
@@ -0,0 +1,392 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+var (
+	// ErrInvalidPasskeyChallenge is returned for an unknown, expired or reused
+	// challenge, or one issued for another ceremony or user
+	ErrInvalidPasskeyChallenge = apperrors.Unauthorized("passkey challenge is invalid or expired").WithCode("invalid_challenge")
+	// ErrInvalidPasskey is returned when an assertion does not verify: unknown
+	// credential, wrong origin or relying party, or a bad signature
+	ErrInvalidPasskey = apperrors.Unauthorized("passkey verification failed").WithCode("invalid_passkey")
+	// ErrPasskeyUserVerification is returned when the authenticator did not
+	// verify the user and WebAuthnConfig.RequireUserVerification is set
+	ErrPasskeyUserVerification = apperrors.Unauthorized("the authenticator must verify the user").WithCode("user_verification_required")
+	// ErrPasskeyCloned is returned when the sign counter did not grow, which
+	// means the credential's private key may have been copied
+	ErrPasskeyCloned = apperrors.Unauthorized("passkey sign counter went backwards; the passkey may have been cloned").WithCode("passkey_cloned")
+	// ErrPasskeyExists is returned when the credential is already registered
+	ErrPasskeyExists = apperrors.Conflict("passkey already registered").WithCode("passkey_exists")
+	// ErrPasskeyNotFound is returned when deleting a passkey the user does not have
+	ErrPasskeyNotFound = apperrors.NotFound("passkey not found")
+)
+
+// passkeyTransports are the AuthenticatorTransport values kept from clients
+var passkeyTransports = map[string]bool{"usb": true, "nfc": true, "ble": true, "smart-card": true, "hybrid": true, "internal": true}
+
+// PasskeySessions starts a session after a passkey login; *SessionService implements it
+type PasskeySessions interface {
+	Issue(ctx context.Context, userID string) (models.TokenPair, error)
+}
+
+// PasskeyService runs the WebAuthn registration and authentication ceremonies.
+// A user may register several passkeys; each keeps its own sign counter so a
+// cloned authenticator is refused once the original has been used.
+type PasskeyService struct {
+	passkeys   repository.PasskeyStore
+	challenges repository.PasskeyChallengeStore
+	users      repository.UserStore
+	sessions   PasskeySessions
+	config     config.WebAuthnConfig
+	origins    []string
+	now        func() time.Time
+}
+
+func NewPasskeyService(passkeys repository.PasskeyStore, challenges repository.PasskeyChallengeStore, users repository.UserStore, sessions PasskeySessions, cfg config.WebAuthnConfig) *PasskeyService {
+	var origins []string
+	for _, origin := range strings.Split(cfg.Origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	return &PasskeyService{passkeys: passkeys, challenges: challenges, users: users, sessions: sessions, config: cfg, origins: origins, now: time.Now}
+}
+
+// RegistrationOptions starts registration of a new passkey for the user. The
+// user's existing passkeys are excluded so an authenticator is not added twice.
+func (s *PasskeyService) RegistrationOptions(ctx context.Context, userID string) (models.PasskeyCreationOptionsResponse, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return models.PasskeyCreationOptionsResponse{}, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user == nil {
+		return models.PasskeyCreationOptionsResponse{}, ErrUserNotFound
+	}
+	existing, err := s.passkeys.ListByUser(ctx, userID)
+	if err != nil {
+		return models.PasskeyCreationOptionsResponse{}, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	challenge, err := s.newChallenge(ctx, models.PasskeyCeremonyRegistration, userID)
+	if err != nil {
+		return models.PasskeyCreationOptionsResponse{}, err
+	}
+
+	name := user.Email
+	if name == "" {
+		name = user.Phone
+	}
+	params := make([]models.PasskeyCredentialParameter, 0, len(passkeyAlgorithms))
+	for _, alg := range passkeyAlgorithms {
+		params = append(params, models.PasskeyCredentialParameter{Type: "public-key", Alg: alg})
+	}
+	return models.PasskeyCreationOptionsResponse{PublicKey: models.PasskeyCreationOptions{
+		RP:                 models.PasskeyRelyingParty{ID: s.config.RPID, Name: s.config.RPName},
+		User:               models.PasskeyUserEntity{ID: base64.RawURLEncoding.EncodeToString([]byte(user.ID)), Name: name, DisplayName: name},
+		Challenge:          challenge,
+		PubKeyCredParams:   params,
+		Timeout:            s.config.ChallengeTTL.Milliseconds(),
+		ExcludeCredentials: descriptors(existing),
+		AuthenticatorSelection: models.PasskeyAuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: s.userVerification(),
+		},
+		Attestation: "none",
+	}}, nil
+}
+
+// Register verifies the authenticator's attestation response and stores the new passkey
+func (s *PasskeyService) Register(ctx context.Context, userID string, req models.PasskeyRegisterRequest) (*models.PasskeyCredential, error) {
+	cred := req.Credential
+	cd, _, err := parseClientData(cred.Response.ClientDataJSON, clientDataCreate)
+	if err != nil {
+		return nil, apperrors.Validation(err.Error())
+	}
+	if err := s.consumeChallenge(ctx, cd.Challenge, models.PasskeyCeremonyRegistration, userID); err != nil {
+		return nil, err
+	}
+	if !s.originAllowed(cd) {
+		return nil, apperrors.Validation("origin is not allowed for passkeys")
+	}
+	ad, err := parseAttestationObject(cred.Response.AttestationObject)
+	if err != nil {
+		return nil, apperrors.Validation(err.Error())
+	}
+	if err := s.checkFlags(ad); err != nil {
+		return nil, err
+	}
+	rawID, err := decodeBase64URL(cred.RawID)
+	if err != nil || !bytes.Equal(rawID, ad.credentialID) {
+		return nil, apperrors.Validation("rawId does not match the attested credential")
+	}
+	_, alg, err := parseCOSEKey(ad.publicKey)
+	if err != nil {
+		return nil, apperrors.Validation(err.Error())
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	var transports []string
+	for _, t := range cred.Response.Transports {
+		if passkeyTransports[t] {
+			transports = append(transports, t)
+		}
+	}
+	now := s.now()
+	passkey := models.PasskeyCredential{
+		ID:         base64.RawURLEncoding.EncodeToString(ad.credentialID),
+		UserID:     userID,
+		Name:       name,
+		PublicKey:  base64.StdEncoding.EncodeToString(ad.publicKey),
+		Algorithm:  alg,
+		SignCount:  ad.signCount,
+		Transports: transports,
+		AAGUID:     formatAAGUID(ad.aaguid),
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	if err := s.passkeys.Create(ctx, passkey); err != nil {
+		if errors.Is(err, repository.ErrPasskeyExists) {
+			return nil, ErrPasskeyExists
+		}
+		return nil, fmt.Errorf("failed to save passkey: %w", err)
+	}
+	return &passkey, nil
+}
+
+// LoginOptions starts a passkey login. With an identifier the user's passkeys
+// are listed; without one, or for an unknown account, the list is empty and
+// the authenticator offers the discoverable passkeys it holds for this site.
+func (s *PasskeyService) LoginOptions(ctx context.Context, identifier string) (models.PasskeyRequestOptionsResponse, error) {
+	var userID string
+	allowed := []models.PasskeyCredentialDescriptor{}
+	if identifier = strings.TrimSpace(identifier); identifier != "" {
+		var user *models.User
+		var err error
+		if strings.HasPrefix(identifier, "+") {
+			user, err = s.users.GetByPhone(ctx, identifier)
+		} else {
+			user, err = s.users.GetByEmail(ctx, normalizeEmail(identifier))
+		}
+		if err != nil {
+			return models.PasskeyRequestOptionsResponse{}, fmt.Errorf("failed to load user: %w", err)
+		}
+		if user != nil {
+			existing, err := s.passkeys.ListByUser(ctx, user.ID)
+			if err != nil {
+				return models.PasskeyRequestOptionsResponse{}, fmt.Errorf("failed to list passkeys: %w", err)
+			}
+			if len(existing) > 0 {
+				userID = user.ID
+				allowed = descriptors(existing)
+			}
+		}
+	}
+	challenge, err := s.newChallenge(ctx, models.PasskeyCeremonyAuthentication, userID)
+	if err != nil {
+		return models.PasskeyRequestOptionsResponse{}, err
+	}
+	return models.PasskeyRequestOptionsResponse{PublicKey: models.PasskeyRequestOptions{
+		RPID:             s.config.RPID,
+		Challenge:        challenge,
+		Timeout:          s.config.ChallengeTTL.Milliseconds(),
+		AllowCredentials: allowed,
+		UserVerification: s.userVerification(),
+	}}, nil
+}
+
+// Login verifies an assertion, advances the passkey's sign counter and starts a session
+func (s *PasskeyService) Login(ctx context.Context, req models.PasskeyLoginRequest) (models.AuthResponse, error) {
+	cred := req.Credential
+	cd, clientDataJSON, err := parseClientData(cred.Response.ClientDataJSON, clientDataGet)
+	if err != nil {
+		return models.AuthResponse{}, apperrors.Validation(err.Error())
+	}
+	stored, err := s.challenges.ConsumeChallenge(ctx, cd.Challenge)
+	if err != nil {
+		return models.AuthResponse{}, fmt.Errorf("failed to check challenge: %w", err)
+	}
+	if stored == nil || stored.Ceremony != models.PasskeyCeremonyAuthentication {
+		return models.AuthResponse{}, ErrInvalidPasskeyChallenge
+	}
+	if !s.originAllowed(cd) {
+		return models.AuthResponse{}, ErrInvalidPasskey
+	}
+
+	rawID, err := decodeBase64URL(cred.RawID)
+	if err != nil {
+		return models.AuthResponse{}, apperrors.Validation("rawId is not base64url")
+	}
+	passkey, err := s.passkeys.Get(ctx, base64.RawURLEncoding.EncodeToString(rawID))
+	if err != nil {
+		return models.AuthResponse{}, fmt.Errorf("failed to load passkey: %w", err)
+	}
+	if passkey == nil || (stored.UserID != "" && stored.UserID != passkey.UserID) {
+		return models.AuthResponse{}, ErrInvalidPasskey
+	}
+	// A discoverable login names the account only through the user handle
+	if cred.Response.UserHandle != "" || stored.UserID == "" {
+		handle, err := decodeBase64URL(cred.Response.UserHandle)
+		if err != nil || string(handle) != passkey.UserID {
+			return models.AuthResponse{}, ErrInvalidPasskey
+		}
+	}
+
+	authData, err := decodeBase64URL(cred.Response.AuthenticatorData)
+	if err != nil {
+		return models.AuthResponse{}, apperrors.Validation("authenticatorData is not base64url")
+	}
+	ad, err := parseAuthenticatorData(authData)
+	if err != nil {
+		return models.AuthResponse{}, apperrors.Validation(err.Error())
+	}
+	if err := s.checkFlags(ad); err != nil {
+		if apperrors.KindOf(err) == apperrors.KindValidation {
+			return models.AuthResponse{}, ErrInvalidPasskey
+		}
+		return models.AuthResponse{}, err
+	}
+	coseKey, err := base64.StdEncoding.DecodeString(passkey.PublicKey)
+	if err != nil {
+		return models.AuthResponse{}, fmt.Errorf("stored passkey is invalid: %w", err)
+	}
+	key, alg, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return models.AuthResponse{}, fmt.Errorf("stored passkey is invalid: %w", err)
+	}
+	sig, err := decodeBase64URL(cred.Response.Signature)
+	if err != nil || !verifyAssertionSignature(key, alg, authData, clientDataJSON, sig) {
+		return models.AuthResponse{}, ErrInvalidPasskey
+	}
+	advanced, err := s.passkeys.UpdateSignCount(ctx, passkey.ID, ad.signCount, s.now())
+	if err != nil {
+		return models.AuthResponse{}, fmt.Errorf("failed to update sign count: %w", err)
+	}
+	if !advanced {
+		return models.AuthResponse{}, ErrPasskeyCloned
+	}
+
+	user, err := s.users.GetByID(ctx, passkey.UserID)
+	if err != nil {
+		return models.AuthResponse{}, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user == nil {
+		return models.AuthResponse{}, ErrInvalidPasskey
+	}
+	pair, err := s.sessions.Issue(ctx, user.ID)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+	return models.AuthResponse{Success: true, Message: "Login successful", TokenPair: pair, User: user.Info()}, nil
+}
+
+// List returns the user's passkeys, oldest first
+func (s *PasskeyService) List(ctx context.Context, userID string) ([]models.PasskeyCredential, error) {
+	passkeys, err := s.passkeys.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	return passkeys, nil
+}
+
+// Delete removes one of the user's passkeys
+func (s *PasskeyService) Delete(ctx context.Context, userID, credentialID string) error {
+	removed, err := s.passkeys.Delete(ctx, userID, credentialID)
+	if err != nil {
+		return fmt.Errorf("failed to remove passkey: %w", err)
+	}
+	if !removed {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
+
+// newChallenge stores a 32-byte random challenge for the ceremony and returns it base64url-encoded
+func (s *PasskeyService) newChallenge(ctx context.Context, ceremony, userID string) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(nonce)
+	err := s.challenges.StoreChallenge(ctx, models.PasskeyChallenge{
+		Challenge: challenge,
+		Ceremony:  ceremony,
+		UserID:    userID,
+		ExpiresAt: s.now().Add(s.config.ChallengeTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// consumeChallenge uses up the challenge and checks it was issued for this ceremony and user
+func (s *PasskeyService) consumeChallenge(ctx context.Context, challenge, ceremony, userID string) error {
+	stored, err := s.challenges.ConsumeChallenge(ctx, challenge)
+	if err != nil {
+		return fmt.Errorf("failed to check challenge: %w", err)
+	}
+	if stored == nil || stored.Ceremony != ceremony || stored.UserID != userID {
+		return ErrInvalidPasskeyChallenge
+	}
+	return nil
+}
+
+// originAllowed reports whether the ceremony ran on one of the configured origins
+// and in a top-level context
+func (s *PasskeyService) originAllowed(cd *clientData) bool {
+	if cd.CrossOrigin {
+		return false
+	}
+	for _, origin := range s.origins {
+		if cd.Origin == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFlags verifies the relying party scope and the presence and verification flags
+func (s *PasskeyService) checkFlags(ad *authenticatorData) error {
+	if !rpIDHashMatches(ad.rpIDHash, s.config.RPID) {
+		return apperrors.Validation("passkey belongs to another relying party")
+	}
+	if ad.flags&authFlagUserPresent == 0 {
+		return apperrors.Validation("the authenticator did not confirm user presence")
+	}
+	if s.config.RequireUserVerification && ad.flags&authFlagUserVerified == 0 {
+		return ErrPasskeyUserVerification
+	}
+	return nil
+}
+
+func (s *PasskeyService) userVerification() string {
+	if s.config.RequireUserVerification {
+		return "required"
+	}
+	return "preferred"
+}
+
+// descriptors lists passkeys for excludeCredentials and allowCredentials
+func descriptors(passkeys []models.PasskeyCredential) []models.PasskeyCredentialDescriptor {
+	list := make([]models.PasskeyCredentialDescriptor, 0, len(passkeys))
+	for _, p := range passkeys {
+		list = append(list, models.PasskeyCredentialDescriptor{Type: "public-key", ID: p.ID, Transports: p.Transports})
+	}
+	return list
+}
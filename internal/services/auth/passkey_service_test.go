@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+const (
+	testRPID   = "vestroll.test"
+	testOrigin = "https://app.vestroll.test"
+)
+
+// cborPair is a map entry for cborEncode; entries are written in the given order
+type cborPair struct {
+	key, value interface{}
+}
+
+// cborEncode writes the CBOR subset authenticators use
+func cborEncode(v interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+		default:
+			b := []byte{major<<5 | 26, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(b[1:], uint32(n))
+			return b
+		}
+	}
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case []cborPair:
+		out := head(5, uint64(len(v)))
+		for _, p := range v {
+			out = append(out, cborEncode(p.key)...)
+			out = append(out, cborEncode(p.value)...)
+		}
+		return out
+	default:
+		panic("cborEncode: unsupported type")
+	}
+}
+
+// softAuthenticator is an in-memory FIDO2 authenticator holding one credential
+type softAuthenticator struct {
+	t            *testing.T
+	credentialID []byte
+	signer       crypto.Signer
+	coseKey      []byte
+	signCount    uint32
+	flags        byte
+	origin       string
+	rpID         string
+}
+
+func newSoftAuthenticator(t *testing.T, alg int) *softAuthenticator {
+	t.Helper()
+	a := &softAuthenticator{t: t, credentialID: make([]byte, 16), flags: authFlagUserPresent | authFlagUserVerified, origin: testOrigin, rpID: testRPID}
+	rand.Read(a.credentialID)
+	switch alg {
+	case coseAlgES256:
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		a.signer = key
+		x, y := make([]byte, 32), make([]byte, 32)
+		key.PublicKey.X.FillBytes(x)
+		key.PublicKey.Y.FillBytes(y)
+		a.coseKey = cborEncode([]cborPair{{1, 2}, {3, coseAlgES256}, {-1, 1}, {-2, x}, {-3, y}})
+	case coseAlgEdDSA:
+		pub, key, _ := ed25519.GenerateKey(rand.Reader)
+		a.signer = key
+		a.coseKey = cborEncode([]cborPair{{1, 1}, {3, coseAlgEdDSA}, {-1, 6}, {-2, []byte(pub)}})
+	}
+	return a
+}
+
+func (a *softAuthenticator) clientData(typ, challenge string) []byte {
+	data, _ := json.Marshal(clientData{Type: typ, Challenge: challenge, Origin: a.origin})
+	return data
+}
+
+func (a *softAuthenticator) authData(attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(a.rpID))
+	data := append([]byte{}, rpHash[:]...)
+	flags := a.flags
+	if attested {
+		flags |= authFlagAttestedCredData
+	}
+	data = append(data, flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...) // zero AAGUID
+		data = append(data, byte(len(a.credentialID)>>8), byte(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey...)
+	}
+	return data
+}
+
+// create answers navigator.credentials.create with "none" attestation
+func (a *softAuthenticator) create(options models.PasskeyCreationOptions) models.PasskeyCredentialJSON {
+	attestation := cborEncode([]cborPair{{"fmt", "none"}, {"attStmt", []cborPair{}}, {"authData", a.authData(true)}})
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	return models.PasskeyCredentialJSON{ID: id, RawID: id, Type: "public-key", Response: models.PasskeyAuthenticatorResponse{
+		ClientDataJSON:    base64.RawURLEncoding.EncodeToString(a.clientData(clientDataCreate, options.Challenge)),
+		AttestationObject: base64.RawURLEncoding.EncodeToString(attestation),
+		Transports:        []string{"internal", "made-up"},
+	}}
+}
+
+// get answers navigator.credentials.get, bumping the counter like a real authenticator
+func (a *softAuthenticator) get(options models.PasskeyRequestOptions, userID string) models.PasskeyCredentialJSON {
+	a.signCount++
+	authData := a.authData(false)
+	clientDataJSON := a.clientData(clientDataGet, options.Challenge)
+	clientHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte{}, authData...), clientHash[:]...)
+	var sig []byte
+	var err error
+	if _, ok := a.signer.(ed25519.PrivateKey); ok {
+		sig, err = a.signer.Sign(rand.Reader, message, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(message)
+		sig, err = a.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		a.t.Fatalf("sign assertion: %v", err)
+	}
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	return models.PasskeyCredentialJSON{ID: id, RawID: id, Type: "public-key", Response: models.PasskeyAuthenticatorResponse{
+		ClientDataJSON:    base64.RawURLEncoding.EncodeToString(clientDataJSON),
+		AuthenticatorData: base64.RawURLEncoding.EncodeToString(authData),
+		Signature:         base64.RawURLEncoding.EncodeToString(sig),
+		UserHandle:        base64.RawURLEncoding.EncodeToString([]byte(userID)),
+	}}
+}
+
+// staticSessions issues a fixed token pair and records who logged in
+type staticSessions struct {
+	issued []string
+}
+
+func (s *staticSessions) Issue(ctx context.Context, userID string) (models.TokenPair, error) {
+	s.issued = append(s.issued, userID)
+	return models.TokenPair{AccessToken: "access-" + userID}, nil
+}
+
+func newTestPasskeys(t *testing.T) (*PasskeyService, *staticSessions) {
+	t.Helper()
+	users := memory.NewUserRepository()
+	ctx := context.Background()
+	for _, u := range []models.User{{ID: "u1", Email: "ada@example.com"}, {ID: "u2", Email: "bob@example.com", Phone: "+2348012345678"}} {
+		if err := users.Create(ctx, u); err != nil {
+			t.Fatalf("Create user: %v", err)
+		}
+	}
+	sessions := &staticSessions{}
+	cfg := config.WebAuthnConfig{RPID: testRPID, RPName: "VestRoll", Origins: testOrigin + ", https://other.vestroll.test", ChallengeTTL: time.Minute, RequireUserVerification: true}
+	return NewPasskeyService(memory.NewPasskeyRepository(), memory.NewPasskeyChallenges(nil), users, sessions, cfg), sessions
+}
+
+// register runs the whole registration ceremony for the authenticator
+func register(t *testing.T, svc *PasskeyService, userID string, a *softAuthenticator) *models.PasskeyCredential {
+	t.Helper()
+	ctx := context.Background()
+	options, err := svc.RegistrationOptions(ctx, userID)
+	if err != nil {
+		t.Fatalf("RegistrationOptions error: %v", err)
+	}
+	passkey, err := svc.Register(ctx, userID, models.PasskeyRegisterRequest{Name: "Laptop", Credential: a.create(options.PublicKey)})
+	if err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	return passkey
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	for _, alg := range []int{coseAlgES256, coseAlgEdDSA} {
+		svc, sessions := newTestPasskeys(t)
+		ctx := context.Background()
+		a := newSoftAuthenticator(t, alg)
+
+		passkey := register(t, svc, "u1", a)
+		if passkey.Algorithm != alg || passkey.Name != "Laptop" || len(passkey.Transports) != 1 || passkey.Transports[0] != "internal" ||
+			passkey.AAGUID != "00000000-0000-0000-0000-000000000000" {
+			t.Fatalf("alg %d: registered passkey = %+v", alg, passkey)
+		}
+
+		options, err := svc.LoginOptions(ctx, "ADA@example.com")
+		if err != nil || len(options.PublicKey.AllowCredentials) != 1 || options.PublicKey.AllowCredentials[0].ID != passkey.ID ||
+			options.PublicKey.UserVerification != "required" || options.PublicKey.RPID != testRPID {
+			t.Fatalf("alg %d: LoginOptions = %+v, %v", alg, options, err)
+		}
+		resp, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: a.get(options.PublicKey, "u1")})
+		if err != nil {
+			t.Fatalf("alg %d: Login error: %v", alg, err)
+		}
+		if resp.User.ID != "u1" || resp.AccessToken != "access-u1" || len(sessions.issued) != 1 {
+			t.Fatalf("alg %d: Login = %+v", alg, resp)
+		}
+		if stored, _ := svc.passkeys.Get(ctx, passkey.ID); stored.SignCount != 1 {
+			t.Fatalf("alg %d: sign count = %d; want 1", alg, stored.SignCount)
+		}
+	}
+}
+
+func TestPasskeyRegistrationChecks(t *testing.T) {
+	svc, _ := newTestPasskeys(t)
+	ctx := context.Background()
+	a := newSoftAuthenticator(t, coseAlgES256)
+
+	options, _ := svc.RegistrationOptions(ctx, "u1")
+	if options.PublicKey.User.ID != base64.RawURLEncoding.EncodeToString([]byte("u1")) || options.PublicKey.Attestation != "none" || len(options.PublicKey.ExcludeCredentials) != 0 {
+		t.Fatalf("RegistrationOptions = %+v", options)
+	}
+	// A challenge issued to one user cannot register a passkey for another
+	if _, err := svc.Register(ctx, "u2", models.PasskeyRegisterRequest{Credential: a.create(options.PublicKey)}); !errors.Is(err, ErrInvalidPasskeyChallenge) {
+		t.Fatalf("Register for another user = %v, want ErrInvalidPasskeyChallenge", err)
+	}
+
+	cases := map[string]func(*softAuthenticator){
+		"wrong origin":          func(a *softAuthenticator) { a.origin = "https://evil.test" },
+		"wrong relying party":   func(a *softAuthenticator) { a.rpID = "evil.test" },
+		"no user verification":  func(a *softAuthenticator) { a.flags = authFlagUserPresent },
+		"unsupported algorithm": func(a *softAuthenticator) { a.coseKey = cborEncode([]cborPair{{1, 2}, {3, -36}}) },
+	}
+	for name, tamper := range cases {
+		bad := newSoftAuthenticator(t, coseAlgES256)
+		tamper(bad)
+		options, _ := svc.RegistrationOptions(ctx, "u1")
+		_, err := svc.Register(ctx, "u1", models.PasskeyRegisterRequest{Credential: bad.create(options.PublicKey)})
+		if kind := apperrors.KindOf(err); kind != apperrors.KindValidation && !errors.Is(err, ErrPasskeyUserVerification) {
+			t.Fatalf("%s: Register = %v, want a rejection", name, err)
+		}
+	}
+
+	first := register(t, svc, "u1", a)
+	options, _ = svc.RegistrationOptions(ctx, "u1")
+	if len(options.PublicKey.ExcludeCredentials) != 1 || options.PublicKey.ExcludeCredentials[0].ID != first.ID {
+		t.Fatalf("ExcludeCredentials = %+v; want the registered passkey", options.PublicKey.ExcludeCredentials)
+	}
+	if _, err := svc.Register(ctx, "u1", models.PasskeyRegisterRequest{Credential: a.create(options.PublicKey)}); !errors.Is(err, ErrPasskeyExists) {
+		t.Fatalf("Register twice = %v, want ErrPasskeyExists", err)
+	}
+}
+
+func TestPasskeyLoginRejectsReplayAndTampering(t *testing.T) {
+	svc, sessions := newTestPasskeys(t)
+	ctx := context.Background()
+	a := newSoftAuthenticator(t, coseAlgES256)
+	register(t, svc, "u1", a)
+
+	options, _ := svc.LoginOptions(ctx, "ada@example.com")
+	assertion := a.get(options.PublicKey, "u1")
+	if _, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: assertion}); err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	if _, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: assertion}); !errors.Is(err, ErrInvalidPasskeyChallenge) {
+		t.Fatalf("replayed Login = %v, want ErrInvalidPasskeyChallenge", err)
+	}
+
+	options, _ = svc.LoginOptions(ctx, "ada@example.com")
+	forged := a.get(options.PublicKey, "u1")
+	forged.Response.Signature = newSoftAuthenticator(t, coseAlgES256).get(options.PublicKey, "u1").Response.Signature
+	if _, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: forged}); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("Login with another key's signature = %v, want ErrInvalidPasskey", err)
+	}
+
+	a.origin = "https://evil.test"
+	options, _ = svc.LoginOptions(ctx, "ada@example.com")
+	if _, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: a.get(options.PublicKey, "u1")}); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("Login from another origin = %v, want ErrInvalidPasskey", err)
+	}
+	a.origin = testOrigin
+
+	// A passkey offered for one account cannot sign in to another
+	b := newSoftAuthenticator(t, coseAlgEdDSA)
+	register(t, svc, "u2", b)
+	options, _ = svc.LoginOptions(ctx, "ada@example.com")
+	if _, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: b.get(options.PublicKey, "u2")}); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("Login with another account's passkey = %v, want ErrInvalidPasskey", err)
+	}
+	if len(sessions.issued) != 1 {
+		t.Fatalf("sessions issued = %v; want only the first login", sessions.issued)
+	}
+}
+
+func TestPasskeyLoginDetectsClonedAuthenticator(t *testing.T) {
+	svc, _ := newTestPasskeys(t)
+	ctx := context.Background()
+	a := newSoftAuthenticator(t, coseAlgES256)
+	register(t, svc, "u1", a)
+	clone := *a
+
+	options, _ := svc.LoginOptions(ctx, "")
+	if _, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: a.get(options.PublicKey, "u1")}); err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	options, _ = svc.LoginOptions(ctx, "")
+	if _, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: clone.get(options.PublicKey, "u1")}); !errors.Is(err, ErrPasskeyCloned) {
+		t.Fatalf("Login from a clone = %v, want ErrPasskeyCloned", err)
+	}
+}
+
+func TestPasskeyDiscoverableLoginAndMultipleCredentials(t *testing.T) {
+	svc, sessions := newTestPasskeys(t)
+	ctx := context.Background()
+	laptop := newSoftAuthenticator(t, coseAlgES256)
+	phone := newSoftAuthenticator(t, coseAlgEdDSA)
+	first := register(t, svc, "u2", laptop)
+	second := register(t, svc, "u2", phone)
+
+	options, _ := svc.LoginOptions(ctx, "+2348012345678")
+	if len(options.PublicKey.AllowCredentials) != 2 {
+		t.Fatalf("AllowCredentials = %+v; want both passkeys", options.PublicKey.AllowCredentials)
+	}
+	for _, identifier := range []string{"", "nobody@example.com"} {
+		options, _ := svc.LoginOptions(ctx, identifier)
+		if len(options.PublicKey.AllowCredentials) != 0 {
+			t.Fatalf("LoginOptions(%q) listed passkeys: %+v", identifier, options.PublicKey.AllowCredentials)
+		}
+		if _, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: phone.get(options.PublicKey, "u2")}); err != nil {
+			t.Fatalf("discoverable Login error: %v", err)
+		}
+	}
+	// Without a user handle a discoverable login cannot name the account
+	options, _ = svc.LoginOptions(ctx, "")
+	anonymous := laptop.get(options.PublicKey, "u2")
+	anonymous.Response.UserHandle = ""
+	if _, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: anonymous}); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("Login without a user handle = %v, want ErrInvalidPasskey", err)
+	}
+
+	if err := svc.Delete(ctx, "u1", first.ID); !errors.Is(err, ErrPasskeyNotFound) {
+		t.Fatalf("Delete another user's passkey = %v, want ErrPasskeyNotFound", err)
+	}
+	if err := svc.Delete(ctx, "u2", first.ID); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	list, _ := svc.List(ctx, "u2")
+	if len(list) != 1 || list[0].ID != second.ID {
+		t.Fatalf("List after Delete = %+v; want the phone passkey", list)
+	}
+	options, _ = svc.LoginOptions(ctx, "")
+	if _, err := svc.Login(ctx, models.PasskeyLoginRequest{Credential: laptop.get(options.PublicKey, "u2")}); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("Login with a deleted passkey = %v, want ErrInvalidPasskey", err)
+	}
+	if len(sessions.issued) != 2 {
+		t.Fatalf("sessions issued = %v; want two", sessions.issued)
+	}
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = apperrors.Unauthorized("invalid or expired refresh token").WithCode("invalid_token")
+	// ErrRefreshTokenReused is returned when an already-rotated refresh token is presented;
+	// the whole session family is revoked when this happens
+	ErrRefreshTokenReused = apperrors.Unauthorized("refresh token reuse detected; session revoked").WithCode("token_reused")
+	// ErrDeviceMismatch is returned when a device-bound refresh token is presented
+	// without its device; the session is revoked because the token has leaked
+	ErrDeviceMismatch = apperrors.Unauthorized("refresh token used from another device; session revoked").WithCode("device_mismatch")
+)
+
+// DeviceVerifier checks that a registered device signed a challenge;
+// *DeviceService implements it
+type DeviceVerifier interface {
+	VerifyChallenge(ctx context.Context, userID, deviceID, challenge, signature string) error
+}
+
+// SessionService issues access/refresh token pairs and manages server-side sessions.
+// Every login starts a token family; each refresh rotates the refresh token within
+// the family, and access tokens carry the family ID so revoking it ends the session.
+type SessionService struct {
+	tokens     *TokenService
+	repo       *repository.RefreshTokenRepository
+	devices    DeviceVerifier
+	refreshTTL time.Duration
+}
+
+func NewSessionService(tokens *TokenService, repo *repository.RefreshTokenRepository, refreshTTL time.Duration) *SessionService {
+	return &SessionService{tokens: tokens, repo: repo, refreshTTL: refreshTTL}
+}
+
+// WithDevices sets the verifier device-bound sessions must pass to refresh;
+// until it is set those sessions cannot be refreshed
+func (s *SessionService) WithDevices(devices DeviceVerifier) *SessionService {
+	s.devices = devices
+	return s
+}
+
+// Issue starts a new session for the user
+func (s *SessionService) Issue(ctx context.Context, userID string) (models.TokenPair, error) {
+	return s.IssueForDevice(ctx, userID, "")
+}
+
+// IssueForDevice starts a session bound to deviceID: its access tokens carry
+// the device and its refresh tokens only rotate when the same device presents them
+func (s *SessionService) IssueForDevice(ctx context.Context, userID, deviceID string) (models.TokenPair, error) {
+	familyID := uuid.NewString()
+	if err := s.repo.CreateFamily(ctx, userID, familyID, deviceID, s.refreshTTL); err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	return s.issuePair(ctx, userID, familyID, deviceID)
+}
+
+// Refresh rotates a refresh token, returning a new pair in the same session.
+// A device-bound token also needs the device's signature over a fresh challenge.
+// Presenting a token that was already rotated, or a device-bound token from
+// another device or with a signature its key did not make, revokes the session entirely.
+func (s *SessionService) Refresh(ctx context.Context, req models.RefreshRequest) (models.TokenPair, error) {
+	hash := hashToken(req.RefreshToken)
+	// Check the token and its device before using the token up, so a client
+	// whose device check fails for a passing reason can retry with the same token
+	record, used, err := s.repo.GetToken(ctx, hash)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to load refresh token: %w", err)
+	}
+	if record == nil {
+		return models.TokenPair{}, ErrInvalidRefreshToken
+	}
+	if used {
+		return models.TokenPair{}, s.revoke(ctx, record, ErrRefreshTokenReused)
+	}
+	if record.DeviceID != "" && record.DeviceID != req.DeviceID {
+		return models.TokenPair{}, s.revoke(ctx, record, ErrDeviceMismatch)
+	}
+	if record.DeviceID != "" {
+		if err := s.verifyDevice(ctx, record, req); err != nil {
+			return models.TokenPair{}, err
+		}
+	}
+
+	record, firstUse, err := s.repo.ConsumeToken(ctx, hash)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to load refresh token: %w", err)
+	}
+	if record == nil {
+		return models.TokenPair{}, ErrInvalidRefreshToken
+	}
+	// Another request rotated the token since it was loaded above
+	if !firstUse {
+		return models.TokenPair{}, s.revoke(ctx, record, ErrRefreshTokenReused)
+	}
+	active, err := s.repo.FamilyActive(ctx, record.FamilyID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to check session: %w", err)
+	}
+	if !active {
+		return models.TokenPair{}, ErrInvalidRefreshToken
+	}
+	if err := s.repo.TouchFamily(ctx, record.UserID, record.FamilyID, record.DeviceID, s.refreshTTL); err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to extend session: %w", err)
+	}
+	return s.issuePair(ctx, record.UserID, record.FamilyID, record.DeviceID)
+}
+
+// verifyDevice checks the bound device is still registered and signed the
+// challenge. Only a signature the device key did not make revokes the session;
+// an expired challenge or a failed lookup can be retried.
+func (s *SessionService) verifyDevice(ctx context.Context, record *models.RefreshTokenRecord, req models.RefreshRequest) error {
+	if s.devices == nil {
+		return ErrUnknownDevice
+	}
+	err := s.devices.VerifyChallenge(ctx, record.UserID, record.DeviceID, req.Challenge, req.Signature)
+	if errors.Is(err, ErrInvalidDeviceSignature) {
+		return s.revoke(ctx, record, err)
+	}
+	return err
+}
+
+// revoke ends the record's session and returns cause
+func (s *SessionService) revoke(ctx context.Context, record *models.RefreshTokenRecord, cause error) error {
+	if err := s.repo.RevokeFamily(ctx, record.UserID, record.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return cause
+}
+
+// Logout ends the session the refresh token belongs to
+func (s *SessionService) Logout(ctx context.Context, refreshToken string) error {
+	record, _, err := s.repo.ConsumeToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return fmt.Errorf("failed to load refresh token: %w", err)
+	}
+	if record == nil {
+		return ErrInvalidRefreshToken
+	}
+	return s.repo.RevokeFamily(ctx, record.UserID, record.FamilyID)
+}
+
+// LogoutDevice ends every session bound to the user's device
+func (s *SessionService) LogoutDevice(ctx context.Context, userID, deviceID string) error {
+	return s.repo.RevokeDevice(ctx, userID, deviceID)
+}
+
+// LogoutAll ends every session of the user
+func (s *SessionService) LogoutAll(ctx context.Context, userID string) error {
+	return s.repo.RevokeAll(ctx, userID)
+}
+
+// ValidateAccessToken parses an access token and checks its session is still active
+func (s *SessionService) ValidateAccessToken(ctx context.Context, token string) (*Claims, error) {
+	claims, err := s.tokens.ParseAccessToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+	active, err := s.repo.FamilyActive(ctx, claims.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check session: %w", err)
+	}
+	if !active {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *SessionService) issuePair(ctx context.Context, userID, familyID, deviceID string) (models.TokenPair, error) {
+	access, err := s.tokens.IssueDeviceAccessToken(userID, familyID, deviceID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to issue token: %w", err)
+	}
+	refresh, err := generateRefreshToken()
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	record := models.RefreshTokenRecord{
+		UserID:    userID,
+		FamilyID:  familyID,
+		DeviceID:  deviceID,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+	if err := s.repo.StoreToken(ctx, hashToken(refresh), record); err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return models.TokenPair{
+		AccessToken:      access,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(s.tokens.AccessTTL().Seconds()),
+		RefreshToken:     refresh,
+		RefreshExpiresIn: int64(s.refreshTTL.Seconds()),
+	}, nil
+}
+
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken keys refresh tokens by digest so a Redis dump does not leak usable tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	redis "github.com/go-redis/redis/v8"
+)
+
+func setupSessionService(t *testing.T) *SessionService {
+	mini, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mini.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: mini.Addr()})
+	tokens := NewTokenService(config.JWTConfig{Secret: "test-secret", TTL: time.Hour})
+	return NewSessionService(tokens, repository.NewRefreshTokenRepository(rdb), 24*time.Hour)
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	svc := setupSessionService(t)
+	ctx := context.Background()
+
+	first, err := svc.Issue(ctx, "u1")
+	if err != nil {
+		t.Fatalf("Issue error: %v", err)
+	}
+	second, err := svc.Refresh(ctx, models.RefreshRequest{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("Refresh error: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatalf("expected refresh token to rotate")
+	}
+	if _, err := svc.ValidateAccessToken(ctx, second.AccessToken); err != nil {
+		t.Fatalf("expected rotated access token to be valid, got %v", err)
+	}
+
+	// Replaying the rotated token revokes the whole family
+	if _, err := svc.Refresh(ctx, models.RefreshRequest{RefreshToken: first.RefreshToken}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, models.RefreshRequest{RefreshToken: second.RefreshToken}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("expected family to be revoked, got %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(ctx, second.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected access token of revoked session to be rejected, got %v", err)
+	}
+}
+
+func TestLogoutAndLogoutAll(t *testing.T) {
+	svc := setupSessionService(t)
+	ctx := context.Background()
+
+	a, _ := svc.Issue(ctx, "u1")
+	b, _ := svc.Issue(ctx, "u1")
+	c, _ := svc.Issue(ctx, "u1")
+
+	if err := svc.Logout(ctx, a.RefreshToken); err != nil {
+		t.Fatalf("Logout error: %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(ctx, a.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected logged out session to be rejected, got %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(ctx, b.AccessToken); err != nil {
+		t.Fatalf("expected other session to stay valid, got %v", err)
+	}
+
+	if err := svc.LogoutAll(ctx, "u1"); err != nil {
+		t.Fatalf("LogoutAll error: %v", err)
+	}
+	for _, pair := range [][2]string{{b.AccessToken, b.RefreshToken}, {c.AccessToken, c.RefreshToken}} {
+		if _, err := svc.ValidateAccessToken(ctx, pair[0]); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected access token to be revoked, got %v", err)
+		}
+		if _, err := svc.Refresh(ctx, models.RefreshRequest{RefreshToken: pair[1]}); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Fatalf("expected refresh token to be revoked, got %v", err)
+		}
+	}
+}
+
+// setupDeviceSessions returns a session service whose device-bound sessions
+// are checked against phone-1, registered for u1, and its signer
+func setupDeviceSessions(t *testing.T) (*SessionService, *DeviceService, func(challenge string) string) {
+	t.Helper()
+	devices, _ := newTestDevices(t)
+	req, sign := newP256Device(t, "phone-1")
+	if _, err := devices.Register(context.Background(), "u1", "ada@example.com", req); err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	return setupSessionService(t).WithDevices(devices), devices, sign
+}
+
+func TestDeviceBoundSessionRefreshesOnlyFromItsDevice(t *testing.T) {
+	svc, devices, sign := setupDeviceSessions(t)
+	ctx := context.Background()
+
+	first, err := svc.IssueForDevice(ctx, "u1", "phone-1")
+	if err != nil {
+		t.Fatalf("IssueForDevice error: %v", err)
+	}
+	claims, err := svc.ValidateAccessToken(ctx, first.AccessToken)
+	if err != nil || claims.DeviceID != "phone-1" {
+		t.Fatalf("expected access token bound to phone-1, got %+v, %v", claims, err)
+	}
+	ch, _ := devices.Challenge(ctx, "u1", "phone-1")
+	second, err := svc.Refresh(ctx, models.RefreshRequest{RefreshToken: first.RefreshToken, DeviceID: "phone-1", Challenge: ch.Challenge, Signature: sign(ch.Challenge)})
+	if err != nil {
+		t.Fatalf("Refresh from the bound device error: %v", err)
+	}
+
+	// A leaked token presented elsewhere ends the session
+	if _, err := svc.Refresh(ctx, models.RefreshRequest{RefreshToken: second.RefreshToken, DeviceID: "laptop"}); !errors.Is(err, ErrDeviceMismatch) {
+		t.Fatalf("expected ErrDeviceMismatch, got %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(ctx, second.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected session to be revoked, got %v", err)
+	}
+}
+
+func TestDeviceBoundRefreshNeedsTheDeviceKey(t *testing.T) {
+	svc, devices, sign := setupDeviceSessions(t)
+	ctx := context.Background()
+	_, otherSign := newP256Device(t, "other")
+
+	// The device ID is in every access token, so naming it is not enough, but a
+	// client that has no challenge yet keeps its session and can retry
+	pair, _ := svc.IssueForDevice(ctx, "u1", "phone-1")
+	refresh := models.RefreshRequest{RefreshToken: pair.RefreshToken, DeviceID: "phone-1"}
+	if _, err := svc.Refresh(ctx, refresh); !errors.Is(err, ErrDeviceChallengeExpired) {
+		t.Fatalf("Refresh without a challenge = %v, want ErrDeviceChallengeExpired", err)
+	}
+	ch, _ := devices.Challenge(ctx, "u1", "phone-1")
+	refresh.Challenge, refresh.Signature = ch.Challenge, sign(ch.Challenge)
+	if _, err := svc.Refresh(ctx, refresh); err != nil {
+		t.Fatalf("Refresh retried with a challenge error: %v", err)
+	}
+
+	// A signature from another key ends the session
+	pair, _ = svc.IssueForDevice(ctx, "u1", "phone-1")
+	ch, _ = devices.Challenge(ctx, "u1", "phone-1")
+	if _, err := svc.Refresh(ctx, models.RefreshRequest{RefreshToken: pair.RefreshToken, DeviceID: "phone-1", Challenge: ch.Challenge, Signature: otherSign(ch.Challenge)}); !errors.Is(err, ErrInvalidDeviceSignature) {
+		t.Fatalf("Refresh with another key = %v, want ErrInvalidDeviceSignature", err)
+	}
+	if _, err := svc.ValidateAccessToken(ctx, pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected session to be revoked, got %v", err)
+	}
+
+	// A removed device cannot keep its session going
+	pair, _ = svc.IssueForDevice(ctx, "u1", "phone-1")
+	ch, _ = devices.Challenge(ctx, "u1", "phone-1")
+	if err := devices.Revoke(ctx, "u1", "phone-1"); err != nil {
+		t.Fatalf("Revoke error: %v", err)
+	}
+	if _, err := svc.Refresh(ctx, models.RefreshRequest{RefreshToken: pair.RefreshToken, DeviceID: "phone-1", Challenge: ch.Challenge, Signature: sign(ch.Challenge)}); !errors.Is(err, ErrInvalidDeviceSignature) {
+		t.Fatalf("Refresh from a removed device = %v, want ErrInvalidDeviceSignature", err)
+	}
+	if _, err := svc.ValidateAccessToken(ctx, pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected session to be revoked, got %v", err)
+	}
+}
+
+// failingDevices fails device checks as a store outage would
+type failingDevices struct{}
+
+func (failingDevices) VerifyChallenge(ctx context.Context, userID, deviceID, challenge, signature string) error {
+	return errors.New("connection refused")
+}
+
+func TestDeviceCheckFailureLeavesRefreshTokenUsable(t *testing.T) {
+	svc, devices, sign := setupDeviceSessions(t)
+	ctx := context.Background()
+
+	pair, _ := svc.IssueForDevice(ctx, "u1", "phone-1")
+	refresh := models.RefreshRequest{RefreshToken: pair.RefreshToken, DeviceID: "phone-1", Challenge: "c", Signature: "s"}
+	svc.WithDevices(failingDevices{})
+	if _, err := svc.Refresh(ctx, refresh); err == nil {
+		t.Fatalf("expected the device check error")
+	}
+
+	// The retry is not mistaken for reuse
+	svc.WithDevices(devices)
+	ch, _ := devices.Challenge(ctx, "u1", "phone-1")
+	refresh.Challenge, refresh.Signature = ch.Challenge, sign(ch.Challenge)
+	if _, err := svc.Refresh(ctx, refresh); err != nil {
+		t.Fatalf("Refresh retry error: %v", err)
+	}
+}
+
+func TestLogoutDeviceEndsOnlyThatDevicesSessions(t *testing.T) {
+	svc := setupSessionService(t)
+	ctx := context.Background()
+
+	phone, _ := svc.IssueForDevice(ctx, "u1", "phone-1")
+	tablet, _ := svc.IssueForDevice(ctx, "u1", "tablet-1")
+	if err := svc.LogoutDevice(ctx, "u1", "phone-1"); err != nil {
+		t.Fatalf("LogoutDevice error: %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(ctx, phone.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected the device's session to end, got %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(ctx, tablet.AccessToken); err != nil {
+		t.Fatalf("expected another device's session to stay valid, got %v", err)
+	}
+}
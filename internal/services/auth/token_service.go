@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// TokenTypeAccess marks tokens that authenticate API requests
+	TokenTypeAccess = "access"
+	// TokenTypeVerification marks short-lived proofs that an OTP was verified
+	TokenTypeVerification = "verification"
+)
+
+// Verification purposes carried by verification tokens
+const (
+	PurposePhoneVerified = "phone_verified"
+	PurposeEmailVerified = "email_verified"
+	// PurposePINReset proves a pin_reset OTP was verified for the phone or email
+	PurposePINReset = "pin_reset_confirmed"
+	// PurposeNewDevice proves a new_device OTP was verified for the phone or email
+	PurposeNewDevice = "new_device_confirmed"
+	// PurposeStepUp proves the user just passed their second factor
+	PurposeStepUp = "step_up"
+	// PurposeElevated proves the user just re-entered their PIN
+	PurposeElevated = "elevated"
+)
+
+// VerificationPurpose returns the purpose proven by verifying an OTP.
+// Contact verification and sign-up codes prove control of the phone or email;
+// codes for other flows only prove that flow, e.g. "transaction_confirmed",
+// so they can never stand in for each other.
+func VerificationPurpose(otpPurpose models.OTPPurpose, otpType models.OTPType) string {
+	switch otpPurpose.PurposeOrDefault() {
+	case models.OTPPurposeVerification, models.OTPPurposeSignup:
+		if otpType == models.OTPTypeSMS {
+			return PurposePhoneVerified
+		}
+		return PurposeEmailVerified
+	default:
+		return string(otpPurpose) + "_confirmed"
+	}
+}
+
+// ErrInvalidToken is returned when a token is malformed, expired or has a bad signature
+var ErrInvalidToken = apperrors.Unauthorized("invalid or expired token")
+
+// Claims are the JWT claims issued by VestRoll
+// Subject holds the user ID (or the verified identifier for verification tokens);
+// Type distinguishes access tokens from other token kinds;
+// SessionID ties an access token to the refresh token family that issued it;
+// DeviceID names the device a device-bound session was started on;
+// Purpose scopes a verification token to what it proves
+type Claims struct {
+	Type      string `json:"typ"`
+	SessionID string `json:"sid,omitempty"`
+	DeviceID  string `json:"did,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenService signs and validates HS256 JWTs using JWTConfig.Secret
+type TokenService struct {
+	secret          []byte
+	ttl             time.Duration
+	verificationTTL time.Duration
+	elevatedTTL     time.Duration
+	usedTokens      repository.UsedTokenStore
+}
+
+func NewTokenService(cfg config.JWTConfig) *TokenService {
+	return &TokenService{secret: []byte(cfg.Secret), ttl: cfg.TTL, verificationTTL: cfg.VerificationTTL, elevatedTTL: cfg.ElevatedTTL}
+}
+
+// WithUsedTokens sets the store ConsumeVerificationToken records redeemed tokens in
+func (s *TokenService) WithUsedTokens(store repository.UsedTokenStore) *TokenService {
+	s.usedTokens = store
+	return s
+}
+
+// AccessTTL is the lifetime of issued access tokens
+func (s *TokenService) AccessTTL() time.Duration {
+	return s.ttl
+}
+
+// IssueAccessToken signs an access token for the user's session
+func (s *TokenService) IssueAccessToken(userID, sessionID string) (string, error) {
+	return s.IssueDeviceAccessToken(userID, sessionID, "")
+}
+
+// IssueDeviceAccessToken signs an access token for a session bound to deviceID
+func (s *TokenService) IssueDeviceAccessToken(userID, sessionID, deviceID string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Type:      TokenTypeAccess,
+		SessionID: sessionID,
+		DeviceID:  deviceID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
+
+// ParseAccessToken validates an access token and returns its claims
+func (s *TokenService) ParseAccessToken(token string) (*Claims, error) {
+	claims, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenTypeAccess || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// VerificationTTL is the lifetime of issued verification tokens
+func (s *TokenService) VerificationTTL() time.Duration {
+	return s.verificationTTL
+}
+
+// IssueVerificationToken signs a token proving the identifier was verified for the purpose
+func (s *TokenService) IssueVerificationToken(purpose, identifier string) (string, error) {
+	return s.issueVerification(purpose, identifier, s.verificationTTL)
+}
+
+// ElevatedTTL is the lifetime of tokens returned by verify-pin
+func (s *TokenService) ElevatedTTL() time.Duration {
+	return s.elevatedTTL
+}
+
+// IssueElevatedToken signs a short-lived token proving the user just re-entered their PIN
+func (s *TokenService) IssueElevatedToken(userID string) (string, error) {
+	return s.issueVerification(PurposeElevated, userID, s.elevatedTTL)
+}
+
+func (s *TokenService) issueVerification(purpose, identifier string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Type:    TokenTypeVerification,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   identifier,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
+
+// ParseVerificationToken validates a verification token; a non-empty purpose must match
+func (s *TokenService) ParseVerificationToken(token, purpose string) (*Claims, error) {
+	claims, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != TokenTypeVerification || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if purpose != "" && claims.Purpose != purpose {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ConsumeVerificationToken validates the token like ParseVerificationToken and
+// redeems it, so one verified OTP authorizes exactly one action
+func (s *TokenService) ConsumeVerificationToken(ctx context.Context, token, purpose string) (*Claims, error) {
+	if s.usedTokens == nil {
+		return nil, errors.New("verification tokens cannot be redeemed without a used-token store")
+	}
+	claims, err := s.ParseVerificationToken(token, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	first, err := s.usedTokens.MarkUsed(ctx, claims.ID, time.Until(claims.ExpiresAt.Time))
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem verification token: %w", err)
+	}
+	if !first {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ValidateAccessToken validates the token without consulting session state
+func (s *TokenService) ValidateAccessToken(ctx context.Context, token string) (*Claims, error) {
+	return s.ParseAccessToken(token)
+}
+
+func (s *TokenService) parse(token string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	"github.com/skip2/go-qrcode"
+)
+
+var (
+	// ErrTOTPNotEnabled is returned when the user has no confirmed authenticator
+	ErrTOTPNotEnabled = apperrors.NotFound("two-factor authentication is not enabled").WithCode("totp_not_enabled")
+	// ErrTOTPNotEnrolled is returned when confirming without starting enrollment
+	ErrTOTPNotEnrolled = apperrors.NotFound("start two-factor enrollment first").WithCode("totp_not_enrolled")
+	// ErrTOTPAlreadyEnabled is returned when enrolling while an authenticator is confirmed
+	ErrTOTPAlreadyEnabled = apperrors.Conflict("two-factor authentication is already enabled").WithCode("totp_already_enabled")
+	// ErrInvalidTOTP is returned for a wrong, expired or already used code
+	ErrInvalidTOTP = apperrors.Validation("invalid authentication code").WithCode("invalid_totp")
+)
+
+// TOTPService manages authenticator-app (RFC 6238) second factors.
+// Wrong codes are counted per user in the OTP store's rate limit counters,
+// like OTP sends, so guesses are capped across replicas.
+type TOTPService struct {
+	store    repository.TOTPStore
+	attempts repository.OTPStore
+	users    repository.UserStore
+	tokens   *TokenService
+	sealer   *sealer
+	config   config.TOTPConfig
+	now      func() time.Time
+}
+
+func NewTOTPService(store repository.TOTPStore, attempts repository.OTPStore, users repository.UserStore, tokens *TokenService, cfg config.TOTPConfig) (*TOTPService, error) {
+	sealer, err := newSealer(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPService{store: store, attempts: attempts, users: users, tokens: tokens, sealer: sealer, config: cfg, now: time.Now}, nil
+}
+
+// Enroll creates a new secret for the user, replacing any unconfirmed one.
+// The secret only takes effect once Confirm accepts a code generated from it.
+func (s *TOTPService) Enroll(ctx context.Context, userID string) (models.TOTPEnrollResponse, error) {
+	existing, err := s.store.Get(ctx, userID)
+	if err != nil {
+		return models.TOTPEnrollResponse{}, fmt.Errorf("failed to load TOTP enrollment: %w", err)
+	}
+	if existing != nil && existing.Confirmed {
+		return models.TOTPEnrollResponse{}, ErrTOTPAlreadyEnabled
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return models.TOTPEnrollResponse{}, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user == nil {
+		return models.TOTPEnrollResponse{}, ErrUserNotFound
+	}
+
+	secret := make([]byte, totpSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return models.TOTPEnrollResponse{}, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	sealed, err := s.sealer.seal(secret)
+	if err != nil {
+		return models.TOTPEnrollResponse{}, fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+	enrollment := models.TOTPEnrollment{UserID: userID, Secret: sealed, CreatedAt: s.now()}
+	if err := s.store.Save(ctx, enrollment); err != nil {
+		return models.TOTPEnrollResponse{}, fmt.Errorf("failed to save TOTP enrollment: %w", err)
+	}
+
+	encoded := secretEncoding.EncodeToString(secret)
+	uri := s.otpauthURI(accountName(user), encoded)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return models.TOTPEnrollResponse{}, fmt.Errorf("failed to render QR code: %w", err)
+	}
+	return models.TOTPEnrollResponse{
+		Secret:     encoded,
+		OTPAuthURI: uri,
+		QRCodePNG:  base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// Confirm turns on TOTP once the user proves their app generates valid codes,
+// and returns recovery codes that are shown only this once
+func (s *TOTPService) Confirm(ctx context.Context, userID, code string) (models.TOTPRecoveryCodesResponse, error) {
+	enrollment, err := s.store.Get(ctx, userID)
+	if err != nil {
+		return models.TOTPRecoveryCodesResponse{}, fmt.Errorf("failed to load TOTP enrollment: %w", err)
+	}
+	if enrollment == nil {
+		return models.TOTPRecoveryCodesResponse{}, ErrTOTPNotEnrolled
+	}
+	if enrollment.Confirmed {
+		return models.TOTPRecoveryCodesResponse{}, ErrTOTPAlreadyEnabled
+	}
+	step, err := s.checkCode(ctx, enrollment, code)
+	if err != nil {
+		return models.TOTPRecoveryCodesResponse{}, err
+	}
+
+	codes, hashes, err := s.newRecoveryCodes(userID)
+	if err != nil {
+		return models.TOTPRecoveryCodesResponse{}, err
+	}
+	now := s.now()
+	enrollment.Confirmed = true
+	enrollment.ConfirmedAt = &now
+	enrollment.LastUsedStep = step
+	enrollment.RecoveryCodes = hashes
+	if err := s.store.Save(ctx, *enrollment); err != nil {
+		return models.TOTPRecoveryCodesResponse{}, fmt.Errorf("failed to save TOTP enrollment: %w", err)
+	}
+	return models.TOTPRecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Verify checks an authenticator or recovery code and returns a short-lived
+// step-up token for routes guarded by middleware.RequireStepUp
+func (s *TOTPService) Verify(ctx context.Context, userID string, req models.TOTPCodeRequest) (models.TOTPStepUpResponse, error) {
+	if _, err := s.verifyFactor(ctx, userID, req); err != nil {
+		return models.TOTPStepUpResponse{}, err
+	}
+	token, err := s.tokens.IssueVerificationToken(PurposeStepUp, userID)
+	if err != nil {
+		return models.TOTPStepUpResponse{}, fmt.Errorf("failed to issue step-up token: %w", err)
+	}
+	return models.TOTPStepUpResponse{StepUpToken: token, ExpiresIn: int(s.tokens.VerificationTTL().Seconds())}, nil
+}
+
+// Disable removes the authenticator and its recovery codes after a valid code
+func (s *TOTPService) Disable(ctx context.Context, userID string, req models.TOTPCodeRequest) error {
+	if _, err := s.verifyFactor(ctx, userID, req); err != nil {
+		return err
+	}
+	if err := s.store.Delete(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete TOTP enrollment: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after a valid code
+func (s *TOTPService) RegenerateRecoveryCodes(ctx context.Context, userID string, req models.TOTPCodeRequest) (models.TOTPRecoveryCodesResponse, error) {
+	enrollment, err := s.verifyFactor(ctx, userID, req)
+	if err != nil {
+		return models.TOTPRecoveryCodesResponse{}, err
+	}
+	codes, hashes, err := s.newRecoveryCodes(userID)
+	if err != nil {
+		return models.TOTPRecoveryCodesResponse{}, err
+	}
+	enrollment.RecoveryCodes = hashes
+	if err := s.store.Save(ctx, *enrollment); err != nil {
+		return models.TOTPRecoveryCodesResponse{}, fmt.Errorf("failed to save TOTP enrollment: %w", err)
+	}
+	return models.TOTPRecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Status reports whether TOTP is on and how many recovery codes are unused
+func (s *TOTPService) Status(ctx context.Context, userID string) (models.TOTPStatusResponse, error) {
+	enrollment, err := s.store.Get(ctx, userID)
+	if err != nil {
+		return models.TOTPStatusResponse{}, fmt.Errorf("failed to load TOTP enrollment: %w", err)
+	}
+	if enrollment == nil || !enrollment.Confirmed {
+		return models.TOTPStatusResponse{}, nil
+	}
+	return models.TOTPStatusResponse{Enabled: true, RecoveryCodesRemaining: len(enrollment.RecoveryCodes)}, nil
+}
+
+// Enabled reports whether the user has a confirmed authenticator
+func (s *TOTPService) Enabled(ctx context.Context, userID string) (bool, error) {
+	status, err := s.Status(ctx, userID)
+	return status.Enabled, err
+}
+
+// verifyFactor accepts either a current authenticator code or an unused recovery
+// code for a confirmed enrollment, and returns the enrollment as updated
+func (s *TOTPService) verifyFactor(ctx context.Context, userID string, req models.TOTPCodeRequest) (*models.TOTPEnrollment, error) {
+	enrollment, err := s.store.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TOTP enrollment: %w", err)
+	}
+	if enrollment == nil || !enrollment.Confirmed {
+		return nil, ErrTOTPNotEnabled
+	}
+	switch {
+	case req.Code != "":
+		step, err := s.checkCode(ctx, enrollment, req.Code)
+		if err != nil {
+			return nil, err
+		}
+		enrollment.LastUsedStep = step
+	case req.RecoveryCode != "":
+		if err := s.checkRecoveryCode(ctx, enrollment, req.RecoveryCode); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, apperrors.Validation("code or recovery_code is required")
+	}
+	return enrollment, nil
+}
+
+// checkCode accepts a code for the current step or up to Skew steps either side
+// that is newer than the last accepted one, and returns its step
+func (s *TOTPService) checkCode(ctx context.Context, enrollment *models.TOTPEnrollment, code string) (int64, error) {
+	if len(code) != totpDigits {
+		return 0, apperrors.Validation(fmt.Sprintf("code must be %d digits", totpDigits))
+	}
+	if err := s.reserveAttempt(ctx, enrollment.UserID); err != nil {
+		return 0, err
+	}
+	secret, err := s.sealer.open(enrollment.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	current := totpStep(s.now())
+	for delta := -s.config.Skew; delta <= s.config.Skew; delta++ {
+		step := current + int64(delta)
+		if subtle.ConstantTimeCompare([]byte(hotp(secret, step, totpDigits)), []byte(code)) != 1 {
+			continue
+		}
+		// A code is good for one use; UseStep also rejects codes older than the last one used
+		ok, err := s.store.UseStep(ctx, enrollment.UserID, step)
+		if err != nil {
+			return 0, fmt.Errorf("failed to record TOTP use: %w", err)
+		}
+		if ok {
+			return step, s.clearAttempts(ctx, enrollment.UserID)
+		}
+		break
+	}
+	return 0, ErrInvalidTOTP
+}
+
+func (s *TOTPService) checkRecoveryCode(ctx context.Context, enrollment *models.TOTPEnrollment, code string) error {
+	if err := s.reserveAttempt(ctx, enrollment.UserID); err != nil {
+		return err
+	}
+	hash := s.hashRecoveryCode(enrollment.UserID, code)
+	ok, err := s.store.UseRecoveryCode(ctx, enrollment.UserID, hash)
+	if err != nil {
+		return fmt.Errorf("failed to redeem recovery code: %w", err)
+	}
+	if !ok {
+		return ErrInvalidTOTP
+	}
+	if err := s.clearAttempts(ctx, enrollment.UserID); err != nil {
+		return err
+	}
+	for i, h := range enrollment.RecoveryCodes {
+		if h == hash {
+			enrollment.RecoveryCodes = append(enrollment.RecoveryCodes[:i:i], enrollment.RecoveryCodes[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// reserveAttempt counts an attempt before the code is checked, so parallel
+// guesses cannot all get in under MaxAttempts; once the window holds
+// MaxAttempts attempts it refuses until the window resets
+func (s *TOTPService) reserveAttempt(ctx context.Context, userID string) error {
+	allowed, err := s.attempts.CheckRateLimit(ctx, attemptKey(userID), s.config.MaxAttempts, s.config.AttemptWindow)
+	if err != nil {
+		return fmt.Errorf("failed to record TOTP attempt: %w", err)
+	}
+	if allowed {
+		return nil
+	}
+	resetIn, err := s.attempts.RateLimitResetIn(ctx, attemptKey(userID))
+	if err != nil {
+		return fmt.Errorf("failed to check TOTP attempts: %w", err)
+	}
+	return apperrors.RateLimited(fmt.Sprintf("too many invalid codes; try again in %d seconds", int(math.Ceil(resetIn.Seconds())))).WithCode("max_attempts_exceeded")
+}
+
+// clearAttempts forgets the attempts once a correct code was entered
+func (s *TOTPService) clearAttempts(ctx context.Context, userID string) error {
+	if err := s.attempts.ClearRateLimit(ctx, attemptKey(userID)); err != nil {
+		return fmt.Errorf("failed to clear TOTP attempts: %w", err)
+	}
+	return nil
+}
+
+func (s *TOTPService) newRecoveryCodes(userID string) ([]string, []string, error) {
+	codes := make([]string, s.config.RecoveryCodes)
+	hashes := make([]string, s.config.RecoveryCodes)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		codes[i] = code
+		hashes[i] = s.hashRecoveryCode(userID, code)
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode keys recovery codes with the encryption key so a database
+// leak alone does not allow offline guessing
+func (s *TOTPService) hashRecoveryCode(userID, code string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.EncryptionKey))
+	fmt.Fprintf(mac, "%s:%s", userID, normalizeRecoveryCode(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *TOTPService) otpauthURI(account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", s.config.Issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+	label := url.PathEscape(s.config.Issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// accountName labels the entry in the authenticator app
+func accountName(user *models.User) string {
+	if user.Email != "" {
+		return user.Email
+	}
+	if user.Phone != "" {
+		return user.Phone
+	}
+	return user.ID
+}
+
+func attemptKey(userID string) string {
+	return "totp:" + userID
+}
@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+var testTOTPConfig = config.TOTPConfig{
+	Issuer:        "VestRoll",
+	EncryptionKey: "test-key",
+	MaxAttempts:   3,
+	AttemptWindow: 15 * time.Minute,
+	RecoveryCodes: 4,
+	Skew:          1,
+}
+
+func newTestTOTP(t *testing.T) (*TOTPService, *TokenService, *time.Time) {
+	t.Helper()
+	clock := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	users := memory.NewUserRepository()
+	if err := users.Create(context.Background(), models.User{ID: "u1", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+	tokens := NewTokenService(config.JWTConfig{Secret: "test-secret", TTL: time.Hour, VerificationTTL: 15 * time.Minute})
+	svc, err := NewTOTPService(memory.NewTOTPRepository(), memory.NewOTPRepository(5*time.Minute, now), users, tokens, testTOTPConfig)
+	if err != nil {
+		t.Fatalf("NewTOTPService: %v", err)
+	}
+	svc.now = now
+	return svc, tokens, &clock
+}
+
+// enrollAndConfirm turns on TOTP for u1 and returns the secret and recovery codes
+func enrollAndConfirm(t *testing.T, svc *TOTPService, clock *time.Time) ([]byte, []string) {
+	t.Helper()
+	ctx := context.Background()
+	enroll, err := svc.Enroll(ctx, "u1")
+	if err != nil {
+		t.Fatalf("Enroll error: %v", err)
+	}
+	secret, err := secretEncoding.DecodeString(enroll.Secret)
+	if err != nil {
+		t.Fatalf("secret is not base32: %v", err)
+	}
+	codes, err := svc.Confirm(ctx, "u1", hotp(secret, totpStep(*clock), totpDigits))
+	if err != nil {
+		t.Fatalf("Confirm error: %v", err)
+	}
+	return secret, codes.RecoveryCodes
+}
+
+func TestHOTPMatchesRFC6238Vectors(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	for unix, want := range map[int64]string{59: "94287082", 1111111109: "07081804", 1234567890: "89005924", 20000000000: "65353130"} {
+		if got := hotp(secret, totpStep(time.Unix(unix, 0)), 8); got != want {
+			t.Fatalf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestTOTPEnrollmentAndConfirmation(t *testing.T) {
+	svc, _, clock := newTestTOTP(t)
+	ctx := context.Background()
+
+	enroll, err := svc.Enroll(ctx, "u1")
+	if err != nil {
+		t.Fatalf("Enroll error: %v", err)
+	}
+	if !strings.HasPrefix(enroll.OTPAuthURI, "otpauth://totp/VestRoll:ada@example.com?") ||
+		!strings.Contains(enroll.OTPAuthURI, "secret="+enroll.Secret) || !strings.Contains(enroll.OTPAuthURI, "issuer=VestRoll") {
+		t.Fatalf("unexpected otpauth URI %q", enroll.OTPAuthURI)
+	}
+	png, err := base64.StdEncoding.DecodeString(enroll.QRCodePNG)
+	if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Fatalf("QR code is not a base64 PNG: %v", err)
+	}
+	if status, _ := svc.Status(ctx, "u1"); status.Enabled {
+		t.Fatalf("TOTP enabled before confirmation")
+	}
+	stored, _ := svc.store.Get(ctx, "u1")
+	if strings.Contains(stored.Secret, enroll.Secret) {
+		t.Fatalf("secret stored in the clear")
+	}
+
+	secret, _ := secretEncoding.DecodeString(enroll.Secret)
+	if _, err := svc.Confirm(ctx, "u1", "000000"); !errors.Is(err, ErrInvalidTOTP) && hotp(secret, totpStep(*clock), totpDigits) != "000000" {
+		t.Fatalf("Confirm(wrong) = %v, want ErrInvalidTOTP", err)
+	}
+	codes, err := svc.Confirm(ctx, "u1", hotp(secret, totpStep(*clock)-1, totpDigits))
+	if err != nil {
+		t.Fatalf("Confirm with previous step's code: %v", err)
+	}
+	if len(codes.RecoveryCodes) != testTOTPConfig.RecoveryCodes {
+		t.Fatalf("got %d recovery codes, want %d", len(codes.RecoveryCodes), testTOTPConfig.RecoveryCodes)
+	}
+	if status, _ := svc.Status(ctx, "u1"); !status.Enabled || status.RecoveryCodesRemaining != testTOTPConfig.RecoveryCodes {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if _, err := svc.Enroll(ctx, "u1"); !errors.Is(err, ErrTOTPAlreadyEnabled) {
+		t.Fatalf("Enroll while enabled = %v, want ErrTOTPAlreadyEnabled", err)
+	}
+}
+
+func TestTOTPVerifyIssuesStepUpTokenAndRejectsReplay(t *testing.T) {
+	svc, tokens, clock := newTestTOTP(t)
+	ctx := context.Background()
+	secret, _ := enrollAndConfirm(t, svc, clock)
+
+	// The confirmation code cannot be used again
+	if _, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{Code: hotp(secret, totpStep(*clock), totpDigits)}); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("replayed code = %v, want ErrInvalidTOTP", err)
+	}
+
+	*clock = clock.Add(totpPeriod)
+	resp, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{Code: hotp(secret, totpStep(*clock), totpDigits)})
+	if err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+	claims, err := tokens.ParseVerificationToken(resp.StepUpToken, PurposeStepUp)
+	if err != nil || claims.Subject != "u1" {
+		t.Fatalf("step-up token = %+v, %v", claims, err)
+	}
+
+	// Codes too far from now are refused
+	far := hotp(secret, totpStep(*clock)+3, totpDigits)
+	if _, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{Code: far}); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("code three steps ahead = %v, want ErrInvalidTOTP", err)
+	}
+}
+
+func TestTOTPRecoveryCodesAreSingleUse(t *testing.T) {
+	svc, _, clock := newTestTOTP(t)
+	ctx := context.Background()
+	secret, recovery := enrollAndConfirm(t, svc, clock)
+
+	// Recovery codes are accepted regardless of case and dashes
+	loose := strings.ToUpper(strings.ReplaceAll(recovery[0], "-", " "))
+	if _, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{RecoveryCode: loose}); err != nil {
+		t.Fatalf("Verify with recovery code: %v", err)
+	}
+	if _, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{RecoveryCode: recovery[0]}); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("reused recovery code = %v, want ErrInvalidTOTP", err)
+	}
+	if status, _ := svc.Status(ctx, "u1"); status.RecoveryCodesRemaining != len(recovery)-1 {
+		t.Fatalf("RecoveryCodesRemaining = %d, want %d", status.RecoveryCodesRemaining, len(recovery)-1)
+	}
+
+	*clock = clock.Add(totpPeriod)
+	fresh, err := svc.RegenerateRecoveryCodes(ctx, "u1", models.TOTPCodeRequest{Code: hotp(secret, totpStep(*clock), totpDigits)})
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes error: %v", err)
+	}
+	if _, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{RecoveryCode: recovery[1]}); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("old recovery code after regenerate = %v, want ErrInvalidTOTP", err)
+	}
+	if _, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{RecoveryCode: fresh.RecoveryCodes[0]}); err != nil {
+		t.Fatalf("new recovery code: %v", err)
+	}
+}
+
+func TestTOTPLocksAfterMaxAttempts(t *testing.T) {
+	svc, _, clock := newTestTOTP(t)
+	ctx := context.Background()
+	secret, _ := enrollAndConfirm(t, svc, clock)
+	*clock = clock.Add(totpPeriod)
+	valid := hotp(secret, totpStep(*clock), totpDigits)
+	wrong := "000000"
+	if wrong == valid {
+		wrong = "111111"
+	}
+
+	for i := 0; i < testTOTPConfig.MaxAttempts; i++ {
+		if _, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{Code: wrong}); !errors.Is(err, ErrInvalidTOTP) {
+			t.Fatalf("attempt %d = %v, want ErrInvalidTOTP", i+1, err)
+		}
+	}
+	_, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{Code: valid})
+	if appErr, ok := apperrors.As(err); !ok || appErr.ResponseCode() != "max_attempts_exceeded" {
+		t.Fatalf("valid code while locked = %v, want max_attempts_exceeded", err)
+	}
+}
+
+func TestTOTPParallelGuessesAreCounted(t *testing.T) {
+	svc, _, clock := newTestTOTP(t)
+	ctx := context.Background()
+	secret, _ := enrollAndConfirm(t, svc, clock)
+	*clock = clock.Add(totpPeriod)
+	valid := map[string]bool{}
+	for delta := -testTOTPConfig.Skew; delta <= testTOTPConfig.Skew; delta++ {
+		valid[hotp(secret, totpStep(*clock)+int64(delta), totpDigits)] = true
+	}
+
+	var guesses []string
+	for i := 0; len(guesses) < 20; i++ {
+		if code := fmt.Sprintf("%06d", i*7919); !valid[code] {
+			guesses = append(guesses, code)
+		}
+	}
+	var invalid, locked int32
+	var wg sync.WaitGroup
+	for _, code := range guesses {
+		wg.Add(1)
+		go func(code string) {
+			defer wg.Done()
+			_, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{Code: code})
+			switch appErr, _ := apperrors.As(err); {
+			case errors.Is(err, ErrInvalidTOTP):
+				atomic.AddInt32(&invalid, 1)
+			case appErr != nil && appErr.ResponseCode() == "max_attempts_exceeded":
+				atomic.AddInt32(&locked, 1)
+			default:
+				t.Errorf("Verify(%s) = %v", code, err)
+			}
+		}(code)
+	}
+	wg.Wait()
+	if invalid != int32(testTOTPConfig.MaxAttempts) || locked != int32(len(guesses)-testTOTPConfig.MaxAttempts) {
+		t.Fatalf("%d guesses were checked and %d locked out, want %d checked", invalid, locked, testTOTPConfig.MaxAttempts)
+	}
+}
+
+func TestTOTPCorrectCodeClearsAttempts(t *testing.T) {
+	svc, _, clock := newTestTOTP(t)
+	ctx := context.Background()
+	secret, _ := enrollAndConfirm(t, svc, clock)
+	*clock = clock.Add(totpPeriod)
+	valid := hotp(secret, totpStep(*clock), totpDigits)
+	wrong := "000000"
+	if wrong == valid {
+		wrong = "111111"
+	}
+
+	for i := 0; i < testTOTPConfig.MaxAttempts-1; i++ {
+		svc.Verify(ctx, "u1", models.TOTPCodeRequest{Code: wrong})
+	}
+	if _, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{Code: valid}); err != nil {
+		t.Fatalf("Verify with the valid code error: %v", err)
+	}
+	// The correct code reset the count, so the user has every attempt again
+	for i := 0; i < testTOTPConfig.MaxAttempts; i++ {
+		if _, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{Code: wrong}); !errors.Is(err, ErrInvalidTOTP) {
+			t.Fatalf("attempt %d after success = %v, want ErrInvalidTOTP", i+1, err)
+		}
+	}
+}
+
+func TestTOTPDisable(t *testing.T) {
+	svc, _, clock := newTestTOTP(t)
+	ctx := context.Background()
+	_, recovery := enrollAndConfirm(t, svc, clock)
+
+	if err := svc.Disable(ctx, "u1", models.TOTPCodeRequest{}); err == nil {
+		t.Fatalf("Disable without a code succeeded")
+	}
+	if err := svc.Disable(ctx, "u1", models.TOTPCodeRequest{RecoveryCode: recovery[0]}); err != nil {
+		t.Fatalf("Disable error: %v", err)
+	}
+	if enabled, _ := svc.Enabled(ctx, "u1"); enabled {
+		t.Fatalf("TOTP still enabled after Disable")
+	}
+	if _, err := svc.Verify(ctx, "u1", models.TOTPCodeRequest{RecoveryCode: recovery[1]}); !errors.Is(err, ErrTOTPNotEnabled) {
+		t.Fatalf("Verify after Disable = %v, want ErrTOTPNotEnabled", err)
+	}
+}
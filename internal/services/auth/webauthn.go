@@ -0,0 +1,358 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// COSE algorithm identifiers (RFC 9053) accepted for passkeys, in order of preference
+const (
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+	coseAlgRS256 = -257
+)
+
+var passkeyAlgorithms = []int{coseAlgES256, coseAlgEdDSA, coseAlgRS256}
+
+// Authenticator data flags (WebAuthn §6.1)
+const (
+	authFlagUserPresent      = 0x01
+	authFlagUserVerified     = 0x04
+	authFlagAttestedCredData = 0x40
+	authFlagExtensionData    = 0x80
+)
+
+// Client data types for the two ceremonies
+const (
+	clientDataCreate = "webauthn.create"
+	clientDataGet    = "webauthn.get"
+)
+
+// clientData is the subset of CollectedClientData the relying party checks
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// authenticatorData is the parsed binary authenticator data. Credential
+// fields are only set when the attested credential data flag is present.
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte // COSE_Key as sent by the authenticator
+}
+
+// decodeBase64URL accepts base64url with or without padding, as browsers vary
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// parseClientData decodes clientDataJSON and returns it with its raw bytes,
+// which assertion signatures cover through their hash
+func parseClientData(encoded, wantType string) (*clientData, []byte, error) {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, nil, errors.New("clientDataJSON is not base64url")
+	}
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, nil, errors.New("clientDataJSON is not valid JSON")
+	}
+	if cd.Type != wantType {
+		return nil, nil, fmt.Errorf("clientDataJSON type must be %s", wantType)
+	}
+	return &cd, raw, nil
+}
+
+// parseAuthenticatorData decodes rpIdHash (32) | flags (1) | signCount (4)
+// followed by attested credential data and extensions when flagged
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+	ad := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+	if ad.flags&authFlagAttestedCredData != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		ad.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return nil, errors.New("credential ID length is invalid")
+		}
+		ad.credentialID = rest[:idLen]
+		rest = rest[idLen:]
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("credential public key: %w", err)
+		}
+		ad.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+	if ad.flags&authFlagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("extension data: %w", err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("authenticator data has trailing bytes")
+	}
+	return ad, nil
+}
+
+// parseAttestationObject returns the authenticator data from an attestation
+// object. Registration asks for no attestation, so only the "none" format is
+// accepted; browsers strip attestation to "none" when it is not requested.
+func parseAttestationObject(encoded string) (*authenticatorData, error) {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, errors.New("attestationObject is not base64url")
+	}
+	value, rest, err := decodeCBOR(raw)
+	if err != nil || len(rest) != 0 {
+		return nil, errors.New("attestationObject is not valid CBOR")
+	}
+	obj, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("attestationObject must be a map")
+	}
+	if format, _ := obj["fmt"].(string); format != "none" {
+		return nil, fmt.Errorf("unsupported attestation format %q", format)
+	}
+	authData, ok := obj["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestationObject has no authData")
+	}
+	ad, err := parseAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if ad.flags&authFlagAttestedCredData == 0 {
+		return nil, errors.New("attestationObject has no credential")
+	}
+	return ad, nil
+}
+
+// parseCOSEKey decodes a COSE_Key and returns the public key and its algorithm.
+// Supported are EC2 P-256 with ES256, OKP Ed25519 with EdDSA and RSA (2048+ bits) with RS256.
+func parseCOSEKey(raw []byte) (crypto.PublicKey, int, error) {
+	value, rest, err := decodeCBOR(raw)
+	if err != nil || len(rest) != 0 {
+		return nil, 0, errors.New("public key is not valid CBOR")
+	}
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("public key must be a COSE map")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+	switch {
+	case kty == 2 && alg == coseAlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("ES256 key must be an uncompressed P-256 point")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, 0, errors.New("ES256 key is not on the P-256 curve")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return key, coseAlgES256, nil
+	case kty == 1 && alg == coseAlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("EdDSA key must be an Ed25519 key")
+		}
+		return ed25519.PublicKey(x), coseAlgEdDSA, nil
+	case kty == 3 && alg == coseAlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		modulus := new(big.Int).SetBytes(n)
+		exponent := new(big.Int).SetBytes(e)
+		if modulus.BitLen() < 2048 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > math.MaxInt32 {
+			return nil, 0, errors.New("RS256 key must have a modulus of at least 2048 bits")
+		}
+		return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, coseAlgRS256, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported key type %d with algorithm %d", kty, alg)
+	}
+}
+
+// verifyAssertionSignature checks sig over authData || SHA-256(clientDataJSON)
+func verifyAssertionSignature(key crypto.PublicKey, alg int, authData, clientDataJSON, sig []byte) bool {
+	clientHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte{}, authData...), clientHash[:]...)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return alg == coseAlgES256 && ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		return alg == coseAlgEdDSA && ed25519.Verify(k, message, sig)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		return alg == coseAlgRS256 && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return false
+	}
+}
+
+// formatAAGUID renders the authenticator model ID in UUID form
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(aaguid)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// rpIDHashMatches reports whether the authenticator scoped the credential to rpID
+func rpIDHashMatches(hash []byte, rpID string) bool {
+	want := sha256.Sum256([]byte(rpID))
+	return bytes.Equal(hash, want[:])
+}
+
+// cborMaxDepth bounds nesting; WebAuthn structures are at most a few levels deep
+const cborMaxDepth = 8
+
+// decodeCBOR decodes one CBOR data item (RFC 8949) and returns the bytes after it.
+// Only the definite-length subset CTAP2 authenticators emit is supported:
+// integers, byte and text strings, arrays, maps, booleans and null.
+// Integers decode to int64, maps to map[interface{}]interface{}.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, errors.New("cbor: nesting too deep")
+	}
+	if len(data) == 0 {
+		return nil, nil, errors.New("cbor: unexpected end of data")
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22:
+			return nil, data, nil
+		default:
+			return nil, nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+		}
+	}
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < size {
+			return nil, nil, errors.New("cbor: unexpected end of data")
+		}
+		for _, b := range data[:size] {
+			arg = arg<<8 | uint64(b)
+		}
+		data = data[size:]
+	default:
+		return nil, nil, errors.New("cbor: indefinite lengths are not supported")
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, errors.New("cbor: integer overflows int64")
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, errors.New("cbor: integer overflows int64")
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, errors.New("cbor: string longer than data")
+		}
+		value := data[:arg]
+		if major == 3 {
+			return string(value), data[arg:], nil
+		}
+		return value, data[arg:], nil
+	case 4:
+		if arg > uint64(len(data)) {
+			return nil, nil, errors.New("cbor: array longer than data")
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			var err error
+			if item, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case 5:
+		if arg > uint64(len(data))/2 {
+			return nil, nil, errors.New("cbor: map longer than data")
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			var err error
+			if key, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errors.New("cbor: map keys must be integers or text")
+			}
+			if value, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			if _, dup := m[key]; dup {
+				return nil, nil, errors.New("cbor: duplicate map key")
+			}
+			m[key] = value
+		}
+		return m, data, nil
+	default:
+		return nil, nil, errors.New("cbor: tags are not supported")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/codeZe-us/vestroll-backend/internal/models"
 	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
 )
 
 // BusinessProfileService handles validation and persistence of business profiles
@@ -14,10 +15,10 @@ import (
 // Completion tracking ensures required fields are provided
 
 type BusinessProfileService struct {
-	repo *repository.BusinessProfileRepository
+	repo repository.BusinessProfileStore
 }
 
-func NewBusinessProfileService(repo *repository.BusinessProfileRepository) *BusinessProfileService {
+func NewBusinessProfileService(repo repository.BusinessProfileStore) *BusinessProfileService {
 	return &BusinessProfileService{repo: repo}
 }
 
@@ -26,13 +27,13 @@ func (s *BusinessProfileService) ValidateContractor(req models.BusinessDetailsRe
 	// Additional business rules can be applied here
 	// e.g., registration number pattern, tax ID format per country
 	if strings.TrimSpace(req.BusinessName) == "" {
-		return ErrValidation("business_name is required")
+		return apperrors.Validation("business_name is required")
 	}
 	if strings.TrimSpace(req.RegistrationNumber) == "" {
-		return ErrValidation("registration_number is required")
+		return apperrors.Validation("registration_number is required")
 	}
 	if strings.TrimSpace(req.TaxID) == "" {
-		return ErrValidation("tax_id is required")
+		return apperrors.Validation("tax_id is required")
 	}
 	return nil
 }
@@ -71,10 +72,3 @@ func (s *BusinessProfileService) BuildProfile(req models.BusinessDetailsRequest)
 func (s *BusinessProfileService) Save(ctx context.Context, profile models.BusinessProfile) error {
 	return s.repo.Save(ctx, profile)
 }
-
-// ErrValidation creates a simple validation error
-func ErrValidation(msg string) error { return &validationError{msg: msg} }
-
-type validationError struct{ msg string }
-
-func (e *validationError) Error() string { return "validation_error: " + e.msg }
\ No newline at end of file
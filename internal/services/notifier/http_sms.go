@@ -0,0 +1,77 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+)
+
+// HTTPSMS sends SMS through an aggregator's JSON API:
+// POST {URL} with {"to","from","text"} and a bearer API key.
+// 4xx responses are permanent failures, anything else non-2xx is retried.
+type HTTPSMS struct {
+	client   *http.Client
+	url      string
+	apiKey   string
+	senderID string
+}
+
+func NewHTTPSMS(cfg config.AggregatorConfig) *HTTPSMS {
+	return &HTTPSMS{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		url:      cfg.URL,
+		apiKey:   cfg.APIKey,
+		senderID: cfg.SenderID,
+	}
+}
+
+type httpSMSRequest struct {
+	To   string `json:"to"`
+	From string `json:"from,omitempty"`
+	Text string `json:"text"`
+}
+
+func (h *HTTPSMS) Ready(msg Message) error {
+	if h.url == "" {
+		return ErrNotConfigured
+	}
+	return nil
+}
+
+func (h *HTTPSMS) Send(ctx context.Context, msg Message) error {
+	if err := h.Ready(msg); err != nil {
+		return err
+	}
+	body, err := json.Marshal(httpSMSRequest{To: msg.To, From: h.senderID, Text: msg.Text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("aggregator returned %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
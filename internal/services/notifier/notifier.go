@@ -0,0 +1,158 @@
+// Package notifier delivers messages to users over SMS and email. Providers
+// (Twilio, SMTP, an HTTP SMS aggregator and a development outbox) implement
+// Notifier, and New picks one per channel from config. SMS can be routed by
+// destination prefix with failover between providers.
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+)
+
+// Channel is the medium a message is delivered over
+type Channel string
+
+const (
+	ChannelSMS   Channel = "sms"
+	ChannelEmail Channel = "email"
+)
+
+// Provider names accepted in config
+const (
+	ProviderTwilio     = "twilio"
+	ProviderSMTP       = "smtp"
+	ProviderAggregator = "aggregator"
+	ProviderOutbox     = "outbox"
+)
+
+// ErrNotConfigured is returned by providers that are missing credentials
+var ErrNotConfigured = errors.New("notification provider is not configured")
+
+// Message is a single notification. Subject and HTML are only used for email.
+type Message struct {
+	Channel Channel `json:"channel"`
+	To      string  `json:"to"`
+	Subject string  `json:"subject,omitempty"`
+	Text    string  `json:"text"`
+	HTML    string  `json:"html,omitempty"`
+	// ExpiresAt is when the message becomes useless (a code's expiry); zero means never
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// Notifier sends a message to its recipient
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// readier is implemented by notifiers that can tell before sending whether
+// they are configured to deliver a message
+type readier interface {
+	Ready(msg Message) error
+}
+
+// Ready returns ErrNotConfigured when n lacks the configuration to deliver msg,
+// so a queue can refuse the message instead of accepting it and failing later.
+// Notifiers that cannot tell up front are assumed ready.
+func Ready(n Notifier, msg Message) error {
+	if r, ok := n.(readier); ok {
+		return r.Ready(msg)
+	}
+	return nil
+}
+
+// Router sends each message with the provider registered for its channel
+type Router map[Channel]Notifier
+
+func (r Router) Ready(msg Message) error {
+	n, ok := r[msg.Channel]
+	if !ok || n == nil {
+		return fmt.Errorf("%w: no provider for %s", ErrNotConfigured, msg.Channel)
+	}
+	return Ready(n, msg)
+}
+
+func (r Router) Send(ctx context.Context, msg Message) error {
+	n, ok := r[msg.Channel]
+	if !ok || n == nil {
+		return fmt.Errorf("%w: no provider for %s", ErrNotConfigured, msg.Channel)
+	}
+	return n.Send(ctx, msg)
+}
+
+// New builds a Router from config. The returned Outbox is non-nil when any
+// channel uses the outbox provider, so callers can expose it for inspection.
+func New(cfg *config.Config) (Router, *Outbox, error) {
+	var outbox *Outbox
+	pick := func(channel Channel, provider string) (Notifier, error) {
+		switch provider {
+		case ProviderTwilio:
+			if channel != ChannelSMS {
+				break
+			}
+			return NewTwilio(cfg.Twilio), nil
+		case ProviderAggregator:
+			if channel != ChannelSMS {
+				break
+			}
+			return NewHTTPSMS(cfg.Aggregator), nil
+		case ProviderSMTP:
+			if channel != ChannelEmail {
+				break
+			}
+			return NewSMTP(cfg.SMTP), nil
+		case ProviderOutbox:
+			if outbox == nil {
+				var err error
+				if outbox, err = NewOutbox(cfg.Notifier.OutboxDir); err != nil {
+					return nil, err
+				}
+			}
+			return outbox, nil
+		}
+		return nil, fmt.Errorf("unsupported %s provider %q", channel, provider)
+	}
+
+	sms, err := newSMS(cfg.Notifier, func(name string) (Notifier, error) { return pick(ChannelSMS, name) })
+	if err != nil {
+		return nil, nil, err
+	}
+	email, err := pick(ChannelEmail, cfg.Notifier.EmailProvider)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Router{ChannelSMS: sms, ChannelEmail: email}, outbox, nil
+}
+
+// newSMS uses a single provider directly, or an SMSRouter when failover or
+// per-prefix routes are configured
+func newSMS(cfg config.NotifierConfig, pick func(string) (Notifier, error)) (Notifier, error) {
+	fallback := splitList(cfg.SMSProvider)
+	routes, err := ParseSMSRoutes(cfg.SMSRoutes)
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 && len(fallback) == 1 {
+		return pick(fallback[0])
+	}
+
+	providers := map[string]Notifier{}
+	chains := [][]string{fallback}
+	for _, route := range routes {
+		chains = append(chains, route.Providers)
+	}
+	for _, chain := range chains {
+		for _, name := range chain {
+			if providers[name] != nil {
+				continue
+			}
+			if providers[name], err = pick(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return NewSMSRouter(providers, fallback, routes)
+}
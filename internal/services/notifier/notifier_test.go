@@ -0,0 +1,63 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+)
+
+func TestOutboxRecordsMessages(t *testing.T) {
+	dir := t.TempDir()
+	outbox, err := NewOutbox(dir)
+	if err != nil {
+		t.Fatalf("NewOutbox error: %v", err)
+	}
+	ctx := context.Background()
+	outbox.Send(ctx, Message{Channel: ChannelSMS, To: "+2348012345678", Text: "first"})
+	outbox.Send(ctx, Message{Channel: ChannelEmail, To: "a@example.com", Subject: "s", Text: "second"})
+	outbox.Send(ctx, Message{Channel: ChannelSMS, To: "+2348012345678", Text: "third"})
+
+	got := outbox.Messages("+2348012345678")
+	if len(got) != 2 || got[0].Text != "third" || got[1].Text != "first" {
+		t.Fatalf("Messages = %+v", got)
+	}
+	if all := outbox.Messages(""); len(all) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(all))
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files in outbox dir, got %d", len(files))
+	}
+
+	outbox.Clear()
+	if all := outbox.Messages(""); len(all) != 0 {
+		t.Fatalf("expected empty outbox after Clear, got %d", len(all))
+	}
+}
+
+func TestNewSelectsProviders(t *testing.T) {
+	cfg := &config.Config{Notifier: config.NotifierConfig{SMSProvider: ProviderOutbox, EmailProvider: ProviderSMTP}}
+	router, outbox, err := New(cfg)
+	if err != nil || outbox == nil {
+		t.Fatalf("New = %v, %v", outbox, err)
+	}
+	ctx := context.Background()
+	if err := router.Send(ctx, Message{Channel: ChannelSMS, To: "+2348012345678", Text: "hi"}); err != nil {
+		t.Fatalf("SMS via outbox error: %v", err)
+	}
+	// SMTP without credentials is unconfigured rather than failing to dial
+	if err := router.Send(ctx, Message{Channel: ChannelEmail, To: "a@example.com", Text: "hi"}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured for unconfigured SMTP, got %v", err)
+	}
+	if len(outbox.Messages("")) != 1 {
+		t.Fatalf("expected only the SMS in the outbox")
+	}
+
+	cfg.Notifier.EmailProvider = ProviderTwilio
+	if _, _, err := New(cfg); err == nil {
+		t.Fatalf("expected error for twilio as an email provider")
+	}
+}
@@ -0,0 +1,84 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// outboxSize is how many recent messages the outbox keeps in memory
+const outboxSize = 200
+
+// OutboxMessage is a message captured by the outbox
+type OutboxMessage struct {
+	Message
+	SentAt time.Time `json:"sent_at"`
+}
+
+// Outbox is a development provider that records messages instead of sending them.
+// Recent messages are kept in memory, and each one is also written as a JSON file
+// when a directory is configured. Never use it in production: it exposes codes.
+type Outbox struct {
+	mu       sync.Mutex
+	dir      string
+	messages []OutboxMessage
+}
+
+// NewOutbox creates an outbox; dir may be empty to keep messages in memory only
+func NewOutbox(dir string) (*Outbox, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create outbox dir: %w", err)
+		}
+	}
+	return &Outbox{dir: dir}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@.+_-]+`)
+
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	captured := OutboxMessage{Message: msg, SentAt: time.Now().UTC()}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.dir != "" {
+		data, err := json.MarshalIndent(captured, "", "  ")
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%s_%s_%s.json", captured.SentAt.Format("20060102T150405.000000000"), msg.Channel, unsafeFileChars.ReplaceAllString(msg.To, "_"))
+		if err := os.WriteFile(filepath.Join(o.dir, name), data, 0o644); err != nil {
+			return fmt.Errorf("failed to write outbox message: %w", err)
+		}
+	}
+	o.messages = append(o.messages, captured)
+	if len(o.messages) > outboxSize {
+		o.messages = o.messages[len(o.messages)-outboxSize:]
+	}
+	return nil
+}
+
+// Messages returns captured messages newest first, optionally only those sent to to
+func (o *Outbox) Messages(to string) []OutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := []OutboxMessage{}
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		if to == "" || o.messages[i].To == to {
+			out = append(out, o.messages[i])
+		}
+	}
+	return out
+}
+
+// Clear drops the in-memory messages; files already written are left alone
+func (o *Outbox) Clear() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = nil
+}
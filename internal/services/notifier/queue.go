@@ -0,0 +1,187 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// queueLease is how long a worker owns a job before another may retry it
+	queueLease = time.Minute
+	// sendTimeout bounds a single provider call so it finishes within the lease
+	sendTimeout = 30 * time.Second
+	// idlePoll is how often idle workers check for new or due jobs
+	idlePoll = 250 * time.Millisecond
+)
+
+// ErrPermanent marks provider errors that retrying cannot fix (e.g. an invalid number)
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// Permanent wraps err so the queue dead-letters the message instead of retrying
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+func isPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent) || errors.Is(err, ErrNotConfigured)
+}
+
+// Queue is a Notifier that stores messages and delivers them from background
+// workers with exponential backoff. Messages that exhaust their attempts, or
+// fail permanently, are dead-lettered. Any Notifier can sit behind it.
+type Queue struct {
+	store  repository.NotificationQueueStore
+	sender Notifier
+	cfg    config.NotifierConfig
+	now    func() time.Time
+}
+
+func NewQueue(store repository.NotificationQueueStore, sender Notifier, cfg config.NotifierConfig) *Queue {
+	return &Queue{store: store, sender: sender, cfg: cfg, now: time.Now}
+}
+
+// Send enqueues the message; use Enqueue to learn its ID
+func (q *Queue) Send(ctx context.Context, msg Message) error {
+	_, err := q.Enqueue(ctx, msg)
+	return err
+}
+
+// Enqueue stores the message for delivery and returns its ID for Status.
+// It fails with ErrNotConfigured, without storing anything, when the sender
+// cannot deliver the message at all.
+func (q *Queue) Enqueue(ctx context.Context, msg Message) (string, error) {
+	if err := Ready(q.sender, msg); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	now := q.now().UTC()
+	job := models.NotificationJob{
+		ID:        uuid.NewString(),
+		Channel:   string(msg.Channel),
+		Payload:   payload,
+		Status:    models.NotificationQueued,
+		ExpiresAt: msg.ExpiresAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := q.store.Enqueue(ctx, job); err != nil {
+		return "", fmt.Errorf("failed to enqueue notification: %w", err)
+	}
+	return job.ID, nil
+}
+
+// Status returns the job for a message ID, or nil when it is unknown or expired
+func (q *Queue) Status(ctx context.Context, id string) (*models.NotificationJob, error) {
+	return q.store.Get(ctx, id)
+}
+
+// DeadLetters returns the most recent messages that could not be delivered
+func (q *Queue) DeadLetters(ctx context.Context, limit int) ([]models.NotificationJob, error) {
+	return q.store.DeadLetters(ctx, limit)
+}
+
+// Run starts cfg.Workers workers and blocks until ctx is cancelled
+func (q *Queue) Run(ctx context.Context) {
+	workers := q.cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		busy, err := q.processNext(ctx)
+		if err != nil {
+			log.Printf("notification queue: %v", err)
+		}
+		if busy {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(idlePoll):
+		}
+	}
+}
+
+// processNext delivers one job and reports whether there was one
+func (q *Queue) processNext(ctx context.Context) (bool, error) {
+	job, err := q.store.Dequeue(ctx, q.now(), queueLease)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	var msg Message
+	sendErr := json.Unmarshal(job.Payload, &msg)
+	switch {
+	case sendErr != nil:
+		sendErr = Permanent(sendErr)
+	case !job.ExpiresAt.IsZero() && !q.now().Before(job.ExpiresAt):
+		// Delivering an expired code would only confuse the recipient
+		sendErr = Permanent(errors.New("message expired before delivery"))
+	default:
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		sendErr = q.sender.Send(sendCtx, msg)
+		cancel()
+	}
+
+	job.Attempts++
+	job.UpdatedAt = q.now().UTC()
+	switch {
+	case sendErr == nil:
+		job.Status = models.NotificationDelivered
+		job.LastError = ""
+		job.Payload = nil
+		return true, q.store.Complete(ctx, *job)
+	case isPermanent(sendErr) || job.Attempts >= q.cfg.MaxAttempts:
+		job.Status = models.NotificationDead
+		job.LastError = sendErr.Error()
+		job.Payload = nil
+		log.Printf("notification %s dead-lettered after %d attempts: %v", job.ID, job.Attempts, sendErr)
+		return true, q.store.Bury(ctx, *job)
+	default:
+		job.Status = models.NotificationRetrying
+		job.LastError = sendErr.Error()
+		job.NextAttemptAt = job.UpdatedAt.Add(q.backoff(job.Attempts))
+		return true, q.store.Retry(ctx, *job)
+	}
+}
+
+// backoff doubles the delay per attempt up to the maximum, with jitter so
+// retries after an outage don't arrive together
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.cfg.RetryBaseDelay
+	for i := 1; i < attempts && delay < q.cfg.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > q.cfg.RetryMaxDelay {
+		delay = q.cfg.RetryMaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
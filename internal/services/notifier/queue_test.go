@@ -0,0 +1,183 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
+)
+
+// flakyNotifier fails the first failures sends with err, then succeeds
+type flakyNotifier struct {
+	mu       sync.Mutex
+	failures int
+	err      error
+	sent     []Message
+}
+
+func (f *flakyNotifier) Send(ctx context.Context, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return f.err
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+var testQueueConfig = config.NotifierConfig{Workers: 2, MaxAttempts: 3, RetryBaseDelay: 2 * time.Second, RetryMaxDelay: 10 * time.Second}
+
+func newTestQueue(sender Notifier) (*Queue, *time.Time) {
+	clock := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := NewQueue(memory.NewNotificationQueue(), sender, testQueueConfig)
+	q.now = func() time.Time { return clock }
+	return q, &clock
+}
+
+func TestQueueDeliversAndClearsPayload(t *testing.T) {
+	sender := &flakyNotifier{}
+	q, _ := newTestQueue(sender)
+	ctx := context.Background()
+
+	id, err := q.Enqueue(ctx, Message{Channel: ChannelSMS, To: "+2348012345678", Text: "code 123456"})
+	if err != nil || id == "" {
+		t.Fatalf("Enqueue = %q, %v", id, err)
+	}
+	if busy, err := q.processNext(ctx); !busy || err != nil {
+		t.Fatalf("processNext = %v, %v", busy, err)
+	}
+	job, _ := q.Status(ctx, id)
+	if job.Status != models.NotificationDelivered || job.Attempts != 1 || len(job.Payload) != 0 {
+		t.Fatalf("unexpected job after delivery %+v", job)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].Text != "code 123456" {
+		t.Fatalf("unexpected sends %+v", sender.sent)
+	}
+}
+
+func TestQueueRefusesUnconfiguredChannels(t *testing.T) {
+	q, _ := newTestQueue(Router{ChannelSMS: &flakyNotifier{}, ChannelEmail: NewSMTP(config.SMTPConfig{})})
+	_, err := q.Enqueue(context.Background(), Message{Channel: ChannelEmail, To: "a@example.com", Text: "code 123456"})
+	if !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+	if busy, _ := q.processNext(context.Background()); busy {
+		t.Fatalf("a refused message must not be stored")
+	}
+}
+
+func TestQueueDropsExpiredMessages(t *testing.T) {
+	sender := &flakyNotifier{}
+	q, clock := newTestQueue(sender)
+	ctx := context.Background()
+
+	id, _ := q.Enqueue(ctx, Message{Channel: ChannelSMS, To: "+2348012345678", Text: "code 123456", ExpiresAt: clock.Add(5 * time.Minute)})
+	*clock = clock.Add(5 * time.Minute)
+	if busy, err := q.processNext(ctx); !busy || err != nil {
+		t.Fatalf("processNext = %v, %v", busy, err)
+	}
+	job, _ := q.Status(ctx, id)
+	if job.Status != models.NotificationDead || len(job.Payload) != 0 || len(sender.sent) != 0 {
+		t.Fatalf("expected an expired code to be dead-lettered unsent, got %+v, sends %+v", job, sender.sent)
+	}
+}
+
+func TestQueueRetriesWithBackoff(t *testing.T) {
+	sender := &flakyNotifier{failures: 1, err: errors.New("smtp timeout")}
+	q, clock := newTestQueue(sender)
+	ctx := context.Background()
+	id, _ := q.Enqueue(ctx, Message{Channel: ChannelEmail, To: "a@example.com"})
+
+	q.processNext(ctx)
+	job, _ := q.Status(ctx, id)
+	if job.Status != models.NotificationRetrying || job.LastError != "smtp timeout" {
+		t.Fatalf("unexpected job after failure %+v", job)
+	}
+	if wait := job.NextAttemptAt.Sub(*clock); wait < time.Second || wait > 2*time.Second {
+		t.Fatalf("first retry should wait 1-2s, waits %v", wait)
+	}
+	if busy, _ := q.processNext(ctx); busy {
+		t.Fatalf("retry ran before its backoff elapsed")
+	}
+
+	*clock = job.NextAttemptAt
+	q.processNext(ctx)
+	if job, _ := q.Status(ctx, id); job.Status != models.NotificationDelivered || job.Attempts != 2 {
+		t.Fatalf("expected delivery on retry, got %+v", job)
+	}
+}
+
+func TestQueueDeadLetters(t *testing.T) {
+	ctx := context.Background()
+
+	sender := &flakyNotifier{failures: 10, err: errors.New("provider down")}
+	q, clock := newTestQueue(sender)
+	id, _ := q.Enqueue(ctx, Message{Channel: ChannelSMS, To: "+2348012345678"})
+	for i := 0; i < testQueueConfig.MaxAttempts; i++ {
+		*clock = clock.Add(time.Minute)
+		q.processNext(ctx)
+	}
+	job, _ := q.Status(ctx, id)
+	if job.Status != models.NotificationDead || job.Attempts != testQueueConfig.MaxAttempts || len(job.Payload) != 0 {
+		t.Fatalf("expected dead job after max attempts, got %+v", job)
+	}
+	if dead, _ := q.DeadLetters(ctx, 10); len(dead) != 1 || dead[0].ID != id {
+		t.Fatalf("DeadLetters = %+v", dead)
+	}
+
+	// Permanent failures are not retried
+	permanent := &flakyNotifier{failures: 1, err: Permanent(errors.New("invalid number"))}
+	q, _ = newTestQueue(permanent)
+	id, _ = q.Enqueue(ctx, Message{Channel: ChannelSMS, To: "+2348012345678"})
+	q.processNext(ctx)
+	if job, _ := q.Status(ctx, id); job.Status != models.NotificationDead || job.Attempts != 1 {
+		t.Fatalf("expected permanent failure to dead-letter at once, got %+v", job)
+	}
+}
+
+func TestQueueBackoffIsCapped(t *testing.T) {
+	q, _ := newTestQueue(&flakyNotifier{})
+	for attempts, max := range map[int]time.Duration{1: 2 * time.Second, 2: 4 * time.Second, 3: 8 * time.Second, 8: 10 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := q.backoff(attempts); d < max/2 || d > max {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempts, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestQueueRunDeliversInBackground(t *testing.T) {
+	sender := &flakyNotifier{}
+	q := NewQueue(memory.NewNotificationQueue(), sender, testQueueConfig)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 5; i++ {
+		q.Send(ctx, Message{Channel: ChannelSMS, To: "+2348012345678"})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sender.mu.Lock()
+		n := len(sender.sent)
+		sender.mu.Unlock()
+		if n == 5 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 5 deliveries, got %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
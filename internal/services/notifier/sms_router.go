@@ -0,0 +1,200 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMSRoute sends numbers starting with Prefix (e.g. "+234") through Providers in order
+type SMSRoute struct {
+	Prefix    string
+	Providers []string
+}
+
+// ProviderStats counts delivery outcomes for one provider
+type ProviderStats struct {
+	Sent   int64 `json:"sent"`
+	Failed int64 `json:"failed"`
+}
+
+// SuccessRate is the share of attempts that succeeded, or 1 before any attempt
+func (s ProviderStats) SuccessRate() float64 {
+	if total := s.Sent + s.Failed; total > 0 {
+		return float64(s.Sent) / float64(total)
+	}
+	return 1
+}
+
+// SMSRouter picks SMS providers by destination prefix and fails over to the next
+// provider in the chain when one errors. Numbers matching no route use the default chain.
+type SMSRouter struct {
+	providers map[string]Notifier
+	routes    []SMSRoute // longest prefix first
+	fallback  []string
+
+	mu    sync.Mutex
+	stats map[string]*ProviderStats
+}
+
+// NewSMSRouter validates that every route names a known provider
+func NewSMSRouter(providers map[string]Notifier, fallback []string, routes []SMSRoute) (*SMSRouter, error) {
+	r := &SMSRouter{providers: providers, fallback: fallback, stats: map[string]*ProviderStats{}}
+	chains := [][]string{fallback}
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Prefix, "+") {
+			return nil, fmt.Errorf("SMS route prefix %q must start with +", route.Prefix)
+		}
+		chains = append(chains, route.Providers)
+		r.routes = append(r.routes, route)
+	}
+	for _, chain := range chains {
+		if len(chain) == 0 {
+			return nil, errors.New("SMS route has no providers")
+		}
+		for _, name := range chain {
+			if providers[name] == nil {
+				return nil, fmt.Errorf("unknown SMS provider %q", name)
+			}
+			r.stats[name] = &ProviderStats{}
+		}
+	}
+	sort.SliceStable(r.routes, func(i, j int) bool { return len(r.routes[i].Prefix) > len(r.routes[j].Prefix) })
+	return r, nil
+}
+
+// Chain returns the providers tried, in order, for a destination number
+func (r *SMSRouter) Chain(to string) []string {
+	for _, route := range r.routes {
+		if strings.HasPrefix(to, route.Prefix) {
+			return route.Providers
+		}
+	}
+	return r.fallback
+}
+
+// Ready succeeds when any provider in the destination's chain is configured
+func (r *SMSRouter) Ready(msg Message) error {
+	var errs []error
+	for _, name := range r.Chain(msg.To) {
+		err := Ready(r.providers[name], msg)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	return errors.Join(errs...)
+}
+
+// Send tries each provider in the destination's chain until one accepts the message.
+// The combined error is permanent only when every provider failed permanently.
+func (r *SMSRouter) Send(ctx context.Context, msg Message) error {
+	chain := r.Chain(msg.To)
+	var errs []error
+	allPermanent := true
+	for i, name := range chain {
+		err := r.providers[name].Send(ctx, msg)
+		r.record(name, err)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		allPermanent = allPermanent && isPermanent(err)
+		if i < len(chain)-1 {
+			log.Printf("SMS provider %s failed, failing over to %s: %v", name, chain[i+1], err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if allPermanent {
+		return fmt.Errorf("all SMS providers failed: %w", errors.Join(errs...))
+	}
+	// Don't wrap, so a permanent error from one provider doesn't stop retries of the others
+	return fmt.Errorf("all SMS providers failed: %v", errors.Join(errs...))
+}
+
+func (r *SMSRouter) record(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		r.stats[name].Sent++
+	} else {
+		r.stats[name].Failed++
+	}
+}
+
+// Stats returns a snapshot of per-provider outcomes since start
+func (r *SMSRouter) Stats() map[string]ProviderStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]ProviderStats, len(r.stats))
+	for name, s := range r.stats {
+		out[name] = *s
+	}
+	return out
+}
+
+// LogStats logs the per-provider counts every interval, and once more when ctx is
+// cancelled, so a provider whose success rate drops shows up before its chain runs dry
+func (r *SMSRouter) LogStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("SMS provider stats: %s", r.statsSummary())
+			return
+		case <-ticker.C:
+			log.Printf("SMS provider stats: %s", r.statsSummary())
+		}
+	}
+}
+
+// statsSummary formats Stats as "aggregator sent=1 failed=1 success=50.0%; twilio ..."
+func (r *SMSRouter) statsSummary() string {
+	stats := r.Stats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		s := stats[name]
+		parts = append(parts, fmt.Sprintf("%s sent=%d failed=%d success=%.1f%%", name, s.Sent, s.Failed, 100*s.SuccessRate()))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ParseSMSRoutes parses "+234=aggregator,twilio;+225=twilio"
+func ParseSMSRoutes(spec string) ([]SMSRoute, error) {
+	var routes []SMSRoute
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		prefix, providers, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("SMS route %q must look like +234=provider[,fallback]", part)
+		}
+		routes = append(routes, SMSRoute{Prefix: strings.TrimSpace(prefix), Providers: splitList(providers)})
+	}
+	return routes, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
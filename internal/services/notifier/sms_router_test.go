@@ -0,0 +1,176 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+)
+
+// fakeAggregator stands in for a local SMS aggregator's HTTP API
+type fakeAggregator struct {
+	mu       sync.Mutex
+	status   int
+	received []httpSMSRequest
+}
+
+func newFakeAggregator(t *testing.T) (*fakeAggregator, *httptest.Server) {
+	agg := &fakeAggregator{status: http.StatusAccepted}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req httpSMSRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		agg.mu.Lock()
+		defer agg.mu.Unlock()
+		if agg.status < 300 {
+			agg.received = append(agg.received, req)
+		}
+		w.WriteHeader(agg.status)
+	}))
+	t.Cleanup(srv.Close)
+	return agg, srv
+}
+
+func (a *fakeAggregator) setStatus(code int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status = code
+}
+
+func (a *fakeAggregator) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.received)
+}
+
+func TestSMSRoutesNigeriaThroughAggregator(t *testing.T) {
+	agg, srv := newFakeAggregator(t)
+	twilio := &flakyNotifier{}
+	router, err := NewSMSRouter(
+		map[string]Notifier{
+			ProviderTwilio:     twilio,
+			ProviderAggregator: NewHTTPSMS(config.AggregatorConfig{URL: srv.URL, APIKey: "test-key", SenderID: "VestRoll"}),
+		},
+		[]string{ProviderTwilio},
+		[]SMSRoute{{Prefix: "+234", Providers: []string{ProviderAggregator, ProviderTwilio}}},
+	)
+	if err != nil {
+		t.Fatalf("NewSMSRouter error: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := router.Send(ctx, Message{Channel: ChannelSMS, To: "+2348012345678", Text: "code 123456"}); err != nil {
+		t.Fatalf("Send to +234 error: %v", err)
+	}
+	if agg.count() != 1 || len(twilio.sent) != 0 {
+		t.Fatalf("expected +234 via aggregator only, aggregator=%d twilio=%d", agg.count(), len(twilio.sent))
+	}
+	if got := agg.received[0]; got.To != "+2348012345678" || got.From != "VestRoll" || got.Text != "code 123456" {
+		t.Fatalf("aggregator received %+v", got)
+	}
+
+	if err := router.Send(ctx, Message{Channel: ChannelSMS, To: "+14155550100", Text: "hi"}); err != nil {
+		t.Fatalf("Send to +1 error: %v", err)
+	}
+	if agg.count() != 1 || len(twilio.sent) != 1 {
+		t.Fatalf("expected +1 via twilio, aggregator=%d twilio=%d", agg.count(), len(twilio.sent))
+	}
+
+	// The aggregator going down fails over to Twilio
+	agg.setStatus(http.StatusBadGateway)
+	if err := router.Send(ctx, Message{Channel: ChannelSMS, To: "+2348012345678", Text: "again"}); err != nil {
+		t.Fatalf("failover Send error: %v", err)
+	}
+	if len(twilio.sent) != 2 {
+		t.Fatalf("expected failover to twilio, got %d twilio sends", len(twilio.sent))
+	}
+
+	stats := router.Stats()
+	if s := stats[ProviderAggregator]; s.Sent != 1 || s.Failed != 1 || s.SuccessRate() != 0.5 {
+		t.Fatalf("aggregator stats = %+v", s)
+	}
+	if s := stats[ProviderTwilio]; s.Sent != 2 || s.Failed != 0 || s.SuccessRate() != 1 {
+		t.Fatalf("twilio stats = %+v", s)
+	}
+	if got := router.statsSummary(); got != "aggregator sent=1 failed=1 success=50.0%; twilio sent=2 failed=0 success=100.0%" {
+		t.Fatalf("statsSummary = %q", got)
+	}
+}
+
+func TestSMSRouterErrors(t *testing.T) {
+	agg, srv := newFakeAggregator(t)
+	aggregator := NewHTTPSMS(config.AggregatorConfig{URL: srv.URL, APIKey: "test-key"})
+	transient := &flakyNotifier{failures: 10, err: errors.New("timeout")}
+	router, err := NewSMSRouter(map[string]Notifier{ProviderAggregator: aggregator, ProviderTwilio: transient}, []string{ProviderAggregator, ProviderTwilio}, nil)
+	if err != nil {
+		t.Fatalf("NewSMSRouter error: %v", err)
+	}
+	ctx := context.Background()
+	msg := Message{Channel: ChannelSMS, To: "+2348012345678", Text: "hi"}
+
+	// A rejected number at one provider is still retried while another failed transiently
+	agg.setStatus(http.StatusBadRequest)
+	if err := router.Send(ctx, msg); err == nil || isPermanent(err) {
+		t.Fatalf("expected a retryable error, got %v", err)
+	}
+
+	// Only when every provider rejects the message is the failure permanent
+	transient.err = Permanent(errors.New("invalid number"))
+	if err := router.Send(ctx, msg); !isPermanent(err) {
+		t.Fatalf("expected a permanent error, got %v", err)
+	}
+
+	if _, err := NewSMSRouter(map[string]Notifier{ProviderTwilio: transient}, []string{ProviderTwilio}, []SMSRoute{{Prefix: "+234", Providers: []string{"missing"}}}); err == nil {
+		t.Fatalf("expected an error for an unknown provider")
+	}
+}
+
+func TestNewBuildsSMSRouter(t *testing.T) {
+	cfg := &config.Config{Notifier: config.NotifierConfig{
+		SMSProvider:   ProviderTwilio,
+		SMSRoutes:     "+234=aggregator,twilio; +44=outbox",
+		EmailProvider: ProviderOutbox,
+	}}
+	router, outbox, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	sms, ok := router[ChannelSMS].(*SMSRouter)
+	if !ok {
+		t.Fatalf("expected an SMSRouter, got %T", router[ChannelSMS])
+	}
+	if chain := sms.Chain("+2348012345678"); len(chain) != 2 || chain[0] != ProviderAggregator {
+		t.Fatalf("+234 chain = %v", chain)
+	}
+	if chain := sms.Chain("+14155550100"); len(chain) != 1 || chain[0] != ProviderTwilio {
+		t.Fatalf("default chain = %v", chain)
+	}
+	if err := router.Send(context.Background(), Message{Channel: ChannelSMS, To: "+447700900123", Text: "hi"}); err != nil {
+		t.Fatalf("Send via outbox route error: %v", err)
+	}
+	if len(outbox.Messages("+447700900123")) != 1 {
+		t.Fatalf("expected the +44 message in the outbox")
+	}
+
+	// Unconfigured providers fail permanently so the queue dead-letters instead of retrying
+	err = router.Send(context.Background(), Message{Channel: ChannelSMS, To: "+2348012345678", Text: "hi"})
+	if !errors.Is(err, ErrNotConfigured) || !isPermanent(err) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+
+	cfg.Notifier.SMSRoutes = "+234"
+	if _, _, err := New(cfg); err == nil {
+		t.Fatalf("expected an error for a malformed route")
+	}
+}
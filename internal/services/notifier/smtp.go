@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"gopkg.in/gomail.v2"
+)
+
+// SMTP sends email through an SMTP relay
+type SMTP struct {
+	dialer    *gomail.Dialer
+	fromEmail string
+	fromName  string
+}
+
+func NewSMTP(cfg config.SMTPConfig) *SMTP {
+	if cfg.Username == "" || cfg.Password == "" {
+		return &SMTP{} // Return unconfigured provider
+	}
+	return &SMTP{
+		dialer:    gomail.NewDialer(cfg.Host, cfg.Port, cfg.Username, cfg.Password),
+		fromEmail: cfg.FromEmail,
+		fromName:  cfg.FromName,
+	}
+}
+
+func (s *SMTP) Ready(msg Message) error {
+	if s.dialer == nil {
+		return ErrNotConfigured
+	}
+	return nil
+}
+
+// Send delivers the HTML body with Text as the plain-text alternative
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := s.Ready(msg); err != nil {
+		return err
+	}
+	m := gomail.NewMessage()
+	m.SetHeader("From", fmt.Sprintf("%s <%s>", s.fromName, s.fromEmail))
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	if msg.HTML != "" {
+		m.SetBody("text/html", msg.HTML)
+		m.AddAlternative("text/plain", msg.Text)
+	} else {
+		m.SetBody("text/plain", msg.Text)
+	}
+	return s.dialer.DialAndSend(m)
+}
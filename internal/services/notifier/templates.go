@@ -0,0 +1,210 @@
+package notifier
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	texttemplate "text/template"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used when no preferred locale is supported, and supplies
+// any template a locale does not define itself
+const DefaultLocale = "en"
+
+// Template names, one per kind of notification
+const (
+	TemplateOTP           = "otp"
+	TemplatePasswordReset = "password_reset"
+	TemplatePayslipReady  = "payslip_ready"
+	TemplateInvite        = "invite"
+	TemplateNewDevice     = "new_device"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Each templates/{locale}/{name}.tmpl defines "subject", "text" and "html" for email
+// and "sms" for text messages; templates/layout.html wraps the email HTML.
+// A locale may define only some of them, the rest come from DefaultLocale.
+
+type templateSet struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates renders localized messages from the embedded templates
+type Templates struct {
+	brand   string
+	now     func() time.Time
+	sets    map[string]templateSet // keyed by locale/name
+	locales []string
+	matcher language.Matcher
+}
+
+var templateFuncs = map[string]any{
+	// dict builds a map so partials like "button" can take named arguments
+	"dict": func(kv ...any) (map[string]any, error) {
+		if len(kv)%2 != 0 {
+			return nil, errors.New("dict needs key/value pairs")
+		}
+		m := make(map[string]any, len(kv)/2)
+		for i := 0; i < len(kv); i += 2 {
+			key, ok := kv[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("dict key %v is not a string", kv[i])
+			}
+			m[key] = kv[i+1]
+		}
+		return m, nil
+	},
+}
+
+// NewTemplates parses the embedded templates; brand is available to every template as .Brand
+func NewTemplates(brand string) (*Templates, error) {
+	dirs, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		return nil, err
+	}
+	t := &Templates{brand: brand, now: time.Now, sets: map[string]templateSet{}}
+	for _, d := range dirs {
+		if d.IsDir() {
+			t.locales = append(t.locales, d.Name())
+		}
+	}
+	sort.Slice(t.locales, func(i, j int) bool {
+		// The matcher falls back to the first tag, so the default goes first
+		return t.locales[i] == DefaultLocale || (t.locales[j] != DefaultLocale && t.locales[i] < t.locales[j])
+	})
+
+	names, err := fs.Glob(templateFS, path.Join("templates", DefaultLocale, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]language.Tag, 0, len(t.locales))
+	for _, locale := range t.locales {
+		tags = append(tags, language.Make(locale))
+		for _, file := range names {
+			name := path.Base(file)
+			if name == "common.tmpl" {
+				continue
+			}
+			set, err := parseSet(locale, name)
+			if err != nil {
+				return nil, fmt.Errorf("template %s/%s: %w", locale, name, err)
+			}
+			t.sets[locale+"/"+name[:len(name)-len(".tmpl")]] = set
+		}
+	}
+	t.matcher = language.NewMatcher(tags)
+	return t, nil
+}
+
+// parseSet layers the locale's files over the default locale's so missing
+// definitions fall back to DefaultLocale
+func parseSet(locale, name string) (templateSet, error) {
+	files := []string{
+		path.Join("templates", DefaultLocale, "common.tmpl"),
+		path.Join("templates", DefaultLocale, name),
+	}
+	for _, f := range []string{"common.tmpl", name} {
+		p := path.Join("templates", locale, f)
+		if _, err := fs.Stat(templateFS, p); locale != DefaultLocale && err == nil {
+			files = append(files, p)
+		}
+	}
+
+	text := texttemplate.New(name).Funcs(templateFuncs).Option("missingkey=error")
+	html := htmltemplate.New(name).Funcs(templateFuncs).Option("missingkey=error")
+	if _, err := html.ParseFS(templateFS, "templates/layout.html"); err != nil {
+		return templateSet{}, err
+	}
+	// Files are parsed one by one so later ones redefine earlier templates
+	for _, f := range files {
+		if _, err := text.ParseFS(templateFS, f); err != nil {
+			return templateSet{}, err
+		}
+		if _, err := html.ParseFS(templateFS, f); err != nil {
+			return templateSet{}, err
+		}
+	}
+	return templateSet{text: text, html: html}, nil
+}
+
+// Locales lists the supported locales, DefaultLocale first
+func (t *Templates) Locales() []string {
+	return append([]string(nil), t.locales...)
+}
+
+// MatchLocale picks the supported locale closest to the preferences, which may be
+// locale tags ("fr-CI") or Accept-Language headers ("fr-CI,fr;q=0.9,en;q=0.8").
+// Empty preferences are skipped; with no match it returns DefaultLocale.
+func (t *Templates) MatchLocale(preferences ...string) string {
+	for _, pref := range preferences {
+		if pref == "" {
+			continue
+		}
+		tags, _, err := language.ParseAcceptLanguage(pref)
+		if err != nil || len(tags) == 0 {
+			continue
+		}
+		_, index, confidence := t.matcher.Match(tags...)
+		if confidence != language.No {
+			return t.locales[index]
+		}
+	}
+	return DefaultLocale
+}
+
+// Render builds a message for the channel from the named template. vars are
+// exposed to the template alongside .Brand and .Year.
+func (t *Templates) Render(name, locale string, channel Channel, to string, vars map[string]any) (Message, error) {
+	set, ok := t.sets[locale+"/"+name]
+	if !ok {
+		if set, ok = t.sets[DefaultLocale+"/"+name]; !ok {
+			return Message{}, fmt.Errorf("unknown template %q", name)
+		}
+	}
+	data := map[string]any{"Brand": t.brand, "Year": t.now().Year()}
+	for k, v := range vars {
+		data[k] = v
+	}
+
+	msg := Message{Channel: channel, To: to}
+	var err error
+	switch channel {
+	case ChannelSMS:
+		msg.Text, err = executeText(set.text, "sms", data)
+	case ChannelEmail:
+		if msg.Subject, err = executeText(set.text, "subject", data); err != nil {
+			break
+		}
+		if msg.Text, err = executeText(set.text, "text", data); err != nil {
+			break
+		}
+		var buf bytes.Buffer
+		err = set.html.ExecuteTemplate(&buf, "layout", data)
+		msg.HTML = buf.String()
+	default:
+		err = fmt.Errorf("unsupported channel %q", channel)
+	}
+	if err != nil {
+		return Message{}, fmt.Errorf("rendering %s/%s: %w", locale, name, err)
+	}
+	return msg, nil
+}
+
+func executeText(tmpl *texttemplate.Template, name string, data map[string]any) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,83 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testVars = map[string]map[string]any{
+	TemplateOTP:           {"Code": "123456", "Purpose": "signup", "TTLMinutes": 5},
+	TemplatePasswordReset: {"Code": "123456", "TTLMinutes": 5},
+	TemplatePayslipReady:  {"Name": "Ada", "Period": "May 2025", "Amount": "NGN 250,000", "Link": "https://app.vestroll.com/payslips/1"},
+	TemplateInvite:        {"InviterName": "Ada", "CompanyName": "Acme <Ltd>", "Link": "https://app.vestroll.com/invite/abc"},
+	TemplateNewDevice:     {"DeviceName": "Ada's iPhone", "Platform": "ios"},
+}
+
+func TestEveryTemplateRendersInEveryLocale(t *testing.T) {
+	tmpl, err := NewTemplates("VestRoll")
+	if err != nil {
+		t.Fatalf("NewTemplates error: %v", err)
+	}
+	for _, locale := range tmpl.Locales() {
+		for name, vars := range testVars {
+			for _, channel := range []Channel{ChannelSMS, ChannelEmail} {
+				msg, err := tmpl.Render(name, locale, channel, "to", vars)
+				if err != nil {
+					t.Errorf("%s/%s/%s: %v", locale, name, channel, err)
+					continue
+				}
+				if msg.Text == "" || (channel == ChannelEmail && (msg.Subject == "" || msg.HTML == "")) {
+					t.Errorf("%s/%s/%s rendered empty parts: %+v", locale, name, channel, msg)
+				}
+			}
+		}
+	}
+}
+
+func TestRenderFillsVariablesAndEscapesHTML(t *testing.T) {
+	tmpl, _ := NewTemplates("PayCo")
+	tmpl.now = func() time.Time { return time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	sms, _ := tmpl.Render(TemplateOTP, "en", ChannelSMS, "+2348012345678", testVars[TemplateOTP])
+	if sms.Text != "Your PayCo sign-up code is: 123456. This code expires in 5 minutes. Never share it with anyone." {
+		t.Fatalf("unexpected SMS %q", sms.Text)
+	}
+	email, _ := tmpl.Render(TemplateInvite, "en", ChannelEmail, "a@example.com", testVars[TemplateInvite])
+	if !strings.Contains(email.HTML, "© 2031 PayCo") || !strings.Contains(email.HTML, "Acme &lt;Ltd&gt;") {
+		t.Fatalf("expected year, brand and escaped HTML, got %s", email.HTML)
+	}
+	if !strings.Contains(email.Text, "Acme <Ltd>") {
+		t.Fatalf("plain text should not be HTML-escaped: %s", email.Text)
+	}
+
+	if _, err := tmpl.Render(TemplateOTP, "en", ChannelSMS, "x", map[string]any{"Purpose": "login"}); err == nil {
+		t.Fatalf("expected an error for a missing variable")
+	}
+}
+
+func TestLocaleFallback(t *testing.T) {
+	tmpl, _ := NewTemplates("VestRoll")
+	// Hausa defines codes on every channel, with the purpose label; other templates fall back to English
+	sms, _ := tmpl.Render(TemplateOTP, "ha", ChannelSMS, "x", testVars[TemplateOTP])
+	email, _ := tmpl.Render(TemplateOTP, "ha", ChannelEmail, "x", testVars[TemplateOTP])
+	if !strings.Contains(sms.Text, "Lambar rajista") || !strings.Contains(sms.Text, "minti 5") || email.Subject != "VestRoll - Lambar rajista ɗinka" {
+		t.Fatalf("unexpected Hausa code: sms %q, subject %q", sms.Text, email.Subject)
+	}
+	invite, _ := tmpl.Render(TemplateInvite, "yo", ChannelEmail, "x", testVars[TemplateInvite])
+	if !strings.HasPrefix(invite.Subject, "Ada invited you") {
+		t.Fatalf("unexpected fallback subject %q", invite.Subject)
+	}
+
+	cases := map[string][]string{
+		"fr": {"fr-CI,fr;q=0.9,en;q=0.8"},
+		"yo": {"", "yo-NG"},
+		"ha": {"ha", "fr"},
+		"en": {"de-DE", "xx-invalid;;"},
+	}
+	for want, prefs := range cases {
+		if got := tmpl.MatchLocale(prefs...); got != want {
+			t.Errorf("MatchLocale(%q) = %s, want %s", prefs, got, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/twilio/twilio-go"
+	api "github.com/twilio/twilio-go/rest/api/v2010"
+)
+
+// Twilio sends SMS through the Twilio messages API
+type Twilio struct {
+	client    *twilio.RestClient
+	fromPhone string
+}
+
+func NewTwilio(cfg config.TwilioConfig) *Twilio {
+	if cfg.AccountSID == "" || cfg.AuthToken == "" {
+		return &Twilio{} // Return unconfigured provider
+	}
+	client := twilio.NewRestClientWithParams(twilio.ClientParams{
+		Username: cfg.AccountSID,
+		Password: cfg.AuthToken,
+	})
+	return &Twilio{client: client, fromPhone: cfg.FromPhone}
+}
+
+func (t *Twilio) Ready(msg Message) error {
+	if t.client == nil || t.fromPhone == "" {
+		return ErrNotConfigured
+	}
+	return nil
+}
+
+func (t *Twilio) Send(ctx context.Context, msg Message) error {
+	if err := t.Ready(msg); err != nil {
+		return err
+	}
+	params := &api.CreateMessageParams{}
+	params.SetTo(msg.To)
+	params.SetFrom(t.fromPhone)
+	params.SetBody(msg.Text)
+	_, err := t.client.Api.CreateMessage(params)
+	return err
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/codeZe-us/vestroll-backend/internal/services/notifier"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+// Shared helpers for services that deliver one-time codes (OTP, password reset)
+
+var (
+	// E.164: a country code and subscriber number of 7 to 15 digits in all
+	phoneRegex = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
+	emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+)
+
+// CodeDispatcher delivers one-time codes over the channel matching the OTP type,
+// rendered in the recipient's language. It is shared by OTPService and PasswordResetService,
+// and also sends account security notices.
+type CodeDispatcher struct {
+	notifier  notifier.Notifier
+	templates *notifier.Templates
+	// users and profiles are optional; when set, a saved profile locale wins over the request's
+	users    repository.UserStore
+	profiles repository.ProfileStore
+}
+
+// NewCodeDispatcher creates a dispatcher; templates defaults to the embedded set branded "VestRoll"
+func NewCodeDispatcher(notify notifier.Notifier, templates *notifier.Templates, users repository.UserStore, profiles repository.ProfileStore) (*CodeDispatcher, error) {
+	if templates == nil {
+		var err error
+		if templates, err = notifier.NewTemplates("VestRoll"); err != nil {
+			return nil, err
+		}
+	}
+	return &CodeDispatcher{notifier: notify, templates: templates, users: users, profiles: profiles}, nil
+}
+
+// enqueuer is implemented by notifiers that deliver later and can report a message ID
+type enqueuer interface {
+	Enqueue(ctx context.Context, msg notifier.Message) (string, error)
+}
+
+// send renders and delivers a code; locale is a locale tag or Accept-Language value.
+// When the notifier is a queue it returns the message ID for status lookups.
+func (d *CodeDispatcher) send(ctx context.Context, channel models.OTPType, identifier, code string, purpose models.OTPPurpose, ttl time.Duration, locale string) (string, error) {
+	var label string
+	var ch notifier.Channel
+	switch channel {
+	case models.OTPTypeSMS:
+		label, ch = "SMS", notifier.ChannelSMS
+	case models.OTPTypeEmail:
+		label, ch = "email", notifier.ChannelEmail
+	default:
+		return "", apperrors.Validation(fmt.Sprintf("unsupported OTP type: %s", channel))
+	}
+	if d == nil || d.notifier == nil {
+		return "", apperrors.Unavailable(label + " service is not configured")
+	}
+
+	name := notifier.TemplateOTP
+	if purpose == models.OTPPurposePasswordReset {
+		name = notifier.TemplatePasswordReset
+	}
+	msg, err := d.templates.Render(name, d.templates.MatchLocale(d.profileLocale(ctx, channel, identifier), locale), ch, identifier, map[string]any{
+		"Code":       code,
+		"Purpose":    string(purpose.PurposeOrDefault()),
+		"TTLMinutes": int(ttl.Minutes()),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render %s message: %w", label, err)
+	}
+	msg.ExpiresAt = time.Now().Add(ttl)
+	var id string
+	if q, ok := d.notifier.(enqueuer); ok {
+		id, err = q.Enqueue(ctx, msg)
+	} else {
+		err = d.notifier.Send(ctx, msg)
+	}
+	if err != nil {
+		if errors.Is(err, notifier.ErrNotConfigured) {
+			return "", apperrors.Unavailable(label + " service is not configured")
+		}
+		return "", apperrors.Wrap(apperrors.KindUnavailable, err, fmt.Sprintf("failed to send %s OTP", label))
+	}
+	return id, nil
+}
+
+// NotifyNewDevice tells the user a device was added to their account, by
+// email and SMS when they have both, in the language of their profile
+func (d *CodeDispatcher) NotifyNewDevice(ctx context.Context, user models.User, device models.Device) error {
+	if d == nil || d.notifier == nil {
+		return notifier.ErrNotConfigured
+	}
+	var locale string
+	if d.profiles != nil {
+		if profile, _ := d.profiles.Get(ctx, user.ID); profile != nil {
+			locale = profile.Locale
+		}
+	}
+	vars := map[string]any{"DeviceName": device.Name, "Platform": device.Platform}
+	var errs []error
+	for channel, to := range map[notifier.Channel]string{notifier.ChannelEmail: user.Email, notifier.ChannelSMS: user.Phone} {
+		if to == "" {
+			continue
+		}
+		msg, err := d.templates.Render(notifier.TemplateNewDevice, d.templates.MatchLocale(locale), channel, to, vars)
+		if err == nil {
+			err = d.notifier.Send(ctx, msg)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// profileLocale returns the locale saved on the recipient's profile, if any
+func (d *CodeDispatcher) profileLocale(ctx context.Context, channel models.OTPType, identifier string) string {
+	if d.users == nil || d.profiles == nil {
+		return ""
+	}
+	var user *models.User
+	if channel == models.OTPTypeSMS {
+		user, _ = d.users.GetByPhone(ctx, identifier)
+	} else {
+		user, _ = d.users.GetByEmail(ctx, identifier)
+	}
+	if user == nil {
+		return ""
+	}
+	profile, _ := d.profiles.Get(ctx, user.ID)
+	if profile == nil {
+		return ""
+	}
+	return profile.Locale
+}
+
+func generateOTPCode(length int) (string, error) {
+	if length <= 0 {
+		return "", fmt.Errorf("invalid OTP length")
+	}
+
+	// Generate random digits
+	code := ""
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code += n.String()
+	}
+
+	return code, nil
+}
+
+func validateIdentifier(identifier string, otpType models.OTPType) error {
+	switch otpType {
+	case models.OTPTypeSMS:
+		// Basic phone number validation (should start with +)
+		if !phoneRegex.MatchString(identifier) {
+			return apperrors.Validation("invalid phone number format. Must be in international format (e.g., +1234567890)")
+		}
+	case models.OTPTypeEmail:
+		// Basic email validation
+		if !emailRegex.MatchString(identifier) {
+			return apperrors.Validation("invalid email address format")
+		}
+	default:
+		return apperrors.Validation(fmt.Sprintf("unsupported OTP type: %s", otpType))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
+	"github.com/codeZe-us/vestroll-backend/internal/services/notifier"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+func TestCodeDispatcherLocalizesMessages(t *testing.T) {
+	ctx := context.Background()
+	outbox, _ := notifier.NewOutbox("")
+	users := memory.NewUserRepository()
+	profiles := memory.NewProfileRepository()
+	dispatcher, err := NewCodeDispatcher(outbox, nil, users, profiles)
+	if err != nil {
+		t.Fatalf("NewCodeDispatcher error: %v", err)
+	}
+	const phone = "+2348012345678"
+
+	// Without a profile the request's Accept-Language decides, and the TTL is rendered
+	if _, err := dispatcher.send(ctx, models.OTPTypeSMS, phone, "123456", models.OTPPurposeLogin, 10*time.Minute, "fr-CI,fr;q=0.9,en;q=0.8"); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	msg := outbox.Messages(phone)[0]
+	if !strings.Contains(msg.Text, "code de connexion") || !strings.Contains(msg.Text, "10 minutes") {
+		t.Fatalf("expected French login copy with the TTL, got %q", msg.Text)
+	}
+
+	// A saved profile locale wins over the request
+	users.Create(ctx, models.User{ID: "u1", Email: "a@example.com", Phone: phone})
+	profiles.Save(ctx, models.UserProfile{UserID: "u1", Locale: "en"})
+	dispatcher.send(ctx, models.OTPTypeSMS, phone, "123456", models.OTPPurposeLogin, 5*time.Minute, "fr")
+	if msg := outbox.Messages(phone)[0]; !strings.HasPrefix(msg.Text, "Your VestRoll login code is: 123456") {
+		t.Fatalf("expected English copy from the profile, got %q", msg.Text)
+	}
+
+	dispatcher.send(ctx, models.OTPTypeEmail, "b@example.com", "654321", models.OTPPurposePasswordReset, 5*time.Minute, "fr")
+	email := outbox.Messages("b@example.com")[0]
+	if !strings.Contains(email.Subject, "réinitialisation") || !strings.Contains(email.HTML, "654321") || email.Text == "" {
+		t.Fatalf("unexpected reset email %+v", email)
+	}
+}
+
+func TestCodeDispatcherReturnsQueuedMessageID(t *testing.T) {
+	ctx := context.Background()
+	outbox, _ := notifier.NewOutbox("")
+	queue := notifier.NewQueue(memory.NewNotificationQueue(), notifier.Router{notifier.ChannelSMS: outbox}, config.NotifierConfig{MaxAttempts: 1})
+	dispatcher, _ := NewCodeDispatcher(queue, nil, nil, nil)
+
+	id, err := dispatcher.send(ctx, models.OTPTypeSMS, "+2348012345678", "123456", models.OTPPurposeSignup, 5*time.Minute, "")
+	if err != nil || id == "" {
+		t.Fatalf("send = %q, %v", id, err)
+	}
+	job, _ := queue.Status(ctx, id)
+	if job == nil || job.Status != models.NotificationQueued || job.Channel != "sms" {
+		t.Fatalf("unexpected queued job %+v", job)
+	}
+	// The queued code is dropped once it would have expired anyway
+	if until := time.Until(job.ExpiresAt); until <= 0 || until > 5*time.Minute {
+		t.Fatalf("expected the job to expire with the code, got %v", job.ExpiresAt)
+	}
+
+	// A channel with no provider is refused up front rather than queued
+	if _, err := dispatcher.send(ctx, models.OTPTypeEmail, "a@example.com", "123456", models.OTPPurposeSignup, 5*time.Minute, ""); apperrors.KindOf(err) != apperrors.KindUnavailable {
+		t.Fatalf("expected unavailable for an unconfigured channel, got %v", err)
+	}
+}
+
+func TestCodeDispatcherNotifiesNewDevice(t *testing.T) {
+	ctx := context.Background()
+	outbox, _ := notifier.NewOutbox("")
+	profiles := memory.NewProfileRepository()
+	profiles.Save(ctx, models.UserProfile{UserID: "u1", Locale: "fr"})
+	dispatcher, _ := NewCodeDispatcher(outbox, nil, memory.NewUserRepository(), profiles)
+
+	user := models.User{ID: "u1", Email: "a@example.com", Phone: "+2348012345678"}
+	device := models.Device{ID: "d1", Name: "Pixel 9", Platform: models.DevicePlatformAndroid}
+	if err := dispatcher.NotifyNewDevice(ctx, user, device); err != nil {
+		t.Fatalf("NotifyNewDevice error: %v", err)
+	}
+	sms := outbox.Messages(user.Phone)
+	if len(sms) != 1 || !strings.Contains(sms[0].Text, "Pixel 9 (android)") || !strings.Contains(sms[0].Text, "nouvel appareil") {
+		t.Fatalf("expected a French SMS naming the device, got %+v", sms)
+	}
+	if email := outbox.Messages(user.Email); len(email) != 1 || !strings.Contains(email[0].HTML, "Pixel 9") {
+		t.Fatalf("expected an email naming the device, got %+v", email)
+	}
+}
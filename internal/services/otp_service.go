@@ -2,185 +2,225 @@ package services
 
 import (
 	"context"
-	"crypto/rand"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
-	"math/big"
-	"regexp"
+	"math"
 	"time"
 
 	"github.com/codeZe-us/vestroll-backend/internal/config"
 	"github.com/codeZe-us/vestroll-backend/internal/models"
 	"github.com/codeZe-us/vestroll-backend/internal/repository"
-	"github.com/codeZe-us/vestroll-backend/internal/services/email_service"
-	"github.com/codeZe-us/vestroll-backend/internal/services/sms_service"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+var (
+	ErrOTPNotFound = apperrors.Validation("OTP not found or expired").WithCode("otp_expired")
+	ErrOTPExpired  = apperrors.Validation("OTP has expired").WithCode("otp_expired")
+	ErrInvalidOTP  = apperrors.Validation("invalid OTP code").WithCode("invalid_otp")
 )
 
 type OTPService struct {
-	otpRepo      *repository.OTPRepository
-	smsService   *sms_service.SMSService
-	emailService *email_service.EmailService
-	config       config.OTPConfig
+	otpRepo    repository.OTPStore
+	dispatcher *CodeDispatcher
+	// smsGuard screens SMS destinations against toll fraud; nil disables it
+	smsGuard *SMSGuard
+	config   config.OTPConfig
 }
 
 func NewOTPService(
-	otpRepo *repository.OTPRepository,
-	smsService *sms_service.SMSService,
-	emailService *email_service.EmailService,
+	otpRepo repository.OTPStore,
+	dispatcher *CodeDispatcher,
+	smsGuard *SMSGuard,
 	config config.OTPConfig,
 ) *OTPService {
 	return &OTPService{
-		otpRepo:      otpRepo,
-		smsService:   smsService,
-		emailService: emailService,
-		config:       config,
+		otpRepo:    otpRepo,
+		dispatcher: dispatcher,
+		smsGuard:   smsGuard,
+		config:     config,
 	}
 }
 
-func (s *OTPService) SendOTP(ctx context.Context, req models.OTPRequest) error {
+// SendOTP issues and delivers a code. The returned message ID is empty when
+// delivery is synchronous; the quota tells clients when they can ask again.
+func (s *OTPService) SendOTP(ctx context.Context, req models.OTPRequest) (string, models.OTPQuota, error) {
 	// Validate identifier format
-	if err := s.validateIdentifier(req.Identifier, req.Type); err != nil {
-		return err
+	if err := validateIdentifier(req.Identifier, req.Type); err != nil {
+		return "", models.OTPQuota{}, err
 	}
 
-	// Check rate limiting
-	allowed, err := s.otpRepo.CheckRateLimit(
-		ctx,
-		req.Identifier,
-		s.config.RateLimit.MaxRequests,
-		s.config.RateLimit.WindowSize,
-	)
+	release, err := s.admitSend(ctx, req.Identifier, req.Type, req.Challenge)
 	if err != nil {
-		return fmt.Errorf("failed to check rate limit: %w", err)
-	}
-	if !allowed {
-		remaining, _ := s.otpRepo.GetRemainingAttempts(ctx, req.Identifier, s.config.RateLimit.MaxRequests)
-		return fmt.Errorf("rate limit exceeded. Try again later. Remaining attempts: %d", remaining)
+		return "", models.OTPQuota{}, err
 	}
+	// A code that never went out should not hold the user to the cooldown
+	sent := false
+	defer func() {
+		if !sent {
+			release()
+		}
+	}()
 
 	// Generate OTP code
-	code, err := s.generateOTPCode(s.config.Length)
+	code, err := generateOTPCode(s.config.Length)
 	if err != nil {
-		return fmt.Errorf("failed to generate OTP code: %w", err)
+		return "", models.OTPQuota{}, fmt.Errorf("failed to generate OTP code: %w", err)
 	}
 
 	// Create OTP data
+	now := time.Now()
 	otpData := models.OTPData{
-		Code:      code,
+		CodeHash:  s.hashCode(req.Identifier, req.Purpose, req.Type, code),
 		Type:      req.Type,
-		ExpiresAt: time.Now().Add(s.config.TTL),
+		Purpose:   req.Purpose.PurposeOrDefault(),
+		ExpiresAt: now.Add(s.config.TTL),
 		Attempts:  0,
 	}
 
 	// Store OTP in Redis
 	if err := s.otpRepo.StoreOTP(ctx, req.Identifier, otpData); err != nil {
-		return fmt.Errorf("failed to store OTP: %w", err)
+		return "", models.OTPQuota{}, fmt.Errorf("failed to store OTP: %w", err)
 	}
 
 	// Send OTP via appropriate channel
-	switch req.Type {
-	case models.OTPTypeSMS:
-		if !s.smsService.IsConfigured() {
-			return fmt.Errorf("SMS service is not configured")
-		}
-		if err := s.smsService.SendOTP(ctx, req.Identifier, code); err != nil {
-			// Clean up stored OTP on send failure
-			s.otpRepo.DeleteOTP(ctx, req.Identifier, req.Type)
-			return fmt.Errorf("failed to send SMS OTP: %w", err)
+	messageID, err := s.dispatcher.send(ctx, req.Type, req.Identifier, code, req.Purpose, s.config.TTL, req.Locale)
+	if err != nil {
+		// Clean up stored OTP when it could not be sent or queued
+		s.otpRepo.DeleteOTP(ctx, req.Identifier, req.Purpose, req.Type)
+		return "", models.OTPQuota{}, err
+	}
+
+	sent = true
+
+	quota, err := s.quota(ctx, req.Identifier, now)
+	if err != nil {
+		return "", models.OTPQuota{}, err
+	}
+	quota.ExpiresAt = otpData.ExpiresAt
+	return messageID, quota, nil
+}
+
+// admitSend applies the resend cooldown, the per-identifier send limit and, for
+// SMS, the SMS guard, then starts the cooldown. release lifts the cooldown again
+// and should be called when no code goes out.
+func (s *OTPService) admitSend(ctx context.Context, identifier string, channel models.OTPType, challenge string) (release func(), err error) {
+	// Refuse resends during the cooldown before they count against the window
+	wait, err := s.otpRepo.CooldownRemaining(ctx, identifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check resend cooldown: %w", err)
+	}
+	if wait > 0 {
+		return nil, resendCooldownError(wait)
+	}
+
+	allowed, err := s.otpRepo.CheckRateLimit(ctx, identifier, s.config.RateLimit.MaxRequests, s.config.RateLimit.WindowSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	if !allowed {
+		reset, _ := s.otpRepo.RateLimitResetIn(ctx, identifier)
+		return nil, apperrors.RateLimited(fmt.Sprintf("rate limit exceeded. Try again in %d seconds", int(math.Ceil(reset.Seconds()))))
+	}
+
+	// Screen SMS destinations for pumping before paying for a message
+	if channel == models.OTPTypeSMS && s.smsGuard != nil {
+		if err := s.smsGuard.Check(ctx, identifier, challenge); err != nil {
+			return nil, err
 		}
-	case models.OTPTypeEmail:
-		if !s.emailService.IsConfigured() {
-			return fmt.Errorf("email service is not configured")
+	}
+
+	// A concurrent request may have started the cooldown since the check above
+	started, err := s.otpRepo.StartCooldown(ctx, identifier, s.config.ResendCooldown)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start resend cooldown: %w", err)
+	}
+	if !started {
+		return nil, resendCooldownError(s.config.ResendCooldown)
+	}
+	return func() { s.otpRepo.ClearCooldown(ctx, identifier) }, nil
+}
+
+// quota reports the sends left in the window and when the next one is allowed
+func (s *OTPService) quota(ctx context.Context, identifier string, now time.Time) (models.OTPQuota, error) {
+	remaining, err := s.otpRepo.GetRemainingAttempts(ctx, identifier, s.config.RateLimit.MaxRequests)
+	if err != nil {
+		return models.OTPQuota{}, fmt.Errorf("failed to read send quota: %w", err)
+	}
+	next := now.Add(s.config.ResendCooldown)
+	if remaining == 0 {
+		reset, err := s.otpRepo.RateLimitResetIn(ctx, identifier)
+		if err != nil {
+			return models.OTPQuota{}, fmt.Errorf("failed to read send quota: %w", err)
 		}
-		if err := s.emailService.SendOTP(ctx, req.Identifier, code); err != nil {
-			// Clean up stored OTP on send failure
-			s.otpRepo.DeleteOTP(ctx, req.Identifier, req.Type)
-			return fmt.Errorf("failed to send email OTP: %w", err)
+		if now.Add(reset).After(next) {
+			next = now.Add(reset)
 		}
-	default:
-		return fmt.Errorf("unsupported OTP type: %s", req.Type)
 	}
+	return models.OTPQuota{
+		RemainingAttempts: s.config.MaxAttempts,
+		RemainingSends:    remaining,
+		ResendAvailableAt: next,
+	}, nil
+}
 
-	return nil
+func resendCooldownError(wait time.Duration) error {
+	return apperrors.RateLimited(fmt.Sprintf("please wait %d seconds before requesting another code", int(math.Ceil(wait.Seconds())))).WithCode("otp_resend_cooldown")
 }
 
 func (s *OTPService) VerifyOTP(ctx context.Context, req models.OTPVerificationRequest) error {
 	// Validate identifier format
-	if err := s.validateIdentifier(req.Identifier, req.Type); err != nil {
+	if err := validateIdentifier(req.Identifier, req.Type); err != nil {
 		return err
 	}
+	if len(req.Code) != s.config.Length {
+		return apperrors.Validation(fmt.Sprintf("code must be %d digits", s.config.Length))
+	}
 
-	// Get stored OTP
-	otpData, err := s.otpRepo.GetOTP(ctx, req.Identifier, req.Type)
+	// Count the attempt and fetch the stored hash atomically so parallel guesses
+	// cannot exceed the limit
+	otpData, err := s.otpRepo.RegisterAttempt(ctx, req.Identifier, req.Purpose, req.Type, s.config.MaxAttempts)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve OTP: %w", err)
 	}
 	if otpData == nil {
-		return fmt.Errorf("OTP not found or expired")
+		return ErrOTPNotFound
 	}
 
-	// Check if OTP has expired
-	if time.Now().After(otpData.ExpiresAt) {
-		s.otpRepo.DeleteOTP(ctx, req.Identifier, req.Type)
-		return fmt.Errorf("OTP has expired")
+	// Check attempt limits; the repository has already removed the code
+	if otpData.Attempts > s.config.MaxAttempts {
+		return ErrTooManyAttempts
 	}
 
-	// Check attempt limits (max 3 attempts)
-	if otpData.Attempts >= 3 {
-		s.otpRepo.DeleteOTP(ctx, req.Identifier, req.Type)
-		return fmt.Errorf("maximum verification attempts exceeded")
+	// Check if OTP has expired
+	if time.Now().After(otpData.ExpiresAt) {
+		s.otpRepo.DeleteOTP(ctx, req.Identifier, req.Purpose, req.Type)
+		return ErrOTPExpired
 	}
 
 	// Verify the code
-	if otpData.Code != req.Code {
-		// Increment attempt counter
-		if err := s.otpRepo.IncrementAttempts(ctx, req.Identifier, req.Type); err != nil {
-			return fmt.Errorf("failed to update attempt counter: %w", err)
-		}
-		return fmt.Errorf("invalid OTP code")
+	candidate := s.hashCode(req.Identifier, req.Purpose, req.Type, req.Code)
+	if subtle.ConstantTimeCompare([]byte(candidate), []byte(otpData.CodeHash)) != 1 {
+		return ErrInvalidOTP
 	}
 
-	// OTP is valid, delete it to prevent reuse
-	if err := s.otpRepo.DeleteOTP(ctx, req.Identifier, req.Type); err != nil {
+	// OTP is valid, consume it so concurrent requests with the same code cannot reuse it
+	consumed, err := s.otpRepo.ConsumeOTP(ctx, req.Identifier, req.Purpose, req.Type, otpData.CodeHash)
+	if err != nil {
 		return fmt.Errorf("failed to clean up OTP: %w", err)
 	}
+	if !consumed {
+		return ErrOTPNotFound
+	}
 
 	return nil
 }
 
-func (s *OTPService) generateOTPCode(length int) (string, error) {
-	if length <= 0 {
-		return "", fmt.Errorf("invalid OTP length")
-	}
-
-	// Generate random digits
-	code := ""
-	for i := 0; i < length; i++ {
-		n, err := rand.Int(rand.Reader, big.NewInt(10))
-		if err != nil {
-			return "", err
-		}
-		code += n.String()
-	}
-
-	return code, nil
+// hashCode keys the stored code to the identifier, purpose and channel it was issued for
+func (s *OTPService) hashCode(identifier string, purpose models.OTPPurpose, otpType models.OTPType, code string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.HashKey))
+	fmt.Fprintf(mac, "%s:%s:%s:%s", purpose.PurposeOrDefault(), otpType, identifier, code)
+	return hex.EncodeToString(mac.Sum(nil))
 }
-
-func (s *OTPService) validateIdentifier(identifier string, otpType models.OTPType) error {
-	switch otpType {
-	case models.OTPTypeSMS:
-		// Basic phone number validation (should start with +)
-		phoneRegex := regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
-		if !phoneRegex.MatchString(identifier) {
-			return fmt.Errorf("invalid phone number format. Must be in international format (e.g., +1234567890)")
-		}
-	case models.OTPTypeEmail:
-		// Basic email validation
-		emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-		if !emailRegex.MatchString(identifier) {
-			return fmt.Errorf("invalid email address format")
-		}
-	default:
-		return fmt.Errorf("unsupported OTP type: %s", otpType)
-	}
-	return nil
-}
\ No newline at end of file
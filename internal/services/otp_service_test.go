@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
+	"github.com/codeZe-us/vestroll-backend/internal/services/notifier"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	redis "github.com/go-redis/redis/v8"
+)
+
+var testOTPConfig = config.OTPConfig{
+	Length:      6,
+	TTL:         5 * time.Minute,
+	MaxAttempts: 3,
+	RateLimit:   config.RateLimitConfig{MaxRequests: 5, WindowSize: 15 * time.Minute},
+	HashKey:     "test-otp-key",
+}
+
+func newTestDispatcher(t *testing.T, notify notifier.Notifier) *CodeDispatcher {
+	t.Helper()
+	dispatcher, err := NewCodeDispatcher(notify, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewCodeDispatcher error: %v", err)
+	}
+	return dispatcher
+}
+
+func setupOTPService(t *testing.T) (*OTPService, *repository.OTPRepository) {
+	svc, repo, _ := setupOTPServiceWithRedis(t)
+	return svc, repo
+}
+
+func setupOTPServiceWithRedis(t *testing.T) (*OTPService, *repository.OTPRepository, *miniredis.Miniredis) {
+	mini, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mini.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: mini.Addr()})
+	repo := repository.NewOTPRepository(rdb, testOTPConfig.TTL)
+	svc := NewOTPService(repo, newTestDispatcher(t, notifier.Router{}), nil, testOTPConfig)
+	return svc, repo, mini
+}
+
+func storeTestOTP(t *testing.T, svc *OTPService, repo *repository.OTPRepository, identifier string, purpose models.OTPPurpose, code string) {
+	t.Helper()
+	data := models.OTPData{
+		CodeHash:  svc.hashCode(identifier, purpose, models.OTPTypeSMS, code),
+		Type:      models.OTPTypeSMS,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	if err := repo.StoreOTP(context.Background(), identifier, data); err != nil {
+		t.Fatalf("StoreOTP error: %v", err)
+	}
+}
+
+func TestOTPIsStoredHashed(t *testing.T) {
+	svc, repo := setupOTPService(t)
+	ctx := context.Background()
+	phone := "+2348012345678"
+	storeTestOTP(t, svc, repo, phone, models.OTPPurposeVerification, "123456")
+
+	data, err := repo.GetOTP(ctx, phone, models.OTPPurposeVerification, models.OTPTypeSMS)
+	if err != nil || data == nil {
+		t.Fatalf("GetOTP error: %v", err)
+	}
+	if strings.Contains(data.CodeHash, "123456") || len(data.CodeHash) != 64 {
+		t.Fatalf("expected a hex HMAC at rest, got %q", data.CodeHash)
+	}
+
+	other := *svc
+	other.config.HashKey = "another-key"
+	if other.hashCode(phone, models.OTPPurposeVerification, models.OTPTypeSMS, "123456") == data.CodeHash {
+		t.Fatalf("expected hash to depend on the key")
+	}
+}
+
+func TestVerifyOTPParallelWrongGuessesRespectLimit(t *testing.T) {
+	svc, repo := setupOTPService(t)
+	ctx := context.Background()
+	phone := "+2348012345678"
+	storeTestOTP(t, svc, repo, phone, models.OTPPurposeVerification, "123456")
+
+	const guesses = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	invalid := 0
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: phone, Code: "000000", Type: models.OTPTypeSMS})
+			if err == nil {
+				t.Errorf("expected wrong guess to fail")
+				return
+			}
+			if errors.Is(err, ErrInvalidOTP) {
+				mu.Lock()
+				invalid++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if invalid != testOTPConfig.MaxAttempts {
+		t.Fatalf("expected exactly %d guesses to be compared, got %d", testOTPConfig.MaxAttempts, invalid)
+	}
+	// The code is burned once the limit is hit, even for the right answer
+	if err := svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: phone, Code: "123456", Type: models.OTPTypeSMS}); err == nil {
+		t.Fatalf("expected code to be invalidated after too many attempts")
+	}
+}
+
+func TestVerifyOTPParallelCorrectCodeSucceedsOnce(t *testing.T) {
+	svc, repo := setupOTPService(t)
+	ctx := context.Background()
+	phone := "+2348012345678"
+	storeTestOTP(t, svc, repo, phone, models.OTPPurposeVerification, "123456")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < testOTPConfig.MaxAttempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: phone, Code: "123456", Type: models.OTPTypeSMS}); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Fatalf("expected the code to be redeemed exactly once, got %d", succeeded)
+	}
+}
+
+func TestVerifyOTPKeepsTTLAcrossAttempts(t *testing.T) {
+	svc, repo, mini := setupOTPServiceWithRedis(t)
+	ctx := context.Background()
+	phone := "+2348012345678"
+	storeTestOTP(t, svc, repo, phone, models.OTPPurposeVerification, "123456")
+
+	mini.FastForward(4 * time.Minute)
+	svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: phone, Code: "000000", Type: models.OTPTypeSMS})
+	mini.FastForward(2 * time.Minute)
+
+	if data, _ := repo.GetOTP(ctx, phone, models.OTPPurposeVerification, models.OTPTypeSMS); data != nil {
+		t.Fatalf("expected a failed attempt not to extend the code's lifetime")
+	}
+}
+
+func TestOTPIsBoundToPurpose(t *testing.T) {
+	svc, repo := setupOTPService(t)
+	ctx := context.Background()
+	phone := "+2348012345678"
+
+	for _, purpose := range []models.OTPPurpose{models.OTPPurposePasswordReset, models.OTPPurposeTransaction} {
+		code := "111111"
+		if purpose == models.OTPPurposeTransaction {
+			code = "222222"
+		}
+		storeTestOTP(t, svc, repo, phone, purpose, code)
+	}
+
+	// A password reset code must not confirm a transaction
+	err := svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: phone, Code: "111111", Type: models.OTPTypeSMS, Purpose: models.OTPPurposeTransaction})
+	if err == nil {
+		t.Fatalf("expected password reset code to be rejected for transaction purpose")
+	}
+	// Codes for different purposes coexist instead of overwriting each other
+	if err := svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: phone, Code: "111111", Type: models.OTPTypeSMS, Purpose: models.OTPPurposePasswordReset}); err != nil {
+		t.Fatalf("expected password reset code to verify, got %v", err)
+	}
+	if err := svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: phone, Code: "222222", Type: models.OTPTypeSMS, Purpose: models.OTPPurposeTransaction}); err != nil {
+		t.Fatalf("expected transaction code to verify, got %v", err)
+	}
+	// Requests without a purpose default to verification and see neither code
+	if err := svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: phone, Code: "222222", Type: models.OTPTypeSMS}); err == nil {
+		t.Fatalf("expected default purpose not to see transaction code")
+	}
+}
+
+func TestSendOTPDeliversThroughNotifier(t *testing.T) {
+	mini, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mini.Close)
+	outbox, _ := notifier.NewOutbox("")
+	repo := repository.NewOTPRepository(redis.NewClient(&redis.Options{Addr: mini.Addr()}), testOTPConfig.TTL)
+	svc := NewOTPService(repo, newTestDispatcher(t, notifier.Router{notifier.ChannelSMS: outbox}), nil, testOTPConfig)
+	ctx := context.Background()
+	phone := "+2348012345678"
+
+	if _, _, err := svc.SendOTP(ctx, models.OTPRequest{Identifier: phone, Type: models.OTPTypeSMS}); err != nil {
+		t.Fatalf("SendOTP error: %v", err)
+	}
+	sent := outbox.Messages(phone)
+	if len(sent) != 1 {
+		t.Fatalf("expected one message in the outbox, got %d", len(sent))
+	}
+	code := regexp.MustCompile(`\d{6}`).FindString(sent[0].Text)
+	if err := svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: phone, Code: code, Type: models.OTPTypeSMS}); err != nil {
+		t.Fatalf("VerifyOTP with delivered code error: %v", err)
+	}
+
+	// Email has no provider, so sending reports the channel as unavailable
+	_, _, err = svc.SendOTP(ctx, models.OTPRequest{Identifier: "a@example.com", Type: models.OTPTypeEmail})
+	if !errors.Is(err, apperrors.ErrUnavailable) {
+		t.Fatalf("expected unavailable error, got %v", err)
+	}
+}
+
+func TestSendOTPFailureLiftsCooldown(t *testing.T) {
+	repo := memory.NewOTPRepository(testOTPConfig.TTL, nil)
+	cfg := testOTPConfig
+	cfg.ResendCooldown = 30 * time.Second
+	svc := NewOTPService(repo, newTestDispatcher(t, notifier.Router{}), nil, cfg)
+	ctx := context.Background()
+
+	_, _, err := svc.SendOTP(ctx, models.OTPRequest{Identifier: "a@example.com", Type: models.OTPTypeEmail})
+	if !errors.Is(err, apperrors.ErrUnavailable) {
+		t.Fatalf("expected unavailable error, got %v", err)
+	}
+	// The user can retry at once rather than waiting out a cooldown for a code they never got
+	if left, _ := repo.CooldownRemaining(ctx, "a@example.com"); left != 0 {
+		t.Fatalf("cooldown left after a failed send = %v; want 0", left)
+	}
+}
+
+func TestSendOTPReportsQuotaAndEnforcesCooldown(t *testing.T) {
+	clock := time.Now()
+	repo := memory.NewOTPRepository(testOTPConfig.TTL, func() time.Time { return clock })
+	outbox, _ := notifier.NewOutbox("")
+	cfg := testOTPConfig
+	cfg.Length = 8
+	cfg.MaxAttempts = 4
+	cfg.ResendCooldown = 30 * time.Second
+	cfg.RateLimit.MaxRequests = 2
+	svc := NewOTPService(repo, newTestDispatcher(t, notifier.Router{notifier.ChannelEmail: outbox}), nil, cfg)
+	ctx := context.Background()
+	email := "a@example.com"
+
+	_, quota, err := svc.SendOTP(ctx, models.OTPRequest{Identifier: email, Type: models.OTPTypeEmail})
+	if err != nil {
+		t.Fatalf("SendOTP error: %v", err)
+	}
+	if quota.RemainingAttempts != 4 || quota.RemainingSends != 1 || quota.ExpiresAt.Before(time.Now().Add(4*time.Minute)) {
+		t.Fatalf("quota = %+v", quota)
+	}
+	if wait := time.Until(quota.ResendAvailableAt); wait < 29*time.Second || wait > 30*time.Second {
+		t.Fatalf("ResendAvailableAt is %v away; want the 30s cooldown", wait)
+	}
+	code := regexp.MustCompile(`\d{8}`).FindString(outbox.Messages(email)[0].Text)
+	if code == "" {
+		t.Fatalf("expected an 8-digit code in %q", outbox.Messages(email)[0].Text)
+	}
+
+	_, _, err = svc.SendOTP(ctx, models.OTPRequest{Identifier: email, Type: models.OTPTypeEmail})
+	if e, ok := apperrors.As(err); !ok || e.ResponseCode() != "otp_resend_cooldown" {
+		t.Fatalf("expected otp_resend_cooldown, got %v", err)
+	}
+
+	// The last send in the window points the client at the window reset, not the cooldown
+	clock = clock.Add(31 * time.Second)
+	_, quota, err = svc.SendOTP(ctx, models.OTPRequest{Identifier: email, Type: models.OTPTypeEmail})
+	if err != nil {
+		t.Fatalf("SendOTP after cooldown error: %v", err)
+	}
+	if quota.RemainingSends != 0 || quota.ResendAvailableAt.Before(clock.Add(14*time.Minute)) {
+		t.Fatalf("quota at the end of the window = %+v", quota)
+	}
+
+	// Verification follows the configured length
+	err = svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: email, Code: "123456", Type: models.OTPTypeEmail})
+	if !errors.Is(err, apperrors.ErrValidation) || errors.Is(err, ErrInvalidOTP) {
+		t.Fatalf("expected a length validation error, got %v", err)
+	}
+	code = regexp.MustCompile(`\d{8}`).FindString(outbox.Messages(email)[0].Text)
+	if err := svc.VerifyOTP(ctx, models.OTPVerificationRequest{Identifier: email, Code: code, Type: models.OTPTypeEmail}); err != nil {
+		t.Fatalf("VerifyOTP error: %v", err)
+	}
+}
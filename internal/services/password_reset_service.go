@@ -3,71 +3,169 @@ package services
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
 	"time"
-	"math/big"
 
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
 	"github.com/codeZe-us/vestroll-backend/internal/repository"
-	"github.com/codeZe-us/vestroll-backend/internal/services/email_service"
-	"github.com/codeZe-us/vestroll-backend/internal/services/sms_service"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
 )
 
+var (
+	ErrInvalidResetCode  = apperrors.Validation("invalid or expired code").WithCode("invalid_code")
+	ErrTooManyAttempts   = apperrors.RateLimited("maximum verification attempts exceeded").WithCode("max_attempts_exceeded")
+	ErrInvalidResetToken = apperrors.Validation("invalid or expired reset token").WithCode("invalid_token")
+)
+
+// PasswordResetService issues reset codes over SMS/email and exchanges a verified
+// code for a single-use reset token. Code generation, delivery and send limits
+// are shared with OTPService.
 type PasswordResetService struct {
-	Repo         *repository.PasswordResetRepository
-	EmailService *email_service.EmailService
-	SMSService   *sms_service.SMSService
-	TTL          time.Duration
+	repo       *repository.PasswordResetRepository
+	dispatcher *CodeDispatcher
+	// sends applies the OTP cooldown, send limit and SMS guard to reset codes
+	sends  *OTPService
+	config config.PasswordResetConfig
 }
 
-func NewPasswordResetService(repo *repository.PasswordResetRepository, emailSvc *email_service.EmailService, smsSvc *sms_service.SMSService, ttl time.Duration) *PasswordResetService {
+func NewPasswordResetService(
+	repo *repository.PasswordResetRepository,
+	dispatcher *CodeDispatcher,
+	sends *OTPService,
+	config config.PasswordResetConfig,
+) *PasswordResetService {
 	return &PasswordResetService{
-		Repo:         repo,
-		EmailService: emailSvc,
-		SMSService:   smsSvc,
-		TTL:          ttl,
+		repo:       repo,
+		dispatcher: dispatcher,
+		sends:      sends,
+		config:     config,
+	}
+}
+
+// TokenTTL is how long a reset token stays valid after verification
+func (s *PasswordResetService) TokenTTL() time.Duration {
+	return s.config.TokenTTL
+}
+
+// ValidateChannel checks that the identifier is a phone number for the sms
+// channel or an email address for the email channel
+func (s *PasswordResetService) ValidateChannel(identifier string, channel models.OTPType) error {
+	return validateIdentifier(strings.TrimSpace(identifier), channel)
+}
+
+// AdmitSend applies the OTP resend cooldown, per-identifier send limit and SMS
+// guard to a reset request. It runs whether or not the account exists, so its
+// answer does not reveal one; release lifts the cooldown when no code went out.
+func (s *PasswordResetService) AdmitSend(ctx context.Context, identifier string, channel models.OTPType, challenge string) (release func(), err error) {
+	identifier = strings.TrimSpace(identifier)
+	if err := validateIdentifier(identifier, channel); err != nil {
+		return nil, err
 	}
+	if channel == models.OTPTypeEmail {
+		identifier = strings.ToLower(identifier)
+	}
+	return s.sends.admitSend(ctx, identifier, channel, challenge)
 }
 
-func (s *PasswordResetService) GenerateAndSendResetCode(ctx context.Context, identifier, channel string) (string, error) {
-	code, err := generateCode(6)
+// SendResetCode generates a code for the identifier and delivers it over the channel;
+// locale is a locale tag or Accept-Language value used when the user has no saved preference.
+// Callers admit the send with AdmitSend first.
+func (s *PasswordResetService) SendResetCode(ctx context.Context, identifier string, channel models.OTPType, locale string) error {
+	if err := validateIdentifier(identifier, channel); err != nil {
+		return err
+	}
+	// No point sending a code that cannot be tried
+	attempts, err := s.repo.Attempts(ctx, identifier)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("failed to read attempt counter: %w", err)
 	}
-	if err := s.Repo.StoreResetCode(ctx, identifier, code); err != nil {
-		return "", err
+	if attempts >= s.config.MaxAttempts {
+		return ErrTooManyAttempts
 	}
-	if channel == "email" {
-		if err := s.EmailService.SendOTP(ctx, identifier, code); err != nil {
-			return "", err
-		}
-	} else if channel == "sms" {
-		if err := s.SMSService.SendOTP(ctx, identifier, code); err != nil {
-			return "", err
-		}
+	code, err := generateOTPCode(s.config.CodeLength)
+	if err != nil {
+		return fmt.Errorf("failed to generate reset code: %w", err)
 	}
-	return code, nil
+	if err := s.repo.StoreResetCode(ctx, identifier, code); err != nil {
+		return fmt.Errorf("failed to store reset code: %w", err)
+	}
+	if _, err := s.dispatcher.send(ctx, channel, identifier, code, models.OTPPurposePasswordReset, s.config.TTL, locale); err != nil {
+		// Clean up stored code on send failure
+		s.repo.DeleteResetCode(ctx, identifier)
+		return err
+	}
+	return nil
 }
 
-func (s *PasswordResetService) VerifyResetCode(ctx context.Context, identifier, code string) (bool, error) {
-	stored, err := s.Repo.GetResetCode(ctx, identifier)
+// VerifyResetCode checks the code and, on success, exchanges it for a single-use
+// reset token. Every attempt counts towards MaxAttempts, across resends; once exceeded
+// the code is deleted and the identifier stays locked until the count expires.
+func (s *PasswordResetService) VerifyResetCode(ctx context.Context, identifier, code string) (string, error) {
+	if len(code) != s.config.CodeLength {
+		return "", ErrInvalidResetCode
+	}
+	stored, err := s.repo.GetResetCode(ctx, identifier)
 	if err != nil {
-		return false, err
+		return "", fmt.Errorf("failed to retrieve reset code: %w", err)
+	}
+	if stored == "" {
+		return "", ErrInvalidResetCode
 	}
-	return stored == code, nil
+	attempts, err := s.repo.IncrementAttempts(ctx, identifier)
+	if err != nil {
+		return "", fmt.Errorf("failed to update attempt counter: %w", err)
+	}
+	if attempts > s.config.MaxAttempts {
+		s.repo.DeleteResetCode(ctx, identifier)
+		return "", ErrTooManyAttempts
+	}
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(code)) != 1 {
+		return "", ErrInvalidResetCode
+	}
+	if err := s.repo.DeleteResetCode(ctx, identifier); err != nil {
+		return "", fmt.Errorf("failed to clean up reset code: %w", err)
+	}
+	if err := s.repo.ClearAttempts(ctx, identifier); err != nil {
+		return "", fmt.Errorf("failed to clean up reset code: %w", err)
+	}
+
+	token, err := generateResetToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	if err := s.repo.StoreResetToken(ctx, hashResetToken(token), identifier, s.config.TokenTTL); err != nil {
+		return "", fmt.Errorf("failed to store reset token: %w", err)
+	}
+	return token, nil
+}
+
+// ConsumeResetToken redeems a reset token, returning the identifier it was issued for
+func (s *PasswordResetService) ConsumeResetToken(ctx context.Context, token string) (string, error) {
+	identifier, err := s.repo.ConsumeResetToken(ctx, hashResetToken(token))
+	if err != nil {
+		return "", fmt.Errorf("failed to redeem reset token: %w", err)
+	}
+	if identifier == "" {
+		return "", ErrInvalidResetToken
+	}
+	return identifier, nil
 }
 
-func (s *PasswordResetService) DeleteResetCode(ctx context.Context, identifier string) error {
-	return s.Repo.DeleteResetCode(ctx, identifier)
+func generateResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func generateCode(length int) (string, error) {
-	numbers := "0123456789"
-	code := ""
-	for i := 0; i < length; i++ {
-		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(numbers))) )
-		if err != nil {
-			return "", err
-		}
-		code += string(numbers[num.Int64()])
-	}
-	return code, nil
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/codeZe-us/vestroll-backend/internal/services/notifier"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+	redis "github.com/go-redis/redis/v8"
+)
+
+func setupPasswordResetService(t *testing.T, cfg config.PasswordResetConfig) (*PasswordResetService, *repository.PasswordResetRepository) {
+	mini, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(mini.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: mini.Addr()})
+	repo := repository.NewPasswordResetRepository(rdb, cfg.TTL)
+	otpCfg := testOTPConfig
+	otpCfg.ResendCooldown = time.Minute
+	sends := NewOTPService(repository.NewOTPRepository(rdb, otpCfg.TTL), newTestDispatcher(t, notifier.Router{}), nil, otpCfg)
+	svc := NewPasswordResetService(repo, newTestDispatcher(t, notifier.Router{}), sends, cfg)
+	return svc, repo
+}
+
+var testResetConfig = config.PasswordResetConfig{CodeLength: 6, TTL: 5 * time.Minute, MaxAttempts: 3, TokenTTL: 10 * time.Minute}
+
+func TestVerifyResetCodeIssuesSingleUseToken(t *testing.T) {
+	svc, repo := setupPasswordResetService(t, testResetConfig)
+	ctx := context.Background()
+	if err := repo.StoreResetCode(ctx, "a@example.com", "123456"); err != nil {
+		t.Fatalf("StoreResetCode error: %v", err)
+	}
+
+	token, err := svc.VerifyResetCode(ctx, "a@example.com", "123456")
+	if err != nil {
+		t.Fatalf("VerifyResetCode error: %v", err)
+	}
+	if _, err := svc.VerifyResetCode(ctx, "a@example.com", "123456"); !errors.Is(err, ErrInvalidResetCode) {
+		t.Fatalf("expected code to be single-use, got %v", err)
+	}
+
+	identifier, err := svc.ConsumeResetToken(ctx, token)
+	if err != nil || identifier != "a@example.com" {
+		t.Fatalf("expected token for a@example.com, got %q %v", identifier, err)
+	}
+	if _, err := svc.ConsumeResetToken(ctx, token); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expected token to be single-use, got %v", err)
+	}
+}
+
+func TestVerifyResetCodeAttemptLimit(t *testing.T) {
+	cfg := testResetConfig
+	cfg.MaxAttempts = 2
+	svc, repo := setupPasswordResetService(t, cfg)
+	ctx := context.Background()
+	if err := repo.StoreResetCode(ctx, "a@example.com", "123456"); err != nil {
+		t.Fatalf("StoreResetCode error: %v", err)
+	}
+
+	for i := 0; i < cfg.MaxAttempts; i++ {
+		if _, err := svc.VerifyResetCode(ctx, "a@example.com", "000000"); !errors.Is(err, ErrInvalidResetCode) {
+			t.Fatalf("attempt %d: expected ErrInvalidResetCode, got %v", i+1, err)
+		}
+	}
+	if _, err := svc.VerifyResetCode(ctx, "a@example.com", "123456"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts once the cap is reached, got %v", err)
+	}
+	if _, err := svc.VerifyResetCode(ctx, "a@example.com", "123456"); !errors.Is(err, ErrInvalidResetCode) {
+		t.Fatalf("expected code to be deleted after too many attempts, got %v", err)
+	}
+}
+
+func TestResetAttemptsSurviveResends(t *testing.T) {
+	cfg := testResetConfig
+	cfg.MaxAttempts = 2
+	svc, repo := setupPasswordResetService(t, cfg)
+	ctx := context.Background()
+
+	for i := 0; i < cfg.MaxAttempts; i++ {
+		// A fresh code does not buy fresh guesses
+		repo.StoreResetCode(ctx, "a@example.com", "123456")
+		if _, err := svc.VerifyResetCode(ctx, "a@example.com", "000000"); !errors.Is(err, ErrInvalidResetCode) {
+			t.Fatalf("attempt %d: expected ErrInvalidResetCode, got %v", i+1, err)
+		}
+	}
+	repo.StoreResetCode(ctx, "a@example.com", "123456")
+	if _, err := svc.VerifyResetCode(ctx, "a@example.com", "123456"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts after a resend, got %v", err)
+	}
+	if err := svc.SendResetCode(ctx, "a@example.com", models.OTPTypeEmail, ""); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected no code to be sent while locked, got %v", err)
+	}
+}
+
+func TestAdmitSendAppliesOTPSendLimits(t *testing.T) {
+	svc, _ := setupPasswordResetService(t, testResetConfig)
+	ctx := context.Background()
+
+	if _, err := svc.AdmitSend(ctx, "not-an-email", models.OTPTypeEmail, ""); apperrors.KindOf(err) != apperrors.KindValidation {
+		t.Fatalf("expected identifier validation error, got %v", err)
+	}
+	release, err := svc.AdmitSend(ctx, "a@example.com", models.OTPTypeEmail, "")
+	if err != nil {
+		t.Fatalf("AdmitSend error: %v", err)
+	}
+	// Case and spacing do not open a separate cooldown
+	if _, err := svc.AdmitSend(ctx, " A@Example.com ", models.OTPTypeEmail, ""); apperrors.KindOf(err) != apperrors.KindRateLimited {
+		t.Fatalf("expected resend cooldown, got %v", err)
+	}
+	release()
+	if _, err := svc.AdmitSend(ctx, "a@example.com", models.OTPTypeEmail, ""); err != nil {
+		t.Fatalf("expected release to lift the cooldown, got %v", err)
+	}
+}
+
+func TestSendResetCodeHonoursConfigAndCleansUpOnFailure(t *testing.T) {
+	cfg := testResetConfig
+	cfg.CodeLength = 8
+	svc, repo := setupPasswordResetService(t, cfg)
+	ctx := context.Background()
+
+	if err := svc.SendResetCode(ctx, "not-an-email", models.OTPTypeEmail, ""); err == nil {
+		t.Fatalf("expected identifier validation error")
+	}
+	// Email is unconfigured in tests, so delivery fails and the code must not linger
+	if err := svc.SendResetCode(ctx, "a@example.com", models.OTPTypeEmail, ""); err == nil {
+		t.Fatalf("expected delivery error with unconfigured email service")
+	}
+	if code, _ := repo.GetResetCode(ctx, "a@example.com"); code != "" {
+		t.Fatalf("expected code to be cleaned up after failed delivery, got %q", code)
+	}
+
+	if err := repo.StoreResetCode(ctx, "a@example.com", "12345678"); err != nil {
+		t.Fatalf("StoreResetCode error: %v", err)
+	}
+	if _, err := svc.VerifyResetCode(ctx, "a@example.com", "123456"); !errors.Is(err, ErrInvalidResetCode) {
+		t.Fatalf("expected wrong-length code to be rejected, got %v", err)
+	}
+	if _, err := svc.VerifyResetCode(ctx, "a@example.com", "12345678"); err != nil {
+		t.Fatalf("expected configured-length code to verify, got %v", err)
+	}
+}
+
+func TestValidateChannelMatchesIdentifierKind(t *testing.T) {
+	svc, _ := setupPasswordResetService(t, testResetConfig)
+	cases := []struct {
+		identifier string
+		channel    models.OTPType
+		ok         bool
+	}{
+		{"a@example.com", models.OTPTypeEmail, true},
+		{" A@Example.com ", models.OTPTypeEmail, true},
+		{"+2348012345678", models.OTPTypeSMS, true},
+		{"a@example.com", models.OTPTypeSMS, false},
+		{"+2348012345678", models.OTPTypeEmail, false},
+	}
+	for _, tc := range cases {
+		if err := svc.ValidateChannel(tc.identifier, tc.channel); (err == nil) != tc.ok {
+			t.Errorf("ValidateChannel(%q, %s) = %v; want ok=%v", tc.identifier, tc.channel, err, tc.ok)
+		}
+	}
+}
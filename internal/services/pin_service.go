@@ -2,84 +2,348 @@ package services
 
 import (
 	"context"
-	"crypto/rand"
+	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
-	"errors"
+	"fmt"
+	"log"
+	"math"
 	"regexp"
+	"strings"
 	"time"
 
+	"github.com/codeZe-us/vestroll-backend/internal/config"
 	"github.com/codeZe-us/vestroll-backend/internal/models"
 	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	"github.com/codeZe-us/vestroll-backend/internal/utils"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
 )
 
-// PINService encapsulates PIN setup and authentication logic
+var (
+	ErrPINNotSet  = apperrors.NotFound("pin not set")
+	ErrInvalidPIN = apperrors.Unauthorized("invalid pin").WithCode("invalid_pin")
+	// ErrPINExists is returned by setup when a PIN is already set
+	ErrPINExists = apperrors.Conflict("pin already set; use change-pin or reset-pin").WithCode("pin_exists")
+	// ErrWeakPIN is returned for PINs an attacker would try first
+	ErrWeakPIN = apperrors.Validation("pin is too easy to guess; avoid repeated or sequential digits and your birth date").WithCode("weak_pin")
+	// ErrPINResetNotVerified is returned when the verified phone or email is not the user's
+	ErrPINResetNotVerified = apperrors.Forbidden("a pin_reset verification for your phone or email is required").WithCode("verification_required")
+)
+
+// PINService encapsulates PIN setup and authentication logic.
+// Wrong PINs are counted per user: after FreeAttempts each failure doubles the
+// wait before the next try, and MaxAttempts lock the PIN for PINConfig.Lockout.
 type PINService struct {
-	repo *repository.PinRepository
+	repo     repository.PINStore
+	attempts repository.PINAttemptStore
+	users    repository.UserStore
+	profiles repository.ProfileStore
+	config   config.PINConfig
+	now      func() time.Time
 }
 
-func NewPINService(repo *repository.PinRepository) *PINService {
-	return &PINService{repo: repo}
+func NewPINService(repo repository.PINStore, attempts repository.PINAttemptStore, users repository.UserStore, profiles repository.ProfileStore, cfg config.PINConfig) *PINService {
+	return &PINService{repo: repo, attempts: attempts, users: users, profiles: profiles, config: cfg, now: time.Now}
 }
 
 // ValidatePINFormat ensures PIN is 4-6 digits numeric
 func (s *PINService) ValidatePINFormat(pin string) error {
 	if len(pin) < 4 || len(pin) > 6 {
-		return errors.New("pin must be 4 to 6 digits")
+		return apperrors.Validation("pin must be 4 to 6 digits")
 	}
 	matched, _ := regexp.MatchString("^[0-9]+$", pin)
 	if !matched {
-		return errors.New("pin must contain only digits")
+		return apperrors.Validation("pin must contain only digits")
 	}
 	return nil
 }
 
-func generateSalt(n int) (string, error) {
-	b := make([]byte, n)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
+// legacyHashPIN is the original unpeppered format, kept to check and migrate old entries
+func legacyHashPIN(pin, salt string) string {
+	sum := sha256.Sum256([]byte(pin + ":" + salt))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashParams converts the configured Argon2id cost
+func (s *PINService) hashParams() utils.Argon2Params {
+	h := s.config.Hash
+	return utils.Argon2Params{Time: uint32(h.Time), Memory: uint32(h.Memory), Threads: uint8(h.Threads), KeyLen: 32}
+}
+
+// pepper keys the PIN with the server-side secret before the slow hash, so a
+// leaked store cannot be brute-forced without also stealing the pepper
+func (s *PINService) pepper(pin string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.Pepper))
+	mac.Write([]byte(pin))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashPIN derives the current PIN format
+func (s *PINService) hashPIN(pin string) (models.PinData, error) {
+	hash, err := utils.HashArgon2id(s.pepper(pin), s.hashParams())
+	if err != nil {
+		return models.PinData{}, err
 	}
-	return hex.EncodeToString(b), nil
+	return models.PinData{Version: models.PINHashArgon2id, Hash: hash, CreatedAt: s.now()}, nil
 }
 
-func hashPIN(pin, salt string) string {
-	sum := sha256.Sum256([]byte(pin + ":" + salt))
-	return hex.EncodeToString(sum[:])
+// matchPIN checks pin against stored and reports whether the entry should be
+// rewritten in the current format with the current parameters
+func (s *PINService) matchPIN(pin string, stored *models.PinData) (match, rehash bool, err error) {
+	switch stored.Version {
+	case models.PINHashLegacySHA256:
+		candidate := legacyHashPIN(pin, stored.Salt)
+		return subtle.ConstantTimeCompare([]byte(candidate), []byte(stored.Hash)) == 1, true, nil
+	case models.PINHashArgon2id:
+		ok, params, err := utils.VerifyArgon2id(s.pepper(pin), stored.Hash)
+		if err != nil {
+			return false, false, err
+		}
+		return ok, params != s.hashParams(), nil
+	default:
+		return false, false, fmt.Errorf("unknown pin hash version %d", stored.Version)
+	}
 }
 
-// SetupPIN validates and stores the user's PIN (salted+hashed)
+// SetupPIN validates and stores the user's first PIN (salted+hashed).
+// An existing PIN can only be replaced through ChangePIN or ResetPIN.
 func (s *PINService) SetupPIN(ctx context.Context, req models.SetupPINRequest) error {
 	if req.UserID == "" {
-		return errors.New("user_id is required")
+		return apperrors.Validation("user_id is required")
 	}
 	if err := s.ValidatePINFormat(req.PIN); err != nil {
 		return err
 	}
-	salt, err := generateSalt(16)
+	stored, err := s.repo.Get(ctx, req.UserID)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to load pin: %w", err)
+	}
+	if stored != nil {
+		return ErrPINExists
+	}
+	return s.save(ctx, req.UserID, req.PIN)
+}
+
+// Enabled reports whether the user has set a PIN, so elevated routes know to ask for it
+func (s *PINService) Enabled(ctx context.Context, userID string) (bool, error) {
+	stored, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load pin: %w", err)
 	}
-	hash := hashPIN(req.PIN, salt)
-	data := models.PinData{Salt: salt, Hash: hash, CreatedAt: time.Now()}
-	return s.repo.Save(ctx, req.UserID, data)
+	return stored != nil, nil
 }
 
 // LoginPIN verifies the provided PIN against stored hash
 func (s *PINService) LoginPIN(ctx context.Context, req models.LoginPINRequest) error {
 	if req.UserID == "" {
-		return errors.New("user_id is required")
+		return apperrors.Validation("user_id is required")
 	}
-	if err := s.ValidatePINFormat(req.PIN); err != nil {
+	return s.VerifyPIN(ctx, req.UserID, req.PIN)
+}
+
+// VerifyPIN checks the user's PIN, e.g. as a step-up before a sensitive
+// action; it shares LoginPIN's attempt limits
+func (s *PINService) VerifyPIN(ctx context.Context, userID, pin string) error {
+	if err := s.ValidatePINFormat(pin); err != nil {
 		return err
 	}
-	stored, err := s.repo.Get(ctx, req.UserID)
+	stored, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load pin: %w", err)
+	}
+	if stored == nil {
+		return ErrPINNotSet
+	}
+	return s.verify(ctx, userID, stored, pin)
+}
+
+// ChangePIN replaces the PIN after checking the current one, which counts
+// towards the same attempt limits as LoginPIN
+func (s *PINService) ChangePIN(ctx context.Context, userID string, req models.ChangePINRequest) error {
+	if err := s.ValidatePINFormat(req.CurrentPIN); err != nil {
+		return err
+	}
+	if err := s.ValidatePINFormat(req.NewPIN); err != nil {
+		return err
+	}
+	stored, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load pin: %w", err)
+	}
+	if stored == nil {
+		return ErrPINNotSet
+	}
+	if err := s.verify(ctx, userID, stored, req.CurrentPIN); err != nil {
+		return err
+	}
+	if req.NewPIN == req.CurrentPIN {
+		return apperrors.Validation("new pin must differ from the current pin")
+	}
+	return s.save(ctx, userID, req.NewPIN)
+}
+
+// ResetPIN sets a new PIN for a user who forgot theirs. verifiedIdentifier is
+// the phone or email proven by a pin_reset OTP and must belong to the user.
+// Resetting also lifts any lockout.
+func (s *PINService) ResetPIN(ctx context.Context, userID, verifiedIdentifier, newPIN string) error {
+	if err := s.ValidatePINFormat(newPIN); err != nil {
+		return err
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+	if user == nil || verifiedIdentifier == "" ||
+		(verifiedIdentifier != user.Phone && !strings.EqualFold(verifiedIdentifier, user.Email)) {
+		return ErrPINResetNotVerified
+	}
+	if err := s.save(ctx, userID, newPIN); err != nil {
+		return err
+	}
+	if err := s.attempts.ClearAttempts(ctx, userID); err != nil {
+		return fmt.Errorf("failed to clear pin attempts: %w", err)
+	}
+	return nil
+}
+
+// save checks the PIN is not guessable, then stores it
+func (s *PINService) save(ctx context.Context, userID, pin string) error {
+	if err := s.checkPINStrength(ctx, userID, pin); err != nil {
+		return err
+	}
+	data, err := s.hashPIN(pin)
+	if err != nil {
+		return err
+	}
+	return s.repo.Save(ctx, userID, data)
+}
+
+// verify compares pin with the stored hash unless the user must wait. The
+// attempt is counted before the comparison so parallel guesses cannot skip
+// the wait, and the count is cleared on success. Entries in an older format
+// or with other Argon2id costs are rehashed once the PIN is known to be right.
+func (s *PINService) verify(ctx context.Context, userID string, stored *models.PinData, pin string) error {
+	now := s.now()
+	attempts, reserved, err := s.attempts.ReserveAttempt(ctx, userID, now, s.config.AttemptWindow, s.waits())
+	if err != nil {
+		return fmt.Errorf("failed to record pin attempt: %w", err)
+	}
+	if !reserved {
+		return pinLockedError(s.retryAfter(attempts, now), attempts.Failures >= s.config.MaxAttempts)
+	}
+	match, rehash, err := s.matchPIN(pin, stored)
+	if err != nil {
+		return fmt.Errorf("failed to check pin: %w", err)
+	}
+	if !match {
+		return ErrInvalidPIN
+	}
+	if err := s.attempts.ClearAttempts(ctx, userID); err != nil {
+		return fmt.Errorf("failed to clear pin attempts: %w", err)
+	}
+	if rehash {
+		if err := s.rehash(ctx, userID, stored, pin); err != nil {
+			log.Printf("failed to rehash pin for user %s: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// rehash stores pin in the current format, keeping its original creation time
+func (s *PINService) rehash(ctx context.Context, userID string, stored *models.PinData, pin string) error {
+	data, err := s.hashPIN(pin)
+	if err != nil {
+		return err
+	}
+	data.CreatedAt = stored.CreatedAt
+	return s.repo.Save(ctx, userID, data)
+}
+
+// waitAfter is how long the user must wait after the last of failures wrong PINs
+func (s *PINService) waitAfter(failures int) time.Duration {
+	switch {
+	case failures >= s.config.MaxAttempts:
+		return s.config.Lockout
+	case failures >= s.config.FreeAttempts:
+		return s.config.BaseDelay << (failures - s.config.FreeAttempts)
+	default:
+		return 0
+	}
+}
+
+// waits lists waitAfter for 0 through MaxAttempts failures, as ReserveAttempt takes them
+func (s *PINService) waits() []time.Duration {
+	waits := make([]time.Duration, 0, s.config.MaxAttempts+1)
+	for failures := 0; failures <= s.config.MaxAttempts; failures++ {
+		waits = append(waits, s.waitAfter(failures))
+	}
+	return waits
+}
+
+// retryAfter is how long the user must wait before trying another PIN
+func (s *PINService) retryAfter(attempts models.PINAttempts, now time.Time) time.Duration {
+	if remaining := attempts.LastFailure.Add(s.waitAfter(attempts.Failures)).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+func pinLockedError(wait time.Duration, locked bool) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if locked {
+		return apperrors.RateLimited(fmt.Sprintf("pin locked after too many incorrect attempts; reset your pin or try again in %d seconds", seconds)).WithCode("pin_locked")
+	}
+	return apperrors.RateLimited(fmt.Sprintf("too many incorrect pin attempts; try again in %d seconds", seconds)).WithCode("pin_retry_later")
+}
+
+// checkPINStrength rejects repeated and sequential PINs and ones built from the
+// date of birth in the user's profile
+func (s *PINService) checkPINStrength(ctx context.Context, userID, pin string) error {
+	if isTrivialPIN(pin) {
+		return ErrWeakPIN
+	}
+	profile, err := s.profiles.Get(ctx, userID)
 	if err != nil {
-		return errors.New("pin not set")
+		return fmt.Errorf("failed to load profile: %w", err)
 	}
-	candidate := hashPIN(req.PIN, stored.Salt)
-	if subtle.ConstantTimeCompare([]byte(candidate), []byte(stored.Hash)) != 1 {
-		return errors.New("invalid pin")
+	if profile != nil && profile.Personal != nil && matchesBirthDate(pin, profile.Personal.DateOfBirth) {
+		return ErrWeakPIN
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// isTrivialPIN reports PINs made of one repeated block (0000, 1212, 123123)
+// or a run of consecutive digits, wrapping past 9 as on a keypad (1234, 98765, 7890)
+func isTrivialPIN(pin string) bool {
+	for size := 1; size <= len(pin)/2; size++ {
+		if len(pin)%size == 0 && strings.Repeat(pin[:size], len(pin)/size) == pin {
+			return true
+		}
+	}
+	ascending, descending := true, true
+	for i := 1; i < len(pin); i++ {
+		step := (int(pin[i]) - int(pin[i-1]) + 10) % 10
+		ascending = ascending && step == 1
+		descending = descending && step == 9
+	}
+	return ascending || descending
+}
+
+// matchesBirthDate reports PINs containing the birth year or equal to the
+// day and month (or day, month and two-digit year) in common orders
+func matchesBirthDate(pin, dateOfBirth string) bool {
+	dob, err := time.Parse("2006-01-02", dateOfBirth)
+	if err != nil {
+		return false
+	}
+	if strings.Contains(pin, dob.Format("2006")) {
+		return true
+	}
+	for _, layout := range []string{"0201", "0102", "020106", "010206", "060102"} {
+		if pin == dob.Format(layout) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
+	"github.com/codeZe-us/vestroll-backend/internal/utils"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+var testPINConfig = config.PINConfig{
+	FreeAttempts:  3,
+	BaseDelay:     30 * time.Second,
+	MaxAttempts:   5,
+	Lockout:       time.Hour,
+	AttemptWindow: 24 * time.Hour,
+	Pepper:        "test-pepper",
+	// Cheap enough for tests; production defaults are far higher
+	Hash: config.Argon2Config{Time: 1, Memory: 64, Threads: 1},
+}
+
+func newTestPINService(t *testing.T) (*PINService, *time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	users := memory.NewUserRepository()
+	if err := users.Create(ctx, models.User{ID: "u1", Email: "ada@example.com", Phone: "+2348012345678"}); err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+	profiles := memory.NewProfileRepository()
+	profiles.Save(ctx, models.UserProfile{UserID: "u1", Personal: &models.PersonalDetails{DateOfBirth: "1990-07-25"}})
+
+	clock := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc := NewPINService(memory.NewPinRepository(), memory.NewPINAttempts(), users, profiles, testPINConfig)
+	svc.now = func() time.Time { return clock }
+	return svc, &clock
+}
+
+func errorCode(err error) string {
+	if appErr, ok := apperrors.As(err); ok {
+		return appErr.ResponseCode()
+	}
+	return ""
+}
+
+func TestSetupPINRejectsWeakPINsAndOverwrites(t *testing.T) {
+	svc, _ := newTestPINService(t)
+	ctx := context.Background()
+
+	for _, pin := range []string{"0000", "1234", "4321", "1212", "123123", "567890", "1990", "2507", "0725", "250790", "482613"} {
+		wantWeak := pin != "482613"
+		err := svc.SetupPIN(ctx, models.SetupPINRequest{UserID: "u1", PIN: pin})
+		if wantWeak && !errors.Is(err, ErrWeakPIN) {
+			t.Fatalf("SetupPIN(%s) = %v, want ErrWeakPIN", pin, err)
+		}
+		if !wantWeak && err != nil {
+			t.Fatalf("SetupPIN(%s) = %v, want success", pin, err)
+		}
+	}
+	if err := svc.SetupPIN(ctx, models.SetupPINRequest{UserID: "u1", PIN: "4826"}); !errors.Is(err, ErrPINExists) {
+		t.Fatalf("SetupPIN over an existing PIN = %v, want ErrPINExists", err)
+	}
+	if err := svc.LoginPIN(ctx, models.LoginPINRequest{UserID: "u1", PIN: "482613"}); err != nil {
+		t.Fatalf("original PIN no longer works: %v", err)
+	}
+}
+
+func TestLoginPINDelaysThenLocksOut(t *testing.T) {
+	svc, clock := newTestPINService(t)
+	ctx := context.Background()
+	svc.SetupPIN(ctx, models.SetupPINRequest{UserID: "u1", PIN: "4826"})
+	wrong := models.LoginPINRequest{UserID: "u1", PIN: "9999"}
+	right := models.LoginPINRequest{UserID: "u1", PIN: "4826"}
+
+	for i := 0; i < testPINConfig.FreeAttempts; i++ {
+		if err := svc.LoginPIN(ctx, wrong); !errors.Is(err, ErrInvalidPIN) {
+			t.Fatalf("attempt %d = %v, want ErrInvalidPIN", i+1, err)
+		}
+	}
+	// The correct PIN must wait out the delay too
+	if err := svc.LoginPIN(ctx, right); errorCode(err) != "pin_retry_later" {
+		t.Fatalf("login during delay = %v, want pin_retry_later", err)
+	}
+	*clock = clock.Add(testPINConfig.BaseDelay)
+	svc.LoginPIN(ctx, wrong)
+	// The fifth failure doubles the delay
+	if err := svc.LoginPIN(ctx, wrong); errorCode(err) != "pin_retry_later" {
+		t.Fatalf("login before the doubled delay = %v, want pin_retry_later", err)
+	}
+	*clock = clock.Add(2 * testPINConfig.BaseDelay)
+	svc.LoginPIN(ctx, wrong)
+
+	if err := svc.LoginPIN(ctx, right); errorCode(err) != "pin_locked" {
+		t.Fatalf("login after %d failures = %v, want pin_locked", testPINConfig.MaxAttempts, err)
+	}
+	*clock = clock.Add(testPINConfig.Lockout)
+	if err := svc.LoginPIN(ctx, right); err != nil {
+		t.Fatalf("login after lockout = %v", err)
+	}
+	// Success clears the count
+	if err := svc.LoginPIN(ctx, wrong); !errors.Is(err, ErrInvalidPIN) {
+		t.Fatalf("failure after success = %v, want ErrInvalidPIN", err)
+	}
+	if err := svc.LoginPIN(ctx, right); err != nil {
+		t.Fatalf("second failure was delayed: %v", err)
+	}
+}
+
+func TestParallelPINGuessesCannotSkipTheDelay(t *testing.T) {
+	svc, _ := newTestPINService(t)
+	ctx := context.Background()
+	svc.SetupPIN(ctx, models.SetupPINRequest{UserID: "u1", PIN: "4826"})
+
+	var invalid, delayed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(pin string) {
+			defer wg.Done()
+			switch err := svc.VerifyPIN(ctx, "u1", pin); {
+			case errors.Is(err, ErrInvalidPIN):
+				atomic.AddInt32(&invalid, 1)
+			case errorCode(err) == "pin_retry_later":
+				atomic.AddInt32(&delayed, 1)
+			default:
+				t.Errorf("VerifyPIN(%s) = %v", pin, err)
+			}
+		}(fmt.Sprintf("%04d", 5000+i))
+	}
+	wg.Wait()
+	// Only the free attempts are compared; the rest wait like sequential guesses would
+	if invalid != int32(testPINConfig.FreeAttempts) || delayed != 20-int32(testPINConfig.FreeAttempts) {
+		t.Fatalf("%d guesses compared and %d delayed, want %d compared", invalid, delayed, testPINConfig.FreeAttempts)
+	}
+}
+
+func TestChangePIN(t *testing.T) {
+	svc, _ := newTestPINService(t)
+	ctx := context.Background()
+	svc.SetupPIN(ctx, models.SetupPINRequest{UserID: "u1", PIN: "4826"})
+
+	if err := svc.ChangePIN(ctx, "u1", models.ChangePINRequest{CurrentPIN: "1357", NewPIN: "7395"}); !errors.Is(err, ErrInvalidPIN) {
+		t.Fatalf("ChangePIN with wrong current PIN = %v, want ErrInvalidPIN", err)
+	}
+	if err := svc.ChangePIN(ctx, "u1", models.ChangePINRequest{CurrentPIN: "4826", NewPIN: "1111"}); !errors.Is(err, ErrWeakPIN) {
+		t.Fatalf("ChangePIN to a weak PIN = %v, want ErrWeakPIN", err)
+	}
+	if err := svc.ChangePIN(ctx, "u1", models.ChangePINRequest{CurrentPIN: "4826", NewPIN: "7395"}); err != nil {
+		t.Fatalf("ChangePIN error: %v", err)
+	}
+	if err := svc.LoginPIN(ctx, models.LoginPINRequest{UserID: "u1", PIN: "7395"}); err != nil {
+		t.Fatalf("new PIN rejected: %v", err)
+	}
+}
+
+func TestResetPINRequiresUsersVerifiedContactAndLiftsLockout(t *testing.T) {
+	svc, clock := newTestPINService(t)
+	ctx := context.Background()
+	svc.SetupPIN(ctx, models.SetupPINRequest{UserID: "u1", PIN: "4826"})
+	for i := 0; i < testPINConfig.MaxAttempts; i++ {
+		*clock = clock.Add(10 * time.Minute)
+		svc.LoginPIN(ctx, models.LoginPINRequest{UserID: "u1", PIN: "9999"})
+	}
+	if err := svc.LoginPIN(ctx, models.LoginPINRequest{UserID: "u1", PIN: "4826"}); errorCode(err) != "pin_locked" {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+
+	if err := svc.ResetPIN(ctx, "u1", "+2348000000000", "7395"); !errors.Is(err, ErrPINResetNotVerified) {
+		t.Fatalf("ResetPIN with someone else's phone = %v, want ErrPINResetNotVerified", err)
+	}
+	if err := svc.ResetPIN(ctx, "u1", "Ada@Example.com", "7395"); err != nil {
+		t.Fatalf("ResetPIN error: %v", err)
+	}
+	if err := svc.LoginPIN(ctx, models.LoginPINRequest{UserID: "u1", PIN: "7395"}); err != nil {
+		t.Fatalf("login after reset = %v", err)
+	}
+}
+
+func TestLoginPINRehashesLegacyAndOutdatedEntries(t *testing.T) {
+	svc, _ := newTestPINService(t)
+	ctx := context.Background()
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	svc.repo.Save(ctx, "u1", models.PinData{Salt: "abc", Hash: legacyHashPIN("4826", "abc"), CreatedAt: created})
+
+	// A wrong PIN leaves the legacy entry alone
+	svc.LoginPIN(ctx, models.LoginPINRequest{UserID: "u1", PIN: "9999"})
+	if stored, _ := svc.repo.Get(ctx, "u1"); stored.Version != models.PINHashLegacySHA256 {
+		t.Fatalf("legacy entry rewritten after a wrong PIN: %+v", stored)
+	}
+
+	if err := svc.LoginPIN(ctx, models.LoginPINRequest{UserID: "u1", PIN: "4826"}); err != nil {
+		t.Fatalf("legacy PIN rejected: %v", err)
+	}
+	stored, _ := svc.repo.Get(ctx, "u1")
+	if stored.Version != models.PINHashArgon2id || stored.Salt != "" || !strings.HasPrefix(stored.Hash, "$argon2id$") || !stored.CreatedAt.Equal(created) {
+		t.Fatalf("legacy entry not rehashed: %+v", stored)
+	}
+	// The hash is of the peppered PIN, not the PIN itself
+	if ok, _, _ := utils.VerifyArgon2id("4826", stored.Hash); ok {
+		t.Fatalf("stored hash verifies without the pepper")
+	}
+
+	// Raising the cost rehashes on the next login
+	svc.config.Hash.Time = 2
+	if err := svc.LoginPIN(ctx, models.LoginPINRequest{UserID: "u1", PIN: "4826"}); err != nil {
+		t.Fatalf("login after cost change: %v", err)
+	}
+	if stored, _ := svc.repo.Get(ctx, "u1"); !strings.Contains(stored.Hash, ",t=2,") {
+		t.Fatalf("hash not upgraded to the new cost: %s", stored.Hash)
+	}
+}
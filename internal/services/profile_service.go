@@ -8,12 +8,14 @@ import (
 
     "github.com/codeZe-us/vestroll-backend/internal/models"
     "github.com/codeZe-us/vestroll-backend/internal/repository"
+    apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+    "golang.org/x/text/language"
 )
 
 // ProfileService orchestrates validation and persistence for the onboarding profile
 
 type ProfileService struct {
-    repo *repository.ProfileRepository
+    repo repository.ProfileStore
 }
 
 // GetProfile returns the current profile for a user (or an empty initialized one)
@@ -24,7 +26,7 @@ func (s *ProfileService) GetProfile(ctx context.Context, userID string) (models.
     return models.UserProfile{UserID: userID}, nil
 }
 
-func NewProfileService(repo *repository.ProfileRepository) *ProfileService {
+func NewProfileService(repo repository.ProfileStore) *ProfileService {
     return &ProfileService{repo: repo}
 }
 
@@ -44,7 +46,7 @@ func (s *ProfileService) UpdatePersonalDetails(ctx context.Context, req models.P
     if err := validateDialCode(req.Data.DialCode); err != nil { return models.UserProfile{}, err }
     if err := validatePhone(req.Data.Phone); err != nil { return models.UserProfile{}, err }
     if req.Data.Gender != "" && !oneOf(strings.ToLower(req.Data.Gender), []string{"male","female","other"}) {
-        return models.UserProfile{}, ErrValidation("gender must be male, female, or other")
+        return models.UserProfile{}, apperrors.Validation("gender must be male, female, or other")
     }
 
     prof := s.getOrInit(ctx, req.UserID)
@@ -60,13 +62,13 @@ func (s *ProfileService) UpdatePersonalDetails(ctx context.Context, req models.P
 
 // UpdateAddress validates and stores address
 func (s *ProfileService) UpdateAddress(ctx context.Context, req models.AddressRequest) (models.UserProfile, error) {
-    if strings.TrimSpace(req.Data.Country) == "" { return models.UserProfile{}, ErrValidation("country is required") }
-    if strings.TrimSpace(req.Data.Street) == "" { return models.UserProfile{}, ErrValidation("street is required") }
-    if strings.TrimSpace(req.Data.City) == "" { return models.UserProfile{}, ErrValidation("city is required") }
+    if strings.TrimSpace(req.Data.Country) == "" { return models.UserProfile{}, apperrors.Validation("country is required") }
+    if strings.TrimSpace(req.Data.Street) == "" { return models.UserProfile{}, apperrors.Validation("street is required") }
+    if strings.TrimSpace(req.Data.City) == "" { return models.UserProfile{}, apperrors.Validation("city is required") }
     if req.Data.PostalCode != "" {
         // Allow simple alphanumeric 3-12
         if ok, _ := regexp.MatchString(`^[A-Za-z0-9\- ]{3,12}$`, req.Data.PostalCode); !ok {
-            return models.UserProfile{}, ErrValidation("postal_code format is invalid")
+            return models.UserProfile{}, apperrors.Validation("postal_code format is invalid")
         }
     }
 
@@ -79,6 +81,19 @@ func (s *ProfileService) UpdateAddress(ctx context.Context, req models.AddressRe
     return prof, nil
 }
 
+// UpdateLocale stores the preferred notification language as a canonical tag;
+// unsupported languages fall back to English when messages are rendered
+func (s *ProfileService) UpdateLocale(ctx context.Context, req models.LocaleRequest) (models.UserProfile, error) {
+    tag, err := language.Parse(strings.TrimSpace(req.Locale))
+    if err != nil { return models.UserProfile{}, apperrors.Validation("locale must be a language tag such as en or fr") }
+
+    prof := s.getOrInit(ctx, req.UserID)
+    prof.Locale = tag.String()
+    prof.UpdatedAt = time.Now()
+    if err := s.repo.Save(ctx, prof); err != nil { return models.UserProfile{}, err }
+    return prof, nil
+}
+
 func (s *ProfileService) getOrInit(ctx context.Context, userID string) models.UserProfile {
     existing, _ := s.repo.Get(ctx, userID)
     if existing != nil { return *existing }
@@ -99,28 +114,28 @@ func validateDOB(d string) error {
     // Expect YYYY-MM-DD and be in the past
     re := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
     if !re.MatchString(d) {
-        return ErrValidation("date_of_birth must be YYYY-MM-DD")
+        return apperrors.Validation("date_of_birth must be YYYY-MM-DD")
     }
     t, err := time.Parse("2006-01-02", d)
-    if err != nil { return ErrValidation("date_of_birth parse error") }
-    if !t.Before(time.Now()) { return ErrValidation("date_of_birth must be in the past") }
+    if err != nil { return apperrors.Validation("date_of_birth parse error") }
+    if !t.Before(time.Now()) { return apperrors.Validation("date_of_birth must be in the past") }
     // Basic age check: at least 16 years old
     if t.After(time.Now().AddDate(-16, 0, 0)) {
-        return ErrValidation("user must be at least 16 years old")
+        return apperrors.Validation("user must be at least 16 years old")
     }
     return nil
 }
 
 func validateDialCode(dc string) error {
     if ok, _ := regexp.MatchString(`^\+\d{1,4}$`, dc); !ok {
-        return ErrValidation("dial_code must look like +234")
+        return apperrors.Validation("dial_code must look like +234")
     }
     return nil
 }
 
 func validatePhone(p string) error {
     if ok, _ := regexp.MatchString(`^[0-9]{7,20}$`, p); !ok {
-        return ErrValidation("phone must be 7-20 digits")
+        return apperrors.Validation("phone must be 7-20 digits")
     }
     return nil
 }
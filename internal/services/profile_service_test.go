@@ -4,20 +4,13 @@ import (
 	"context"
 	"testing"
 
-	"github.com/alicebob/miniredis/v2"
 	"github.com/codeZe-us/vestroll-backend/internal/models"
-	"github.com/codeZe-us/vestroll-backend/internal/repository"
-	redis "github.com/go-redis/redis/v8"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
 )
 
 func setupProfileService(t *testing.T) (*ProfileService, func()) {
-	mini, err := miniredis.Run()
-	if err != nil { t.Fatalf("failed to start miniredis: %v", err) }
-	rdb := redis.NewClient(&redis.Options{Addr: mini.Addr()})
-	repo := repository.NewProfileRepository(rdb, 0)
-	service := NewProfileService(repo)
-	cleanup := func() { mini.Close() }
-	return service, cleanup
+	service := NewProfileService(memory.NewProfileRepository())
+	return service, func() {}
 }
 
 func TestAccountTypeAndCompletion(t *testing.T) {
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/repository"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+// Protection against SMS pumping (toll fraud): attackers request codes to premium-rate
+// or sequential numbers they profit from. SMSGuard screens send-otp destinations by
+// country, caps hourly spend per calling code and overall, and watches for bursts across
+// consecutive numbers. Near those limits it asks for a challenge instead of refusing outright.
+
+var (
+	ErrSMSCountryBlocked = apperrors.Forbidden("SMS delivery to this country is not supported").WithCode("sms_country_blocked")
+	ErrSMSCapacity       = apperrors.RateLimited("SMS delivery to this region is temporarily limited. Try again later or use email").WithCode("sms_capacity")
+	ErrSMSBurst          = apperrors.RateLimited("too many codes requested for similar numbers. Try again later").WithCode("sms_burst")
+	ErrInvalidChallenge  = apperrors.Forbidden("challenge response is invalid or expired").WithCode("invalid_challenge")
+)
+
+// ChallengeVerifier checks the response to a human or proof-of-work challenge sent
+// with send-otp. Implementations can wrap a CAPTCHA provider's verification API.
+type ChallengeVerifier interface {
+	// Name identifies the challenge to clients, e.g. "pow" or "captcha"
+	Name() string
+	// Describe tells clients how to answer the challenge
+	Describe() string
+	Verify(ctx context.Context, identifier, response string) (bool, error)
+}
+
+// SMSGuard decides whether an SMS code may be sent to a number
+type SMSGuard struct {
+	store      repository.SMSUsageStore
+	challenger ChallengeVerifier
+	config     config.SMSGuardConfig
+	allowed    []string
+	blocked    []string
+	costs      map[string]int64
+	now        func() time.Time
+}
+
+// NewSMSGuard parses the guard config; challenger may be nil, in which case only
+// the hard limits apply
+func NewSMSGuard(store repository.SMSUsageStore, challenger ChallengeVerifier, cfg config.SMSGuardConfig) (*SMSGuard, error) {
+	g := &SMSGuard{
+		store:      store,
+		challenger: challenger,
+		config:     cfg,
+		allowed:    splitPrefixes(cfg.AllowedPrefixes),
+		blocked:    splitPrefixes(cfg.BlockedPrefixes),
+		costs:      map[string]int64{},
+		now:        time.Now,
+	}
+	for _, part := range strings.Split(cfg.Costs, ";") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		prefix, price, ok := strings.Cut(part, "=")
+		cost, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+		if !ok || err != nil || !strings.HasPrefix(strings.TrimSpace(prefix), "+") {
+			return nil, fmt.Errorf("SMS cost %q must look like +234=0.03", part)
+		}
+		g.costs[strings.TrimSpace(prefix)] = micros(cost)
+	}
+	return g, nil
+}
+
+// Check screens an SMS destination and reserves its cost against the hourly caps.
+// challenge is the client's answer to a challenge, if it sent one.
+func (g *SMSGuard) Check(ctx context.Context, number, challenge string) error {
+	if !g.config.Enabled {
+		return nil
+	}
+	// Callers validate numbers first; this keeps the prefix slicing below in bounds
+	if !phoneRegex.MatchString(number) {
+		return apperrors.Validation("invalid phone number format. Must be in international format (e.g., +1234567890)")
+	}
+	if (len(g.allowed) > 0 && !hasAnyPrefix(number, g.allowed)) || hasAnyPrefix(number, g.blocked) {
+		return ErrSMSCountryBlocked
+	}
+
+	now := g.now()
+	code := callingCode(number)
+	numberRange := number[:len(number)-2]
+	seen, err := g.store.TrackDestination(ctx, numberRange, number, now, g.config.BurstWindow)
+	if err != nil {
+		return fmt.Errorf("failed to track SMS destination: %w", err)
+	}
+	if g.config.BurstBlock > 0 && seen >= g.config.BurstBlock {
+		log.Printf("SMS guard: refusing burst of %d numbers in range %sXX", seen, numberRange)
+		return ErrSMSBurst
+	}
+
+	cost := g.cost(number)
+	prefixCap, globalCap := micros(g.config.PrefixHourlyCap), micros(g.config.GlobalHourlyCap)
+	prefixSpend, globalSpend, err := g.store.HourlySpend(ctx, code, now)
+	if err != nil {
+		return fmt.Errorf("failed to read SMS spend: %w", err)
+	}
+
+	suspicious := (g.config.BurstChallenge > 0 && seen >= g.config.BurstChallenge) ||
+		nearCap(prefixSpend+cost, prefixCap, g.config.ChallengePercent) ||
+		nearCap(globalSpend+cost, globalCap, g.config.ChallengePercent)
+	if suspicious && g.challenger != nil {
+		if challenge == "" {
+			return apperrors.Forbidden(fmt.Sprintf("complete a %s challenge before requesting a code: %s",
+				g.challenger.Name(), g.challenger.Describe())).WithCode("challenge_required")
+		}
+		ok, err := g.challenger.Verify(ctx, number, challenge)
+		if err != nil {
+			return fmt.Errorf("failed to verify challenge: %w", err)
+		}
+		if !ok {
+			return ErrInvalidChallenge
+		}
+	}
+
+	ok, err := g.store.AddSpend(ctx, code, cost, prefixCap, globalCap, now)
+	if err != nil {
+		return fmt.Errorf("failed to record SMS spend: %w", err)
+	}
+	if !ok {
+		log.Printf("SMS guard: hourly spend cap reached for +%s", code)
+		return ErrSMSCapacity
+	}
+	return nil
+}
+
+// cost is the price of the longest configured prefix matching number
+func (g *SMSGuard) cost(number string) int64 {
+	best, cost := 0, micros(g.config.DefaultCost)
+	for prefix, c := range g.costs {
+		if len(prefix) > best && strings.HasPrefix(number, prefix) {
+			best, cost = len(prefix), c
+		}
+	}
+	return cost
+}
+
+// nearCap reports whether spend has reached percent of limit
+func nearCap(spend, limit int64, percent int) bool {
+	return percent > 0 && spend*100 >= limit*int64(percent)
+}
+
+// micros converts a price to integer millionths so counters stay exact
+func micros(price float64) int64 {
+	return int64(math.Round(price * 1e6))
+}
+
+func splitPrefixes(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func hasAnyPrefix(number string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(number, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// twoDigitCodes are the ITU calling codes with two digits; +1 and +7 have one
+// digit and every other code has three
+var twoDigitCodes = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true, "34": true, "36": true,
+	"39": true, "40": true, "41": true, "43": true, "44": true, "45": true, "46": true, "47": true,
+	"48": true, "49": true, "51": true, "52": true, "53": true, "54": true, "55": true, "56": true,
+	"57": true, "58": true, "60": true, "61": true, "62": true, "63": true, "64": true, "65": true,
+	"66": true, "81": true, "82": true, "84": true, "86": true, "90": true, "91": true, "92": true,
+	"93": true, "94": true, "95": true, "98": true,
+}
+
+// callingCode returns the country calling code of an E.164 number, without the +.
+// Numbers too short to hold one are returned whole.
+func callingCode(number string) string {
+	digits := strings.TrimPrefix(number, "+")
+	switch {
+	case len(digits) == 0:
+		return ""
+	case digits[0] == '1' || digits[0] == '7':
+		return digits[:1]
+	case len(digits) >= 2 && twoDigitCodes[digits[:2]]:
+		return digits[:2]
+	case len(digits) >= 3:
+		return digits[:3]
+	default:
+		return digits
+	}
+}
+
+// powMaxAge is how long a proof-of-work stamp stays valid
+const powMaxAge = 10 * time.Minute
+
+// ProofOfWork is a ChallengeVerifier that needs no third party. The client picks
+// a nonce so that sha256("{identifier}:{unix seconds}:{nonce}") starts with
+// Difficulty zero bits, and sends "{unix seconds}:{nonce}" as the challenge.
+type ProofOfWork struct {
+	Difficulty int
+	now        func() time.Time
+}
+
+func NewProofOfWork(difficulty int) *ProofOfWork {
+	return &ProofOfWork{Difficulty: difficulty, now: time.Now}
+}
+
+func (p *ProofOfWork) Name() string { return "pow" }
+
+func (p *ProofOfWork) Describe() string {
+	return fmt.Sprintf("send challenge \"{unix_seconds}:{nonce}\" where sha256(\"{identifier}:{unix_seconds}:{nonce}\") has %d leading zero bits", p.Difficulty)
+}
+
+func (p *ProofOfWork) Verify(ctx context.Context, identifier, response string) (bool, error) {
+	stamp, nonce, ok := strings.Cut(response, ":")
+	if !ok || nonce == "" || len(nonce) > 64 {
+		return false, nil
+	}
+	secs, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil {
+		return false, nil
+	}
+	if age := p.now().Sub(time.Unix(secs, 0)); age > powMaxAge || age < -time.Minute {
+		return false, nil
+	}
+	sum := sha256.Sum256([]byte(identifier + ":" + response))
+	return leadingZeroBits(sum[:]) >= p.Difficulty, nil
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if c != 0 {
+			return n + bits.LeadingZeros8(c)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/codeZe-us/vestroll-backend/internal/config"
+	"github.com/codeZe-us/vestroll-backend/internal/models"
+	"github.com/codeZe-us/vestroll-backend/internal/repository/memory"
+	"github.com/codeZe-us/vestroll-backend/internal/services/notifier"
+	apperrors "github.com/codeZe-us/vestroll-backend/pkg/errors"
+)
+
+var testGuardConfig = config.SMSGuardConfig{
+	Enabled:          true,
+	BlockedPrefixes:  "+882,+979",
+	Costs:            "+234=0.02;+44=0.04",
+	DefaultCost:      0.10,
+	PrefixHourlyCap:  0.10,
+	GlobalHourlyCap:  0.25,
+	ChallengePercent: 50,
+	BurstWindow:      10 * time.Minute,
+	BurstChallenge:   3,
+	BurstBlock:       5,
+}
+
+func newTestGuard(t *testing.T, cfg config.SMSGuardConfig, challenger ChallengeVerifier) *SMSGuard {
+	t.Helper()
+	guard, err := NewSMSGuard(memory.NewSMSUsage(), challenger, cfg)
+	if err != nil {
+		t.Fatalf("NewSMSGuard error: %v", err)
+	}
+	clock := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return clock }
+	return guard
+}
+
+func solvePoW(identifier string, difficulty int, at time.Time) string {
+	for nonce := 0; ; nonce++ {
+		response := fmt.Sprintf("%d:%d", at.Unix(), nonce)
+		sum := sha256.Sum256([]byte(identifier + ":" + response))
+		if leadingZeroBits(sum[:]) >= difficulty {
+			return response
+		}
+	}
+}
+
+func TestCallingCode(t *testing.T) {
+	for number, want := range map[string]string{
+		"+14155550100":   "1",
+		"+79161234567":   "7",
+		"+447700900123":  "44",
+		"+2348012345678": "234",
+		"+88212345678":   "882",
+	} {
+		if got := callingCode(number); got != want {
+			t.Errorf("callingCode(%s) = %s; want %s", number, got, want)
+		}
+	}
+}
+
+func TestShortNumbersAreRejectedNotPanics(t *testing.T) {
+	guard := newTestGuard(t, testGuardConfig, nil)
+	svc, _ := setupOTPService(t)
+	outbox, _ := notifier.NewOutbox("")
+	svc.dispatcher = newTestDispatcher(t, notifier.Router{notifier.ChannelSMS: outbox})
+	svc.smsGuard = guard
+	ctx := context.Background()
+
+	for _, number := range []string{"+23", "+234", "+2348", "+23480", "+234801"} {
+		callingCode(number) // must not panic
+		if err := guard.Check(ctx, number, ""); apperrors.KindOf(err) != apperrors.KindValidation {
+			t.Errorf("Check(%s) = %v; want a validation error", number, err)
+		}
+		if _, _, err := svc.SendOTP(ctx, models.OTPRequest{Identifier: number, Type: models.OTPTypeSMS}); apperrors.KindOf(err) != apperrors.KindValidation {
+			t.Errorf("SendOTP(%s) = %v; want a validation error", number, err)
+		}
+	}
+	if len(outbox.Messages("")) != 0 {
+		t.Fatalf("no SMS should be sent to a short number")
+	}
+	// The shortest valid numbers (7 digits, e.g. Niue) pass
+	if err := guard.Check(ctx, "+6834002", ""); err != nil {
+		t.Fatalf("7-digit number refused: %v", err)
+	}
+}
+
+func TestSMSGuardCountryLists(t *testing.T) {
+	ctx := context.Background()
+	guard := newTestGuard(t, testGuardConfig, nil)
+	if err := guard.Check(ctx, "+88212345678", ""); !errors.Is(err, ErrSMSCountryBlocked) {
+		t.Fatalf("expected blocked prefix to be refused, got %v", err)
+	}
+
+	cfg := testGuardConfig
+	cfg.AllowedPrefixes = "+234,+233"
+	guard = newTestGuard(t, cfg, nil)
+	if err := guard.Check(ctx, "+2348012345678", ""); err != nil {
+		t.Fatalf("allowed country refused: %v", err)
+	}
+	if err := guard.Check(ctx, "+447700900123", ""); !errors.Is(err, ErrSMSCountryBlocked) {
+		t.Fatalf("expected country outside the allowlist to be refused, got %v", err)
+	}
+
+	cfg.Enabled = false
+	if err := newTestGuard(t, cfg, nil).Check(ctx, "+88212345678", ""); err != nil {
+		t.Fatalf("disabled guard refused: %v", err)
+	}
+}
+
+func TestSMSGuardSpendCaps(t *testing.T) {
+	ctx := context.Background()
+	guard := newTestGuard(t, testGuardConfig, nil)
+
+	// +234 costs 0.02 against a 0.10 cap per calling code
+	for i := 0; i < 5; i++ {
+		if err := guard.Check(ctx, fmt.Sprintf("+234801%07d", i*1000), ""); err != nil {
+			t.Fatalf("send %d refused: %v", i, err)
+		}
+	}
+	if err := guard.Check(ctx, "+2348090000000", ""); !errors.Is(err, ErrSMSCapacity) {
+		t.Fatalf("expected the +234 cap to be reached, got %v", err)
+	}
+	// Another country has its own cap but shares the 0.25 global one
+	if err := guard.Check(ctx, "+14155550100", ""); err != nil {
+		t.Fatalf("+1 refused: %v", err)
+	}
+	if err := guard.Check(ctx, "+33612345678", ""); !errors.Is(err, ErrSMSCapacity) {
+		t.Fatalf("expected the global cap to be reached, got %v", err)
+	}
+
+	// Caps reset with the hour
+	next := guard.now().Add(time.Hour)
+	guard.now = func() time.Time { return next }
+	if err := guard.Check(ctx, "+2348090000000", ""); err != nil {
+		t.Fatalf("send in the next hour refused: %v", err)
+	}
+}
+
+func TestSMSGuardSequentialBurst(t *testing.T) {
+	ctx := context.Background()
+	pow := NewProofOfWork(8)
+	guard := newTestGuard(t, testGuardConfig, pow)
+	guard.config.PrefixHourlyCap, guard.config.GlobalHourlyCap = 100, 100
+	pow.now = guard.now
+
+	for i := 0; i < 2; i++ {
+		if err := guard.Check(ctx, fmt.Sprintf("+23480123456%02d", i), ""); err != nil {
+			t.Fatalf("send %d refused: %v", i, err)
+		}
+	}
+	// The third number in the same block of 100 needs a challenge
+	number := "+2348012345602"
+	err := guard.Check(ctx, number, "")
+	if e, ok := apperrors.As(err); !ok || e.ResponseCode() != "challenge_required" {
+		t.Fatalf("expected challenge_required, got %v", err)
+	}
+	if err := guard.Check(ctx, number, "1893499200:bogus"); !errors.Is(err, ErrInvalidChallenge) {
+		t.Fatalf("expected invalid challenge, got %v", err)
+	}
+	if err := guard.Check(ctx, number, solvePoW(number, 8, guard.now())); err != nil {
+		t.Fatalf("solved challenge refused: %v", err)
+	}
+	// A stale stamp is rejected
+	if err := guard.Check(ctx, "+2348012345603", solvePoW("+2348012345603", 8, guard.now().Add(-time.Hour))); !errors.Is(err, ErrInvalidChallenge) {
+		t.Fatalf("expected stale challenge to be refused, got %v", err)
+	}
+	// Enough distinct numbers in the block are refused even with a challenge
+	if err := guard.Check(ctx, "+2348012345604", solvePoW("+2348012345604", 8, guard.now())); !errors.Is(err, ErrSMSBurst) {
+		t.Fatalf("expected burst to be refused, got %v", err)
+	}
+	// Numbers elsewhere are unaffected
+	if err := guard.Check(ctx, "+2348099999999", ""); err != nil {
+		t.Fatalf("unrelated number refused: %v", err)
+	}
+}
+
+func TestSendOTPAppliesSMSGuard(t *testing.T) {
+	svc, _ := setupOTPService(t)
+	outbox, _ := notifier.NewOutbox("")
+	svc.dispatcher = newTestDispatcher(t, notifier.Router{notifier.ChannelSMS: outbox})
+	svc.smsGuard = newTestGuard(t, testGuardConfig, nil)
+	ctx := context.Background()
+
+	_, _, err := svc.SendOTP(ctx, models.OTPRequest{Identifier: "+97912345678", Type: models.OTPTypeSMS})
+	if !errors.Is(err, ErrSMSCountryBlocked) {
+		t.Fatalf("expected blocked country, got %v", err)
+	}
+	if len(outbox.Messages("")) != 0 {
+		t.Fatalf("no SMS should be sent to a blocked country")
+	}
+	if _, _, err := svc.SendOTP(ctx, models.OTPRequest{Identifier: "+2348012345678", Type: models.OTPTypeSMS}); err != nil {
+		t.Fatalf("SendOTP error: %v", err)
+	}
+	// Email is not screened
+	if _, _, err := svc.SendOTP(ctx, models.OTPRequest{Identifier: "a@example.com", Type: models.OTPTypeEmail}); errors.Is(err, ErrSMSCountryBlocked) {
+		t.Fatalf("email was screened by the SMS guard")
+	}
+}
@@ -0,0 +1,130 @@
+// Package testdb gives tests a Postgres database. It uses TEST_DATABASE_URL when
+// set and otherwise starts an embedded Postgres on first use, so the Postgres
+// suites run under a plain go test ./... wherever the binaries can be fetched.
+// Offline, the tests are skipped unless TEST_DATABASE_REQUIRED is set.
+// Packages using it call Main from their TestMain so the server is stopped.
+package testdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	once     sync.Once
+	url      string
+	server   *embeddedpostgres.EmbeddedPostgres
+	runtime  string
+	startErr error
+)
+
+// Main runs the package's tests and then stops the embedded server, if one was started
+func Main(m *testing.M) {
+	code := m.Run()
+	if server != nil {
+		if err := server.Stop(); err != nil {
+			fmt.Fprintf(os.Stderr, "testdb: failed to stop embedded Postgres: %v\n", err)
+		}
+		os.RemoveAll(runtime)
+	}
+	os.Exit(code)
+}
+
+// URL returns the connection URL, starting the embedded server on first use.
+// EMBEDDED_POSTGRES_REPOSITORY points the binary download at a Maven mirror.
+// When the server cannot start the test is skipped, or fails if
+// TEST_DATABASE_REQUIRED is set so CI cannot pass without running it.
+func URL(t *testing.T) string {
+	t.Helper()
+	once.Do(start)
+	if startErr != nil {
+		msg := fmt.Sprintf("failed to start embedded Postgres (or set TEST_DATABASE_URL to an existing server): %v", startErr)
+		if os.Getenv("TEST_DATABASE_REQUIRED") != "" {
+			t.Fatal(msg)
+		}
+		t.Skip(msg)
+	}
+	return url
+}
+
+func start() {
+	if url = os.Getenv("TEST_DATABASE_URL"); url != "" {
+		return
+	}
+	port, err := freePort()
+	if err != nil {
+		startErr = err
+		return
+	}
+	if runtime, err = os.MkdirTemp("", "vestroll-postgres-"); err != nil {
+		startErr = err
+		return
+	}
+	cfg := embeddedpostgres.DefaultConfig().
+		Version(embeddedpostgres.V16).
+		Port(port).
+		RuntimePath(runtime).
+		StartTimeout(time.Minute).
+		Logger(io.Discard)
+	if repo := os.Getenv("EMBEDDED_POSTGRES_REPOSITORY"); repo != "" {
+		cfg = cfg.BinaryRepositoryURL(repo)
+	}
+	db := embeddedpostgres.NewDatabase(cfg)
+	if startErr = db.Start(); startErr != nil {
+		os.RemoveAll(runtime)
+		return
+	}
+	server = db
+	url = cfg.GetConnectionURL() + "?sslmode=disable"
+}
+
+// freePort asks the kernel for an unused TCP port
+func freePort() (uint32, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return uint32(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// NewPool connects with search_path set to a fresh schema named after prefix,
+// so tests sharing the server do not see each other's tables. The schema is
+// dropped on cleanup.
+func NewPool(t *testing.T, prefix string) *pgxpool.Pool {
+	t.Helper()
+	dbURL := URL(t)
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(admin.Close)
+	schemaName := fmt.Sprintf("%s_%d", prefix, time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schemaName); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec(context.Background(), "DROP SCHEMA "+schemaName+" CASCADE") })
+
+	cfg, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		t.Fatalf("failed to parse url: %v", err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schemaName
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
@@ -1,8 +1,15 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 )
 
 // ValidatePasswordStrength checks if the password meets strength requirements
@@ -30,3 +37,70 @@ func ValidatePasswordStrength(password string) error {
 	}
 	return nil
 }
+
+// Argon2Params are the Argon2id cost settings; Memory is in KiB
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+}
+
+// PasswordParams are the Argon2id parameters used for password hashing
+var PasswordParams = Argon2Params{Time: 3, Memory: 64 * 1024, Threads: 2, KeyLen: 32}
+
+const argonSaltLen = 16
+
+// HashPassword derives an Argon2id hash encoded in the PHC string format
+// ($argon2id$v=19$m=...,t=...,p=...$salt$hash) so parameters can change later
+func HashPassword(password string) (string, error) {
+	return HashArgon2id(password, PasswordParams)
+}
+
+// VerifyPassword checks a password against a hash produced by HashPassword
+func VerifyPassword(password, encoded string) (bool, error) {
+	ok, _, err := VerifyArgon2id(password, encoded)
+	return ok, err
+}
+
+// HashArgon2id hashes secret with a random salt and encodes it in the PHC string format
+func HashArgon2id(secret string, p Argon2Params) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(secret), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyArgon2id checks secret against a PHC-encoded Argon2id hash and returns
+// the parameters it was hashed with, so callers can tell when to rehash
+func VerifyArgon2id(secret, encoded string) (bool, Argon2Params, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, Argon2Params{}, errors.New("unsupported password hash format")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, Argon2Params{}, errors.New("unsupported argon2 version")
+	}
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return false, Argon2Params{}, errors.New("invalid argon2 parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, Argon2Params{}, errors.New("invalid argon2 salt")
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, Argon2Params{}, errors.New("invalid argon2 hash")
+	}
+	p.KeyLen = uint32(len(want))
+	got := argon2.IDKey([]byte(secret), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return subtle.ConstantTimeCompare(got, want) == 1, p, nil
+}
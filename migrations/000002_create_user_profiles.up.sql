@@ -0,0 +1,11 @@
+-- Profiles are keyed by user ID without a foreign key: compatibility mode lets
+-- legacy clients create profiles for IDs that have no account yet.
+CREATE TABLE user_profiles (
+    user_id            TEXT PRIMARY KEY,
+    account_type       TEXT NOT NULL DEFAULT '',
+    personal           JSONB,
+    address            JSONB,
+    completed          BOOLEAN NOT NULL DEFAULT FALSE,
+    completion_percent INTEGER NOT NULL DEFAULT 0,
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
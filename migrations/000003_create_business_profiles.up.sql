@@ -0,0 +1,12 @@
+CREATE TABLE business_profiles (
+    user_id             TEXT PRIMARY KEY,
+    account_type        TEXT NOT NULL,
+    business_name       TEXT NOT NULL,
+    registration_number TEXT NOT NULL,
+    tax_id              TEXT NOT NULL,
+    address             JSONB NOT NULL,
+    contact             JSONB NOT NULL,
+    completed           BOOLEAN NOT NULL DEFAULT FALSE,
+    completion_percent  INTEGER NOT NULL DEFAULT 0,
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
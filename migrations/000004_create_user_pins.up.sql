@@ -0,0 +1,6 @@
+CREATE TABLE user_pins (
+    user_id    TEXT PRIMARY KEY,
+    salt       TEXT NOT NULL,
+    hash       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
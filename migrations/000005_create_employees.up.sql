@@ -0,0 +1,21 @@
+-- Employees belong to the business account (employer) that onboarded them
+CREATE TABLE employees (
+    id              TEXT PRIMARY KEY,
+    employer_id     TEXT NOT NULL,
+    user_id         TEXT REFERENCES users (id) ON DELETE SET NULL,
+    email           TEXT NOT NULL,
+    first_name      TEXT NOT NULL,
+    last_name       TEXT NOT NULL,
+    job_title       TEXT NOT NULL DEFAULT '',
+    employment_type TEXT NOT NULL DEFAULT 'full_time'
+        CHECK (employment_type IN ('full_time', 'part_time', 'contractor')),
+    salary_amount   NUMERIC(18, 2) NOT NULL DEFAULT 0 CHECK (salary_amount >= 0),
+    salary_currency CHAR(3) NOT NULL DEFAULT 'USD',
+    status          TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('invited', 'active', 'terminated')),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (employer_id, email)
+);
+
+CREATE INDEX employees_employer_id_idx ON employees (employer_id);
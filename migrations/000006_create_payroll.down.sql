@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS payroll_items;
+DROP TABLE IF EXISTS payroll_runs;
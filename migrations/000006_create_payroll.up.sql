@@ -0,0 +1,28 @@
+-- A payroll run pays a set of employees of one employer for a period
+CREATE TABLE payroll_runs (
+    id           TEXT PRIMARY KEY,
+    employer_id  TEXT NOT NULL,
+    period_start DATE NOT NULL,
+    period_end   DATE NOT NULL,
+    currency     CHAR(3) NOT NULL,
+    total_amount NUMERIC(18, 2) NOT NULL DEFAULT 0,
+    status       TEXT NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'approved', 'processing', 'completed', 'failed')),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    processed_at TIMESTAMPTZ,
+    CHECK (period_end >= period_start)
+);
+
+CREATE INDEX payroll_runs_employer_id_idx ON payroll_runs (employer_id, period_start);
+
+CREATE TABLE payroll_items (
+    id          TEXT PRIMARY KEY,
+    run_id      TEXT NOT NULL REFERENCES payroll_runs (id) ON DELETE CASCADE,
+    employee_id TEXT NOT NULL REFERENCES employees (id),
+    amount      NUMERIC(18, 2) NOT NULL CHECK (amount >= 0),
+    currency    CHAR(3) NOT NULL,
+    status      TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'paid', 'failed')),
+    paid_at     TIMESTAMPTZ,
+    UNIQUE (run_id, employee_id)
+);
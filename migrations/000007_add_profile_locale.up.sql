@@ -0,0 +1,2 @@
+-- Preferred language for notifications; empty means use the request's language
+ALTER TABLE user_profiles ADD COLUMN locale TEXT NOT NULL DEFAULT '';
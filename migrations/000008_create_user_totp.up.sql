@@ -0,0 +1,9 @@
+CREATE TABLE user_totp (
+    user_id        TEXT PRIMARY KEY,
+    secret         TEXT NOT NULL,
+    confirmed      BOOLEAN NOT NULL DEFAULT false,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    recovery_codes TEXT[] NOT NULL DEFAULT '{}',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    confirmed_at   TIMESTAMPTZ
+);
@@ -0,0 +1 @@
+ALTER TABLE user_pins DROP COLUMN version;
@@ -0,0 +1,2 @@
+-- 0 is the legacy sha256(pin:salt) format, 1 is peppered Argon2id (salt kept inside hash)
+ALTER TABLE user_pins ADD COLUMN version SMALLINT NOT NULL DEFAULT 0;
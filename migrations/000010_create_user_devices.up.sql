@@ -0,0 +1,10 @@
+CREATE TABLE user_devices (
+    user_id      TEXT NOT NULL,
+    device_id    TEXT NOT NULL,
+    platform     TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    public_key   TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, device_id)
+);
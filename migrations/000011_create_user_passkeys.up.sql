@@ -0,0 +1,14 @@
+CREATE TABLE user_passkeys (
+    credential_id TEXT PRIMARY KEY,
+    user_id       TEXT NOT NULL,
+    name          TEXT NOT NULL,
+    public_key    TEXT NOT NULL,
+    algorithm     INTEGER NOT NULL,
+    sign_count    BIGINT NOT NULL DEFAULT 0,
+    transports    TEXT[] NOT NULL DEFAULT '{}',
+    aaguid        TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_user_passkeys_user_id ON user_passkeys (user_id);
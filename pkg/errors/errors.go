@@ -0,0 +1,155 @@
+// Package errors defines typed application errors that carry a kind and a
+// machine-readable code, so HTTP layers can map them without inspecting messages.
+package errors
+
+import (
+	stderrors "errors"
+	"net/http"
+)
+
+// Kind classifies an error independently of its message
+type Kind string
+
+const (
+	KindValidation   Kind = "validation"
+	KindNotFound     Kind = "not_found"
+	KindRateLimited  Kind = "rate_limited"
+	KindUnavailable  Kind = "unavailable"
+	KindUnauthorized Kind = "unauthorized"
+	KindForbidden    Kind = "forbidden"
+	KindConflict     Kind = "conflict"
+	KindInternal     Kind = "internal"
+)
+
+// Kind sentinels match any error of that kind with errors.Is
+var (
+	ErrValidation   = &Error{Kind: KindValidation}
+	ErrNotFound     = &Error{Kind: KindNotFound}
+	ErrRateLimited  = &Error{Kind: KindRateLimited}
+	ErrUnavailable  = &Error{Kind: KindUnavailable}
+	ErrUnauthorized = &Error{Kind: KindUnauthorized}
+	ErrForbidden    = &Error{Kind: KindForbidden}
+	ErrConflict     = &Error{Kind: KindConflict}
+)
+
+// Error is an application error with a kind, an optional response code and an optional cause
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+}
+
+// New creates an error of the given kind
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap attaches a kind and message to an underlying error
+func Wrap(kind Kind, err error, message string) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func Validation(message string) *Error   { return New(KindValidation, message) }
+func NotFound(message string) *Error     { return New(KindNotFound, message) }
+func RateLimited(message string) *Error  { return New(KindRateLimited, message) }
+func Unavailable(message string) *Error  { return New(KindUnavailable, message) }
+func Unauthorized(message string) *Error { return New(KindUnauthorized, message) }
+func Forbidden(message string) *Error    { return New(KindForbidden, message) }
+func Conflict(message string) *Error     { return New(KindConflict, message) }
+
+// WithCode returns a copy of the error that renders with the given response code
+func (e *Error) WithCode(code string) *Error {
+	cp := *e
+	cp.Code = code
+	return &cp
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return e.Err.Error()
+	default:
+		return e.Message + ": " + e.Err.Error()
+	}
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is matches targets of the same kind; a target's code and message, when set, must match too
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.Kind == e.Kind &&
+		(t.Code == "" || t.Code == e.Code) &&
+		(t.Message == "" || t.Message == e.Message)
+}
+
+// ResponseCode is the code rendered for the error, defaulting per kind
+func (e *Error) ResponseCode() string {
+	if e.Code != "" {
+		return e.Code
+	}
+	return defaultCodes[e.Kind]
+}
+
+var defaultCodes = map[Kind]string{
+	KindValidation:   "validation_error",
+	KindNotFound:     "not_found",
+	KindRateLimited:  "rate_limit_exceeded",
+	KindUnavailable:  "service_unavailable",
+	KindUnauthorized: "unauthorized",
+	KindForbidden:    "forbidden",
+	KindConflict:     "conflict",
+	KindInternal:     "internal_error",
+}
+
+var statuses = map[Kind]int{
+	KindValidation:   http.StatusBadRequest,
+	KindNotFound:     http.StatusNotFound,
+	KindRateLimited:  http.StatusTooManyRequests,
+	KindUnavailable:  http.StatusServiceUnavailable,
+	KindUnauthorized: http.StatusUnauthorized,
+	KindForbidden:    http.StatusForbidden,
+	KindConflict:     http.StatusConflict,
+	KindInternal:     http.StatusInternalServerError,
+}
+
+// As finds the first *Error in err's chain
+func As(err error) (*Error, bool) {
+	var e *Error
+	if stderrors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// KindOf returns the kind of the first *Error in err's chain, or KindInternal
+func KindOf(err error) Kind {
+	if e, ok := As(err); ok {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// HTTPStatus maps an error to its HTTP status code
+func HTTPStatus(err error) int {
+	if status, ok := statuses[KindOf(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// ResponseCode maps an error to the code used in error response bodies
+func ResponseCode(err error) string {
+	if e, ok := As(err); ok {
+		if code := e.ResponseCode(); code != "" {
+			return code
+		}
+	}
+	return defaultCodes[KindInternal]
+}
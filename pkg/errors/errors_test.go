@@ -0,0 +1,68 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestIsMatchesKindAndSentinels(t *testing.T) {
+	errInvalidCode := Validation("invalid code").WithCode("invalid_code")
+	wrapped := fmt.Errorf("verify: %w", errInvalidCode)
+
+	if !stderrors.Is(wrapped, ErrValidation) {
+		t.Fatalf("expected wrapped error to match its kind")
+	}
+	if !stderrors.Is(wrapped, errInvalidCode) {
+		t.Fatalf("expected wrapped error to match its sentinel")
+	}
+	if stderrors.Is(wrapped, Validation("other").WithCode("invalid_code")) {
+		t.Fatalf("expected sentinels with different messages not to match")
+	}
+	if stderrors.Is(wrapped, ErrNotFound) {
+		t.Fatalf("expected different kinds not to match")
+	}
+}
+
+func TestWrapKeepsCause(t *testing.T) {
+	cause := stderrors.New("dial tcp: timeout")
+	err := Wrap(KindUnavailable, cause, "failed to send SMS")
+
+	if err.Error() != "failed to send SMS: dial tcp: timeout" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+	if !stderrors.Is(err, cause) {
+		t.Fatalf("expected cause to be reachable through Unwrap")
+	}
+	var target *Error
+	if !stderrors.As(fmt.Errorf("outer: %w", err), &target) || target.Kind != KindUnavailable {
+		t.Fatalf("expected errors.As to find the typed error")
+	}
+}
+
+func TestHTTPMapping(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{Validation("bad"), http.StatusBadRequest, "validation_error"},
+		{NotFound("missing"), http.StatusNotFound, "not_found"},
+		{RateLimited("slow down"), http.StatusTooManyRequests, "rate_limit_exceeded"},
+		{Unavailable("down"), http.StatusServiceUnavailable, "service_unavailable"},
+		{Unauthorized("who"), http.StatusUnauthorized, "unauthorized"},
+		{Forbidden("no"), http.StatusForbidden, "forbidden"},
+		{Conflict("taken").WithCode("account_exists"), http.StatusConflict, "account_exists"},
+		{fmt.Errorf("wrapped: %w", RateLimited("x").WithCode("max_attempts_exceeded")), http.StatusTooManyRequests, "max_attempts_exceeded"},
+		{stderrors.New("boom"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tc := range cases {
+		if got := HTTPStatus(tc.err); got != tc.status {
+			t.Errorf("HTTPStatus(%v) = %d, want %d", tc.err, got, tc.status)
+		}
+		if got := ResponseCode(tc.err); got != tc.code {
+			t.Errorf("ResponseCode(%v) = %q, want %q", tc.err, got, tc.code)
+		}
+	}
+}